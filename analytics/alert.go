@@ -0,0 +1,114 @@
+// The MIT License (MIT)
+//
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package analytics
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/pkg/errors"
+)
+
+// Notifier emits the alerts of anomalies.
+type Notifier interface {
+	Notify([]Anomaly) error
+}
+
+// LogNotifier logs every anomaly as a warning.
+type LogNotifier struct{}
+
+func (LogNotifier) Notify(anomalies []Anomaly) error {
+	for _, a := range anomalies {
+		log.WithFields(log.Fields{
+			"gateway":    a.GatewayID,
+			"metric":     a.Metric,
+			"start":      a.Start,
+			"end":        a.End,
+			"cells":      a.Cells,
+			"receptions": a.Receptions,
+			"baseline":   a.Baseline,
+			"drop":       a.Drop,
+			"z":          a.Z,
+		}).Warn("[Anomaly] gateway degraded")
+	}
+
+	return nil
+}
+
+type webhookPayload struct {
+	Anomalies []Anomaly `json:"anomalies"`
+	SentAt    time.Time `json:"sent_at"`
+}
+
+// WebhookNotifier posts the anomalies as JSON to the URL:
+// {"anomalies": [...], "sent_at": "..."}.
+type WebhookNotifier struct {
+	URL    string
+	Client *http.Client
+}
+
+func NewWebhookNotifier(url string) *WebhookNotifier {
+	return &WebhookNotifier{
+		URL:    url,
+		Client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (n *WebhookNotifier) Notify(anomalies []Anomaly) error {
+	if len(anomalies) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(webhookPayload{Anomalies: anomalies, SentAt: time.Now().UTC()})
+	if err != nil {
+		return errors.Wrap(err, "encoding anomalies")
+	}
+
+	res, err := n.Client.Post(n.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "posting anomalies")
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return errors.Errorf("posting anomalies: %s", res.Status)
+	}
+
+	return nil
+}
+
+// Notify sends the anomalies to every notifier and returns the first error.
+func Notify(anomalies []Anomaly, notifiers ...Notifier) error {
+	var first error
+
+	for _, n := range notifiers {
+		if err := n.Notify(anomalies); err != nil && first == nil {
+			first = err
+		}
+	}
+
+	return first
+}
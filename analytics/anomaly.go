@@ -0,0 +1,222 @@
+// The MIT License (MIT)
+//
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package analytics
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/bullettime/lora-mapper/model"
+)
+
+const (
+	MetricRSSI = "rssi"
+	MetricSNR  = "snr"
+
+	// minSigma is the lowest spread in dB of the baseline of a cell, so that
+	// a few identical receptions don't make every change significant.
+	minSigma = 1.0
+)
+
+// AnomalyOptions of the anomaly detection. The receptions of a gateway in
+// every Window are compared with the receptions of the same gateway in the
+// same cell during the Baseline before the window. Cells need MinBaseline
+// receptions in the baseline, and a drop is only reported when MinCells
+// cells were compared, the mean drop is at least MinDrop dB and the z-score
+// of the drop is below -Z.
+type AnomalyOptions struct {
+	Window      time.Duration
+	Baseline    time.Duration
+	MinBaseline int
+	MinCells    int
+	MinDrop     float64
+	Z           float64
+}
+
+func DefaultAnomalyOptions() AnomalyOptions {
+	return AnomalyOptions{
+		Window:      24 * time.Hour,
+		Baseline:    14 * 24 * time.Hour,
+		MinBaseline: 5,
+		MinCells:    2,
+		MinDrop:     3,
+		Z:           3,
+	}
+}
+
+// Anomaly is a significant drop of the rssi or snr of a gateway during a
+// window. Baseline is the expected mean level of the receptions of the
+// window, Drop the mean difference with the baseline of their cell (negative)
+// and Z the drop divided by its standard error.
+type Anomaly struct {
+	GatewayID  string    `json:"gateway_id"`
+	Metric     string    `json:"metric"`
+	Start      time.Time `json:"start"`
+	End        time.Time `json:"end"`
+	Cells      int       `json:"cells"`
+	Receptions int       `json:"receptions"`
+	Baseline   float64   `json:"baseline"`
+	Drop       float64   `json:"drop"`
+	Z          float64   `json:"z"`
+}
+
+// cellSeries holds the receptions of a gateway in a cell sorted by time.
+type cellSeries struct {
+	times  []time.Time
+	values map[string][]float64
+}
+
+func (s *cellSeries) index(t time.Time) int {
+	return sort.Search(len(s.times), func(i int) bool {
+		return !s.times[i].Before(t)
+	})
+}
+
+// DetectAnomalies compares every window from the from time until the to time
+// with the rolling baseline before it, per gateway, for the rssi and snr. The
+// receptions need to include the baseline of the first window. A zero from
+// is the first reception plus the baseline, a zero to the last reception.
+// The anomalies are sorted by start, gateway and metric.
+func DetectAnomalies(grid model.Grid, receptions []model.Reception, from, to time.Time, options AnomalyOptions) []Anomaly {
+	anomalies := []Anomaly{}
+
+	if len(receptions) == 0 || options.Window <= 0 {
+		return anomalies
+	}
+
+	sorted := make([]model.Reception, len(receptions))
+	copy(sorted, receptions)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Time.Before(sorted[j].Time)
+	})
+
+	gateways := make(map[string]map[model.CellID]*cellSeries)
+
+	for _, r := range sorted {
+		cells, ok := gateways[r.GatewayID]
+		if !ok {
+			cells = make(map[model.CellID]*cellSeries)
+			gateways[r.GatewayID] = cells
+		}
+
+		id := grid.Cell(r.Location)
+
+		s, ok := cells[id]
+		if !ok {
+			s = &cellSeries{values: make(map[string][]float64)}
+			cells[id] = s
+		}
+
+		s.times = append(s.times, r.Time)
+		s.values[MetricRSSI] = append(s.values[MetricRSSI], r.RSSI)
+		s.values[MetricSNR] = append(s.values[MetricSNR], r.SNR)
+	}
+
+	if from.IsZero() {
+		from = sorted[0].Time.Add(options.Baseline)
+	}
+
+	if to.IsZero() {
+		to = sorted[len(sorted)-1].Time.Add(time.Nanosecond)
+	}
+
+	var ids []string
+	for id := range gateways {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for start := from; start.Before(to); start = start.Add(options.Window) {
+		end := start.Add(options.Window)
+
+		for _, id := range ids {
+			for _, metric := range []string{MetricRSSI, MetricSNR} {
+				if a, ok := detectWindow(gateways[id], metric, start, end, options); ok {
+					a.GatewayID = id
+					anomalies = append(anomalies, a)
+				}
+			}
+		}
+	}
+
+	return anomalies
+}
+
+// detectWindow compares the window of the metric of the cells of a gateway
+// with their baselines.
+func detectWindow(cells map[model.CellID]*cellSeries, metric string, start, end time.Time, options AnomalyOptions) (Anomaly, bool) {
+	a := Anomaly{Metric: metric, Start: start, End: end}
+
+	var sumDelta, sumBaseline, variance float64
+
+	for _, s := range cells {
+		b0, b1, c1 := s.index(start.Add(-options.Baseline)), s.index(start), s.index(end)
+
+		if b1-b0 < options.MinBaseline || c1 == b1 {
+			continue
+		}
+
+		values := s.values[metric]
+		median, sigma := robustStats(values[b0:b1])
+
+		for _, v := range values[b1:c1] {
+			sumDelta += v - median
+			sumBaseline += median
+			variance += sigma * sigma
+		}
+
+		a.Cells++
+		a.Receptions += c1 - b1
+	}
+
+	if a.Cells < options.MinCells || a.Receptions == 0 {
+		return a, false
+	}
+
+	n := float64(a.Receptions)
+	drop := sumDelta / n
+	z := drop / (math.Sqrt(variance) / n)
+
+	a.Baseline = round(sumBaseline/n, 2)
+	a.Drop = round(drop, 2)
+	a.Z = round(z, 2)
+
+	return a, drop <= -options.MinDrop && z <= -options.Z
+}
+
+// robustStats returns the median and the spread estimated from the median
+// absolute deviation, at least minSigma.
+func robustStats(values []float64) (float64, float64) {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	median := model.Percentile(sorted, 0.5)
+
+	deviations := make([]float64, len(values))
+	for i, v := range values {
+		deviations[i] = math.Abs(v - median)
+	}
+	sort.Float64s(deviations)
+
+	return median, math.Max(1.4826*model.Percentile(deviations, 0.5), minSigma)
+}
@@ -0,0 +1,113 @@
+// The MIT License (MIT)
+//
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package analytics
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bullettime/lora-mapper/model"
+)
+
+func TestDetectAnomalies(t *testing.T) {
+	grid, err := model.NewGrid(model.ShapeSquare, 100, model.LatLon{Latitude: 51, Longitude: 4.7})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2018, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	var receptions []model.Reception
+	for day := 0; day < 20; day++ {
+		for cell := 0; cell < 3; cell++ {
+			for i := 0; i < 3; i++ {
+				noise := float64((day+cell+i)%3) - 1
+
+				rssi := -100 - 5*float64(cell) + noise
+				if day >= 18 {
+					// water ingress on g1
+					rssi -= 8
+				}
+
+				ll := grid.Center(model.CellID{X: cell, Y: 0})
+				at := start.Add(time.Duration(day)*24*time.Hour + time.Duration(i+cell*3)*time.Hour)
+
+				receptions = append(receptions,
+					model.Reception{Location: ll, GatewayID: "g1", RSSI: rssi, SNR: 5 + noise, Time: at},
+					model.Reception{Location: ll, GatewayID: "g2", RSSI: -110 + noise, SNR: noise, Time: at},
+				)
+			}
+		}
+	}
+
+	anomalies := DetectAnomalies(grid, receptions, time.Time{}, time.Time{}, DefaultAnomalyOptions())
+
+	if len(anomalies) != 2 {
+		t.Fatalf("expected 2 anomalies, got %+v", anomalies)
+	}
+
+	for i, a := range anomalies {
+		if a.GatewayID != "g1" || a.Metric != MetricRSSI || a.Cells != 3 || a.Receptions != 9 || a.Drop > -7 || a.Z > -3 {
+			t.Errorf("unexpected anomaly %+v", a)
+		}
+
+		if day := start.Add(time.Duration(18+i) * 24 * time.Hour); !a.Start.Equal(day) {
+			t.Errorf("expected the anomaly at %v, got %v", day, a.Start)
+		}
+	}
+
+	// the drop is too small for a higher threshold
+	options := DefaultAnomalyOptions()
+	options.MinDrop = 10
+	if anomalies := DetectAnomalies(grid, receptions, time.Time{}, time.Time{}, options); len(anomalies) != 0 {
+		t.Errorf("expected no anomalies, got %+v", anomalies)
+	}
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	var received webhookPayload
+
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if err := json.NewDecoder(req.Body).Decode(&received); err != nil {
+			t.Error(err)
+		}
+	}))
+	defer server.Close()
+
+	anomalies := []Anomaly{{GatewayID: "g1", Metric: MetricRSSI, Drop: -8}}
+
+	if err := Notify(anomalies, LogNotifier{}, NewWebhookNotifier(server.URL)); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(received.Anomalies) != 1 || received.Anomalies[0].GatewayID != "g1" {
+		t.Errorf("unexpected payload %+v", received)
+	}
+
+	if err := NewWebhookNotifier(server.URL + "/missing\x00").Notify(anomalies); err == nil {
+		t.Error("expected an error for an invalid url")
+	}
+}
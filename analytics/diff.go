@@ -0,0 +1,388 @@
+// The MIT License (MIT)
+//
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package analytics
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bullettime/lora-mapper/model"
+	"github.com/paulmach/go.geojson"
+	"github.com/pkg/errors"
+)
+
+const (
+	DiffNew       = "new"
+	DiffLost      = "lost"
+	DiffImproved  = "improved"
+	DiffRegressed = "regressed"
+	DiffUnchanged = "unchanged"
+
+	// DefaultDiffThreshold is the change of the median rssi in dB that
+	// improves or regresses a cell at the same spreading factor.
+	DefaultDiffThreshold = 3.0
+)
+
+// DiffColors are the fill colors of the diff layer per status.
+var DiffColors = map[string]string{
+	DiffNew:       "#2c7bb6",
+	DiffLost:      "#d7191c",
+	DiffImproved:  "#1a9641",
+	DiffRegressed: "#fdae61",
+	DiffUnchanged: "#bababa",
+}
+
+var ErrDiffWindow = errors.New("a window needs a time range or campaigns")
+
+// DiffWindow selects the receptions of one side of the diff, by time range
+// or by campaigns.
+type DiffWindow struct {
+	Start     time.Time
+	End       time.Time
+	Campaigns []string
+}
+
+func (w DiffWindow) Validate() error {
+	if w.Start.IsZero() && w.End.IsZero() && len(w.Campaigns) == 0 {
+		return ErrDiffWindow
+	}
+
+	return nil
+}
+
+// Filter returns the base filter with the time range and campaigns of the
+// window that are set.
+func (w DiffWindow) Filter(base model.Filter) model.Filter {
+	filter := base
+
+	if !w.Start.IsZero() {
+		filter.Start = w.Start
+	}
+
+	if !w.End.IsZero() {
+		filter.End = w.End
+	}
+
+	if len(w.Campaigns) > 0 {
+		filter.Campaigns = w.Campaigns
+	}
+
+	return filter
+}
+
+func (w DiffWindow) String() string {
+	var parts []string
+
+	if len(w.Campaigns) > 0 {
+		parts = append(parts, "campaign "+strings.Join(w.Campaigns, ", "))
+	}
+
+	if !w.Start.IsZero() || !w.End.IsZero() {
+		format := func(t time.Time) string {
+			if t.IsZero() {
+				return "..."
+			}
+			return t.Format(time.RFC3339)
+		}
+		parts = append(parts, format(w.Start)+" - "+format(w.End))
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// DiffOptions of the comparison: cells need MinCount receptions on a side
+// to count as covered, Threshold is the rssi change in dB of an improvement
+// or regression at the same spreading factor.
+type DiffOptions struct {
+	MinCount  int
+	Threshold float64
+}
+
+func DefaultDiffOptions() DiffOptions {
+	return DiffOptions{MinCount: 1, Threshold: DefaultDiffThreshold}
+}
+
+// CellDiff compares the statistics of a cell in the two windows, the rssi
+// is the median and the deltas are after minus before (a negative delta of
+// the spreading factor is an improvement).
+type CellDiff struct {
+	Cell           model.CellID `json:"cell"`
+	Center         model.LatLon `json:"center"`
+	Status         string       `json:"status"`
+	CountBefore    int          `json:"count_before"`
+	CountAfter     int          `json:"count_after"`
+	RSSIBefore     float64      `json:"rssi_before,omitempty"`
+	RSSIAfter      float64      `json:"rssi_after,omitempty"`
+	DeltaRSSI      float64      `json:"delta_rssi"`
+	SFBefore       int          `json:"sf_before,omitempty"`
+	SFAfter        int          `json:"sf_after,omitempty"`
+	DeltaSF        int          `json:"delta_sf"`
+	GatewaysBefore int          `json:"gateways_before"`
+	GatewaysAfter  int          `json:"gateways_after"`
+}
+
+// DiffSummary counts the cells per status, the statistics of the rssi change
+// of the cells covered in both windows and the spreading factor changes (eg.
+// "SF9BW125 > SF7BW125").
+type DiffSummary struct {
+	Before     string         `json:"before"`
+	After      string         `json:"after"`
+	Receptions [2]int         `json:"receptions"`
+	Cells      int            `json:"cells"`
+	Compared   int            `json:"compared"`
+	New        int            `json:"new"`
+	Lost       int            `json:"lost"`
+	Improved   int            `json:"improved"`
+	Regressed  int            `json:"regressed"`
+	Unchanged  int            `json:"unchanged"`
+	DeltaRSSI  model.Stats    `json:"delta_rssi"`
+	SFChanges  map[string]int `json:"sf_changes"`
+}
+
+// Diff is the comparison of the cells of a grid, sorted by id.
+type Diff struct {
+	Grid    model.Grid  `json:"-"`
+	Summary DiffSummary `json:"summary"`
+	Cells   []CellDiff  `json:"cells"`
+}
+
+// CompareCells compares the receptions of the two windows on the cells of a
+// grid created with the grid options, by default with the origin of all
+// receptions so that both windows share the cells.
+func CompareCells(gridOptions model.GridOptions, before, after []model.Reception, options DiffOptions) (*Diff, error) {
+	all := append(model.ReceptionLocations(before), model.ReceptionLocations(after)...)
+
+	grid, err := gridOptions.NewGrid(all)
+	if err != nil {
+		return nil, err
+	}
+
+	cellsBefore := make(map[model.CellID]model.CellStats)
+	for _, c := range model.Aggregate(grid, before) {
+		if c.Count >= options.MinCount {
+			cellsBefore[c.Cell] = c
+		}
+	}
+
+	cellsAfter := make(map[model.CellID]model.CellStats)
+	for _, c := range model.Aggregate(grid, after) {
+		if c.Count >= options.MinCount {
+			cellsAfter[c.Cell] = c
+		}
+	}
+
+	diff := &Diff{
+		Grid: grid,
+		Summary: DiffSummary{
+			Receptions: [2]int{len(before), len(after)},
+			SFChanges:  make(map[string]int),
+		},
+		Cells: []CellDiff{},
+	}
+
+	ids := make(map[model.CellID]bool)
+	for id := range cellsBefore {
+		ids[id] = true
+	}
+	for id := range cellsAfter {
+		ids[id] = true
+	}
+
+	var deltas []float64
+
+	for id := range ids {
+		b, okBefore := cellsBefore[id]
+		a, okAfter := cellsAfter[id]
+
+		c := CellDiff{
+			Cell:           id,
+			Center:         grid.Center(id),
+			Status:         DiffUnchanged,
+			CountBefore:    b.Count,
+			CountAfter:     a.Count,
+			GatewaysBefore: b.Gateways,
+			GatewaysAfter:  a.Gateways,
+		}
+
+		if okBefore {
+			c.RSSIBefore = round(b.RSSI.Median, 2)
+			c.SFBefore = b.BestSF
+		}
+
+		if okAfter {
+			c.RSSIAfter = round(a.RSSI.Median, 2)
+			c.SFAfter = a.BestSF
+		}
+
+		switch {
+		case !okBefore:
+			c.Status = DiffNew
+			diff.Summary.New++
+		case !okAfter:
+			c.Status = DiffLost
+			diff.Summary.Lost++
+		default:
+			c.DeltaRSSI = round(a.RSSI.Median-b.RSSI.Median, 2)
+			c.DeltaSF = a.BestSF - b.BestSF
+			deltas = append(deltas, c.DeltaRSSI)
+			diff.Summary.Compared++
+
+			if c.DeltaSF != 0 {
+				diff.Summary.SFChanges[model.DataRate(b.BestSF)+" > "+model.DataRate(a.BestSF)]++
+			}
+
+			switch {
+			case c.DeltaSF < 0 || (c.DeltaSF == 0 && c.DeltaRSSI >= options.Threshold):
+				c.Status = DiffImproved
+				diff.Summary.Improved++
+			case c.DeltaSF > 0 || (c.DeltaSF == 0 && c.DeltaRSSI <= -options.Threshold):
+				c.Status = DiffRegressed
+				diff.Summary.Regressed++
+			default:
+				diff.Summary.Unchanged++
+			}
+		}
+
+		diff.Cells = append(diff.Cells, c)
+	}
+
+	diff.Summary.Cells = len(diff.Cells)
+
+	if len(deltas) > 0 {
+		s := model.NewStats(deltas)
+		diff.Summary.DeltaRSSI = model.Stats{
+			Mean:   round(s.Mean, 2),
+			Median: round(s.Median, 2),
+			P10:    round(s.P10, 2),
+			P90:    round(s.P90, 2),
+		}
+	}
+
+	sort.Slice(diff.Cells, func(i, j int) bool {
+		return diff.Cells[i].Cell.Less(diff.Cells[j].Cell)
+	})
+
+	return diff, nil
+}
+
+// Report describes the diff as a summary table.
+func (d *Diff) Report() string {
+	var b bytes.Buffer
+
+	s := d.Summary
+
+	if s.Before != "" || s.After != "" {
+		fmt.Fprintf(&b, "before: %s (%d receptions)\n", s.Before, s.Receptions[0])
+		fmt.Fprintf(&b, "after:  %s (%d receptions)\n\n", s.After, s.Receptions[1])
+	}
+
+	if s.Cells == 0 {
+		fmt.Fprintln(&b, "no cells with coverage")
+		return b.String()
+	}
+
+	percent := func(n int) float64 {
+		return float64(n) / float64(s.Cells) * 100
+	}
+
+	fmt.Fprintf(&b, "%-10s %6s %6s\n", "STATUS", "CELLS", "SHARE")
+	for _, row := range []struct {
+		status string
+		cells  int
+	}{
+		{DiffNew, s.New},
+		{DiffLost, s.Lost},
+		{DiffImproved, s.Improved},
+		{DiffRegressed, s.Regressed},
+		{DiffUnchanged, s.Unchanged},
+	} {
+		fmt.Fprintf(&b, "%-10s %6d %5.0f%%\n", row.status, row.cells, percent(row.cells))
+	}
+	fmt.Fprintf(&b, "%-10s %6d\n", "total", s.Cells)
+
+	if s.Compared > 0 {
+		fmt.Fprintf(&b, "\nrssi change of the %d cells in both windows: mean %+.1f dB, median %+.1f dB (p10 %+.1f dB, p90 %+.1f dB)\n",
+			s.Compared, s.DeltaRSSI.Mean, s.DeltaRSSI.Median, s.DeltaRSSI.P10, s.DeltaRSSI.P90)
+	}
+
+	if len(s.SFChanges) > 0 {
+		var changes []string
+		for change := range s.SFChanges {
+			changes = append(changes, change)
+		}
+		sort.Strings(changes)
+
+		fmt.Fprintln(&b, "\nspreading factor changes:")
+		for _, change := range changes {
+			fmt.Fprintf(&b, "\t%s: %d cells\n", change, s.SFChanges[change])
+		}
+	}
+
+	return b.String()
+}
+
+// GeoJSON returns the cells as GeoJSON polygons (in [lon, lat] order) with
+// their status and deltas, coloured by status with the fill and stroke
+// properties of the simplestyle spec.
+func (d *Diff) GeoJSON(callback string) (string, error) {
+	fc := geojson.NewFeatureCollection()
+
+	for _, c := range d.Cells {
+		feature := geojson.NewFeature(model.CellPolygon(d.Grid, c.Cell))
+		feature.SetProperty("cell", c.Cell.String())
+		feature.SetProperty("status", c.Status)
+		feature.SetProperty("count_before", c.CountBefore)
+		feature.SetProperty("count_after", c.CountAfter)
+		feature.SetProperty("gateways_before", c.GatewaysBefore)
+		feature.SetProperty("gateways_after", c.GatewaysAfter)
+
+		if c.SFBefore > 0 {
+			feature.SetProperty("rssi_before", c.RSSIBefore)
+			feature.SetProperty("sf_before", c.SFBefore)
+		}
+
+		if c.SFAfter > 0 {
+			feature.SetProperty("rssi_after", c.RSSIAfter)
+			feature.SetProperty("sf_after", c.SFAfter)
+		}
+
+		if c.SFBefore > 0 && c.SFAfter > 0 {
+			feature.SetProperty("delta_rssi", c.DeltaRSSI)
+			feature.SetProperty("delta_sf", c.DeltaSF)
+		}
+
+		color := DiffColors[c.Status]
+		feature.SetProperty("color", color)
+		feature.SetProperty("fill", color)
+		feature.SetProperty("fill-opacity", 0.6)
+		feature.SetProperty("stroke", color)
+		feature.SetProperty("stroke-width", 0)
+
+		fc.AddFeature(feature)
+	}
+
+	return model.FeatureCollectionJSON(fc, callback)
+}
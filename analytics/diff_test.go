@@ -0,0 +1,120 @@
+// The MIT License (MIT)
+//
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package analytics
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bullettime/lora-mapper/model"
+)
+
+func TestCompareCells(t *testing.T) {
+	origin := model.LatLon{Latitude: 51, Longitude: 4.7}
+	grid, err := model.NewGrid(model.ShapeSquare, 100, origin)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	at := func(x int) model.LatLon {
+		return grid.Center(model.CellID{X: x, Y: 0})
+	}
+
+	reception := func(x int, sf int, rssi float64) model.Reception {
+		return model.Reception{Location: at(x), GatewayID: "g1", SF: sf, DataRate: model.DataRate(sf), RSSI: rssi}
+	}
+
+	before := []model.Reception{
+		reception(0, 9, -110), // improved sf
+		reception(1, 7, -100), // improved rssi
+		reception(2, 7, -100), // unchanged
+		reception(3, 7, -100), // regressed sf
+		reception(4, 7, -100), // lost
+	}
+
+	after := []model.Reception{
+		reception(0, 7, -105),
+		reception(1, 7, -95),
+		reception(2, 7, -101),
+		reception(3, 10, -115),
+		reception(5, 8, -108), // new
+	}
+
+	diff, err := CompareCells(model.GridOptions{Shape: model.ShapeSquare, Size: 100, Origin: &origin}, before, after, DefaultDiffOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	statuses := []string{DiffImproved, DiffImproved, DiffUnchanged, DiffRegressed, DiffLost, DiffNew}
+	if len(diff.Cells) != len(statuses) {
+		t.Fatalf("expected %d cells, got %+v", len(statuses), diff.Cells)
+	}
+
+	for i, c := range diff.Cells {
+		if c.Status != statuses[i] {
+			t.Errorf("cell %d: expected %s, got %+v", i, statuses[i], c)
+		}
+	}
+
+	s := diff.Summary
+	if s.Compared != 4 || s.New != 1 || s.Lost != 1 || s.Improved != 2 || s.Regressed != 1 || s.Unchanged != 1 {
+		t.Errorf("unexpected summary %+v", s)
+	}
+
+	if s.SFChanges["SF9BW125 > SF7BW125"] != 1 || s.SFChanges["SF7BW125 > SF10BW125"] != 1 || len(s.SFChanges) != 2 {
+		t.Errorf("unexpected sf changes %v", s.SFChanges)
+	}
+
+	if c := diff.Cells[0]; c.DeltaSF != -2 || c.DeltaRSSI != 5 {
+		t.Errorf("unexpected deltas %+v", c)
+	}
+
+	data, err := diff.GeoJSON("")
+	if err != nil || !strings.Contains(data, DiffColors[DiffLost]) {
+		t.Errorf("unexpected geojson %s %v", data, err)
+	}
+
+	if !strings.Contains(diff.Report(), "regressed") {
+		t.Errorf("unexpected report %s", diff.Report())
+	}
+}
+
+func TestDiffWindow(t *testing.T) {
+	if err := (DiffWindow{}).Validate(); err != ErrDiffWindow {
+		t.Errorf("expected ErrDiffWindow, got %v", err)
+	}
+
+	start := time.Date(2018, 5, 1, 0, 0, 0, 0, time.UTC)
+	base := model.Filter{Campaigns: []string{"a"}, DeviceIDs: []string{"d"}}
+
+	f := DiffWindow{Start: start}.Filter(base)
+	if !f.Start.Equal(start) || len(f.Campaigns) != 1 || len(f.DeviceIDs) != 1 {
+		t.Errorf("unexpected filter %+v", f)
+	}
+
+	f = DiffWindow{Campaigns: []string{"b"}}.Filter(base)
+	if f.Campaigns[0] != "b" || !f.Start.IsZero() {
+		t.Errorf("unexpected filter %+v", f)
+	}
+}
@@ -0,0 +1,342 @@
+// The MIT License (MIT)
+//
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package analytics
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bullettime/lora-mapper/model"
+	"github.com/paulmach/go.geojson"
+)
+
+const (
+	// DefaultMaxGap is the largest gap (in frames) that is attributed to the
+	// locations between the neighbouring uplinks.
+	DefaultMaxGap = 64
+	// DefaultMaxGapDuration is the longest gap that is attributed, longer
+	// gaps are likely a device that was switched off or out of the area.
+	DefaultMaxGapDuration = time.Hour
+	// UplinkTolerance is the longest time between the receptions of the same
+	// frame by different gateways.
+	UplinkTolerance = 30 * time.Second
+)
+
+// FrameOptions limit the gaps that are attributed to a location.
+type FrameOptions struct {
+	MaxGap         uint32
+	MaxGapDuration time.Duration
+}
+
+func DefaultFrameOptions() FrameOptions {
+	return FrameOptions{
+		MaxGap:         DefaultMaxGap,
+		MaxGapDuration: DefaultMaxGapDuration,
+	}
+}
+
+// Uplink is a frame received by one or more gateways.
+type Uplink struct {
+	DeviceID string
+	FCnt     uint32
+	Location model.LatLon
+	SF       int
+	Gateways []string
+	Time     time.Time
+}
+
+// Frame is an expected frame of a device, delivered or lost. The location,
+// spreading factor and gateway set of a lost frame are interpolated from and
+// taken from the previous uplink.
+type Frame struct {
+	DeviceID   string       `json:"device_id"`
+	FCnt       uint32       `json:"fcnt"`
+	Location   model.LatLon `json:"location"`
+	SF         int          `json:"sf"`
+	GatewaySet string       `json:"gateway_set"`
+	Delivered  bool         `json:"delivered"`
+}
+
+// DeviceLoss is the frame loss of a device. Lost frames in gaps that are too
+// long to attribute to a location are unattributed.
+type DeviceLoss struct {
+	DeviceID     string  `json:"device_id"`
+	Sessions     int     `json:"sessions"`
+	Received     int     `json:"received"`
+	Lost         int     `json:"lost"`
+	Unattributed int     `json:"unattributed"`
+	LongestGap   uint32  `json:"longest_gap"`
+	PDR          float64 `json:"pdr"`
+}
+
+// FrameLoss holds the frames that could be attributed to a location and the
+// loss per device, sorted by device id.
+type FrameLoss struct {
+	Frames  []Frame      `json:"-"`
+	Devices []DeviceLoss `json:"devices"`
+	Total   model.PDR    `json:"total"`
+	Skipped int          `json:"skipped"`
+}
+
+// Uplinks merges the receptions of the same frame by several gateways, per
+// device sorted by time. Receptions without frame counter or device are
+// skipped.
+func Uplinks(receptions []model.Reception) (map[string][]Uplink, int) {
+	skipped := 0
+	sorted := make([]model.Reception, 0, len(receptions))
+
+	for _, r := range receptions {
+		if !r.HasFCnt || r.DeviceID == "" {
+			skipped++
+			continue
+		}
+
+		sorted = append(sorted, r)
+	}
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Time.Equal(sorted[j].Time) {
+			return sorted[i].FCnt < sorted[j].FCnt
+		}
+		return sorted[i].Time.Before(sorted[j].Time)
+	})
+
+	devices := make(map[string][]Uplink)
+
+	for _, r := range sorted {
+		uplinks := devices[r.DeviceID]
+
+		// a frame is received by the gateways within seconds, a repeated
+		// counter (after a reset) much later
+		if n := len(uplinks); n > 0 && uplinks[n-1].FCnt == r.FCnt && r.Time.Sub(uplinks[n-1].Time) <= UplinkTolerance {
+			uplinks[n-1].Gateways = append(uplinks[n-1].Gateways, r.GatewayID)
+			continue
+		}
+
+		devices[r.DeviceID] = append(uplinks, Uplink{
+			DeviceID: r.DeviceID,
+			FCnt:     r.FCnt,
+			Location: r.Location,
+			SF:       r.SF,
+			Gateways: []string{r.GatewayID},
+			Time:     r.Time,
+		})
+	}
+
+	for _, uplinks := range devices {
+		for i := range uplinks {
+			sort.Strings(uplinks[i].Gateways)
+		}
+	}
+
+	return devices, skipped
+}
+
+// GatewaySet returns the id of a set of gateways, eg. "gw-1+gw-2".
+func GatewaySet(gateways []string) string {
+	return strings.Join(gateways, "+")
+}
+
+// FrameCounterLoss reconstructs the frame counter sequence of every device. A
+// counter lower than or equal to the previous one starts a new session. The
+// frames missing between two uplinks are lost, and attributed to the line
+// between their locations when the gap is short enough.
+func FrameCounterLoss(receptions []model.Reception, options FrameOptions) FrameLoss {
+	devices, skipped := Uplinks(receptions)
+
+	result := FrameLoss{
+		Devices: []DeviceLoss{},
+		Skipped: skipped,
+	}
+
+	for id, uplinks := range devices {
+		loss := DeviceLoss{DeviceID: id}
+
+		for i, u := range uplinks {
+			loss.Received++
+
+			result.Frames = append(result.Frames, Frame{
+				DeviceID:   id,
+				FCnt:       u.FCnt,
+				Location:   u.Location,
+				SF:         u.SF,
+				GatewaySet: GatewaySet(u.Gateways),
+				Delivered:  true,
+			})
+
+			if i == 0 || u.FCnt <= uplinks[i-1].FCnt {
+				loss.Sessions++
+				continue
+			}
+
+			prev := uplinks[i-1]
+			gap := u.FCnt - prev.FCnt - 1
+
+			if gap == 0 {
+				continue
+			}
+
+			loss.Lost += int(gap)
+			if gap > loss.LongestGap {
+				loss.LongestGap = gap
+			}
+
+			if gap > options.MaxGap || u.Time.Sub(prev.Time) > options.MaxGapDuration {
+				loss.Unattributed += int(gap)
+				continue
+			}
+
+			for j := uint32(1); j <= gap; j++ {
+				t := float64(j) / float64(gap+1)
+
+				result.Frames = append(result.Frames, Frame{
+					DeviceID: id,
+					FCnt:     prev.FCnt + j,
+					Location: model.LatLon{
+						Latitude:  prev.Location.Latitude + t*(u.Location.Latitude-prev.Location.Latitude),
+						Longitude: prev.Location.Longitude + t*(u.Location.Longitude-prev.Location.Longitude),
+					},
+					SF:         prev.SF,
+					GatewaySet: GatewaySet(prev.Gateways),
+				})
+			}
+		}
+
+		total := model.PDR{}
+		total.Add(loss.Received+loss.Lost, loss.Received)
+		total.UpdateRatio()
+		loss.PDR = total.Ratio
+
+		result.Total.Add(loss.Received+loss.Lost, loss.Received)
+		result.Devices = append(result.Devices, loss)
+	}
+
+	result.Total.UpdateRatio()
+
+	sort.Slice(result.Devices, func(i, j int) bool {
+		return result.Devices[i].DeviceID < result.Devices[j].DeviceID
+	})
+
+	return result
+}
+
+// FrameCell is the delivery ratio of the frames attributed to a cell, of all
+// frames, per data rate and per gateway set.
+type FrameCell struct {
+	Cell   model.CellID `json:"cell"`
+	Center model.LatLon `json:"center"`
+	model.PDR
+	DataRates   map[string]model.PDR `json:"data_rates"`
+	GatewaySets map[string]model.PDR `json:"gateway_sets"`
+}
+
+// FrameLocations returns the locations of the frames.
+func FrameLocations(frames []Frame) []model.LatLon {
+	locations := make([]model.LatLon, len(frames))
+
+	for i, f := range frames {
+		locations[i] = f.Location
+	}
+
+	return locations
+}
+
+// FrameCells returns the delivery ratio of every cell of the grid with at
+// least one frame.
+func FrameCells(grid model.Grid, frames []Frame) []FrameCell {
+	bins := make(map[model.CellID]*FrameCell)
+	var ids []model.CellID
+
+	for _, f := range frames {
+		id := grid.Cell(f.Location)
+
+		c, ok := bins[id]
+		if !ok {
+			c = &FrameCell{
+				Cell:        id,
+				Center:      grid.Center(id),
+				DataRates:   make(map[string]model.PDR),
+				GatewaySets: make(map[string]model.PDR),
+			}
+			bins[id] = c
+			ids = append(ids, id)
+		}
+
+		delivered := 0
+		if f.Delivered {
+			delivered = 1
+		}
+
+		c.Add(1, delivered)
+		model.AddPDR(c.DataRates, model.DataRate(f.SF), 1, delivered)
+		model.AddPDR(c.GatewaySets, f.GatewaySet, 1, delivered)
+	}
+
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i].Less(ids[j])
+	})
+
+	cells := make([]FrameCell, 0, len(ids))
+	for _, id := range ids {
+		c := bins[id]
+		c.UpdateRatio()
+		model.UpdateRatios(c.DataRates)
+		model.UpdateRatios(c.GatewaySets)
+		cells = append(cells, *c)
+	}
+
+	return cells
+}
+
+// FrameCellsGeoJSON returns the cells as GeoJSON polygons (in [lon, lat]
+// order) with the ratio of all frames, of every data rate (eg. pdr_sf7) and
+// of every gateway set (in gateway_sets).
+func FrameCellsGeoJSON(grid model.Grid, cells []FrameCell, callback string) (string, error) {
+	fc := geojson.NewFeatureCollection()
+
+	for _, c := range cells {
+		feature := geojson.NewFeature(model.CellPolygon(grid, c.Cell))
+		feature.SetProperty("cell", c.Cell.String())
+		feature.SetProperty("frames", c.Attempts)
+		feature.SetProperty("delivered", c.Delivered)
+		feature.SetProperty("pdr", c.Ratio)
+
+		for dataRate, p := range c.DataRates {
+			if sf, err := model.SpreadingFactor(dataRate); err == nil {
+				feature.SetProperty(fmt.Sprintf("pdr_sf%d", sf), p.Ratio)
+			}
+		}
+
+		sets := make(map[string]float64, len(c.GatewaySets))
+		for set, p := range c.GatewaySets {
+			sets[set] = p.Ratio
+		}
+		feature.SetProperty("gateway_sets", sets)
+
+		fc.AddFeature(feature)
+	}
+
+	return model.FeatureCollectionJSON(fc, callback)
+}
@@ -0,0 +1,121 @@
+// The MIT License (MIT)
+//
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package analytics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bullettime/lora-mapper/model"
+)
+
+func TestUplinksMinuteBoundary(t *testing.T) {
+	ts := time.Date(2018, 4, 1, 12, 0, 59, 900000000, time.UTC)
+
+	receptions := []model.Reception{
+		{DeviceID: "d", GatewayID: "b", FCnt: 10, HasFCnt: true, Time: ts.Add(200 * time.Millisecond)},
+		{DeviceID: "d", GatewayID: "a", FCnt: 10, HasFCnt: true, Time: ts},
+		{DeviceID: "d", GatewayID: "a", FCnt: 11, HasFCnt: true, Time: ts.Add(time.Minute)},
+	}
+
+	devices, _ := Uplinks(receptions)
+
+	uplinks := devices["d"]
+	if len(uplinks) != 2 {
+		t.Fatalf("expected 2 uplinks, got %+v", uplinks)
+	}
+
+	if uplinks[0].FCnt != 10 || GatewaySet(uplinks[0].Gateways) != "a+b" || !uplinks[0].Time.Equal(ts) {
+		t.Errorf("unexpected uplink %+v", uplinks[0])
+	}
+
+	loss := FrameCounterLoss(receptions, DefaultFrameOptions())
+	if d := loss.Devices[0]; d.Sessions != 1 || d.Received != 2 || d.Lost != 0 {
+		t.Errorf("unexpected device loss %+v", d)
+	}
+}
+
+func TestFrameCounterLoss(t *testing.T) {
+	ts := time.Date(2018, 4, 1, 12, 0, 0, 0, time.UTC)
+
+	reception := func(gateway string, fcnt uint32, lat float64, offset time.Duration) model.Reception {
+		return model.Reception{
+			DeviceID:  "d",
+			GatewayID: gateway,
+			FCnt:      fcnt,
+			HasFCnt:   true,
+			Location:  model.LatLon{Latitude: lat, Longitude: 4.7},
+			SF:        7,
+			DataRate:  "SF7BW125",
+			Time:      ts.Add(offset),
+		}
+	}
+
+	receptions := []model.Reception{
+		reception("a", 10, 51.000, 0),
+		reception("b", 10, 51.000, time.Second),
+		// 3 frames lost between 51.000 and 51.004
+		reception("a", 14, 51.004, 4*time.Minute),
+		// a gap of 2 hours isn't attributed
+		reception("a", 20, 51.010, 2*time.Hour),
+		// new session
+		reception("a", 1, 51.010, 3*time.Hour),
+		{DeviceID: "d", GatewayID: "a", Time: ts},
+	}
+
+	loss := FrameCounterLoss(receptions, DefaultFrameOptions())
+
+	if loss.Skipped != 1 || len(loss.Devices) != 1 {
+		t.Fatalf("unexpected loss %+v", loss)
+	}
+
+	d := loss.Devices[0]
+	if d.Sessions != 2 || d.Received != 4 || d.Lost != 8 || d.Unattributed != 5 || d.LongestGap != 5 || d.PDR != 0.333 {
+		t.Errorf("unexpected device loss %+v", d)
+	}
+
+	// 4 uplinks and 3 attributed frames
+	if len(loss.Frames) != 7 {
+		t.Fatalf("expected 7 frames, got %d", len(loss.Frames))
+	}
+
+	for _, f := range loss.Frames {
+		if f.FCnt == 10 && f.GatewaySet != "a+b" {
+			t.Errorf("expected gateway set a+b, got %s", f.GatewaySet)
+		}
+
+		if f.FCnt == 12 && (f.Delivered || f.Location.Latitude < 51.0019 || f.Location.Latitude > 51.0021 || f.GatewaySet != "a+b") {
+			t.Errorf("unexpected lost frame %+v", f)
+		}
+	}
+
+	grid, err := model.NewGrid(model.ShapeSquare, 1000, model.LatLon{Latitude: 51, Longitude: 4.7})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cells := FrameCells(grid, loss.Frames)
+	if len(cells) == 0 || cells[0].Attempts != 5 || cells[0].Delivered != 2 || cells[0].GatewaySets["a+b"].Attempts != 4 {
+		t.Errorf("unexpected cells %+v", cells)
+	}
+}
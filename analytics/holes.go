@@ -0,0 +1,473 @@
+// The MIT License (MIT)
+//
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package analytics
+
+import (
+	"math"
+	"sort"
+
+	"github.com/bullettime/lora-mapper/model"
+	"github.com/paulmach/go.geojson"
+	"github.com/pkg/errors"
+)
+
+const (
+	// HoleUncovered cells have no reception, HoleSF12 cells only receptions
+	// at SF12.
+	HoleUncovered = "uncovered"
+	HoleSF12      = "sf12"
+
+	// MaxHoleCells limits the number of cells of the target area.
+	MaxHoleCells = 250000
+)
+
+// HoleOptions of the hole detection: SF12 counts the cells with only SF12
+// receptions as holes and MinCells drops the smaller holes.
+type HoleOptions struct {
+	SF12     bool
+	MinCells int
+}
+
+func DefaultHoleOptions() HoleOptions {
+	return HoleOptions{SF12: true, MinCells: 1}
+}
+
+// Hole is a region of neighbouring hole cells. The distance to the nearest
+// gateway is from the centroid, in meters.
+type Hole struct {
+	ID             int            `json:"id"`
+	Cells          int            `json:"cells"`
+	UncoveredCells int            `json:"uncovered_cells"`
+	SF12Cells      int            `json:"sf12_cells"`
+	Area           float64        `json:"area_km2"`
+	Centroid       model.LatLon   `json:"centroid"`
+	NearestGateway string         `json:"nearest_gateway,omitempty"`
+	Distance       float64        `json:"nearest_gateway_distance,omitempty"`
+	IDs            []model.CellID `json:"-"`
+}
+
+// HoleSummary of the target area.
+type HoleSummary struct {
+	Cells          int     `json:"cells"`
+	Area           float64 `json:"area_km2"`
+	Holes          int     `json:"holes"`
+	HoleCells      int     `json:"hole_cells"`
+	HoleArea       float64 `json:"hole_area_km2"`
+	HoleShare      float64 `json:"hole_share"`
+	UncoveredCells int     `json:"uncovered_cells"`
+	SF12Cells      int     `json:"sf12_cells"`
+}
+
+// Holes holds the grid of the detection and the holes, largest first.
+type Holes struct {
+	Grid    model.Grid  `json:"-"`
+	Summary HoleSummary `json:"summary"`
+	Holes   []Hole      `json:"holes"`
+}
+
+// HoleGrid creates the grid of the options, the default origin is the one of
+// the receptions or of the target area without receptions.
+func HoleGrid(options model.GridOptions, receptions []model.Reception, area model.Area) (model.Grid, error) {
+	locations := model.ReceptionLocations(receptions)
+
+	if len(locations) == 0 {
+		b := area.Bounds()
+		locations = []model.LatLon{{Latitude: (b.MinLatitude + b.MaxLatitude) / 2, Longitude: (b.MinLongitude + b.MaxLongitude) / 2}}
+	}
+
+	return options.NewGrid(locations)
+}
+
+// AreaCells returns the cells of the grid with the center inside the area,
+// sorted by id.
+func AreaCells(grid model.Grid, area model.Area) ([]model.CellID, error) {
+	bounds := area.Bounds()
+
+	minX, minY := grid.Project(model.LatLon{Latitude: bounds.MinLatitude, Longitude: bounds.MinLongitude})
+	maxX, maxY := grid.Project(model.LatLon{Latitude: bounds.MaxLatitude, Longitude: bounds.MaxLongitude})
+
+	// steps of half a cell visit every cell of both shapes
+	step := grid.Size() / 2
+	if (maxX-minX)/step*(maxY-minY)/step > 4*MaxHoleCells {
+		return nil, errors.Errorf("target area is too large for cells of %v m, use larger cells", grid.Size())
+	}
+
+	seen := make(map[model.CellID]bool)
+	var ids []model.CellID
+
+	for x := minX - step; x <= maxX+step; x += step {
+		for y := minY - step; y <= maxY+step; y += step {
+			id := grid.Cell(grid.Unproject(x, y))
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+
+			if area.Contains(grid.Center(id)) {
+				ids = append(ids, id)
+			}
+		}
+	}
+
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i].Less(ids[j])
+	})
+
+	return ids, nil
+}
+
+// DetectHoles finds the coverage holes in the target area: the regions of
+// neighbouring cells without receptions in the aggregated cells, or with
+// only SF12 receptions.
+func DetectHoles(grid model.Grid, cells []model.CellStats, area model.Area, gateways []model.Gateway, options HoleOptions) (*Holes, error) {
+	ids, err := AreaCells(grid, area)
+	if err != nil {
+		return nil, err
+	}
+
+	bestSF := make(map[model.CellID]int, len(cells))
+	for _, c := range cells {
+		bestSF[c.Cell] = c.BestSF
+	}
+
+	holeCells := make(map[model.CellID]string)
+	for _, id := range ids {
+		sf, ok := bestSF[id]
+
+		switch {
+		case !ok:
+			holeCells[id] = HoleUncovered
+		case sf == 12 && options.SF12:
+			holeCells[id] = HoleSF12
+		}
+	}
+
+	cellArea := model.CellArea(grid)
+
+	result := &Holes{
+		Grid: grid,
+		Summary: HoleSummary{
+			Cells: len(ids),
+			Area:  round(float64(len(ids))*cellArea/1e6, 2),
+		},
+		Holes: []Hole{},
+	}
+
+	visited := make(map[model.CellID]bool)
+
+	for _, id := range ids {
+		if _, ok := holeCells[id]; !ok || visited[id] {
+			continue
+		}
+
+		hole := Hole{}
+		queue := []model.CellID{id}
+		visited[id] = true
+
+		for len(queue) > 0 {
+			c := queue[0]
+			queue = queue[1:]
+
+			hole.IDs = append(hole.IDs, c)
+			if holeCells[c] == HoleUncovered {
+				hole.UncoveredCells++
+			} else {
+				hole.SF12Cells++
+			}
+
+			for _, n := range grid.Neighbours(c) {
+				if _, ok := holeCells[n]; ok && !visited[n] {
+					visited[n] = true
+					queue = append(queue, n)
+				}
+			}
+		}
+
+		hole.Cells = len(hole.IDs)
+		if hole.Cells < options.MinCells {
+			continue
+		}
+
+		sort.Slice(hole.IDs, func(i, j int) bool {
+			return hole.IDs[i].Less(hole.IDs[j])
+		})
+
+		hole.Area = round(float64(hole.Cells)*cellArea/1e6, 4)
+		hole.Centroid = centroid(grid, hole.IDs)
+		hole.NearestGateway, hole.Distance = nearestGateway(gateways, hole.Centroid)
+
+		result.Holes = append(result.Holes, hole)
+
+		result.Summary.HoleCells += hole.Cells
+		result.Summary.UncoveredCells += hole.UncoveredCells
+		result.Summary.SF12Cells += hole.SF12Cells
+	}
+
+	sort.SliceStable(result.Holes, func(i, j int) bool {
+		return result.Holes[i].Cells > result.Holes[j].Cells
+	})
+
+	for i := range result.Holes {
+		result.Holes[i].ID = i + 1
+	}
+
+	result.Summary.Holes = len(result.Holes)
+	result.Summary.HoleArea = round(float64(result.Summary.HoleCells)*cellArea/1e6, 2)
+
+	if len(ids) > 0 {
+		result.Summary.HoleShare = round(float64(result.Summary.HoleCells)/float64(len(ids)), 3)
+	}
+
+	return result, nil
+}
+
+func centroid(grid model.Grid, ids []model.CellID) model.LatLon {
+	var sumX, sumY float64
+
+	for _, id := range ids {
+		x, y := grid.Project(grid.Center(id))
+		sumX += x
+		sumY += y
+	}
+
+	n := float64(len(ids))
+
+	return grid.Unproject(sumX/n, sumY/n)
+}
+
+func nearestGateway(gateways []model.Gateway, ll model.LatLon) (string, float64) {
+	id, distance := "", math.Inf(1)
+
+	for _, g := range gateways {
+		if d := g.Location.Distance(ll) * 1000; d < distance {
+			id, distance = g.ID, d
+		}
+	}
+
+	if id == "" {
+		return "", 0
+	}
+
+	return id, math.Floor(distance + 0.5)
+}
+
+// vertex is a corner of a cell on the tangent plane, rounded to centimeters
+// so that the corners of neighbouring cells match.
+type vertex struct {
+	x, y int64
+}
+
+func (v vertex) less(other vertex) bool {
+	if v.y != other.y {
+		return v.y < other.y
+	}
+	return v.x < other.x
+}
+
+type edge struct {
+	from, to vertex
+}
+
+// Outline returns the outline of the hole as rings of [lon, lat] positions,
+// per polygon the outer ring followed by the rings of the covered cells
+// inside the hole.
+func (h Hole) Outline(grid model.Grid) [][][][]float64 {
+	edges := make(map[edge]bool)
+
+	toVertex := func(ll model.LatLon) vertex {
+		x, y := grid.Project(ll)
+		return vertex{int64(math.Floor(x*100 + 0.5)), int64(math.Floor(y*100 + 0.5))}
+	}
+
+	for _, id := range h.IDs {
+		polygon := grid.Polygon(id)
+		for i := 1; i < len(polygon); i++ {
+			e := edge{toVertex(polygon[i-1]), toVertex(polygon[i])}
+			reverse := edge{e.to, e.from}
+
+			// edges shared with a neighbouring cell of the hole are inside
+			if edges[reverse] {
+				delete(edges, reverse)
+			} else {
+				edges[e] = true
+			}
+		}
+	}
+
+	next := make(map[vertex][]vertex)
+	var starts []vertex
+
+	for e := range edges {
+		next[e.from] = append(next[e.from], e.to)
+		starts = append(starts, e.from)
+	}
+
+	sort.Slice(starts, func(i, j int) bool {
+		return starts[i].less(starts[j])
+	})
+
+	for v := range next {
+		to := next[v]
+		sort.Slice(to, func(i, j int) bool {
+			return to[i].less(to[j])
+		})
+	}
+
+	var outers, inners [][]vertex
+
+	for _, start := range starts {
+		if len(next[start]) == 0 {
+			continue
+		}
+
+		ring := []vertex{start}
+		for v := start; ; {
+			to := next[v][0]
+			next[v] = next[v][1:]
+
+			ring = append(ring, to)
+			if to == start {
+				break
+			}
+			v = to
+		}
+
+		ring = simplify(ring)
+
+		// the cells are counterclockwise, so are the outer rings
+		if signedArea(ring) > 0 {
+			outers = append(outers, ring)
+		} else {
+			inners = append(inners, ring)
+		}
+	}
+
+	toPositions := func(ring []vertex) [][]float64 {
+		positions := make([][]float64, len(ring))
+		for i, v := range ring {
+			ll := grid.Unproject(float64(v.x)/100, float64(v.y)/100)
+			positions[i] = []float64{ll.Longitude, ll.Latitude}
+		}
+		return positions
+	}
+
+	polygons := make([][][][]float64, len(outers))
+	for i, outer := range outers {
+		polygons[i] = [][][]float64{toPositions(outer)}
+	}
+
+	for _, inner := range inners {
+		for i, outer := range outers {
+			if ringContainsVertex(outer, inner[0]) {
+				polygons[i] = append(polygons[i], toPositions(inner))
+				break
+			}
+		}
+	}
+
+	return polygons
+}
+
+// simplify drops the vertices on a straight line between their neighbours
+// from the closed ring.
+func simplify(ring []vertex) []vertex {
+	n := len(ring) - 1
+	if n < 3 {
+		return ring
+	}
+
+	var result []vertex
+
+	for i := 0; i < n; i++ {
+		prev, v, next := ring[(i+n-1)%n], ring[i], ring[(i+1)%n]
+
+		cross := (v.x-prev.x)*(next.y-v.y) - (v.y-prev.y)*(next.x-v.x)
+		if cross != 0 {
+			result = append(result, v)
+		}
+	}
+
+	return append(result, result[0])
+}
+
+func signedArea(ring []vertex) float64 {
+	area := 0.0
+	for i := 1; i < len(ring); i++ {
+		area += float64(ring[i-1].x)*float64(ring[i].y) - float64(ring[i].x)*float64(ring[i-1].y)
+	}
+	return area / 2
+}
+
+// ringContainsVertex tests a point just next to the vertex, as the vertices
+// of the inner rings are on the corners of the cells.
+func ringContainsVertex(ring []vertex, v vertex) bool {
+	x, y := float64(v.x)+0.25, float64(v.y)+0.25
+	inside := false
+
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		a, b := ring[i], ring[j]
+		ay, by := float64(a.y), float64(b.y)
+		ax, bx := float64(a.x), float64(b.x)
+
+		if (ay > y) != (by > y) && x < (bx-ax)*(y-ay)/(by-ay)+ax {
+			inside = !inside
+		}
+	}
+
+	return inside
+}
+
+// HolesGeoJSON returns the holes as GeoJSON polygons (in [lon, lat] order),
+// or multi polygons when cells only touch at a corner, with the area,
+// centroid and nearest gateway.
+func HolesGeoJSON(holes *Holes, callback string) (string, error) {
+	fc := geojson.NewFeatureCollection()
+
+	for _, h := range holes.Holes {
+		polygons := h.Outline(holes.Grid)
+
+		var geometry *geojson.Geometry
+		if len(polygons) == 1 {
+			geometry = geojson.NewPolygonGeometry(polygons[0])
+		} else {
+			geometry = geojson.NewMultiPolygonGeometry(polygons...)
+		}
+
+		feature := geojson.NewFeature(geometry)
+		feature.SetProperty("id", h.ID)
+		feature.SetProperty("cells", h.Cells)
+		feature.SetProperty("uncovered_cells", h.UncoveredCells)
+		feature.SetProperty("sf12_cells", h.SF12Cells)
+		feature.SetProperty("area_km2", h.Area)
+		feature.SetProperty("centroid", []float64{h.Centroid.Longitude, h.Centroid.Latitude})
+
+		if h.NearestGateway != "" {
+			feature.SetProperty("nearest_gateway", h.NearestGateway)
+			feature.SetProperty("nearest_gateway_distance", h.Distance)
+		}
+
+		fc.AddFeature(feature)
+	}
+
+	return model.FeatureCollectionJSON(fc, callback)
+}
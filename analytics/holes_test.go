@@ -0,0 +1,115 @@
+// The MIT License (MIT)
+//
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package analytics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bullettime/lora-mapper/model"
+)
+
+func TestDetectHoles(t *testing.T) {
+	grid, err := model.NewGrid(model.ShapeSquare, 100, model.LatLon{Latitude: 51, Longitude: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the cells 0:0 to 9:9
+	sw := grid.Unproject(1, 1)
+	ne := grid.Unproject(999, 999)
+	area := model.Area{model.BoundingBox{
+		MinLatitude:  sw.Latitude,
+		MinLongitude: sw.Longitude,
+		MaxLatitude:  ne.Latitude,
+		MaxLongitude: ne.Longitude,
+	}.Polygon()}
+
+	uncovered := map[model.CellID]bool{
+		// a ring around the covered cell 6:6
+		{X: 5, Y: 5}: true, {X: 6, Y: 5}: true, {X: 7, Y: 5}: true,
+		{X: 5, Y: 6}: true, {X: 7, Y: 6}: true,
+		{X: 5, Y: 7}: true, {X: 6, Y: 7}: true, {X: 7, Y: 7}: true,
+		// a block with an SF12 cell next to it
+		{X: 1, Y: 1}: true, {X: 2, Y: 1}: true, {X: 1, Y: 2}: true, {X: 2, Y: 2}: true,
+	}
+
+	var cells []model.CellStats
+	for x := 0; x < 10; x++ {
+		for y := 0; y < 10; y++ {
+			id := model.CellID{X: x, Y: y}
+			if uncovered[id] {
+				continue
+			}
+
+			sf := 7
+			if x == 3 && y == 1 {
+				sf = 12
+			}
+
+			cells = append(cells, model.CellStats{Cell: id, BestSF: sf})
+		}
+	}
+
+	gateways := []model.Gateway{{ID: "g1", Location: grid.Center(model.CellID{X: 9, Y: 9})}}
+
+	holes, err := DetectHoles(grid, cells, area, gateways, DefaultHoleOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if holes.Summary.Cells != 100 || holes.Summary.Holes != 2 || holes.Summary.HoleCells != 13 || holes.Summary.SF12Cells != 1 {
+		t.Fatalf("unexpected summary %+v", holes.Summary)
+	}
+
+	ring, block := holes.Holes[0], holes.Holes[1]
+	if ring.Cells != 8 || block.Cells != 5 || block.SF12Cells != 1 || block.UncoveredCells != 4 {
+		t.Errorf("unexpected holes %+v", holes.Holes)
+	}
+
+	if ring.NearestGateway != "g1" || ring.Distance < 420 || ring.Distance > 430 {
+		t.Errorf("unexpected nearest gateway %s at %v m", ring.NearestGateway, ring.Distance)
+	}
+
+	if c := grid.Cell(ring.Centroid); c != (model.CellID{X: 6, Y: 6}) {
+		t.Errorf("unexpected centroid in cell %v", c)
+	}
+
+	if outline := ring.Outline(grid); len(outline) != 1 || len(outline[0]) != 2 || len(outline[0][0]) != 5 || len(outline[0][1]) != 5 {
+		t.Errorf("expected a square with a square hole, got %v", outline)
+	}
+
+	if outline := block.Outline(grid); len(outline) != 1 || len(outline[0]) != 1 || len(outline[0][0]) != 7 {
+		t.Errorf("expected a polygon of 6 corners, got %v", outline)
+	}
+
+	data, err := HolesGeoJSON(holes, "")
+	if err != nil || strings.Count(data, `"Polygon"`) != 2 {
+		t.Errorf("unexpected geojson %s %v", data, err)
+	}
+
+	holes, err = DetectHoles(grid, cells, area, gateways, HoleOptions{MinCells: 5})
+	if err != nil || len(holes.Holes) != 1 || holes.Holes[0].Cells != 8 {
+		t.Errorf("expected only the ring without the sf12 cells, got %+v %v", holes, err)
+	}
+}
@@ -0,0 +1,267 @@
+// The MIT License (MIT)
+//
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package analytics
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/bullettime/lora-mapper/model"
+	"github.com/paulmach/go.geojson"
+	"github.com/pkg/errors"
+)
+
+const (
+	OutageLost      = "lost"
+	OutageDegraded  = "degraded"
+	OutageReduced   = "reduced"
+	OutageUnchanged = "unchanged"
+)
+
+var ErrNoOutageGateways = errors.New("no gateways to exclude")
+
+// OutageCell compares a cell with and without the excluded gateways: the
+// distinct gateways that received in it and the recommended spreading factor
+// (0 without recommendation). A cell is lost when it has neither receptions
+// nor a recommendation left, degraded when it needs a higher spreading factor
+// and reduced when fewer gateways hear it.
+type OutageCell struct {
+	Cell           model.CellID `json:"cell"`
+	Center         model.LatLon `json:"center"`
+	Status         string       `json:"status"`
+	GatewaysBefore int          `json:"gateways_before"`
+	GatewaysAfter  int          `json:"gateways_after"`
+	SFBefore       int          `json:"sf_before"`
+	SFAfter        int          `json:"sf_after"`
+}
+
+// OutageSummary counts the cells per status, the cells that depend on a
+// single gateway before and after, and the spreading factor changes of the
+// degraded cells (eg. "SF7BW125 > SF9BW125").
+type OutageSummary struct {
+	Gateways            []string       `json:"gateways"`
+	Receptions          int            `json:"receptions"`
+	Excluded            int            `json:"excluded"`
+	Cells               int            `json:"cells"`
+	Lost                int            `json:"lost"`
+	Degraded            int            `json:"degraded"`
+	Reduced             int            `json:"reduced"`
+	SingleGatewayBefore int            `json:"single_gateway_before"`
+	SingleGatewayAfter  int            `json:"single_gateway_after"`
+	SFChanges           map[string]int `json:"sf_changes"`
+}
+
+// Outage is the result of a simulation, the cells that changed sorted by id.
+type Outage struct {
+	Grid    model.Grid    `json:"-"`
+	Summary OutageSummary `json:"summary"`
+	Cells   []OutageCell  `json:"cells"`
+}
+
+// SimulateOutage recomputes the coverage, ddr recommendations and redundancy
+// of square cells of size meters without the receptions of the gateways. The
+// ddr recommendations are computed at the center of the cells with the radius
+// (in meters) and options.
+func SimulateOutage(receptions []model.Reception, gateways []string, size float64, origin *model.LatLon, radius float64, options model.DDROptions) (*Outage, error) {
+	if len(gateways) == 0 {
+		return nil, ErrNoOutageGateways
+	}
+
+	o := model.DefaultOrigin(model.ReceptionLocations(receptions))
+	if origin != nil {
+		o = *origin
+	}
+
+	grid, err := model.NewGrid(model.ShapeSquare, size, o)
+	if err != nil {
+		return nil, err
+	}
+
+	excluded := make(map[string]bool, len(gateways))
+	for _, g := range gateways {
+		excluded[g] = true
+	}
+
+	var remaining []model.Reception
+	for _, r := range receptions {
+		if !excluded[r.GatewayID] {
+			remaining = append(remaining, r)
+		}
+	}
+
+	ddrBefore, err := model.ComputeDDRGrid(receptions, size, &o, radius, options)
+	if err != nil {
+		return nil, err
+	}
+
+	ddrAfter, err := model.ComputeDDRGrid(remaining, size, &o, radius, options)
+	if err != nil {
+		return nil, err
+	}
+
+	type state struct {
+		gatewaysBefore, gatewaysAfter int
+	}
+
+	cells := make(map[model.CellID]*state)
+
+	get := func(id model.CellID) *state {
+		s, ok := cells[id]
+		if !ok {
+			s = &state{}
+			cells[id] = s
+		}
+		return s
+	}
+
+	for _, c := range Redundancy(grid, receptions) {
+		get(c.Cell).gatewaysBefore = c.Gateways
+	}
+
+	for _, c := range Redundancy(grid, remaining) {
+		get(c.Cell).gatewaysAfter = c.Gateways
+	}
+
+	for id := range ddrBefore.Cells {
+		get(id)
+	}
+
+	outage := &Outage{
+		Grid: grid,
+		Summary: OutageSummary{
+			Gateways:   gateways,
+			Receptions: len(receptions),
+			Excluded:   len(receptions) - len(remaining),
+			Cells:      len(cells),
+			SFChanges:  make(map[string]int),
+		},
+		Cells: []OutageCell{},
+	}
+
+	for id, s := range cells {
+		c := OutageCell{
+			Cell:           id,
+			Center:         grid.Center(id),
+			Status:         OutageUnchanged,
+			GatewaysBefore: s.gatewaysBefore,
+			GatewaysAfter:  s.gatewaysAfter,
+		}
+
+		if r, ok := ddrBefore.Cells[id]; ok {
+			c.SFBefore = r.SF
+		}
+
+		if r, ok := ddrAfter.Cells[id]; ok {
+			c.SFAfter = r.SF
+		}
+
+		if s.gatewaysBefore == 1 {
+			outage.Summary.SingleGatewayBefore++
+		}
+
+		if s.gatewaysAfter == 1 {
+			outage.Summary.SingleGatewayAfter++
+		}
+
+		switch {
+		case s.gatewaysAfter == 0 && c.SFAfter == 0:
+			c.Status = OutageLost
+			outage.Summary.Lost++
+		case c.SFAfter > c.SFBefore && c.SFBefore > 0:
+			c.Status = OutageDegraded
+			outage.Summary.Degraded++
+			outage.Summary.SFChanges[model.DataRate(c.SFBefore)+" > "+model.DataRate(c.SFAfter)]++
+		case s.gatewaysAfter < s.gatewaysBefore:
+			c.Status = OutageReduced
+			outage.Summary.Reduced++
+		}
+
+		if c.Status != OutageUnchanged {
+			outage.Cells = append(outage.Cells, c)
+		}
+	}
+
+	sort.Slice(outage.Cells, func(i, j int) bool {
+		return outage.Cells[i].Cell.Less(outage.Cells[j].Cell)
+	})
+
+	return outage, nil
+}
+
+// Report describes the impact of the outage in a few lines of text.
+func (o *Outage) Report() string {
+	var b bytes.Buffer
+
+	s := o.Summary
+
+	fmt.Fprintf(&b, "outage of %s: %d of %d receptions excluded\n", strings.Join(s.Gateways, ", "), s.Excluded, s.Receptions)
+
+	if s.Cells == 0 {
+		fmt.Fprintln(&b, "no cells with coverage")
+		return b.String()
+	}
+
+	percent := func(n int) float64 {
+		return float64(n) / float64(s.Cells) * 100
+	}
+
+	fmt.Fprintf(&b, "%d of %d cells (%.0f%%) lose their coverage\n", s.Lost, s.Cells, percent(s.Lost))
+	fmt.Fprintf(&b, "%d cells (%.0f%%) need a higher spreading factor\n", s.Degraded, percent(s.Degraded))
+
+	var changes []string
+	for change := range s.SFChanges {
+		changes = append(changes, change)
+	}
+	sort.Strings(changes)
+
+	for _, change := range changes {
+		fmt.Fprintf(&b, "\t%s: %d cells\n", change, s.SFChanges[change])
+	}
+
+	fmt.Fprintf(&b, "%d cells (%.0f%%) are heard by fewer gateways\n", s.Reduced, percent(s.Reduced))
+	fmt.Fprintf(&b, "cells that depend on a single gateway: %d before, %d after\n", s.SingleGatewayBefore, s.SingleGatewayAfter)
+
+	return b.String()
+}
+
+// GeoJSON returns the changed cells as GeoJSON polygons (in [lon, lat]
+// order) with their status, gateways and spreading factors before and after.
+func (o *Outage) GeoJSON(callback string) (string, error) {
+	fc := geojson.NewFeatureCollection()
+
+	for _, c := range o.Cells {
+		feature := geojson.NewFeature(model.CellPolygon(o.Grid, c.Cell))
+		feature.SetProperty("cell", c.Cell.String())
+		feature.SetProperty("status", c.Status)
+		feature.SetProperty("gateways_before", c.GatewaysBefore)
+		feature.SetProperty("gateways_after", c.GatewaysAfter)
+		feature.SetProperty("sf_before", c.SFBefore)
+		feature.SetProperty("sf_after", c.SFAfter)
+
+		fc.AddFeature(feature)
+	}
+
+	return model.FeatureCollectionJSON(fc, callback)
+}
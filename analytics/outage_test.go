@@ -0,0 +1,79 @@
+// The MIT License (MIT)
+//
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package analytics
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bullettime/lora-mapper/model"
+)
+
+func TestSimulateOutage(t *testing.T) {
+	ts := time.Now().UTC()
+	a := model.LatLon{Latitude: 51.0001, Longitude: 4.7001}
+	b := model.LatLon{Latitude: 51.0101, Longitude: 4.7001}
+
+	var receptions []model.Reception
+	for i := 0; i < 5; i++ {
+		receptions = append(receptions,
+			// a is heard by g1 at SF7 with a large margin, by g2 only at SF12
+			model.Reception{Location: a, GatewayID: "g1", DataRate: "SF7BW125", SF: 7, RSSI: -90, SNR: 8, Time: ts},
+			model.Reception{Location: a, GatewayID: "g2", DataRate: "SF12BW125", SF: 12, RSSI: -125, SNR: -15, Time: ts},
+			// b is only heard by g1
+			model.Reception{Location: b, GatewayID: "g1", DataRate: "SF7BW125", SF: 7, RSSI: -90, SNR: 8, Time: ts},
+		)
+	}
+
+	origin := model.LatLon{Latitude: 51, Longitude: 4.7}
+
+	outage, err := SimulateOutage(receptions, []string{"g1"}, 100, &origin, 100, model.DefaultDDROptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := outage.Summary
+	if s.Excluded != 10 || s.Lost < 1 || s.Degraded < 1 || s.SingleGatewayBefore != 1 || s.SingleGatewayAfter != 1 {
+		t.Errorf("unexpected summary %+v", s)
+	}
+
+	// the recommendations reach into the neighbouring cells
+	if s.SFChanges["SF7BW125 > SF12BW125"] != s.Degraded {
+		t.Errorf("expected a change from SF7 to SF12, got %v", s.SFChanges)
+	}
+
+	for _, c := range outage.Cells {
+		if c.Status == OutageUnchanged {
+			t.Errorf("unchanged cell %+v in the result", c)
+		}
+	}
+
+	if report := outage.Report(); !strings.Contains(report, "outage of g1") || !strings.Contains(report, "SF7BW125 > SF12BW125") {
+		t.Errorf("unexpected report %s", report)
+	}
+
+	if _, err := SimulateOutage(receptions, nil, 100, &origin, 100, model.DefaultDDROptions()); err != ErrNoOutageGateways {
+		t.Errorf("expected ErrNoOutageGateways, got %v", err)
+	}
+}
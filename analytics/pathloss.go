@@ -0,0 +1,220 @@
+// The MIT License (MIT)
+//
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package analytics derives models and reports from the stored receptions:
+// the path loss of the gateways, frame loss, gateway redundancy and outages,
+// coverage holes, differences between periods, coverage over time and signal
+// anomalies.
+package analytics
+
+import (
+	"math"
+	"sort"
+
+	"github.com/bullettime/lora-mapper/model"
+	"github.com/pkg/errors"
+)
+
+const (
+	// ReferenceDistance is the distance d0 (in meters) of the reference loss
+	// of the log-distance model.
+	ReferenceDistance = 1000.0
+	// DefaultTxPower is the transmit power (in dBm) of receptions without
+	// a power tag.
+	DefaultTxPower = 14.0
+	// MinFitDistance excludes receptions closer to the gateway (in meters),
+	// which are dominated by the antenna pattern rather than the path.
+	MinFitDistance = 10.0
+	// MinFitSamples is the number of receptions needed for a fit.
+	MinFitSamples = 3
+)
+
+var ErrNotEnoughSamples = errors.New("not enough receptions to fit a path loss model")
+
+// PathLossFit is a log-distance path loss model of a gateway:
+//
+//	PL(d) = ReferenceLoss + 10 * Exponent * log10(d / ReferenceDistance) + X
+//
+// with X the shadowing, normally distributed with standard deviation Sigma.
+type PathLossFit struct {
+	GatewayID         string           `json:"gateway_id"`
+	Location          model.LatLon     `json:"location"`
+	Count             int              `json:"count"`
+	ReferenceDistance float64          `json:"reference_distance"`
+	ReferenceLoss     float64          `json:"reference_loss"`
+	Exponent          float64          `json:"exponent"`
+	Sigma             float64          `json:"sigma"`
+	RSquared          float64          `json:"r_squared"`
+	MinDistance       float64          `json:"min_distance"`
+	MaxDistance       float64          `json:"max_distance"`
+	Residuals         model.Stats      `json:"residuals"`
+	Samples           []PathLossSample `json:"samples,omitempty"`
+}
+
+// PathLossSample is a reception used for the fit, distances in meters and
+// losses in dB.
+type PathLossSample struct {
+	Location  model.LatLon `json:"location"`
+	Distance  float64      `json:"distance"`
+	PathLoss  float64      `json:"path_loss"`
+	Predicted float64      `json:"predicted"`
+	Residual  float64      `json:"residual"`
+}
+
+// PathLoss returns the median path loss in dB at the distance in meters.
+func (f PathLossFit) PathLoss(distance float64) float64 {
+	return f.ReferenceLoss + 10*f.Exponent*math.Log10(distance/f.ReferenceDistance)
+}
+
+// Distance returns the distance in meters at which the median path loss
+// reaches the loss in dB.
+func (f PathLossFit) Distance(loss float64) float64 {
+	return f.ReferenceDistance * math.Pow(10, (loss-f.ReferenceLoss)/(10*f.Exponent))
+}
+
+// FitPathLoss fits the model on the receptions of a gateway at the location
+// by least squares on the logarithm of the distance. The path loss of a
+// reception is its transmit power (txPower when unknown) minus the rssi,
+// antenna gains are part of the reference loss.
+func FitPathLoss(gatewayID string, location model.LatLon, receptions []model.Reception, txPower float64) (PathLossFit, error) {
+	fit := PathLossFit{
+		GatewayID:         gatewayID,
+		Location:          location,
+		ReferenceDistance: ReferenceDistance,
+		MinDistance:       math.Inf(1),
+	}
+
+	var xs, ys []float64
+
+	for _, r := range receptions {
+		d := location.Distance(r.Location) * 1000
+		if d < MinFitDistance {
+			continue
+		}
+
+		power := r.TxPower
+		if math.IsNaN(power) {
+			power = txPower
+		}
+
+		fit.Samples = append(fit.Samples, PathLossSample{
+			Location: r.Location,
+			Distance: d,
+			PathLoss: power - r.RSSI,
+		})
+
+		xs = append(xs, 10*math.Log10(d/ReferenceDistance))
+		ys = append(ys, power-r.RSSI)
+
+		fit.MinDistance = math.Min(fit.MinDistance, d)
+		fit.MaxDistance = math.Max(fit.MaxDistance, d)
+	}
+
+	fit.Count = len(xs)
+
+	if fit.Count < MinFitSamples {
+		return fit, ErrNotEnoughSamples
+	}
+
+	var mx, my float64
+	for i := range xs {
+		mx += xs[i]
+		my += ys[i]
+	}
+	mx /= float64(fit.Count)
+	my /= float64(fit.Count)
+
+	var sxx, sxy, syy float64
+	for i := range xs {
+		sxx += (xs[i] - mx) * (xs[i] - mx)
+		sxy += (xs[i] - mx) * (ys[i] - my)
+		syy += (ys[i] - my) * (ys[i] - my)
+	}
+
+	if sxx == 0 {
+		// all receptions at the same distance
+		return fit, ErrNotEnoughSamples
+	}
+
+	fit.Exponent = sxy / sxx
+	fit.ReferenceLoss = my - fit.Exponent*mx
+
+	residuals := make([]float64, fit.Count)
+	var ssr float64
+
+	for i := range fit.Samples {
+		s := &fit.Samples[i]
+		s.Predicted = fit.PathLoss(s.Distance)
+		s.Residual = s.PathLoss - s.Predicted
+
+		residuals[i] = s.Residual
+		ssr += s.Residual * s.Residual
+	}
+
+	fit.Residuals = model.NewStats(residuals)
+
+	if fit.Count > 2 {
+		fit.Sigma = math.Sqrt(ssr / float64(fit.Count-2))
+	}
+
+	if syy > 0 {
+		fit.RSquared = 1 - ssr/syy
+	}
+
+	return fit, nil
+}
+
+// FitGateways fits a model for every gateway with a known location and
+// enough receptions, sorted by gateway id. The other gateways are returned
+// with the reason they were skipped.
+func FitGateways(receptions []model.Reception, locations map[string]model.LatLon, txPower float64) ([]PathLossFit, map[string]error) {
+	byGateway := make(map[string][]model.Reception)
+
+	for _, r := range receptions {
+		byGateway[r.GatewayID] = append(byGateway[r.GatewayID], r)
+	}
+
+	var fits []PathLossFit
+	skipped := make(map[string]error)
+
+	for id, rs := range byGateway {
+		location, ok := locations[id]
+		if !ok {
+			skipped[id] = errors.New("unknown gateway location")
+			continue
+		}
+
+		fit, err := FitPathLoss(id, location, rs, txPower)
+		if err != nil {
+			skipped[id] = err
+			continue
+		}
+
+		fits = append(fits, fit)
+	}
+
+	sort.Slice(fits, func(i, j int) bool {
+		return fits[i].GatewayID < fits[j].GatewayID
+	})
+
+	return fits, skipped
+}
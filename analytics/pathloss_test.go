@@ -0,0 +1,85 @@
+// The MIT License (MIT)
+//
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package analytics
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/bullettime/lora-mapper/model"
+)
+
+func TestFitPathLoss(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	gateway := model.LatLon{Latitude: 51, Longitude: 4}
+
+	var receptions []model.Reception
+
+	for i := 0; i < 500; i++ {
+		// between 100 m and 10 km to the north east
+		d := 100 * math.Pow(100, r.Float64())
+		ll := model.LatLon{
+			Latitude:  gateway.Latitude + d/model.LatitudeDegreeInMeters/math.Sqrt2,
+			Longitude: gateway.Longitude + d/model.LatitudeDegreeInMeters/math.Sqrt2/math.Cos(51*math.Pi/180),
+		}
+		d = gateway.Distance(ll) * 1000
+
+		loss := 120 + 10*3.2*math.Log10(d/1000) + r.NormFloat64()*6
+
+		receptions = append(receptions, model.Reception{
+			Location:  ll,
+			GatewayID: "gw",
+			TxPower:   math.NaN(),
+			RSSI:      14 - loss,
+		})
+	}
+
+	fits, skipped := FitGateways(receptions, map[string]model.LatLon{"gw": gateway}, 14)
+	if len(fits) != 1 || len(skipped) != 0 {
+		t.Fatalf("got %d fits and skipped %v", len(fits), skipped)
+	}
+
+	fit := fits[0]
+
+	if math.Abs(fit.ReferenceLoss-120) > 1 || math.Abs(fit.Exponent-3.2) > 0.15 || math.Abs(fit.Sigma-6) > 0.6 {
+		t.Errorf("unexpected fit: reference loss %v, exponent %v, sigma %v", fit.ReferenceLoss, fit.Exponent, fit.Sigma)
+	}
+
+	if math.Abs(fit.Residuals.Mean) > 1e-6 {
+		t.Errorf("mean residual is %v", fit.Residuals.Mean)
+	}
+
+	if d := fit.Distance(fit.PathLoss(2500)); math.Abs(d-2500) > 1e-6 {
+		t.Errorf("distance of the path loss at 2500 m is %v", d)
+	}
+
+	_, skipped = FitGateways(receptions, nil, 14)
+	if skipped["gw"] == nil {
+		t.Error("gateway without location should be skipped")
+	}
+
+	if _, err := FitPathLoss("gw", gateway, receptions[:2], 14); err != ErrNotEnoughSamples {
+		t.Errorf("two receptions should give %v, got %v", ErrNotEnoughSamples, err)
+	}
+}
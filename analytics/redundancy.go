@@ -0,0 +1,233 @@
+// The MIT License (MIT)
+//
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package analytics
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/bullettime/lora-mapper/model"
+	"github.com/paulmach/go.geojson"
+)
+
+// GatewayRSSI is the median rssi (in dBm) of the receptions of a gateway in a
+// cell.
+type GatewayRSSI struct {
+	GatewayID string  `json:"gateway_id"`
+	RSSI      float64 `json:"rssi"`
+	Count     int     `json:"count"`
+}
+
+// RedundancyCell holds the distinct gateways that received in a cell, in
+// total and per data rate, and the best and second best gateway by rssi.
+type RedundancyCell struct {
+	Cell      model.CellID   `json:"cell"`
+	Center    model.LatLon   `json:"center"`
+	Count     int            `json:"count"`
+	Gateways  int            `json:"gateways"`
+	DataRates map[string]int `json:"data_rates"`
+	Best      GatewayRSSI    `json:"best"`
+	Second    *GatewayRSSI   `json:"second,omitempty"`
+}
+
+// DataRateRedundancy counts the cells with receptions at a data rate, by one
+// gateway only and by more than one.
+type DataRateRedundancy struct {
+	Cells         int `json:"cells"`
+	SingleGateway int `json:"single_gateway"`
+	Redundant     int `json:"redundant"`
+}
+
+// RedundancySummary has the number of cells per number of gateways, the
+// cells that depend on a single gateway (in total and per gateway) and the
+// redundancy per data rate.
+type RedundancySummary struct {
+	Cells              int                           `json:"cells"`
+	Histogram          map[int]int                   `json:"histogram"`
+	MeanGateways       float64                       `json:"mean_gateways"`
+	SingleGateway      int                           `json:"single_gateway"`
+	SingleGatewayShare float64                       `json:"single_gateway_share"`
+	Dependencies       map[string]int                `json:"dependencies"`
+	DataRates          map[string]DataRateRedundancy `json:"data_rates"`
+}
+
+// Redundancy returns the gateway redundancy of every cell of the grid with at
+// least one reception, sorted by id.
+func Redundancy(grid model.Grid, receptions []model.Reception) []RedundancyCell {
+	type bin struct {
+		count     int
+		rssi      map[string][]float64
+		dataRates map[string]map[string]bool
+	}
+
+	bins := make(map[model.CellID]*bin)
+	var ids []model.CellID
+
+	for _, r := range receptions {
+		id := grid.Cell(r.Location)
+
+		b, ok := bins[id]
+		if !ok {
+			b = &bin{
+				rssi:      make(map[string][]float64),
+				dataRates: make(map[string]map[string]bool),
+			}
+			bins[id] = b
+			ids = append(ids, id)
+		}
+
+		b.count++
+		b.rssi[r.GatewayID] = append(b.rssi[r.GatewayID], r.RSSI)
+
+		if b.dataRates[r.DataRate] == nil {
+			b.dataRates[r.DataRate] = make(map[string]bool)
+		}
+		b.dataRates[r.DataRate][r.GatewayID] = true
+	}
+
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i].Less(ids[j])
+	})
+
+	cells := make([]RedundancyCell, 0, len(ids))
+
+	for _, id := range ids {
+		b := bins[id]
+
+		var gateways []GatewayRSSI
+		for g, values := range b.rssi {
+			gateways = append(gateways, GatewayRSSI{
+				GatewayID: g,
+				RSSI:      model.NewStats(values).Median,
+				Count:     len(values),
+			})
+		}
+
+		sort.Slice(gateways, func(i, j int) bool {
+			if gateways[i].RSSI != gateways[j].RSSI {
+				return gateways[i].RSSI > gateways[j].RSSI
+			}
+			return gateways[i].GatewayID < gateways[j].GatewayID
+		})
+
+		c := RedundancyCell{
+			Cell:      id,
+			Center:    grid.Center(id),
+			Count:     b.count,
+			Gateways:  len(gateways),
+			DataRates: make(map[string]int, len(b.dataRates)),
+			Best:      gateways[0],
+		}
+
+		if len(gateways) > 1 {
+			second := gateways[1]
+			c.Second = &second
+		}
+
+		for dataRate, g := range b.dataRates {
+			c.DataRates[dataRate] = len(g)
+		}
+
+		cells = append(cells, c)
+	}
+
+	return cells
+}
+
+// SummarizeRedundancy returns the summary of the cells.
+func SummarizeRedundancy(cells []RedundancyCell) RedundancySummary {
+	s := RedundancySummary{
+		Cells:        len(cells),
+		Histogram:    make(map[int]int),
+		Dependencies: make(map[string]int),
+		DataRates:    make(map[string]DataRateRedundancy),
+	}
+
+	total := 0
+
+	for _, c := range cells {
+		s.Histogram[c.Gateways]++
+		total += c.Gateways
+
+		if c.Gateways == 1 {
+			s.SingleGateway++
+			s.Dependencies[c.Best.GatewayID]++
+		}
+
+		for dataRate, n := range c.DataRates {
+			d := s.DataRates[dataRate]
+			d.Cells++
+			if n == 1 {
+				d.SingleGateway++
+			} else {
+				d.Redundant++
+			}
+			s.DataRates[dataRate] = d
+		}
+	}
+
+	if len(cells) > 0 {
+		s.MeanGateways = round(float64(total)/float64(len(cells)), 2)
+		s.SingleGatewayShare = round(float64(s.SingleGateway)/float64(len(cells)), 3)
+	}
+
+	return s
+}
+
+// RedundancyGeoJSON returns the cells as GeoJSON polygons (in [lon, lat]
+// order) with the number of gateways, in total and per data rate (eg.
+// gateways_sf7), and the best and second best gateway.
+func RedundancyGeoJSON(grid model.Grid, cells []RedundancyCell, callback string) (string, error) {
+	fc := geojson.NewFeatureCollection()
+
+	for _, c := range cells {
+		feature := geojson.NewFeature(model.CellPolygon(grid, c.Cell))
+		feature.SetProperty("cell", c.Cell.String())
+		feature.SetProperty("count", c.Count)
+		feature.SetProperty("gateways", c.Gateways)
+		feature.SetProperty("single_gateway", c.Gateways == 1)
+		feature.SetProperty("best_gateway", c.Best.GatewayID)
+		feature.SetProperty("best_rssi", round(c.Best.RSSI, 2))
+
+		if c.Second != nil {
+			feature.SetProperty("second_gateway", c.Second.GatewayID)
+			feature.SetProperty("second_rssi", round(c.Second.RSSI, 2))
+		}
+
+		for dataRate, n := range c.DataRates {
+			if sf, err := model.SpreadingFactor(dataRate); err == nil {
+				feature.SetProperty(fmt.Sprintf("gateways_sf%d", sf), n)
+			}
+		}
+
+		fc.AddFeature(feature)
+	}
+
+	return model.FeatureCollectionJSON(fc, callback)
+}
+
+func round(v float64, decimals int) float64 {
+	p := math.Pow(10, float64(decimals))
+	return math.Floor(v*p+0.5) / p
+}
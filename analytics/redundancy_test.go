@@ -0,0 +1,74 @@
+// The MIT License (MIT)
+//
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package analytics
+
+import (
+	"testing"
+
+	"github.com/bullettime/lora-mapper/model"
+)
+
+func TestRedundancy(t *testing.T) {
+	a := model.LatLon{Latitude: 51.0001, Longitude: 4.7001}
+	b := model.LatLon{Latitude: 51.0101, Longitude: 4.7001}
+
+	receptions := []model.Reception{
+		{Location: a, GatewayID: "g1", DataRate: "SF7BW125", RSSI: -100},
+		{Location: a, GatewayID: "g1", DataRate: "SF7BW125", RSSI: -90},
+		{Location: a, GatewayID: "g2", DataRate: "SF7BW125", RSSI: -110},
+		{Location: a, GatewayID: "g2", DataRate: "SF12BW125", RSSI: -120},
+		{Location: b, GatewayID: "g2", DataRate: "SF9BW125", RSSI: -115},
+	}
+
+	grid, err := model.NewGrid(model.ShapeSquare, 100, model.LatLon{Latitude: 51, Longitude: 4.7})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cells := Redundancy(grid, receptions)
+	if len(cells) != 2 {
+		t.Fatalf("expected 2 cells, got %d", len(cells))
+	}
+
+	c := cells[0]
+	if c.Gateways != 2 || c.DataRates["SF7BW125"] != 2 || c.DataRates["SF12BW125"] != 1 {
+		t.Errorf("unexpected cell %+v", c)
+	}
+
+	if c.Best.GatewayID != "g1" || c.Best.RSSI != -95 || c.Second == nil || c.Second.GatewayID != "g2" || c.Second.RSSI != -115 {
+		t.Errorf("unexpected best %+v and second %+v", c.Best, c.Second)
+	}
+
+	if cells[1].Second != nil {
+		t.Error("expected no second gateway")
+	}
+
+	s := SummarizeRedundancy(cells)
+	if s.SingleGateway != 1 || s.Dependencies["g2"] != 1 || s.Histogram[2] != 1 || s.MeanGateways != 1.5 {
+		t.Errorf("unexpected summary %+v", s)
+	}
+
+	if d := s.DataRates["SF7BW125"]; d.Cells != 1 || d.Redundant != 1 {
+		t.Errorf("unexpected SF7 redundancy %+v", d)
+	}
+}
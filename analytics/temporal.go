@@ -0,0 +1,334 @@
+// The MIT License (MIT)
+//
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package analytics
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/bullettime/lora-mapper/model"
+	"github.com/paulmach/go.geojson"
+	"github.com/pkg/errors"
+)
+
+const (
+	BucketHour    = "hour"
+	BucketWeekday = "weekday"
+	BucketMonth   = "month"
+
+	// DefaultTemporalThreshold is the range of the median rssi of the buckets
+	// in dB of a significant variation.
+	DefaultTemporalThreshold = 6.0
+	// DefaultTemporalMinCount is the number of receptions of a bucket to be
+	// compared.
+	DefaultTemporalMinCount = 5
+)
+
+// TemporalOptions of the temporal analysis: the bucket (hour, weekday or
+// month) in the time zone (UTC when not set). Buckets need MinCount
+// receptions to be compared, a variation is significant when the median rssi
+// of the buckets differs by Threshold dB or the best spreading factor
+// changes.
+type TemporalOptions struct {
+	Bucket    string
+	TimeZone  *time.Location
+	MinCount  int
+	Threshold float64
+}
+
+func DefaultTemporalOptions() TemporalOptions {
+	return TemporalOptions{
+		Bucket:    BucketHour,
+		MinCount:  DefaultTemporalMinCount,
+		Threshold: DefaultTemporalThreshold,
+	}
+}
+
+func (o TemporalOptions) Validate() error {
+	switch o.Bucket {
+	case BucketHour, BucketWeekday, BucketMonth:
+		return nil
+	}
+
+	return errors.Errorf("invalid bucket: %s", o.Bucket)
+}
+
+// bucket returns the bucket of the time: the hour (0-23), weekday (0 is
+// sunday) or month (1-12).
+func (o TemporalOptions) bucket(t time.Time) int {
+	if o.TimeZone != nil {
+		t = t.In(o.TimeZone)
+	} else {
+		t = t.UTC()
+	}
+
+	switch o.Bucket {
+	case BucketWeekday:
+		return int(t.Weekday())
+	case BucketMonth:
+		return int(t.Month())
+	}
+
+	return t.Hour()
+}
+
+// BucketLabel returns the label of a bucket, eg. "08h", "Monday" or "June".
+func BucketLabel(bucket string, b int) string {
+	switch bucket {
+	case BucketWeekday:
+		return time.Weekday(b).String()
+	case BucketMonth:
+		return time.Month(b).String()
+	}
+
+	return fmt.Sprintf("%02dh", b)
+}
+
+// TemporalBucket holds the receptions of a bucket, the rssi is the median.
+type TemporalBucket struct {
+	Bucket int     `json:"bucket"`
+	Label  string  `json:"label"`
+	Count  int     `json:"count"`
+	RSSI   float64 `json:"rssi"`
+	SNR    float64 `json:"snr"`
+	BestSF int     `json:"best_sf"`
+}
+
+// TemporalVariation holds the buckets of a cell or gateway and the variation
+// between the buckets with enough receptions: the range of the median rssi
+// (best minus worst bucket) and of the best spreading factor.
+type TemporalVariation struct {
+	Count    int              `json:"count"`
+	Buckets  []TemporalBucket `json:"buckets"`
+	Compared int              `json:"compared"`
+	Range    float64          `json:"range"`
+	SFRange  int              `json:"sf_range"`
+	Best     string           `json:"best,omitempty"`
+	Worst    string           `json:"worst,omitempty"`
+	Flagged  bool             `json:"flagged"`
+}
+
+type TemporalCell struct {
+	Cell   model.CellID `json:"cell"`
+	Center model.LatLon `json:"center"`
+	TemporalVariation
+}
+
+type TemporalGateway struct {
+	GatewayID string `json:"gateway_id"`
+	TemporalVariation
+}
+
+// TemporalSummary has the profile of all receptions per bucket and the
+// number of cells that were compared and flagged.
+type TemporalSummary struct {
+	Bucket   string           `json:"bucket"`
+	Profile  []TemporalBucket `json:"profile"`
+	Cells    int              `json:"cells"`
+	Compared int              `json:"compared"`
+	Flagged  int              `json:"flagged"`
+}
+
+// Temporal is the temporal analysis per cell, sorted by id, and per gateway,
+// sorted by id.
+type Temporal struct {
+	Grid     model.Grid        `json:"-"`
+	Summary  TemporalSummary   `json:"summary"`
+	Cells    []TemporalCell    `json:"cells"`
+	Gateways []TemporalGateway `json:"gateways"`
+}
+
+// variation buckets the receptions.
+func variation(receptions []model.Reception, options TemporalOptions) TemporalVariation {
+	type bin struct {
+		rssi []float64
+		snr  []float64
+		sf   int
+	}
+
+	bins := make(map[int]*bin)
+
+	for _, r := range receptions {
+		b := options.bucket(r.Time)
+
+		if bins[b] == nil {
+			bins[b] = &bin{sf: 13}
+		}
+
+		bins[b].rssi = append(bins[b].rssi, r.RSSI)
+		bins[b].snr = append(bins[b].snr, r.SNR)
+
+		if r.SF < bins[b].sf {
+			bins[b].sf = r.SF
+		}
+	}
+
+	v := TemporalVariation{
+		Count:   len(receptions),
+		Buckets: make([]TemporalBucket, 0, len(bins)),
+	}
+
+	for b, values := range bins {
+		v.Buckets = append(v.Buckets, TemporalBucket{
+			Bucket: b,
+			Label:  BucketLabel(options.Bucket, b),
+			Count:  len(values.rssi),
+			RSSI:   round(model.NewStats(values.rssi).Median, 2),
+			SNR:    round(model.NewStats(values.snr).Median, 2),
+			BestSF: values.sf,
+		})
+	}
+
+	sort.Slice(v.Buckets, func(i, j int) bool {
+		return v.Buckets[i].Bucket < v.Buckets[j].Bucket
+	})
+
+	best, worst := -1, -1
+	minSF, maxSF := 13, 0
+
+	for i, b := range v.Buckets {
+		if b.Count < options.MinCount {
+			continue
+		}
+
+		v.Compared++
+
+		if best < 0 || b.RSSI > v.Buckets[best].RSSI {
+			best = i
+		}
+
+		if worst < 0 || b.RSSI < v.Buckets[worst].RSSI {
+			worst = i
+		}
+
+		if b.BestSF < minSF {
+			minSF = b.BestSF
+		}
+
+		if b.BestSF > maxSF {
+			maxSF = b.BestSF
+		}
+	}
+
+	if v.Compared < 2 {
+		return v
+	}
+
+	v.Range = round(v.Buckets[best].RSSI-v.Buckets[worst].RSSI, 2)
+	v.SFRange = maxSF - minSF
+	v.Best = v.Buckets[best].Label
+	v.Worst = v.Buckets[worst].Label
+	v.Flagged = v.Range >= options.Threshold || v.SFRange > 0
+
+	return v
+}
+
+// AnalyseTemporal buckets the receptions per cell of the grid and per
+// gateway, and flags the cells and gateways with a significant variation
+// between the buckets.
+func AnalyseTemporal(grid model.Grid, receptions []model.Reception, options TemporalOptions) (*Temporal, error) {
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
+
+	cells := make(map[model.CellID][]model.Reception)
+	gateways := make(map[string][]model.Reception)
+
+	for _, r := range receptions {
+		id := grid.Cell(r.Location)
+		cells[id] = append(cells[id], r)
+		gateways[r.GatewayID] = append(gateways[r.GatewayID], r)
+	}
+
+	profile := options
+	profile.MinCount = 0
+
+	t := &Temporal{
+		Grid: grid,
+		Summary: TemporalSummary{
+			Bucket:  options.Bucket,
+			Profile: variation(receptions, profile).Buckets,
+			Cells:   len(cells),
+		},
+		Cells:    make([]TemporalCell, 0, len(cells)),
+		Gateways: make([]TemporalGateway, 0, len(gateways)),
+	}
+
+	for id, r := range cells {
+		c := TemporalCell{Cell: id, Center: grid.Center(id), TemporalVariation: variation(r, options)}
+
+		if c.Compared >= 2 {
+			t.Summary.Compared++
+		}
+
+		if c.Flagged {
+			t.Summary.Flagged++
+		}
+
+		t.Cells = append(t.Cells, c)
+	}
+
+	sort.Slice(t.Cells, func(i, j int) bool {
+		return t.Cells[i].Cell.Less(t.Cells[j].Cell)
+	})
+
+	for id, r := range gateways {
+		t.Gateways = append(t.Gateways, TemporalGateway{GatewayID: id, TemporalVariation: variation(r, options)})
+	}
+
+	sort.Slice(t.Gateways, func(i, j int) bool {
+		return t.Gateways[i].GatewayID < t.Gateways[j].GatewayID
+	})
+
+	return t, nil
+}
+
+// GeoJSON returns the cells as GeoJSON polygons (in [lon, lat] order) with
+// the variation and the median rssi of every bucket (eg. rssi_08h).
+func (t *Temporal) GeoJSON(callback string) (string, error) {
+	fc := geojson.NewFeatureCollection()
+
+	for _, c := range t.Cells {
+		feature := geojson.NewFeature(model.CellPolygon(t.Grid, c.Cell))
+		feature.SetProperty("cell", c.Cell.String())
+		feature.SetProperty("count", c.Count)
+		feature.SetProperty("compared", c.Compared)
+		feature.SetProperty("range", c.Range)
+		feature.SetProperty("sf_range", c.SFRange)
+		feature.SetProperty("flagged", c.Flagged)
+
+		if c.Compared >= 2 {
+			feature.SetProperty("best", c.Best)
+			feature.SetProperty("worst", c.Worst)
+		}
+
+		for _, b := range c.Buckets {
+			feature.SetProperty("rssi_"+b.Label, b.RSSI)
+		}
+
+		fc.AddFeature(feature)
+	}
+
+	return model.FeatureCollectionJSON(fc, callback)
+}
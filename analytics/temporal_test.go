@@ -0,0 +1,90 @@
+// The MIT License (MIT)
+//
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package analytics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bullettime/lora-mapper/model"
+)
+
+func TestAnalyseTemporal(t *testing.T) {
+	grid, err := model.NewGrid(model.ShapeSquare, 100, model.LatLon{Latitude: 51, Longitude: 4.7})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	day := time.Date(2018, 6, 4, 0, 0, 0, 0, time.UTC)
+	a := grid.Center(model.CellID{X: 0, Y: 0})
+	b := grid.Center(model.CellID{X: 5, Y: 0})
+
+	var receptions []model.Reception
+	for i := 0; i < 5; i++ {
+		// cell a is 10 dB worse during the day, cell b is stable
+		receptions = append(receptions,
+			model.Reception{Location: a, GatewayID: "g1", SF: 7, RSSI: -100, Time: day.Add(3 * time.Hour)},
+			model.Reception{Location: a, GatewayID: "g1", SF: 7, RSSI: -110, Time: day.Add(14 * time.Hour)},
+			model.Reception{Location: b, GatewayID: "g2", SF: 9, RSSI: -115, Time: day.Add(3 * time.Hour)},
+			model.Reception{Location: b, GatewayID: "g2", SF: 9, RSSI: -117, Time: day.Add(14 * time.Hour)},
+		)
+	}
+	// too few receptions to compare
+	receptions = append(receptions, model.Reception{Location: b, GatewayID: "g2", SF: 12, RSSI: -130, Time: day.Add(20 * time.Hour)})
+
+	temporal, err := AnalyseTemporal(grid, receptions, DefaultTemporalOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(temporal.Cells) != 2 || temporal.Summary.Compared != 2 || temporal.Summary.Flagged != 1 || len(temporal.Summary.Profile) != 3 {
+		t.Fatalf("unexpected summary %+v", temporal.Summary)
+	}
+
+	c := temporal.Cells[0]
+	if !c.Flagged || c.Range != 10 || c.Best != "03h" || c.Worst != "14h" || c.SFRange != 0 {
+		t.Errorf("unexpected cell %+v", c)
+	}
+
+	if c := temporal.Cells[1]; c.Flagged || c.Compared != 2 || len(c.Buckets) != 3 || c.Range != 2 {
+		t.Errorf("unexpected cell %+v", c)
+	}
+
+	if len(temporal.Gateways) != 2 || !temporal.Gateways[0].Flagged || temporal.Gateways[1].Flagged {
+		t.Errorf("unexpected gateways %+v", temporal.Gateways)
+	}
+
+	options := DefaultTemporalOptions()
+	options.Bucket = BucketWeekday
+	options.MinCount = 1
+
+	temporal, err = AnalyseTemporal(grid, receptions, options)
+	if err != nil || len(temporal.Summary.Profile) != 1 || temporal.Summary.Profile[0].Label != "Monday" {
+		t.Errorf("unexpected weekday profile %+v %v", temporal, err)
+	}
+
+	options.Bucket = "year"
+	if _, err := AnalyseTemporal(grid, receptions, options); err == nil {
+		t.Error("expected an error for an invalid bucket")
+	}
+}
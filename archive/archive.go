@@ -0,0 +1,404 @@
+// The MIT License (MIT)
+//
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package archive
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/bullettime/lora-mapper/model"
+	"github.com/pkg/errors"
+)
+
+const (
+	Format  = "lora-mapper-archive"
+	Version = 1
+
+	InfluxExport = `select * from %s%s group by * slimit %d soffset %d`
+
+	// exportSeries is the amount of series per export query, so that the
+	// measurement isn't loaded into memory at once
+	exportSeries = 1000
+)
+
+const (
+	TypeInteger = "integer"
+	TypeFloat   = "float"
+	TypeString  = "string"
+	TypeBoolean = "boolean"
+)
+
+var (
+	ErrFormat  = errors.New("not a lora-mapper archive")
+	ErrVersion = errors.New("unsupported archive version")
+)
+
+// Query results don't tell integers and floats apart when a float happens to
+// be integral, these fields are always restored as floats.
+var floatFields = map[string]bool{
+	"snr": true,
+}
+
+// An archive is a gzip'd JSON Lines file: the first line holds the header,
+// every following line one metric.
+type Header struct {
+	Format      string            `json:"format"`
+	Version     int               `json:"version"`
+	Measurement string            `json:"measurement"`
+	Created     time.Time         `json:"created"`
+	Count       int               `json:"count"`
+	Fields      map[string]string `json:"fields"`
+}
+
+type record struct {
+	Name   string                 `json:"name"`
+	Tags   map[string]string      `json:"tags"`
+	Fields map[string]interface{} `json:"fields"`
+	Time   time.Time              `json:"time"`
+}
+
+type Writer struct {
+	gz  *gzip.Writer
+	buf *bufio.Writer
+	enc *json.Encoder
+}
+
+func NewWriter(w io.Writer, header Header) (*Writer, error) {
+	gz := gzip.NewWriter(w)
+	buf := bufio.NewWriter(gz)
+
+	aw := &Writer{
+		gz:  gz,
+		buf: buf,
+		enc: json.NewEncoder(buf),
+	}
+
+	header.Format = Format
+	header.Version = Version
+
+	if err := aw.enc.Encode(header); err != nil {
+		return nil, errors.Wrap(err, "[Archive] error writing header")
+	}
+
+	return aw, nil
+}
+
+func (w *Writer) Write(m model.Metric) error {
+	r := record{
+		Name:   m.Name(),
+		Tags:   m.Tags(),
+		Fields: make(map[string]interface{}, len(m.Fields())),
+		Time:   m.Time(),
+	}
+
+	for k, v := range m.Fields() {
+		if v != nil {
+			r.Fields[k] = v
+		}
+	}
+
+	if err := w.enc.Encode(r); err != nil {
+		return errors.Wrap(err, "[Archive] error writing metric")
+	}
+
+	return nil
+}
+
+func (w *Writer) Close() error {
+	if err := w.buf.Flush(); err != nil {
+		return errors.Wrap(err, "[Archive] error flushing")
+	}
+
+	return w.gz.Close()
+}
+
+type Reader struct {
+	gz     *gzip.Reader
+	dec    *json.Decoder
+	header Header
+}
+
+func NewReader(r io.Reader) (*Reader, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, errors.Wrap(ErrFormat, err.Error())
+	}
+
+	ar := &Reader{
+		gz:  gz,
+		dec: json.NewDecoder(gz),
+	}
+	ar.dec.UseNumber()
+
+	if err := ar.dec.Decode(&ar.header); err != nil {
+		return nil, errors.Wrap(ErrFormat, err.Error())
+	}
+
+	if ar.header.Format != Format {
+		return nil, ErrFormat
+	}
+
+	if ar.header.Version != Version {
+		return nil, errors.Wrapf(ErrVersion, "version %d", ar.header.Version)
+	}
+
+	return ar, nil
+}
+
+func (r *Reader) Header() Header {
+	return r.header
+}
+
+// Read returns the next metric in the archive, or io.EOF when there are none
+// left.
+func (r *Reader) Read() (model.Metric, error) {
+	var rec record
+
+	if err := r.dec.Decode(&rec); err != nil {
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		return nil, errors.Wrap(err, "[Archive] error reading metric")
+	}
+
+	for k, v := range rec.Fields {
+		value, err := convert(v, r.header.Fields[k])
+		if err != nil {
+			return nil, errors.Wrapf(err, "[Archive] field %s", k)
+		}
+		rec.Fields[k] = value
+	}
+
+	if rec.Tags == nil {
+		rec.Tags = make(map[string]string)
+	}
+
+	return model.NewMetric(rec.Name, rec.Tags, rec.Fields, rec.Time)
+}
+
+func (r *Reader) Close() error {
+	return r.gz.Close()
+}
+
+// Export writes every metric of the measurement that matches the filter to w
+// and returns the amount of metrics written. The metrics are queried twice
+// by pages of series: first for the count and field types of the header,
+// then to write them.
+func Export(w io.Writer, db model.Database, measurement string, filter model.Filter) (int, error) {
+	var count int
+
+	types := make(map[string]string)
+
+	err := each(db, measurement, filter, func(metric model.Metric) error {
+		count++
+		addFieldTypes(types, metric)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	aw, err := NewWriter(w, Header{
+		Measurement: measurement,
+		Created:     time.Now().UTC(),
+		Count:       count,
+		Fields:      types,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	count = 0
+
+	err = each(db, measurement, filter, func(metric model.Metric) error {
+		count++
+		return aw.Write(metric)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return count, aw.Close()
+}
+
+// each calls fn with every metric of the measurement that matches the filter,
+// querying exportSeries series at a time.
+func each(db model.Database, measurement string, filter model.Filter, fn func(model.Metric) error) error {
+	where := ""
+	if condition := filter.Condition(); condition != "" {
+		where = " where " + condition
+	}
+
+	for offset := 0; ; offset += exportSeries {
+		series, err := db.Query(fmt.Sprintf(InfluxExport, measurement, where, exportSeries, offset))
+		if err != nil {
+			return errors.Wrap(err, "[Archive] error querying metrics")
+		}
+
+		for _, serie := range series {
+			for _, metric := range serie {
+				if !filter.Match(metric) {
+					continue
+				}
+
+				if err := fn(metric); err != nil {
+					return err
+				}
+			}
+		}
+
+		if len(series) < exportSeries {
+			return nil
+		}
+	}
+}
+
+// Import writes the metrics of an archive that match the filter to the
+// database in batches. When measurement is empty the measurement stored in the
+// archive is used.
+func Import(r io.Reader, db model.Database, measurement string, filter model.Filter, batchSize int) (int, error) {
+	var batch []model.Metric
+	var count int
+
+	ar, err := NewReader(r)
+	if err != nil {
+		return 0, err
+	}
+	defer ar.Close()
+
+	if measurement == "" {
+		measurement = ar.Header().Measurement
+	}
+
+	if batchSize <= 0 {
+		batchSize = 5000
+	}
+
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := db.Write(batch); err != nil {
+			return errors.Wrap(err, "[Archive] error writing metrics")
+		}
+		log.WithField("amount", len(batch)).Debug("[Archive] batch written")
+		count += len(batch)
+		batch = batch[:0]
+		return nil
+	}
+
+	for {
+		metric, err := ar.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return count, err
+		}
+
+		if !filter.Match(metric) {
+			continue
+		}
+
+		m, err := model.NewMetric(measurement, metric.Tags(), metric.Fields(), metric.Time())
+		if err != nil {
+			log.WithError(err).Warn("[Archive] skipping metric")
+			continue
+		}
+
+		batch = append(batch, m)
+
+		if len(batch) >= batchSize {
+			if err := flush(); err != nil {
+				return count, err
+			}
+		}
+	}
+
+	return count, flush()
+}
+
+// addFieldTypes adds the types of the fields of the metric, a field that is
+// a float in any metric stays a float.
+func addFieldTypes(types map[string]string, metric model.Metric) {
+	for k, v := range metric.Fields() {
+		t := valueType(k, v)
+		if t == "" || types[k] == TypeFloat {
+			continue
+		}
+		types[k] = t
+	}
+}
+
+func valueType(key string, v interface{}) string {
+	switch value := v.(type) {
+	case string:
+		return TypeString
+	case bool:
+		return TypeBoolean
+	case float32, float64:
+		return TypeFloat
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		if floatFields[key] {
+			return TypeFloat
+		}
+		return TypeInteger
+	case json.Number:
+		if floatFields[key] {
+			return TypeFloat
+		}
+		if _, err := value.Int64(); err == nil {
+			return TypeInteger
+		}
+		return TypeFloat
+	}
+
+	return ""
+}
+
+func convert(v interface{}, t string) (interface{}, error) {
+	n, ok := v.(json.Number)
+	if !ok {
+		return v, nil
+	}
+
+	switch t {
+	case TypeInteger:
+		if i, err := n.Int64(); err == nil {
+			return i, nil
+		}
+		f, err := n.Float64()
+		if err != nil {
+			return nil, err
+		}
+		return int64(math.Round(f)), nil
+	default:
+		return n.Float64()
+	}
+}
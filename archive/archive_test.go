@@ -0,0 +1,130 @@
+// The MIT License (MIT)
+//
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package archive
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/bullettime/lora-mapper/model"
+)
+
+type memoryDB struct {
+	metrics []model.Metric
+	written []model.Metric
+}
+
+func (db *memoryDB) Connect() error { return nil }
+
+func (db *memoryDB) Write(metrics []model.Metric) error {
+	db.written = append(db.written, metrics...)
+	return nil
+}
+
+func (db *memoryDB) Query(string) ([][]model.Metric, error) {
+	return [][]model.Metric{db.metrics}, nil
+}
+
+func (db *memoryDB) HasMetric(model.Metric, time.Time) bool { return false }
+
+func (db *memoryDB) Close() error { return nil }
+
+func newMetric(t *testing.T, device string, lat string, fields map[string]interface{}, ts time.Time) model.Metric {
+	m, err := model.NewMetric("coverage", map[string]string{
+		"device_id":  device,
+		"gateway_id": "008000000000b88d",
+		"latitude":   lat,
+		"longitude":  "4.7134",
+		"data_rate":  "SF7BW125",
+	}, fields, ts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestExportImport(t *testing.T) {
+	ts := time.Date(2018, 4, 1, 12, 0, 0, 0, time.UTC)
+
+	src := &memoryDB{metrics: []model.Metric{
+		newMetric(t, "a", "51.0017", map[string]interface{}{"rssi": json.Number("-97"), "snr": json.Number("7"), "size": json.Number("7")}, ts),
+		newMetric(t, "b", "51.0018", map[string]interface{}{"rssi": json.Number("-110"), "snr": json.Number("-3.5"), "size": json.Number("7")}, ts.Add(time.Minute)),
+		newMetric(t, "a", "52.0000", map[string]interface{}{"rssi": json.Number("-120"), "snr": json.Number("-12.25"), "size": json.Number("7")}, ts.Add(time.Hour)),
+	}}
+
+	var buf bytes.Buffer
+
+	n, err := Export(&buf, src, "coverage", model.Filter{
+		BoundingBox: &model.BoundingBox{MinLatitude: 51, MinLongitude: 4, MaxLatitude: 51.5, MaxLongitude: 5},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Fatalf("expected 2 exported metrics, got %d", n)
+	}
+
+	ar, err := NewReader(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h := ar.Header(); h.Count != 2 || h.Fields["rssi"] != TypeInteger || h.Fields["snr"] != TypeFloat {
+		t.Errorf("unexpected header %+v", h)
+	}
+
+	dst := &memoryDB{}
+
+	n, err = Import(&buf, dst, "restored", model.Filter{DeviceIDs: []string{"a"}}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 || len(dst.written) != 1 {
+		t.Fatalf("expected 1 imported metric, got %d", n)
+	}
+
+	m := dst.written[0]
+
+	if m.Name() != "restored" {
+		t.Errorf("expected measurement restored, got %s", m.Name())
+	}
+	if !m.Time().Equal(ts) {
+		t.Errorf("expected time %v, got %v", ts, m.Time())
+	}
+	if m.Tags()["gateway_id"] != "008000000000b88d" {
+		t.Error("gateway id tag was not restored")
+	}
+	if v, ok := m.Fields()["rssi"].(int64); !ok || v != -97 {
+		t.Errorf("expected integer rssi -97, got %#v", m.Fields()["rssi"])
+	}
+	if v, ok := m.Fields()["snr"].(float64); !ok || v != 7 {
+		t.Errorf("expected float snr 7, got %#v", m.Fields()["snr"])
+	}
+}
+
+func TestNewReader(t *testing.T) {
+	if _, err := NewReader(bytes.NewBufferString(`{"format":"lora-mapper-archive","version":1}`)); err == nil {
+		t.Error("uncompressed data should give an error")
+	}
+}
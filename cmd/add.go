@@ -35,6 +35,7 @@ import (
 
 var (
 	deviceID   string
+	campaignID string
 	timeString string
 )
 
@@ -43,13 +44,17 @@ var addCmd = &cobra.Command{
 	Use:   "add",
 	Short: "Add data from a file",
 	Long: `lora-mapper add will process the data stored in a csv file format and produced
-by the Sodaq-One logging device. It will only add the data that is missing in the database.
-This is necessary if you want to plot a coverage map that includes the points that were
-scanned and did not have reception.
+by the Sodaq-One logging device. Every data rate that was sent is added as a
+transmission attempt (to attempt.measurement, default attempts), the attempts
+that are already in the database are skipped. The attempts are needed for the
+packet delivery ratio, see lora-mapper pdr.
 
 This command takes one argument:
 	- file name from the csv file [eg. data.csv]
-It will parse the data and add the missing data to the influx database.`,
+It will parse the data and add the missing data to the influx database.
+Every metric is tagged with the id of an existing, not archived campaign: the
+--campaign, campaign.default in the config file or else the "default" campaign,
+which is created when missing.`,
 	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		influxOptions := influxdb.InfluxOptions{
@@ -90,6 +95,7 @@ func init() {
 	// is called directly, e.g.:
 	// addCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
 	addCmd.Flags().StringVar(&deviceID, "device-id", "", "adds the device id to data")
+	addCmd.Flags().StringVar(&campaignID, "campaign", "", "adds the campaign id to data (default is campaign.default from the config, or \"default\")")
 	addCmd.Flags().StringVar(&timeString, "time", "", "set the oldest time to compare data (in RFC3339 format)")
 }
 
@@ -101,10 +107,34 @@ func addDataFromCSV(fileName string, db model.Database) {
 
 	p := csv.New()
 
+	defaultTags := make(map[string]string)
+
 	if len(deviceID) > 0 {
-		p.SetDefaultTags(map[string]string{
-			"device_id": deviceID,
-		})
+		defaultTags["device_id"] = deviceID
+	}
+
+	if len(campaignID) == 0 {
+		campaignID = viper.GetString("campaign.default")
+	}
+
+	campaigns := getCampaigns(db)
+
+	// untagged data would drop out of the campaign maps and ddr
+	if len(campaignID) == 0 {
+		campaignID = model.DefaultCampaign
+
+		if err := campaigns.Create(model.Campaign{ID: campaignID}); err != nil && err != model.ErrCampaignExists {
+			log.WithError(err).Fatal("creating default campaign")
+		}
+	}
+
+	if err := campaigns.Active(campaignID); err != nil {
+		log.WithError(err).Fatal("invalid campaign")
+	}
+	defaultTags[model.CampaignTag] = campaignID
+
+	if len(defaultTags) > 0 {
+		p.SetDefaultTags(defaultTags)
 	}
 
 	if len(timeString) > 0 {
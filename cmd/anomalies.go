@@ -0,0 +1,175 @@
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/bullettime/lora-mapper/analytics"
+	"github.com/bullettime/lora-mapper/model"
+	"github.com/bullettime/lora-mapper/web/utils"
+	"github.com/spf13/cobra"
+)
+
+var (
+	anomaliesGrid   string
+	anomaliesNotify bool
+)
+
+// anomaliesCmd represents the anomalies command
+var anomaliesCmd = &cobra.Command{
+	Use:   "anomalies",
+	Short: "Report the gateways with a drop of the rssi or snr",
+	Long: `lora-mapper anomalies compares the receptions of every gateway in windows of
+--window with a rolling baseline: the receptions of the same gateway in the
+same grid cell during the --baseline before the window. It lists the windows
+with a significant drop of the rssi or snr, eg. caused by water in the antenna
+or a damaged cable.
+
+The period of the report is --from until --to (by default the last baseline
+before now), the receptions of the baseline before it are queried as well.
+With --notify the anomalies are also posted to anomaly.webhook from the config
+file. The defaults of the options are read from the anomaly section of the
+config file:
+	anomaly:
+	  window: 24h
+	  baseline: 336h
+	  minbaseline: 5
+	  mincells: 2
+	  mindrop: 3
+	  z: 3
+	  webhook: https://example.com/hooks/lora
+
+The data can be limited with the --campaign, --device, --gateway and --bbox flags.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		options := utils.AnomalyOptions()
+
+		if cmd.Flags().Changed("window") {
+			options.Window, _ = cmd.Flags().GetDuration("window")
+		}
+
+		if cmd.Flags().Changed("baseline") {
+			options.Baseline, _ = cmd.Flags().GetDuration("baseline")
+		}
+
+		if cmd.Flags().Changed("min-baseline") {
+			options.MinBaseline, _ = cmd.Flags().GetInt("min-baseline")
+		}
+
+		if cmd.Flags().Changed("min-cells") {
+			options.MinCells, _ = cmd.Flags().GetInt("min-cells")
+		}
+
+		if cmd.Flags().Changed("min-drop") {
+			options.MinDrop, _ = cmd.Flags().GetFloat64("min-drop")
+		}
+
+		if cmd.Flags().Changed("z") {
+			options.Z, _ = cmd.Flags().GetFloat64("z")
+		}
+
+		if options.Window <= 0 || options.Baseline <= 0 {
+			log.Fatal("the window and baseline need to be positive")
+		}
+
+		filter := getFilter()
+
+		from, to := filter.Start, filter.End
+		if from.IsZero() {
+			from = time.Now().UTC().Add(-options.Baseline).Truncate(options.Window)
+		}
+
+		filter.Start = from.Add(-options.Baseline)
+
+		db := connectDatabase()
+		defer db.Close()
+
+		receptions, err := model.GetReceptions(db, getMetricName(), filter)
+		if err != nil {
+			log.WithError(err).Fatal("querying receptions")
+		}
+
+		grid, err := getGridOptions(anomaliesGrid).NewGrid(model.ReceptionLocations(receptions))
+		if err != nil {
+			log.WithError(err).Fatal("invalid grid")
+		}
+
+		anomalies := analytics.DetectAnomalies(grid, receptions, from, to, options)
+
+		period := "now"
+		if !to.IsZero() {
+			period = to.Format(time.RFC3339)
+		}
+
+		fmt.Printf("%d anomalies from %s until %s (windows of %s, baseline of %s)\n\n",
+			len(anomalies), from.Format(time.RFC3339), period, options.Window, options.Baseline)
+
+		if len(anomalies) > 0 {
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "GATEWAY\tMETRIC\tSTART\tEND\tCELLS\tRECEPTIONS\tBASELINE\tDROP\tZ")
+			for _, a := range anomalies {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%d\t%.1f\t%.1f dB\t%.1f\n",
+					a.GatewayID, a.Metric, a.Start.Format(time.RFC3339), a.End.Format(time.RFC3339),
+					a.Cells, a.Receptions, a.Baseline, a.Drop, a.Z)
+			}
+			w.Flush()
+		}
+
+		if !anomaliesNotify {
+			return
+		}
+
+		// the log notifier would repeat the table
+		notifiers := utils.AnomalyNotifiers()[1:]
+		if len(notifiers) == 0 {
+			log.Fatal("anomaly.webhook is not set in the config file")
+		}
+
+		if err := analytics.Notify(anomalies, notifiers...); err != nil {
+			log.WithError(err).Fatal("sending anomalies")
+		}
+
+		log.WithField("anomalies", len(anomalies)).Info("anomalies sent")
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(anomaliesCmd)
+
+	defaults := analytics.DefaultAnomalyOptions()
+
+	anomaliesCmd.Flags().Duration("window", defaults.Window, "length of the windows (default is anomaly.window from the config or 24h)")
+	anomaliesCmd.Flags().Duration("baseline", defaults.Baseline, "length of the baseline before a window (default is anomaly.baseline from the config or 336h)")
+	anomaliesCmd.Flags().Int("min-baseline", defaults.MinBaseline, "minimum number of receptions of a cell in the baseline")
+	anomaliesCmd.Flags().Int("min-cells", defaults.MinCells, "minimum number of compared cells of a gateway in a window")
+	anomaliesCmd.Flags().Float64("min-drop", defaults.MinDrop, "minimum mean drop in dB")
+	anomaliesCmd.Flags().Float64("z", defaults.Z, "minimum z-score of the drop")
+	anomaliesCmd.Flags().BoolVar(&anomaliesNotify, "notify", false, "post the anomalies to the webhook of the config file")
+	anomaliesCmd.Flags().StringVar(&anomaliesGrid, "grid", "", "shape of the grid cells: square or hexagon (default is grid.shape from the config or square)")
+	anomaliesCmd.Flags().Float64Var(&gridSize, "cell-size", 0, "size of the grid cells in meters (default is grid.size from the config or 100)")
+	anomaliesCmd.Flags().StringVar(&gridOrigin, "origin", "", "origin of the grid [lat,lon] (default is grid.origin from the config)")
+	addFilterFlags(anomaliesCmd)
+}
@@ -0,0 +1,140 @@
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/bullettime/lora-mapper/model"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	campaignName        string
+	campaignDescription string
+	campaignAll         bool
+)
+
+// campaignCmd represents the campaign command
+var campaignCmd = &cobra.Command{
+	Use:   "campaign",
+	Short: "Manage campaigns",
+	Long: `lora-mapper campaign manages the campaigns (datasets) of this instance.
+
+Every metric added with the --campaign flag is tagged with the campaign id, so
+unrelated drive tests or customer sites can be kept apart. Maps, geojson files
+and data rate lookups can be scoped to one or more campaigns.`,
+}
+
+var campaignCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Create a new campaign",
+	Long: `lora-mapper campaign create adds a new campaign.
+
+This command takes one argument:
+	- campaign id (letters, digits, '-' and '_') [eg. leuven-2018]`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		db := connectDatabase()
+		defer db.Close()
+
+		err := getCampaigns(db).Create(model.Campaign{
+			ID:          args[0],
+			Name:        campaignName,
+			Description: campaignDescription,
+		})
+		if err != nil {
+			log.WithError(err).Fatal("creating campaign")
+		}
+
+		log.WithField("id", args[0]).Info("campaign created")
+	},
+}
+
+var campaignListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the campaigns",
+	Long:  `lora-mapper campaign list prints the campaigns, archived campaigns are only shown with --all`,
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		db := connectDatabase()
+		defer db.Close()
+
+		list, err := getCampaigns(db).List(campaignAll)
+		if err != nil {
+			log.WithError(err).Fatal("listing campaigns")
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tNAME\tCREATED\tARCHIVED\tDESCRIPTION")
+		for _, c := range list {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%t\t%s\n", c.ID, c.Name, c.Created.Format(time.RFC3339), c.Archived, c.Description)
+		}
+		w.Flush()
+	},
+}
+
+var campaignArchiveCmd = &cobra.Command{
+	Use:   "archive",
+	Short: "Archive a campaign",
+	Long: `lora-mapper campaign archive marks a campaign as archived. Its data is kept and
+can still be viewed, but no new data can be added to it.
+
+This command takes one argument:
+	- campaign id [eg. leuven-2018]`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		db := connectDatabase()
+		defer db.Close()
+
+		if err := getCampaigns(db).Archive(args[0]); err != nil {
+			log.WithError(err).Fatal("archiving campaign")
+		}
+
+		log.WithField("id", args[0]).Info("campaign archived")
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(campaignCmd)
+	campaignCmd.AddCommand(campaignCreateCmd)
+	campaignCmd.AddCommand(campaignListCmd)
+	campaignCmd.AddCommand(campaignArchiveCmd)
+
+	campaignCreateCmd.Flags().StringVar(&campaignName, "name", "", "human readable name (default is the id)")
+	campaignCreateCmd.Flags().StringVar(&campaignDescription, "description", "", "description of the campaign")
+	campaignListCmd.Flags().BoolVarP(&campaignAll, "all", "a", false, "include archived campaigns")
+}
+
+func getCampaigns(db model.Database) model.Campaigns {
+	measurementName := viper.GetString("campaign.measurement")
+
+	if measurementName == "" {
+		measurementName = model.CampaignData
+	}
+
+	return model.NewCampaigns(db, measurementName)
+}
@@ -0,0 +1,76 @@
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"github.com/apex/log"
+	"github.com/bullettime/lora-mapper/database/influxdb"
+	"github.com/bullettime/lora-mapper/model"
+	"github.com/bullettime/lora-mapper/parser/csv"
+	"github.com/bullettime/lora-mapper/web/utils"
+	"github.com/spf13/viper"
+)
+
+// connectDatabase connects to the configured database, the caller is
+// responsible for closing it.
+func connectDatabase() model.Database {
+	influxOptions := influxdb.InfluxOptions{
+		Server:    viper.GetString("influxdb.server.url"),
+		Username:  viper.GetString("influxdb.server.username"),
+		Password:  viper.GetString("influxdb.server.password"),
+		Database:  viper.GetString("influxdb.database"),
+		Precision: viper.GetString("influxdb.precision"),
+	}
+	log.WithFields(log.Fields{
+		"Server":    influxOptions.Server,
+		"Username":  influxOptions.Username,
+		"Database":  influxOptions.Database,
+		"Precision": influxOptions.Precision,
+	}).Debug("InfluxDB Options")
+	db := influxdb.New(influxOptions)
+
+	err := db.Connect()
+	if err != nil {
+		log.WithError(err).Fatal("can't connect to the influx database")
+	}
+
+	return db
+}
+
+func getMetricName() string {
+	metricName := viper.GetString("metric.name")
+
+	if metricName == "" {
+		metricName = csv.LocationData
+	}
+
+	return metricName
+}
+
+// getGateways returns the active and planned gateways of the registry.
+func getGateways(db model.Database) []model.Gateway {
+	gateways, err := utils.Gateways(db)
+	if err != nil {
+		log.WithError(err).Fatal("reading gateways")
+	}
+
+	return gateways
+}
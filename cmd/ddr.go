@@ -0,0 +1,609 @@
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/bullettime/lora-mapper/ddrtable"
+	"github.com/bullettime/lora-mapper/model"
+	"github.com/bullettime/lora-mapper/web/utils"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var (
+	ddrProfile  string
+	ddrDevice   string
+	ddrPolicy   string
+	ddrCellSize float64
+	ddrOutput   string
+	ddrStep     float64
+	ddrPoints   bool
+	ddrFormat   string
+	ddrCSV      string
+	ddrRegion   string
+	ddrInterval time.Duration
+	ddrEnergy   bool
+)
+
+type ddrResult struct {
+	Latitude  float64 `json:"lat"`
+	Longitude float64 `json:"lon"`
+	model.Recommendation
+	Explanation string `json:"explanation"`
+}
+
+// ddrCmd represents the ddr command
+var ddrCmd = &cobra.Command{
+	Use:   "ddr [lat,lon]",
+	Short: "Recommend the data rate at a location",
+	Long: `lora-mapper ddr recommends the data rate at a location directly from the
+database, like the /ddr/q endpoint without a precomputed grid, with the policy
+and options of the ddr section of the config file or of a device profile
+(--profile, or the profile of the --device-profile device). The --device flag
+only limits the data to the receptions of the devices.
+
+The recommendation is printed as a table with its explanation, as json or as
+csv (--format). The locations of a csv file (lat,lon per line, a header line is
+skipped) can be recommended with --csv instead of the argument.
+
+With --energy, or when the profile has energy settings, the airtime, duty cycle
+and battery lifetime of the recommended data rate are added (the --region and
+--interval flags override the settings of the profile).
+
+This command takes one optional argument:
+	1. location [lat,lon]
+
+The data can be limited with the --campaign, --from, --to, --device, --gateway and --bbox flags.
+The subcommands export, route and energy compute the recommendations of an area
+or route.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if ddrFormat != "table" && ddrFormat != "json" && ddrFormat != "csv" {
+			log.WithField("format", ddrFormat).Fatal("invalid format")
+		}
+
+		var locations []model.LatLon
+
+		switch {
+		case len(args) == 1 && ddrCSV == "":
+			ll, err := model.ParseLatLon(args[0])
+			if err != nil {
+				log.WithError(err).Fatal("invalid location")
+			}
+			locations = append(locations, ll)
+		case len(args) == 0 && ddrCSV != "":
+			var err error
+
+			locations, err = readLocationsCSV(ddrCSV)
+			if err != nil {
+				log.WithError(err).Fatal("reading locations")
+			}
+		default:
+			log.Fatal("either a location or --csv is required")
+		}
+
+		filter := getFilter()
+
+		db := connectDatabase()
+		defer db.Close()
+
+		d := model.NewDDR(db, getMetricName(), utils.DDRRadius())
+		d.SetFilter(filter)
+		d.SetOptions(getDDROptions())
+
+		var profile *model.EnergyProfile
+
+		if ddrEnergy || ddrRegion != "" || ddrInterval != 0 || utils.HasEnergySettings(getDDRProfile()) {
+			p, err := getEnergyProfile()
+			if err != nil {
+				log.WithError(err).Warn("no energy estimate")
+			} else {
+				profile = &p
+			}
+		}
+
+		results := make([]ddrResult, 0, len(locations))
+
+		for _, ll := range locations {
+			r, err := d.Recommend(ll)
+			if err != nil {
+				log.WithError(err).WithField("location", ll).Fatal("recommending data rate")
+			}
+
+			if profile != nil {
+				if energy, err := model.EstimateEnergy(r.SF, *profile); err == nil {
+					r.Energy = &energy
+				}
+			}
+
+			results = append(results, ddrResult{
+				Latitude:       ll.Latitude,
+				Longitude:      ll.Longitude,
+				Recommendation: r,
+				Explanation:    r.Explanation(),
+			})
+		}
+
+		if err := writeDDRResults(os.Stdout, results, ddrFormat); err != nil {
+			log.WithError(err).Fatal("writing recommendations")
+		}
+	},
+}
+
+var ddrExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export a ddr lookup table for device firmware",
+	Long: `lora-mapper ddr export compiles the data rate recommendations of an area into a
+compact binary lookup table (3 bits per cell, run-length encoded when smaller),
+with a versioned header and a crc32 checksum. See the ddrtable package for the
+format and a reference decoder.
+
+This command takes one argument:
+	1. area [min_lon,min_lat,max_lon,max_lat]
+
+The data can be limited with the --campaign, --from, --to, --device, --gateway and --bbox flags.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		area, err := model.ParseBoundingBox(args[0])
+		if err != nil {
+			log.WithError(err).Fatal("invalid area")
+		}
+
+		if ddrCellSize <= 0 {
+			log.WithField("cell-size", ddrCellSize).Fatal("invalid cell size")
+		}
+
+		options := getDDROptions()
+		radius := utils.DDRRadius()
+
+		// include the receptions within the radius of the cells at the edges
+		bbox := area.Extend(radius)
+
+		filter := getFilter()
+		limitFilter(&filter, bbox)
+
+		db := connectDatabase()
+		defer db.Close()
+
+		receptions, err := model.GetReceptions(db, getMetricName(), filter)
+		if err != nil {
+			log.WithError(err).Fatal("querying receptions")
+		}
+
+		origin := model.LatLon{Latitude: area.MinLatitude, Longitude: area.MinLongitude}
+
+		grid, err := model.ComputeDDRGrid(receptions, ddrCellSize, &origin, radius, options)
+		if err != nil {
+			log.WithError(err).Fatal("computing ddr grid")
+		}
+
+		table, err := ddrtable.Compile(grid, area, ddrCellSize)
+		if err != nil {
+			log.WithError(err).Fatal("compiling ddr table")
+		}
+
+		data, err := table.MarshalBinary()
+		if err != nil {
+			log.WithError(err).Fatal("encoding ddr table")
+		}
+
+		if err := ioutil.WriteFile(ddrOutput, data, 0644); err != nil {
+			log.WithError(err).Fatal("writing output file")
+		}
+
+		log.WithFields(log.Fields{
+			"filename": ddrOutput,
+			"columns":  table.Columns,
+			"rows":     table.Rows,
+			"bytes":    len(data),
+		}).Info("ddr table written")
+	},
+}
+
+var ddrRouteCmd = &cobra.Command{
+	Use:   "route",
+	Short: "Recommend the data rates along a GPX route",
+	Long: `lora-mapper ddr route prints the data rate recommendation of every segment of
+the tracks and routes of a GPX file, sampled every --step meters (the ddr radius
+by default), with the worst spreading factor on the route and the share of the
+route without coverage. With --points the track points are printed instead.
+
+This command takes one argument:
+	1. gpx file [eg. drive.gpx]
+
+The data can be limited with the --campaign, --from, --to, --device, --gateway and --bbox flags.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		file, err := os.Open(args[0])
+		if err != nil {
+			log.WithError(err).Fatal("opening gpx file")
+		}
+
+		lines, err := model.ReadGPX(file)
+		file.Close()
+		if err != nil {
+			log.WithError(err).Fatal("reading gpx file")
+		}
+
+		options := getDDROptions()
+		radius := utils.DDRRadius()
+
+		if ddrStep <= 0 {
+			ddrStep = radius
+		}
+
+		var locations []model.LatLon
+		for _, line := range lines {
+			locations = append(locations, line...)
+		}
+
+		bbox := model.Bounds(locations).Extend(radius)
+
+		filter := getFilter()
+		limitFilter(&filter, bbox)
+
+		db := connectDatabase()
+		defer db.Close()
+
+		receptions, err := model.GetReceptions(db, getMetricName(), filter)
+		if err != nil {
+			log.WithError(err).Fatal("querying receptions")
+		}
+
+		recommend := model.ReceptionsRecommender(receptions, radius, options)
+
+		for i, line := range lines {
+			var route model.Route
+
+			if ddrPoints {
+				route, err = model.RecommendPoints(line, recommend)
+			} else {
+				route, err = model.RecommendRoute(line, ddrStep, recommend)
+			}
+			if err != nil {
+				log.WithError(err).Fatal("recommending data rates")
+			}
+
+			fmt.Printf("route %d: %d points, %.0f m\n\n", i+1, route.Summary.Points, route.Summary.Distance)
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			if ddrPoints {
+				fmt.Fprintln(w, "POINT\tLOCATION\tDATA RATE\tCONFIDENCE\tSAMPLES\tCOVERED")
+				for j, p := range route.Points {
+					fmt.Fprintf(w, "%d\t%.6f,%.6f\t%s\t%.2f\t%d\t%t\n",
+						j, p.Latitude, p.Longitude, p.DataRate, p.Confidence, p.Samples, !p.Fallback)
+				}
+			} else {
+				fmt.Fprintln(w, "SEGMENT\tDISTANCE\tDATA RATE\tSAMPLES\tUNCOVERED")
+				for _, s := range route.Segments {
+					fmt.Fprintf(w, "%d-%d\t%.0f m\t%s\t%d\t%.0f%%\n", s.From, s.To, s.Distance, s.DataRate, s.Samples, s.Uncovered*100)
+				}
+			}
+			w.Flush()
+
+			worst := route.Summary.WorstDataRate
+			if worst == "" {
+				worst = "none"
+			}
+
+			fmt.Printf("\nworst data rate: %s, %.0f%% without coverage\n\n", worst, route.Summary.Uncovered*100)
+		}
+	},
+}
+
+var ddrEnergyCmd = &cobra.Command{
+	Use:   "energy",
+	Short: "Export the airtime and battery lifetime of an area",
+	Long: `lora-mapper ddr energy recommends the data rate of the cells of an area and
+writes them as a GeoJSON layer with the airtime, duty cycle headroom, energy per
+message and battery lifetime of the energy settings of the profile, eg.
+
+	ddr:
+	  profiles:
+	    tracker:
+	      region: EU868
+	      payloadsize: 7
+	      interval: 5m
+	      battery: 1000
+	      txcurrent: 40
+	      sleepcurrent: 2
+	      voltage: 3
+
+The battery capacity is in mAh, the tx current in mA and the sleep current in µA.
+
+This command takes one argument:
+	1. area [min_lon,min_lat,max_lon,max_lat]
+
+The data can be limited with the --campaign, --from, --to, --device, --gateway and --bbox flags.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		area, err := model.ParseBoundingBox(args[0])
+		if err != nil {
+			log.WithError(err).Fatal("invalid area")
+		}
+
+		if ddrCellSize <= 0 {
+			log.WithField("cell-size", ddrCellSize).Fatal("invalid cell size")
+		}
+
+		options := getDDROptions()
+		radius := utils.DDRRadius()
+
+		profile, err := getEnergyProfile()
+		if err != nil {
+			log.WithError(err).Fatal("invalid energy settings")
+		}
+
+		// include the receptions within the radius of the cells at the edges
+		bbox := area.Extend(radius)
+
+		filter := getFilter()
+		limitFilter(&filter, bbox)
+
+		db := connectDatabase()
+		defer db.Close()
+
+		receptions, err := model.GetReceptions(db, getMetricName(), filter)
+		if err != nil {
+			log.WithError(err).Fatal("querying receptions")
+		}
+
+		origin := model.LatLon{Latitude: area.MinLatitude, Longitude: area.MinLongitude}
+
+		grid, err := model.ComputeDDRGrid(receptions, ddrCellSize, &origin, radius, options)
+		if err != nil {
+			log.WithError(err).Fatal("computing ddr grid")
+		}
+
+		data, err := grid.EnergyGeoJSON(profile, &area, "")
+		if err != nil {
+			log.WithError(err).Fatal("creating geojson")
+		}
+
+		if err := ioutil.WriteFile(ddrOutput, []byte(data), 0644); err != nil {
+			log.WithError(err).Fatal("writing output file")
+		}
+
+		log.WithFields(log.Fields{
+			"filename": ddrOutput,
+			"region":   profile.Region,
+			"interval": profile.Interval,
+		}).Info("energy layer written")
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(ddrCmd)
+	ddrCmd.AddCommand(ddrExportCmd)
+	ddrCmd.AddCommand(ddrRouteCmd)
+	ddrCmd.AddCommand(ddrEnergyCmd)
+
+	ddrCmd.PersistentFlags().StringVar(&ddrProfile, "profile", "", "ddr profile of the config file")
+	ddrCmd.PersistentFlags().StringVar(&ddrDevice, "device-profile", "", "use the ddr profile of this device (default is --profile)")
+	ddrCmd.PersistentFlags().StringVar(&ddrPolicy, "policy", "", "ddr policy (default is the policy of the profile)")
+	ddrCmd.PersistentFlags().StringVar(&ddrRegion, "region", "", "region of the duty cycle limits (default is the region of the profile)")
+	ddrCmd.PersistentFlags().DurationVar(&ddrInterval, "interval", 0, "interval between the messages (default is the interval of the profile)")
+
+	ddrCmd.Flags().StringVarP(&ddrFormat, "format", "f", "table", "output format: table, json or csv")
+	ddrCmd.Flags().StringVar(&ddrCSV, "csv", "", "csv file with the locations [lat,lon per line]")
+	ddrCmd.Flags().BoolVar(&ddrEnergy, "energy", false, "add the airtime, duty cycle and battery lifetime of the recommended data rate")
+
+	ddrExportCmd.Flags().Float64Var(&ddrCellSize, "cell-size", 100, "size of the cells in meters")
+	ddrExportCmd.Flags().StringVarP(&ddrOutput, "output", "o", "ddr.bin", "name of the output file")
+
+	ddrEnergyCmd.Flags().Float64Var(&ddrCellSize, "cell-size", 100, "size of the cells in meters")
+	ddrEnergyCmd.Flags().StringVarP(&ddrOutput, "output", "o", "energy.geojson", "name of the output file")
+
+	ddrRouteCmd.Flags().Float64Var(&ddrStep, "step", 0, "distance between the samples of a segment in meters (default is the ddr radius)")
+	ddrRouteCmd.Flags().BoolVar(&ddrPoints, "points", false, "print the recommendation of every point instead of the segments")
+
+	addFilterFlags(ddrCmd)
+	addFilterFlags(ddrExportCmd)
+	addFilterFlags(ddrRouteCmd)
+	addFilterFlags(ddrEnergyCmd)
+}
+
+// getDDRProfile returns the --profile, or the profile of the --device-profile.
+func getDDRProfile() string {
+	if ddrProfile == "" {
+		return utils.DeviceProfile(ddrDevice)
+	}
+
+	return ddrProfile
+}
+
+// getDDROptions returns the ddr options of the --profile (or the profile of
+// the --device-profile), with the --policy override.
+func getDDROptions() model.DDROptions {
+	options, err := utils.DDROptions(getDDRProfile())
+	if err != nil {
+		log.WithError(err).Fatal("reading ddr options")
+	}
+
+	if ddrPolicy != "" {
+		if _, err := model.GetDDRPolicy(ddrPolicy); err != nil {
+			log.WithError(err).Fatal("invalid policy")
+		}
+		options.Policy = ddrPolicy
+	}
+
+	return options
+}
+
+// getEnergyProfile returns the energy settings of the --profile (or the
+// profile of the --device-profile), with the --region and --interval overrides.
+func getEnergyProfile() (model.EnergyProfile, error) {
+	profile, err := utils.EnergyProfile(getDDRProfile())
+	if err != nil {
+		return profile, err
+	}
+
+	if ddrRegion != "" {
+		profile.Region = ddrRegion
+	}
+
+	if ddrInterval != 0 {
+		profile.Interval = ddrInterval
+	}
+
+	return profile, profile.Validate()
+}
+
+// limitFilter limits the receptions of the filter to the bounding box, or to
+// the part of it inside the --bbox.
+func limitFilter(filter *model.Filter, bbox model.BoundingBox) {
+	if filter.BoundingBox != nil {
+		var ok bool
+
+		bbox, ok = filter.BoundingBox.Intersect(bbox)
+		if !ok {
+			log.WithField("bbox", filter.BoundingBox).Fatal("the bbox is outside of the area")
+		}
+	}
+
+	filter.BoundingBox = &bbox
+}
+
+// readLocationsCSV reads the lat,lon locations of a csv file, further columns
+// are ignored and a first line that isn't a location is taken as a header.
+func readLocationsCSV(filename string) ([]model.LatLon, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	var locations []model.LatLon
+
+	for i, record := range records {
+		if len(record) < 2 {
+			return nil, errors.Errorf("line %d: expected lat,lon", i+1)
+		}
+
+		ll, err := model.ParseLatLon(record[0] + "," + record[1])
+		if err != nil {
+			if i == 0 {
+				continue
+			}
+			return nil, errors.Wrapf(err, "line %d", i+1)
+		}
+
+		locations = append(locations, ll)
+	}
+
+	return locations, nil
+}
+
+func writeDDRResults(w io.Writer, results []ddrResult, format string) error {
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(results)
+	case "csv":
+		energy := false
+		for _, r := range results {
+			energy = energy || r.Energy != nil
+		}
+
+		header := []string{"lat", "lon", "datarate", "sf", "confidence", "samples", "nearest_distance", "gateway", "gateways", "snr_margin", "rssi_margin", "policy", "fallback"}
+		if energy {
+			header = append(header, "airtime", "duty_cycle", "headroom", "energy_per_message", "lifetime")
+		}
+
+		writer := csv.NewWriter(w)
+		writer.Write(header)
+
+		for _, r := range results {
+			record := []string{
+				strconv.FormatFloat(r.Latitude, 'f', -1, 64),
+				strconv.FormatFloat(r.Longitude, 'f', -1, 64),
+				r.DataRate,
+				strconv.Itoa(r.SF),
+				strconv.FormatFloat(r.Confidence, 'f', -1, 64),
+				strconv.Itoa(r.Samples),
+				strconv.FormatFloat(r.NearestDistance, 'f', -1, 64),
+				r.Gateway,
+				strconv.Itoa(r.Gateways),
+				strconv.FormatFloat(r.SNRMargin, 'f', -1, 64),
+				strconv.FormatFloat(r.RSSIMargin, 'f', -1, 64),
+				r.Policy,
+				strconv.FormatBool(r.Fallback),
+			}
+
+			switch {
+			case r.Energy != nil:
+				record = append(record,
+					strconv.FormatFloat(r.Energy.Airtime, 'f', -1, 64),
+					strconv.FormatFloat(r.Energy.DutyCycle, 'f', -1, 64),
+					strconv.FormatFloat(r.Energy.Headroom, 'f', -1, 64),
+					strconv.FormatFloat(r.Energy.EnergyPerMessage, 'f', -1, 64),
+					strconv.FormatFloat(r.Energy.Lifetime, 'f', -1, 64),
+				)
+			case energy:
+				record = append(record, "", "", "", "", "")
+			}
+
+			writer.Write(record)
+		}
+
+		writer.Flush()
+		return writer.Error()
+	default:
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "LOCATION\tDATA RATE\tCONFIDENCE\tSAMPLES\tGATEWAYS\tGATEWAY\tSNR MARGIN\tRSSI MARGIN")
+		for _, r := range results {
+			fmt.Fprintf(tw, "%.6f,%.6f\t%s\t%.2f\t%d\t%d\t%s\t%.1f dB\t%.1f dB\n",
+				r.Latitude, r.Longitude, r.DataRate, r.Confidence, r.Samples, r.Gateways, r.Gateway, r.SNRMargin, r.RSSIMargin)
+		}
+		tw.Flush()
+
+		fmt.Fprintln(w)
+		for _, r := range results {
+			fmt.Fprintf(w, "%.6f,%.6f: %s\n", r.Latitude, r.Longitude, r.Explanation)
+			if r.Energy != nil {
+				fmt.Fprintf(w, "\t%s\n", r.Energy.Summary())
+			}
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,162 @@
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/bullettime/lora-mapper/analytics"
+	"github.com/bullettime/lora-mapper/model"
+	"github.com/spf13/cobra"
+)
+
+var (
+	diffGrid           string
+	diffBeforeFrom     string
+	diffBeforeTo       string
+	diffBeforeCampaign []string
+	diffAfterFrom      string
+	diffAfterTo        string
+	diffAfterCampaign  []string
+	diffMinCount       int
+	diffThreshold      float64
+	diffOutput         string
+	diffCallback       string
+)
+
+// diffCmd represents the diff command
+var diffCmd = &cobra.Command{
+	Use:   "diff",
+	Short: "Compare the coverage of two time ranges or campaigns",
+	Long: `lora-mapper diff compares the cells of a grid between a before and an after
+window, eg. before and after moving an antenna or adding a gateway. A window is
+a time range (--before-from, --before-to) and/or campaigns (--before-campaign),
+the same for after.
+
+Every cell is new, lost, improved (a lower best spreading factor, or a median
+rssi at least --threshold dB higher at the same spreading factor), regressed
+or unchanged. It prints a summary table with the rssi change and the
+spreading factor changes.
+
+With --output the cells are written as a coloured GeoJSON diff layer.
+The --campaign, --from, --to, --device, --gateway and --bbox flags apply to
+both windows.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		before := getDiffWindow(diffBeforeFrom, diffBeforeTo, diffBeforeCampaign, "before")
+		after := getDiffWindow(diffAfterFrom, diffAfterTo, diffAfterCampaign, "after")
+
+		db := connectDatabase()
+		defer db.Close()
+
+		filter := getFilter()
+
+		receptions := make([][]model.Reception, 2)
+
+		for i, window := range []analytics.DiffWindow{before, after} {
+			var err error
+
+			receptions[i], err = model.GetReceptions(db, getMetricName(), window.Filter(filter))
+			if err != nil {
+				log.WithError(err).Fatal("querying receptions")
+			}
+		}
+
+		diff, err := analytics.CompareCells(getGridOptions(diffGrid), receptions[0], receptions[1], analytics.DiffOptions{
+			MinCount:  diffMinCount,
+			Threshold: diffThreshold,
+		})
+		if err != nil {
+			log.WithError(err).Fatal("comparing cells")
+		}
+
+		diff.Summary.Before = before.String()
+		diff.Summary.After = after.String()
+
+		fmt.Print(diff.Report())
+
+		if diffOutput == "" {
+			return
+		}
+
+		data, err := diff.GeoJSON(diffCallback)
+		if err != nil {
+			log.WithError(err).Fatal("creating geojson")
+		}
+
+		if err := ioutil.WriteFile(diffOutput, []byte(data), 0644); err != nil {
+			log.WithError(err).Fatal("writing output file")
+		}
+
+		log.WithFields(log.Fields{
+			"filename": diffOutput,
+			"cells":    len(diff.Cells),
+		}).Info("diff layer written")
+	},
+}
+
+func getDiffWindow(from, to string, campaigns []string, name string) analytics.DiffWindow {
+	window := analytics.DiffWindow{Campaigns: campaigns}
+
+	var err error
+
+	if from != "" {
+		window.Start, err = time.Parse(time.RFC3339, from)
+		if err != nil {
+			log.WithError(err).Fatalf("parsing %s from time", name)
+		}
+	}
+
+	if to != "" {
+		window.End, err = time.Parse(time.RFC3339, to)
+		if err != nil {
+			log.WithError(err).Fatalf("parsing %s to time", name)
+		}
+	}
+
+	if err := window.Validate(); err != nil {
+		log.WithError(err).Fatalf("invalid %s window", name)
+	}
+
+	return window
+}
+
+func init() {
+	RootCmd.AddCommand(diffCmd)
+
+	diffCmd.Flags().StringVar(&diffBeforeFrom, "before-from", "", "start of the before window (in RFC3339 format)")
+	diffCmd.Flags().StringVar(&diffBeforeTo, "before-to", "", "end of the before window (in RFC3339 format)")
+	diffCmd.Flags().StringSliceVar(&diffBeforeCampaign, "before-campaign", nil, "campaigns of the before window")
+	diffCmd.Flags().StringVar(&diffAfterFrom, "after-from", "", "start of the after window (in RFC3339 format)")
+	diffCmd.Flags().StringVar(&diffAfterTo, "after-to", "", "end of the after window (in RFC3339 format)")
+	diffCmd.Flags().StringSliceVar(&diffAfterCampaign, "after-campaign", nil, "campaigns of the after window")
+	diffCmd.Flags().StringVar(&diffGrid, "grid", "", "shape of the grid cells: square or hexagon (default is grid.shape from the config or square)")
+	diffCmd.Flags().Float64Var(&gridSize, "cell-size", 0, "size of the grid cells in meters (default is grid.size from the config or 100)")
+	diffCmd.Flags().StringVar(&gridOrigin, "origin", "", "origin of the grid [lat,lon] (default is grid.origin from the config)")
+	diffCmd.Flags().IntVar(&diffMinCount, "min-count", 1, "minimum number of receptions of a cell in a window")
+	diffCmd.Flags().Float64Var(&diffThreshold, "threshold", analytics.DefaultDiffThreshold, "rssi change in dB of an improvement or regression at the same spreading factor")
+	diffCmd.Flags().StringVarP(&diffOutput, "output", "o", "", "name of the GeoJSON output file of the diff layer")
+	diffCmd.Flags().StringVarP(&diffCallback, "callback", "c", "", "name of the callback function (jsonp)")
+	addFilterFlags(diffCmd)
+}
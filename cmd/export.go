@@ -0,0 +1,73 @@
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"os"
+
+	"github.com/apex/log"
+	"github.com/bullettime/lora-mapper/archive"
+	"github.com/spf13/cobra"
+)
+
+// exportCmd represents the export command
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export data to an archive file",
+	Long: `lora-mapper export dumps the coverage data from the database to a portable,
+versioned archive (gzip'd JSON Lines). The archive can be restored with
+lora-mapper import, on the same or on another database.
+
+This command takes one argument:
+	- file name of the archive [eg. coverage.jsonl.gz]
+The data can be limited with the --from, --to, --device, --gateway and --bbox flags.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		filter := getFilter()
+		metricName := getMetricName()
+
+		db := connectDatabase()
+		defer db.Close()
+
+		file, err := os.Create(args[0])
+		if err != nil {
+			log.WithError(err).Fatal("creating archive file")
+		}
+		defer file.Close()
+
+		n, err := archive.Export(file, db, metricName, filter)
+		if err != nil {
+			log.WithError(err).Fatal("exporting metrics")
+		}
+
+		log.WithFields(log.Fields{
+			"filename":    args[0],
+			"measurement": metricName,
+			"amount":      n,
+		}).Info("metrics exported")
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(exportCmd)
+
+	addFilterFlags(exportCmd)
+}
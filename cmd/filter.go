@@ -0,0 +1,99 @@
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"time"
+
+	"github.com/apex/log"
+	"github.com/bullettime/lora-mapper/model"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	filterFrom       string
+	filterTo         string
+	filterDeviceIDs  []string
+	filterGatewayIDs []string
+	filterCampaigns  []string
+	filterBBox       string
+	filterHours      string
+	filterWeekdays   string
+	filterMonths     string
+	filterTimeZone   string
+)
+
+func addFilterFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&filterFrom, "from", "", "only use data from this time on (in RFC3339 format)")
+	cmd.Flags().StringVar(&filterTo, "to", "", "only use data before this time (in RFC3339 format)")
+	cmd.Flags().StringSliceVar(&filterDeviceIDs, "device", nil, "only use data from these device ids")
+	cmd.Flags().StringSliceVar(&filterGatewayIDs, "gateway", nil, "only use data received by these gateway ids")
+	cmd.Flags().StringSliceVar(&filterCampaigns, "campaign", nil, "only use data from these campaigns")
+	cmd.Flags().StringVar(&filterBBox, "bbox", "", "only use data inside the bounding box [min_lon,min_lat,max_lon,max_lat]")
+	cmd.Flags().StringVar(&filterHours, "hours", "", "only use data of these hours of the day [eg. 8-18 or 22-6]")
+	cmd.Flags().StringVar(&filterWeekdays, "weekdays", "", "only use data of these weekdays [eg. mon-fri or sat,sun]")
+	cmd.Flags().StringVar(&filterMonths, "months", "", "only use data of these months [eg. jun-aug or 11-2]")
+	cmd.Flags().StringVar(&filterTimeZone, "timezone", "", "time zone of the hours, weekdays and months (default is analytics.timezone from the config or UTC)")
+}
+
+func getFilter() model.Filter {
+	var err error
+
+	filter := model.Filter{
+		DeviceIDs:  filterDeviceIDs,
+		GatewayIDs: filterGatewayIDs,
+		Campaigns:  filterCampaigns,
+	}
+
+	if len(filterFrom) > 0 {
+		filter.Start, err = time.Parse(time.RFC3339, filterFrom)
+		if err != nil {
+			log.WithError(err).Fatal("parsing from time")
+		}
+	}
+
+	if len(filterTo) > 0 {
+		filter.End, err = time.Parse(time.RFC3339, filterTo)
+		if err != nil {
+			log.WithError(err).Fatal("parsing to time")
+		}
+	}
+
+	if len(filterBBox) > 0 {
+		bbox, err := model.ParseBoundingBox(filterBBox)
+		if err != nil {
+			log.WithError(err).Fatal("parsing bounding box")
+		}
+		filter.BoundingBox = &bbox
+	}
+
+	if filterTimeZone == "" {
+		filterTimeZone = viper.GetString("analytics.timezone")
+	}
+
+	filter.Window, err = model.ParseTimeWindow(filterHours, filterWeekdays, filterMonths, filterTimeZone)
+	if err != nil {
+		log.WithError(err).Fatal("parsing time window")
+	}
+
+	return filter
+}
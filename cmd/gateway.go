@@ -0,0 +1,270 @@
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/apex/log"
+	"github.com/bullettime/lora-mapper/model"
+	"github.com/bullettime/lora-mapper/web/utils"
+	"github.com/spf13/cobra"
+)
+
+var (
+	gatewayName          string
+	gatewayLocation      string
+	gatewayAltitude      float64
+	gatewayAntennaGain   float64
+	gatewayAntennaHeight float64
+	gatewayCableLoss     float64
+	gatewayOwner         string
+	gatewayStatus        string
+	gatewayAll           bool
+)
+
+// gatewayCmd represents the gateway command
+var gatewayCmd = &cobra.Command{
+	Use:   "gateway",
+	Short: "Manage the gateway registry",
+	Long: `lora-mapper gateway manages the registry of gateways: their EUI, name,
+location, altitude, antenna gain and height, cable loss, owner and status
+(active, planned or inactive).
+
+The maps show markers of the registered gateways and the analytics and
+predictions use their locations and antennas. Inactive gateways are ignored.`,
+}
+
+var gatewayCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Register a gateway",
+	Long: `lora-mapper gateway create adds a gateway to the registry.
+
+This command takes two arguments:
+	1. gateway EUI (letters, digits, '-' and '_') [eg. 008000000000b88d]
+	2. location [lat,lon]`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		ll, err := model.ParseLatLon(args[1])
+		if err != nil {
+			log.WithError(err).Fatal("invalid location")
+		}
+
+		db := connectDatabase()
+		defer db.Close()
+
+		err = utils.NewGateways(db).Create(model.Gateway{
+			ID:            args[0],
+			Name:          gatewayName,
+			Location:      ll,
+			Altitude:      gatewayAltitude,
+			AntennaGain:   gatewayAntennaGain,
+			AntennaHeight: gatewayAntennaHeight,
+			CableLoss:     gatewayCableLoss,
+			Owner:         gatewayOwner,
+			Status:        gatewayStatus,
+		})
+		if err != nil {
+			log.WithError(err).Fatal("creating gateway")
+		}
+
+		log.WithField("id", args[0]).Info("gateway created")
+	},
+}
+
+var gatewayListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the gateways",
+	Long:  `lora-mapper gateway list prints the registered gateways, inactive gateways are only shown with --all`,
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		db := connectDatabase()
+		defer db.Close()
+
+		list, err := utils.NewGateways(db).List(gatewayAll)
+		if err != nil {
+			log.WithError(err).Fatal("listing gateways")
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tNAME\tLOCATION\tALTITUDE\tGAIN\tHEIGHT\tCABLE LOSS\tOWNER\tSTATUS")
+		for _, g := range list {
+			fmt.Fprintf(w, "%s\t%s\t%.6f,%.6f\t%.0f m\t%.1f dBi\t%.0f m\t%.1f dB\t%s\t%s\n",
+				g.ID, g.Name, g.Location.Latitude, g.Location.Longitude, g.Altitude, g.AntennaGain, g.AntennaHeight, g.CableLoss, g.Owner, g.Status)
+		}
+		w.Flush()
+	},
+}
+
+var gatewayUpdateCmd = &cobra.Command{
+	Use:   "update",
+	Short: "Update a gateway",
+	Long: `lora-mapper gateway update changes the fields of a gateway set with the flags,
+eg. --location 51.0,4.7 or --status inactive.
+
+This command takes one argument:
+	1. gateway EUI [eg. 008000000000b88d]`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		db := connectDatabase()
+		defer db.Close()
+
+		gateways := utils.NewGateways(db)
+
+		g, err := gateways.Get(args[0])
+		if err != nil {
+			log.WithError(err).Fatal("getting gateway")
+		}
+
+		flags := cmd.Flags()
+
+		if flags.Changed("location") {
+			g.Location, err = model.ParseLatLon(gatewayLocation)
+			if err != nil {
+				log.WithError(err).Fatal("invalid location")
+			}
+		}
+
+		texts := map[string]*string{
+			"name":   &g.Name,
+			"owner":  &g.Owner,
+			"status": &g.Status,
+		}
+
+		for name, value := range texts {
+			if flags.Changed(name) {
+				*value, _ = flags.GetString(name)
+			}
+		}
+
+		floats := map[string]*float64{
+			"altitude":       &g.Altitude,
+			"antenna-gain":   &g.AntennaGain,
+			"antenna-height": &g.AntennaHeight,
+			"cable-loss":     &g.CableLoss,
+		}
+
+		for name, value := range floats {
+			if flags.Changed(name) {
+				*value, _ = flags.GetFloat64(name)
+			}
+		}
+
+		if err := gateways.Update(g); err != nil {
+			log.WithError(err).Fatal("updating gateway")
+		}
+
+		log.WithField("id", args[0]).Info("gateway updated")
+	},
+}
+
+var gatewayDeleteCmd = &cobra.Command{
+	Use:   "delete",
+	Short: "Remove a gateway from the registry",
+	Long: `lora-mapper gateway delete removes a gateway from the registry, its receptions
+are kept. Use update --status inactive to keep the gateway for later.
+
+This command takes one argument:
+	1. gateway EUI [eg. 008000000000b88d]`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		db := connectDatabase()
+		defer db.Close()
+
+		if err := utils.NewGateways(db).Delete(args[0]); err != nil {
+			log.WithError(err).Fatal("deleting gateway")
+		}
+
+		log.WithField("id", args[0]).Info("gateway deleted")
+	},
+}
+
+var gatewayImportCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Import gateways from a YAML file",
+	Long: `lora-mapper gateway import registers the gateways of a YAML file, gateways that
+are already registered are updated.
+
+This command takes one argument:
+	1. file [eg. gateways.yaml]
+The file holds a list of gateways:
+	gateways:
+	  - id: 008000000000b88d
+	    name: home
+	    latitude: 51.00178534
+	    longitude: 4.71346780
+	    altitude: 15
+	    antenna_gain: 3
+	    antenna_height: 10
+	    cable_loss: 1
+	    owner: sven
+	    status: active`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		file, err := os.Open(args[0])
+		if err != nil {
+			log.WithError(err).Fatal("opening file")
+		}
+		defer file.Close()
+
+		list, err := model.ReadGatewaysYAML(file)
+		if err != nil {
+			log.WithError(err).Fatal("reading gateways")
+		}
+
+		db := connectDatabase()
+		defer db.Close()
+
+		created, updated, err := utils.NewGateways(db).Import(list)
+		if err != nil {
+			log.WithError(err).Fatal("importing gateways")
+		}
+
+		log.WithFields(log.Fields{
+			"file":    args[0],
+			"created": created,
+			"updated": updated,
+		}).Info("gateways imported")
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(gatewayCmd)
+	gatewayCmd.AddCommand(gatewayCreateCmd)
+	gatewayCmd.AddCommand(gatewayListCmd)
+	gatewayCmd.AddCommand(gatewayUpdateCmd)
+	gatewayCmd.AddCommand(gatewayDeleteCmd)
+	gatewayCmd.AddCommand(gatewayImportCmd)
+
+	for _, c := range []*cobra.Command{gatewayCreateCmd, gatewayUpdateCmd} {
+		c.Flags().StringVar(&gatewayName, "name", "", "human readable name (default is the id)")
+		c.Flags().Float64Var(&gatewayAltitude, "altitude", 0, "altitude of the gateway in meters")
+		c.Flags().Float64Var(&gatewayAntennaGain, "antenna-gain", 0, "antenna gain in dBi")
+		c.Flags().Float64Var(&gatewayAntennaHeight, "antenna-height", 0, "height of the antenna above the ground in meters")
+		c.Flags().Float64Var(&gatewayCableLoss, "cable-loss", 0, "loss of the antenna cable in dB")
+		c.Flags().StringVar(&gatewayOwner, "owner", "", "owner of the gateway")
+		c.Flags().StringVar(&gatewayStatus, "status", model.GatewayActive, "status: active, planned or inactive")
+	}
+	gatewayUpdateCmd.Flags().StringVar(&gatewayLocation, "location", "", "location of the gateway [lat,lon]")
+	gatewayListCmd.Flags().BoolVarP(&gatewayAll, "all", "a", false, "include inactive gateways")
+}
@@ -24,17 +24,18 @@ import (
 	"io/ioutil"
 
 	"github.com/apex/log"
-	"github.com/bullettime/lora-mapper/database/influxdb"
 	"github.com/bullettime/lora-mapper/model"
-	"github.com/bullettime/lora-mapper/parser/csv"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
 var (
-	callback string
-	output   string
+	callback   string
+	output     string
+	gridShape  string
+	gridSize   float64
+	gridOrigin string
 )
 
 // geojsonCmd represents the geojson command
@@ -44,31 +45,16 @@ var geojsonCmd = &cobra.Command{
 	Long: `lora-mapper geojson creates a geo jsonp file from the data currently in the database.
 
 This command takes one arguments:
-	1. datarate [eg. SF7BW125]`,
+	1. datarate [eg. SF7BW125] or all
+With --grid square or --grid hexagon the receptions are aggregated into cells of
+--cell-size meters, written as polygons with per cell statistics.
+The data can be limited with the --campaign, --from, --to, --device, --gateway and --bbox flags.`,
 	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		influxOptions := influxdb.InfluxOptions{
-			Server:    viper.GetString("influxdb.server.url"),
-			Username:  viper.GetString("influxdb.server.username"),
-			Password:  viper.GetString("influxdb.server.password"),
-			Database:  viper.GetString("influxdb.database"),
-			Precision: viper.GetString("influxdb.precision"),
-		}
-		log.WithFields(log.Fields{
-			"Server":    influxOptions.Server,
-			"Username":  influxOptions.Username,
-			"Database":  influxOptions.Database,
-			"Precision": influxOptions.Precision,
-		}).Debug("InfluxDB Options")
-		db := influxdb.New(influxOptions)
-
-		err := db.Connect()
-		if err != nil {
-			log.WithError(err).Fatal("can't connect to the influx database")
-		}
+		db := connectDatabase()
 		defer db.Close()
 
-		err = writeGeoJSONFile(db, args[0])
+		err := writeGeoJSONFile(db, args[0])
 		if err != nil {
 			log.WithError(err).Fatal("can't write geojson file")
 		}
@@ -96,21 +82,85 @@ func init() {
 
 	geojsonCmd.Flags().StringVarP(&callback, "callback", "c", "eqfeed_callback", "name of the callback function")
 	geojsonCmd.Flags().StringVarP(&output, "output", "o", "data_geo.json", "name of the output file")
+	geojsonCmd.Flags().StringVar(&gridShape, "grid", "", "aggregate into a grid of square or hexagon cells")
+	geojsonCmd.Flags().Float64Var(&gridSize, "cell-size", 0, "size of the grid cells in meters (default is grid.size from the config or 100)")
+	geojsonCmd.Flags().StringVar(&gridOrigin, "origin", "", "origin of the grid [lat,lon] (default is grid.origin from the config)")
+	addFilterFlags(geojsonCmd)
 }
 
 func writeGeoJSONFile(db model.Database, sf string) error {
-	metricName := viper.GetString("metric.name")
+	metricName := getMetricName()
 
-	if metricName == "" {
-		metricName = csv.LocationData
-	}
+	var data string
+	var err error
+
+	filter := getFilter()
+
+	if len(gridShape) > 0 {
+		if sf != "all" {
+			filter.DataRates = []string{sf}
+		}
 
-	g := model.NewGeoJSON(db, metricName)
+		a := model.NewAggregation(db, metricName, getGridOptions(gridShape))
+		a.SetFilter(filter)
 
-	data, err := g.GetGeoJSONFromSF(sf, callback)
+		data, err = a.GetGeoJSON(callback)
+	} else {
+		g := model.NewGeoJSON(db, metricName)
+		g.SetFilter(filter)
+
+		if sf == "all" {
+			data, err = g.GetGeoJSONFromAllSF(callback)
+		} else {
+			data, err = g.GetGeoJSONFromSF(sf, callback)
+		}
+	}
 	if err != nil {
 		return errors.Wrapf(err, "retrieving geojson data with sf: %s", sf)
 	}
 
 	return ioutil.WriteFile(output, []byte(data), 0644)
 }
+
+func getGridOptions(shape string) model.GridOptions {
+	options := model.GridOptions{
+		Shape: shape,
+		Size:  gridSize,
+	}
+
+	if options.Shape == "" {
+		options.Shape = viper.GetString("grid.shape")
+	}
+
+	if options.Shape == "" {
+		options.Shape = model.ShapeSquare
+	}
+
+	if options.Size <= 0 {
+		options.Size = viper.GetFloat64("grid.size")
+	}
+
+	if options.Size <= 0 {
+		options.Size = 100
+	}
+
+	origin := gridOrigin
+
+	if origin == "" {
+		origin = viper.GetString("grid.origin")
+	}
+
+	if origin != "" {
+		ll, err := model.ParseLatLon(origin)
+		if err != nil {
+			log.WithError(err).Fatal("parsing grid origin")
+		}
+		options.Origin = &ll
+	}
+
+	if _, err := model.NewGrid(options.Shape, options.Size, model.LatLon{}); err != nil {
+		log.WithError(err).Fatal("invalid grid")
+	}
+
+	return options
+}
@@ -0,0 +1,106 @@
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"os"
+	"strconv"
+
+	"github.com/apex/log"
+	"github.com/bullettime/lora-mapper/h3"
+	"github.com/bullettime/lora-mapper/model"
+	"github.com/spf13/cobra"
+)
+
+var (
+	hexFormat   string
+	hexOutput   string
+	hexCallback string
+)
+
+// hexesCmd represents the hexes command
+var hexesCmd = &cobra.Command{
+	Use:   "hexes",
+	Short: "Export the coverage as H3 hexes",
+	Long: `lora-mapper hexes aggregates the receptions in the H3 cells of a resolution
+and writes the index, count, best RSSI and best SF of every cell as GeoJSON or
+CSV, the format used by the community coverage maps (Helium, TTN Mapper).
+
+This command takes one argument:
+	1. resolution [0-15, eg. 8]
+The data can be limited with the --campaign, --from, --to, --device, --gateway and --bbox flags.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		resolution, err := strconv.Atoi(args[0])
+		if err != nil || resolution < 0 || resolution > h3.MaxResolution {
+			log.WithField("resolution", args[0]).Fatal("invalid resolution")
+		}
+
+		if hexFormat != "geojson" && hexFormat != "csv" {
+			log.WithField("format", hexFormat).Fatal("invalid format")
+		}
+
+		if hexOutput == "" {
+			hexOutput = "hexes." + hexFormat
+		}
+
+		db := connectDatabase()
+		defer db.Close()
+
+		l := model.NewHexLayer(db, getMetricName(), resolution)
+		l.SetFilter(getFilter())
+
+		file, err := os.Create(hexOutput)
+		if err != nil {
+			log.WithError(err).Fatal("creating output file")
+		}
+		defer file.Close()
+
+		if hexFormat == "csv" {
+			err = l.WriteCSV(file)
+		} else {
+			var json string
+
+			json, err = l.GetGeoJSON(hexCallback)
+			if err == nil {
+				_, err = file.WriteString(json)
+			}
+		}
+		if err != nil {
+			log.WithError(err).Fatal("can't write hexes")
+		}
+
+		log.WithFields(log.Fields{
+			"filename":   hexOutput,
+			"resolution": resolution,
+			"format":     hexFormat,
+		}).Info("hexes written")
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(hexesCmd)
+
+	hexesCmd.Flags().StringVarP(&hexFormat, "format", "f", "geojson", "output format: geojson or csv")
+	hexesCmd.Flags().StringVarP(&hexOutput, "output", "o", "", "name of the output file (default is hexes.geojson or hexes.csv)")
+	hexesCmd.Flags().StringVarP(&hexCallback, "callback", "c", "", "name of the callback function (jsonp)")
+	addFilterFlags(hexesCmd)
+}
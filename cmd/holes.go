@@ -0,0 +1,143 @@
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"text/tabwriter"
+
+	"github.com/apex/log"
+	"github.com/bullettime/lora-mapper/analytics"
+	"github.com/bullettime/lora-mapper/model"
+	"github.com/spf13/cobra"
+)
+
+var (
+	holesGrid     string
+	holesNoSF12   bool
+	holesMinCells int
+	holesOutput   string
+	holesCallback string
+)
+
+// holesCmd represents the holes command
+var holesCmd = &cobra.Command{
+	Use:   "holes [area]",
+	Short: "Find the coverage holes in an area",
+	Long: `lora-mapper holes finds the coverage holes in a target area: the regions of
+neighbouring grid cells where no gateway received any spreading factor, or
+only SF12 (unless --no-sf12). It prints every hole with its area, centroid and
+the distance to the nearest gateway of the registry, largest first.
+
+This command takes one argument:
+	1. target area: a GeoJSON file with a polygon, or [min_lon,min_lat,max_lon,max_lat]
+
+With --output the holes are written as GeoJSON polygons as well.
+The data can be limited with the --campaign, --from, --to, --device, --gateway and --bbox flags.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		area, err := readArea(args[0])
+		if err != nil {
+			log.WithError(err).Fatal("invalid area")
+		}
+
+		db := connectDatabase()
+		defer db.Close()
+
+		receptions, err := model.GetReceptions(db, getMetricName(), getFilter())
+		if err != nil {
+			log.WithError(err).Fatal("querying receptions")
+		}
+
+		grid, err := analytics.HoleGrid(getGridOptions(holesGrid), receptions, area)
+		if err != nil {
+			log.WithError(err).Fatal("invalid grid")
+		}
+
+		holes, err := analytics.DetectHoles(grid, model.Aggregate(grid, receptions), area, getGateways(db), analytics.HoleOptions{
+			SF12:     !holesNoSF12,
+			MinCells: holesMinCells,
+		})
+		if err != nil {
+			log.WithError(err).Fatal("detecting holes")
+		}
+
+		s := holes.Summary
+		fmt.Printf("%d holes of %d cells (%.2f km², %.1f%% of %.2f km²): %d cells without coverage, %d with SF12 only\n\n",
+			s.Holes, s.HoleCells, s.HoleArea, s.HoleShare*100, s.Area, s.UncoveredCells, s.SF12Cells)
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "HOLE\tCELLS\tUNCOVERED\tSF12\tAREA\tCENTROID\tNEAREST GATEWAY\tDISTANCE")
+		for _, h := range holes.Holes {
+			fmt.Fprintf(w, "%d\t%d\t%d\t%d\t%.4f km²\t%.6f,%.6f\t%s\t%.0f m\n",
+				h.ID, h.Cells, h.UncoveredCells, h.SF12Cells, h.Area,
+				h.Centroid.Latitude, h.Centroid.Longitude, h.NearestGateway, h.Distance)
+		}
+		w.Flush()
+
+		if holesOutput == "" {
+			return
+		}
+
+		data, err := analytics.HolesGeoJSON(holes, holesCallback)
+		if err != nil {
+			log.WithError(err).Fatal("creating geojson")
+		}
+
+		if err := ioutil.WriteFile(holesOutput, []byte(data), 0644); err != nil {
+			log.WithError(err).Fatal("writing output file")
+		}
+
+		log.WithFields(log.Fields{
+			"filename": holesOutput,
+			"holes":    len(holes.Holes),
+		}).Info("holes written")
+	},
+}
+
+// readArea reads the area from a GeoJSON file, or parses it as a bounding
+// box when there is no such file.
+func readArea(s string) (model.Area, error) {
+	data, err := ioutil.ReadFile(s)
+	if os.IsNotExist(err) {
+		return model.ParseArea(s)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return model.ParseAreaGeoJSON(data)
+}
+
+func init() {
+	RootCmd.AddCommand(holesCmd)
+
+	holesCmd.Flags().StringVar(&holesGrid, "grid", "", "shape of the grid cells: square or hexagon (default is grid.shape from the config or square)")
+	holesCmd.Flags().Float64Var(&gridSize, "cell-size", 0, "size of the grid cells in meters (default is grid.size from the config or 100)")
+	holesCmd.Flags().StringVar(&gridOrigin, "origin", "", "origin of the grid [lat,lon] (default is grid.origin from the config)")
+	holesCmd.Flags().BoolVar(&holesNoSF12, "no-sf12", false, "only count the cells without receptions as holes")
+	holesCmd.Flags().IntVar(&holesMinCells, "min-cells", 1, "minimum number of cells of a hole")
+	holesCmd.Flags().StringVarP(&holesOutput, "output", "o", "", "name of the GeoJSON output file of the holes")
+	holesCmd.Flags().StringVarP(&holesCallback, "callback", "c", "", "name of the callback function (jsonp)")
+	addFilterFlags(holesCmd)
+}
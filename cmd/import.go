@@ -0,0 +1,77 @@
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"os"
+
+	"github.com/apex/log"
+	"github.com/bullettime/lora-mapper/archive"
+	"github.com/spf13/cobra"
+)
+
+var (
+	importMeasurement string
+	importBatchSize   int
+)
+
+// importCmd represents the import command
+var importCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Import data from an archive file",
+	Long: `lora-mapper import restores the data of an archive made by lora-mapper export
+into the database. Importing the same archive twice doesn't duplicate data.
+
+This command takes one argument:
+	- file name of the archive [eg. coverage.jsonl.gz]
+The data can be limited with the --from, --to, --device, --gateway and --bbox flags.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		filter := getFilter()
+
+		file, err := os.Open(args[0])
+		if err != nil {
+			log.WithError(err).Fatal("opening archive file")
+		}
+		defer file.Close()
+
+		db := connectDatabase()
+		defer db.Close()
+
+		n, err := archive.Import(file, db, importMeasurement, filter, importBatchSize)
+		if err != nil {
+			log.WithError(err).WithField("amount", n).Fatal("importing metrics")
+		}
+
+		log.WithFields(log.Fields{
+			"filename": args[0],
+			"amount":   n,
+		}).Info("metrics imported")
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(importCmd)
+
+	addFilterFlags(importCmd)
+	importCmd.Flags().StringVar(&importMeasurement, "measurement", "", "measurement to import into (default is the measurement stored in the archive)")
+	importCmd.Flags().IntVar(&importBatchSize, "batch-size", 5000, "amount of metrics written per batch")
+}
@@ -0,0 +1,178 @@
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"strconv"
+
+	"github.com/apex/log"
+	"github.com/bullettime/lora-mapper/interpolation"
+	"github.com/bullettime/lora-mapper/model"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	interpolationOptions = interpolation.DefaultOptions()
+	interpolationOutput  string
+	interpolationOrigin  string
+	interpolationRate    string
+	contourLevels        []string
+	contourStep          float64
+)
+
+// interpolateCmd represents the interpolate command
+var interpolateCmd = &cobra.Command{
+	Use:   "interpolate",
+	Short: "Interpolate the rssi onto a regular grid",
+	Long: `lora-mapper interpolate computes a continuous rssi surface from the receptions,
+with inverse distance weighting (idw) or ordinary kriging with a fitted
+variogram. Cells farther than --max-distance from a sample are masked.
+
+This command takes one argument:
+	1. output [grid or contours]
+The grid is written as JSON, the contours as GeoJSON polygons of the area at
+or above every level. Use --gateway or --data-rate for a surface per gateway or
+data rate, the data can further be limited with the --campaign, --from, --to,
+--device and --bbox flags.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if args[0] != "grid" && args[0] != "contours" {
+			log.WithField("output", args[0]).Fatal("invalid output, use grid or contours")
+		}
+
+		options := getInterpolationOptions(cmd)
+
+		filter := getFilter()
+		if interpolationRate != "" {
+			filter.DataRates = []string{interpolationRate}
+		}
+
+		db := connectDatabase()
+		defer db.Close()
+
+		receptions, err := model.GetReceptions(db, getMetricName(), filter)
+		if err != nil {
+			log.WithError(err).Fatal("querying receptions")
+		}
+
+		surface, err := interpolation.Interpolate(interpolation.RSSISamples(receptions), options)
+		if err != nil {
+			log.WithError(err).Fatal("interpolating")
+		}
+
+		var data []byte
+
+		if args[0] == "grid" {
+			data, err = json.Marshal(surface)
+		} else {
+			var levels []float64
+
+			for _, l := range contourLevels {
+				level, err := strconv.ParseFloat(l, 64)
+				if err != nil {
+					log.WithError(err).Fatal("parsing contour level")
+				}
+				levels = append(levels, level)
+			}
+
+			if len(levels) == 0 {
+				levels = surface.Levels(contourStep)
+			}
+
+			var js string
+			js, err = interpolation.ContoursGeoJSON(surface.Contours(levels), callback)
+			data = []byte(js)
+		}
+		if err != nil {
+			log.WithError(err).Fatal("encoding surface")
+		}
+
+		if interpolationOutput == "" {
+			interpolationOutput = args[0] + ".json"
+		}
+
+		err = ioutil.WriteFile(interpolationOutput, data, 0644)
+		if err != nil {
+			log.WithError(err).Fatal("writing output file")
+		}
+
+		log.WithFields(log.Fields{
+			"filename": interpolationOutput,
+			"method":   options.Method,
+			"columns":  surface.Columns,
+			"rows":     surface.Rows,
+		}).Info("surface written")
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(interpolateCmd)
+
+	interpolateCmd.Flags().StringVarP(&interpolationOptions.Method, "method", "m", interpolationOptions.Method, "interpolation method: idw or kriging (default is interpolation.method from the config or idw)")
+	interpolateCmd.Flags().Float64Var(&interpolationOptions.CellSize, "cell-size", interpolationOptions.CellSize, "size of the grid cells in meters (default is interpolation.size from the config or 50)")
+	interpolateCmd.Flags().Float64Var(&interpolationOptions.MaxDistance, "max-distance", interpolationOptions.MaxDistance, "mask cells farther than this from a sample in meters (default is interpolation.maxdistance from the config or 500)")
+	interpolateCmd.Flags().Float64Var(&interpolationOptions.Power, "power", interpolationOptions.Power, "power of the inverse distance weights")
+	interpolateCmd.Flags().IntVar(&interpolationOptions.Neighbours, "neighbours", interpolationOptions.Neighbours, "number of closest samples used for every cell")
+	interpolateCmd.Flags().StringVar(&interpolationOrigin, "origin", "", "origin of the grid [lat,lon] (default is grid.origin from the config)")
+	interpolateCmd.Flags().StringVar(&interpolationRate, "data-rate", "", "only use receptions at this data rate [eg. SF7BW125]")
+	interpolateCmd.Flags().StringSliceVar(&contourLevels, "levels", nil, "contour levels in dBm [eg. -120,-110,-100]")
+	interpolateCmd.Flags().Float64Var(&contourStep, "step", 10, "step between the contour levels when no levels are given")
+	interpolateCmd.Flags().StringVarP(&interpolationOutput, "output", "o", "", "name of the output file (default is grid.json or contours.json)")
+	interpolateCmd.Flags().StringVarP(&callback, "callback", "c", "", "name of the callback function (jsonp)")
+	addFilterFlags(interpolateCmd)
+}
+
+// getInterpolationOptions applies the config file settings to the options
+// whose flag was not given.
+func getInterpolationOptions(cmd *cobra.Command) interpolation.Options {
+	options := interpolationOptions
+
+	if !cmd.Flags().Changed("method") && viper.GetString("interpolation.method") != "" {
+		options.Method = viper.GetString("interpolation.method")
+	}
+
+	if !cmd.Flags().Changed("cell-size") && viper.GetFloat64("interpolation.size") > 0 {
+		options.CellSize = viper.GetFloat64("interpolation.size")
+	}
+
+	if !cmd.Flags().Changed("max-distance") && viper.GetFloat64("interpolation.maxdistance") > 0 {
+		options.MaxDistance = viper.GetFloat64("interpolation.maxdistance")
+	}
+
+	origin := interpolationOrigin
+
+	if origin == "" {
+		origin = viper.GetString("grid.origin")
+	}
+
+	if origin != "" {
+		ll, err := model.ParseLatLon(origin)
+		if err != nil {
+			log.WithError(err).Fatal("parsing grid origin")
+		}
+		options.Origin = &ll
+	}
+
+	return options
+}
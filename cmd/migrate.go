@@ -0,0 +1,61 @@
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"github.com/apex/log"
+	"github.com/bullettime/lora-mapper/model"
+	"github.com/bullettime/lora-mapper/web/utils"
+	"github.com/spf13/cobra"
+)
+
+// migrateCmd represents the migrate command
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Move the no reception placeholders to the attempts",
+	Long: `lora-mapper migrate converts the data of older versions, which stored every
+transmission without reception as a reception with rssi 0 and without gateway,
+into transmission attempts (attempt.measurement, default attempts) and deletes
+these placeholders from the receptions.
+
+Run it once after upgrading, the attempts of newly added csv files are stored
+separately.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		db := connectDatabase()
+		defer db.Close()
+
+		migrated, skipped, err := model.MigratePlaceholders(db, getMetricName(), utils.AttemptMeasurement())
+		if err != nil {
+			log.WithError(err).Fatal("migrating placeholders")
+		}
+
+		if skipped > 0 {
+			log.WithField("amount", skipped).Warn("receptions without gateway deleted")
+		}
+
+		log.WithField("amount", migrated).Info("placeholders migrated")
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(migrateCmd)
+}
@@ -0,0 +1,104 @@
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"github.com/apex/log"
+	"github.com/bullettime/lora-mapper/analytics"
+	"github.com/bullettime/lora-mapper/model"
+	"github.com/bullettime/lora-mapper/web/utils"
+	"github.com/spf13/cobra"
+)
+
+var (
+	outageOutput   string
+	outageCallback string
+)
+
+// outageCmd represents the outage command
+var outageCmd = &cobra.Command{
+	Use:   "outage",
+	Short: "Simulate the outage of gateways",
+	Long: `lora-mapper outage recomputes the coverage, data rate recommendations and
+gateway redundancy of square cells without the receptions of the given
+gateways, and prints the impact: the cells that lose their coverage, need a
+higher spreading factor or are heard by fewer gateways.
+
+With --output the changed cells are written as a GeoJSON diff layer.
+The ddr options are those of the --profile and --policy flags.
+
+This command takes one or more arguments:
+	- gateway id [eg. eui-b827ebfffe8b1a2c]
+
+The data can be limited with the --campaign, --from, --to, --device and --bbox flags.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		options := getGridOptions(model.ShapeSquare)
+
+		db := connectDatabase()
+		defer db.Close()
+
+		receptions, err := model.GetReceptions(db, getMetricName(), getFilter())
+		if err != nil {
+			log.WithError(err).Fatal("querying receptions")
+		}
+
+		outage, err := analytics.SimulateOutage(receptions, args, options.Size, options.Origin, utils.DDRRadius(), getDDROptions())
+		if err != nil {
+			log.WithError(err).Fatal("simulating outage")
+		}
+
+		fmt.Print(outage.Report())
+
+		if outageOutput == "" {
+			return
+		}
+
+		data, err := outage.GeoJSON(outageCallback)
+		if err != nil {
+			log.WithError(err).Fatal("creating geojson")
+		}
+
+		if err := ioutil.WriteFile(outageOutput, []byte(data), 0644); err != nil {
+			log.WithError(err).Fatal("writing output file")
+		}
+
+		log.WithFields(log.Fields{
+			"filename": outageOutput,
+			"cells":    len(outage.Cells),
+		}).Info("outage cells written")
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(outageCmd)
+
+	outageCmd.Flags().Float64Var(&gridSize, "cell-size", 0, "size of the grid cells in meters (default is grid.size from the config or 100)")
+	outageCmd.Flags().StringVar(&gridOrigin, "origin", "", "origin of the grid [lat,lon] (default is grid.origin from the config)")
+	outageCmd.Flags().StringVarP(&outageOutput, "output", "o", "", "name of the GeoJSON output file of the changed cells")
+	outageCmd.Flags().StringVarP(&outageCallback, "callback", "c", "", "name of the callback function (jsonp)")
+	outageCmd.Flags().StringVar(&ddrProfile, "profile", "", "ddr profile of the config file")
+	outageCmd.Flags().StringVar(&ddrPolicy, "policy", "", "ddr policy (default is the policy of the profile)")
+	addFilterFlags(outageCmd)
+}
@@ -0,0 +1,119 @@
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"text/tabwriter"
+
+	"github.com/apex/log"
+	"github.com/bullettime/lora-mapper/analytics"
+	"github.com/bullettime/lora-mapper/model"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	pathLossTxPower float64
+	pathLossOutput  string
+	pathLossSamples bool
+)
+
+// pathlossCmd represents the pathloss command
+var pathlossCmd = &cobra.Command{
+	Use:   "pathloss",
+	Short: "Fit a path loss model per gateway",
+	Long: `lora-mapper pathloss fits a log-distance path loss model on the receptions of
+every gateway: the reference loss at 1 km, the path loss exponent and the
+standard deviation of the shadowing (sigma).
+
+The gateway locations are read from the gateway registry (see lora-mapper gateway).
+The fits are printed as a table, or written as JSON with --output (with the
+residual of every reception when --samples is set).
+The data can be limited with the --campaign, --from, --to, --device, --gateway and --bbox flags.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		txPower := pathLossTxPower
+		if !cmd.Flags().Changed("tx-power") && viper.IsSet("analytics.txpower") {
+			txPower = viper.GetFloat64("analytics.txpower")
+		}
+
+		db := connectDatabase()
+		defer db.Close()
+
+		locations := model.GatewayLocations(getGateways(db))
+
+		receptions, err := model.GetReceptions(db, getMetricName(), getFilter())
+		if err != nil {
+			log.WithError(err).Fatal("querying receptions")
+		}
+
+		fits, skipped := analytics.FitGateways(receptions, locations, txPower)
+
+		for id, err := range skipped {
+			log.WithField("gateway", id).WithError(err).Warn("gateway skipped")
+		}
+
+		if len(pathLossOutput) > 0 {
+			if !pathLossSamples {
+				for i := range fits {
+					fits[i].Samples = nil
+				}
+			}
+
+			data, err := json.MarshalIndent(fits, "", "  ")
+			if err != nil {
+				log.WithError(err).Fatal("encoding fits")
+			}
+
+			err = ioutil.WriteFile(pathLossOutput, data, 0644)
+			if err != nil {
+				log.WithError(err).Fatal("writing output file")
+			}
+
+			log.WithFields(log.Fields{
+				"filename": pathLossOutput,
+				"gateways": len(fits),
+			}).Info("path loss fits written")
+			return
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "GATEWAY\tRECEPTIONS\tPL(1 KM)\tEXPONENT\tSIGMA\tR2\tDISTANCE")
+		for _, f := range fits {
+			fmt.Fprintf(w, "%s\t%d\t%.1f dB\t%.2f\t%.1f dB\t%.2f\t%.0f - %.0f m\n",
+				f.GatewayID, f.Count, f.ReferenceLoss, f.Exponent, f.Sigma, f.RSquared, f.MinDistance, f.MaxDistance)
+		}
+		w.Flush()
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(pathlossCmd)
+
+	pathlossCmd.Flags().Float64Var(&pathLossTxPower, "tx-power", analytics.DefaultTxPower, "transmit power in dBm of receptions without power (default is analytics.txpower from the config or 14)")
+	pathlossCmd.Flags().StringVarP(&pathLossOutput, "output", "o", "", "write the fits as JSON to this file")
+	pathlossCmd.Flags().BoolVar(&pathLossSamples, "samples", false, "include the residual of every reception in the JSON output")
+	addFilterFlags(pathlossCmd)
+}
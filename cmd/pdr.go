@@ -0,0 +1,224 @@
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/bullettime/lora-mapper/analytics"
+	"github.com/bullettime/lora-mapper/model"
+	"github.com/bullettime/lora-mapper/web/utils"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	pdrGrid     string
+	pdrOutput   string
+	pdrCallback string
+
+	pdrMaxGap         uint32
+	pdrMaxGapDuration time.Duration
+)
+
+// pdrCmd represents the pdr command
+var pdrCmd = &cobra.Command{
+	Use:   "pdr",
+	Short: "Compute the packet delivery ratio",
+	Long: `lora-mapper pdr prints the packet delivery ratio, the share of the transmission
+attempts (see lora-mapper add) that was received, by any gateway, per data rate
+and per gateway. The ratio of a gateway is over all attempts.
+
+With --grid square or --grid hexagon the ratio per cell of --cell-size meters is
+written as GeoJSON polygons instead.
+The data can be limited with the --campaign, --from, --to, --device, --gateway and --bbox flags,
+the gateway filter only applies to the receptions.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		db := connectDatabase()
+		defer db.Close()
+
+		attempts, receptions, err := model.GetTransmissions(db, getMetricName(), utils.AttemptMeasurement(), getFilter())
+		if err != nil {
+			log.WithError(err).Fatal("querying transmissions")
+		}
+
+		if pdrGrid != "" {
+			grid, err := getGridOptions(pdrGrid).NewGrid(model.AttemptLocations(attempts, receptions))
+			if err != nil {
+				log.WithError(err).Fatal("invalid grid")
+			}
+
+			cells := model.CellsPDR(grid, attempts, receptions)
+
+			data, err := model.PDRCellsGeoJSON(grid, cells, pdrCallback)
+			if err != nil {
+				log.WithError(err).Fatal("creating geojson")
+			}
+
+			if err := ioutil.WriteFile(pdrOutput, []byte(data), 0644); err != nil {
+				log.WithError(err).Fatal("writing output file")
+			}
+
+			log.WithFields(log.Fields{
+				"filename": pdrOutput,
+				"cells":    len(cells),
+			}).Info("pdr cells written")
+			return
+		}
+
+		report := model.ComputePDR(attempts, receptions)
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "\tATTEMPTS\tDELIVERED\tPDR")
+		fmt.Fprintf(w, "all\t%d\t%d\t%.1f%%\n", report.Attempts, report.Delivered, report.Ratio*100)
+
+		for _, key := range sortedPDRKeys(report.DataRates, true) {
+			p := report.DataRates[key]
+			fmt.Fprintf(w, "%s\t%d\t%d\t%.1f%%\n", key, p.Attempts, p.Delivered, p.Ratio*100)
+		}
+
+		for _, key := range sortedPDRKeys(report.Gateways, false) {
+			p := report.Gateways[key]
+			fmt.Fprintf(w, "gateway %s\t%d\t%d\t%.1f%%\n", key, p.Attempts, p.Delivered, p.Ratio*100)
+		}
+		w.Flush()
+	},
+}
+
+var pdrFramesCmd = &cobra.Command{
+	Use:   "frames",
+	Short: "Analyse the frame counter loss",
+	Long: `lora-mapper pdr frames reconstructs the frame counter (fcnt) sequence of every
+device from the uplinks of the network server and prints the lost frames per
+device. A counter lower than the previous one starts a new session.
+
+The frames lost between two uplinks are attributed to the line between them,
+unless the gap is longer than --max-gap frames or --max-gap-duration. With
+--grid square or --grid hexagon the delivery ratio of the frames per cell is
+written as GeoJSON polygons, per data rate and per gateway set.
+The data can be limited with the --campaign, --from, --to, --device, --gateway and --bbox flags.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		options := analytics.DefaultFrameOptions()
+		if cmd.Flags().Changed("max-gap") {
+			options.MaxGap = pdrMaxGap
+		} else if viper.IsSet("analytics.frames.maxgap") {
+			options.MaxGap = uint32(viper.GetInt("analytics.frames.maxgap"))
+		}
+
+		if cmd.Flags().Changed("max-gap-duration") {
+			options.MaxGapDuration = pdrMaxGapDuration
+		} else if viper.IsSet("analytics.frames.maxgapduration") {
+			options.MaxGapDuration = viper.GetDuration("analytics.frames.maxgapduration")
+		}
+
+		db := connectDatabase()
+		defer db.Close()
+
+		receptions, err := model.GetReceptions(db, getMetricName(), getFilter())
+		if err != nil {
+			log.WithError(err).Fatal("querying receptions")
+		}
+
+		loss := analytics.FrameCounterLoss(receptions, options)
+
+		if loss.Skipped > 0 {
+			log.WithField("receptions", loss.Skipped).Warn("receptions without frame counter skipped")
+		}
+
+		if pdrGrid != "" {
+			grid, err := getGridOptions(pdrGrid).NewGrid(analytics.FrameLocations(loss.Frames))
+			if err != nil {
+				log.WithError(err).Fatal("invalid grid")
+			}
+
+			cells := analytics.FrameCells(grid, loss.Frames)
+
+			data, err := analytics.FrameCellsGeoJSON(grid, cells, pdrCallback)
+			if err != nil {
+				log.WithError(err).Fatal("creating geojson")
+			}
+
+			if err := ioutil.WriteFile(pdrOutput, []byte(data), 0644); err != nil {
+				log.WithError(err).Fatal("writing output file")
+			}
+
+			log.WithFields(log.Fields{
+				"filename": pdrOutput,
+				"cells":    len(cells),
+			}).Info("frame cells written")
+			return
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "DEVICE\tSESSIONS\tRECEIVED\tLOST\tUNATTRIBUTED\tLONGEST GAP\tPDR")
+		for _, d := range loss.Devices {
+			fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%d\t%.1f%%\n", d.DeviceID, d.Sessions, d.Received, d.Lost, d.Unattributed, d.LongestGap, d.PDR*100)
+		}
+		fmt.Fprintf(w, "all\t\t%d\t%d\t\t\t%.1f%%\n", loss.Total.Delivered, loss.Total.Attempts-loss.Total.Delivered, loss.Total.Ratio*100)
+		w.Flush()
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(pdrCmd)
+	pdrCmd.AddCommand(pdrFramesCmd)
+
+	for _, c := range []*cobra.Command{pdrCmd, pdrFramesCmd} {
+		c.Flags().StringVar(&pdrGrid, "grid", "", "write the ratio per cell of a grid of square or hexagon cells")
+		c.Flags().Float64Var(&gridSize, "cell-size", 0, "size of the grid cells in meters (default is grid.size from the config or 100)")
+		c.Flags().StringVar(&gridOrigin, "origin", "", "origin of the grid [lat,lon] (default is grid.origin from the config)")
+		c.Flags().StringVarP(&pdrOutput, "output", "o", "pdr.geojson", "name of the output file of the grid")
+		c.Flags().StringVarP(&pdrCallback, "callback", "c", "", "name of the callback function (jsonp)")
+		addFilterFlags(c)
+	}
+
+	pdrFramesCmd.Flags().Uint32Var(&pdrMaxGap, "max-gap", analytics.DefaultMaxGap, "largest gap in frames that is attributed to a location (default is analytics.frames.maxgap from the config)")
+	pdrFramesCmd.Flags().DurationVar(&pdrMaxGapDuration, "max-gap-duration", analytics.DefaultMaxGapDuration, "longest gap that is attributed to a location")
+}
+
+// sortedPDRKeys sorts the keys, data rates by spreading factor.
+func sortedPDRKeys(m map[string]model.PDR, dataRates bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if dataRates {
+			a, _ := model.SpreadingFactor(keys[i])
+			b, _ := model.SpreadingFactor(keys[j])
+			if a != b {
+				return a < b
+			}
+		}
+		return keys[i] < keys[j]
+	})
+
+	return keys
+}
@@ -0,0 +1,353 @@
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"text/tabwriter"
+
+	"github.com/apex/log"
+	"github.com/bullettime/lora-mapper/model"
+	"github.com/bullettime/lora-mapper/prediction"
+	"github.com/bullettime/lora-mapper/web/utils"
+	"github.com/spf13/cobra"
+)
+
+var (
+	predictTxPower    float64
+	predictDeviceGain float64
+	predictFadeMargin float64
+	predictExponent   float64
+	predictSigma      float64
+	predictNoFit      bool
+	predictFormat     string
+	predictValue      string
+	predictCellSize   float64
+	predictArea       string
+	predictOutput     string
+	predictCallback   string
+	predictCandidates string
+	predictTarget     string
+	predictPicks      int
+	predictObjective  string
+	placementFormat   string
+)
+
+// predictCmd represents the predict command
+var predictCmd = &cobra.Command{
+	Use:   "predict",
+	Short: "Predict the coverage from a link budget",
+	Long: `lora-mapper predict predicts the rssi, snr and best spreading factor from a
+link budget with a log-distance path loss model per gateway. The model is
+fitted on the receptions of a gateway when there are enough, the default model
+(--exponent and --sigma) is used otherwise or with --no-fit.
+
+The gateway locations, antenna gains and cable losses are read from the
+gateway registry (see lora-mapper gateway), the link budget from the
+prediction section of the config file:
+	prediction:
+	  txpower: 14
+	  devicegain: 0
+	  noisefigure: 6
+	  fademargin: 0`,
+}
+
+var predictPointCmd = &cobra.Command{
+	Use:   "point",
+	Short: "Predict the coverage at a location",
+	Long: `lora-mapper predict point prints the prediction of every gateway at a location.
+
+This command takes one argument:
+	1. location [lat,lon]`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ll, err := model.ParseLatLon(args[0])
+		if err != nil {
+			log.WithError(err).Fatal("invalid location")
+		}
+
+		p, _ := getPredictor(cmd, false)
+		result := p.Predict(ll)
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "GATEWAY\tDISTANCE\tPATH LOSS\tRSSI\tSNR\tSF7 MARGIN\tSF12 MARGIN\tFITTED")
+		for _, g := range result.Gateways {
+			fmt.Fprintf(w, "%s\t%.0f m\t%.1f dB\t%.1f dBm\t%.1f dB\t%.1f dB\t%.1f dB\t%t\n",
+				g.GatewayID, g.Distance, g.PathLoss, g.RSSI, g.SNR, g.Margins[0].Margin, g.Margins[5].Margin, g.Fitted)
+		}
+		w.Flush()
+
+		if result.BestSF == 0 {
+			fmt.Println("\nno coverage predicted")
+			return
+		}
+
+		fmt.Printf("\nbest data rate: %s (%.0f%% probability)\n", result.BestDataRate, result.Probability*100)
+	},
+}
+
+var predictLayerCmd = &cobra.Command{
+	Use:   "layer",
+	Short: "Write a layer of the predicted coverage",
+	Long: `lora-mapper predict layer writes the predicted coverage of the area (--area,
+by default the range of the gateways) in cells of --cell-size meters, as
+GeoJSON polygons of the best spreading factor or as a JSON raster of the
+--value (sf, rssi or snr).`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if predictFormat != "geojson" && predictFormat != "raster" {
+			log.WithField("format", predictFormat).Fatal("invalid format")
+		}
+
+		p, _ := getPredictor(cmd, false)
+
+		area := p.Area()
+		if predictArea != "" {
+			var err error
+
+			area, err = model.ParseBoundingBox(predictArea)
+			if err != nil {
+				log.WithError(err).Fatal("invalid area")
+			}
+		}
+
+		if predictOutput == "" {
+			predictOutput = "prediction." + map[string]string{"geojson": "geojson", "raster": "json"}[predictFormat]
+		}
+
+		var data []byte
+
+		if predictFormat == "geojson" {
+			json, err := p.GeoJSON(area, predictCellSize, predictCallback)
+			if err != nil {
+				log.WithError(err).Fatal("predicting coverage")
+			}
+			data = []byte(json)
+		} else {
+			surface, err := p.Raster(area, predictCellSize, predictValue)
+			if err != nil {
+				log.WithError(err).Fatal("predicting coverage")
+			}
+
+			data, err = json.Marshal(surface)
+			if err != nil {
+				log.WithError(err).Fatal("encoding raster")
+			}
+		}
+
+		if err := ioutil.WriteFile(predictOutput, data, 0644); err != nil {
+			log.WithError(err).Fatal("writing output file")
+		}
+
+		log.WithFields(log.Fields{
+			"filename": predictOutput,
+			"format":   predictFormat,
+			"area":     area.String(),
+		}).Info("prediction written")
+	},
+}
+
+var predictValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Compare the predictions with the measurements",
+	Long: `lora-mapper predict validate compares the predicted rssi with the measured rssi
+of every reception: the bias (measured minus predicted), the rmse and the
+fraction of receptions at a spreading factor the gateway was predicted to
+receive. With --no-fit the default model is validated.
+The data can be limited with the --campaign, --from, --to, --device, --gateway and --bbox flags.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		p, receptions := getPredictor(cmd, true)
+		v := p.Validate(receptions)
+
+		if v.Unknown > 0 {
+			log.WithField("receptions", v.Unknown).Warn("receptions of gateways without location skipped")
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "GATEWAY\tRECEPTIONS\tBIAS\tRMSE\tP10\tP90\tAGREEMENT\tFITTED")
+		for _, g := range append(v.Gateways, v.GatewayValidation) {
+			id := g.GatewayID
+			if id == "" {
+				id = "all"
+			}
+
+			fmt.Fprintf(w, "%s\t%d\t%.1f dB\t%.1f dB\t%.1f dB\t%.1f dB\t%.0f%%\t%t\n",
+				id, g.Count, g.Bias, g.RMSE, g.Residuals.P10, g.Residuals.P90, g.Agreement*100, g.Fitted)
+		}
+		w.Flush()
+	},
+}
+
+var predictPlacementCmd = &cobra.Command{
+	Use:   "placement",
+	Short: "Recommend sites for new gateways",
+	Long: `lora-mapper predict placement predicts the coverage gain of candidate gateway
+sites in a target area, on top of the configured gateways. The candidates are
+the points of a GeoJSON feature collection (--candidates, with the optional
+id, name, antenna_gain, cable_loss and antenna_height properties) and the
+target area a GeoJSON polygon (--area). The candidates get the median of the
+fitted path loss models, corrected for their antenna gain, or the default model.
+
+The candidates are ranked on their own by the --objective: coverage (newly
+covered cells) or sf (reduction of the required spreading factor, uncovered
+cells count as SF13). Then --picks sites are picked greedily, every pick adding
+the most to the previous picks.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		data, err := ioutil.ReadFile(predictCandidates)
+		if err != nil {
+			log.WithError(err).Fatal("reading candidates")
+		}
+
+		candidates, err := prediction.ParseCandidatesGeoJSON(data)
+		if err != nil {
+			log.WithError(err).Fatal("invalid candidates")
+		}
+
+		data, err = ioutil.ReadFile(predictTarget)
+		if err != nil {
+			log.WithError(err).Fatal("reading area")
+		}
+
+		area, err := model.ParseAreaGeoJSON(data)
+		if err != nil {
+			log.WithError(err).Fatal("invalid area")
+		}
+
+		p, _ := getPredictor(cmd, false)
+		options := getPredictionOptions(cmd)
+
+		placement, err := prediction.PlaceGateways(p.Gateways(), prediction.NewCandidates(candidates, p.Gateways(), options), area, options, prediction.PlacementOptions{
+			CellSize:  predictCellSize,
+			Picks:     predictPicks,
+			Objective: predictObjective,
+		})
+		if err != nil {
+			log.WithError(err).Fatal("placing gateways")
+		}
+
+		if placementFormat == "json" {
+			if err := json.NewEncoder(os.Stdout).Encode(placement); err != nil {
+				log.WithError(err).Fatal("encoding placement")
+			}
+			return
+		}
+
+		placement.WriteReport(os.Stdout)
+	},
+}
+
+// getPredictionOptions returns the link budget of the config file with the
+// flags that are set.
+func getPredictionOptions(cmd *cobra.Command) prediction.Options {
+	options := utils.PredictionOptions()
+
+	floats := map[string]*float64{
+		"tx-power":    &options.TxPower,
+		"device-gain": &options.DeviceGain,
+		"fade-margin": &options.FadeMargin,
+		"exponent":    &options.Exponent,
+		"sigma":       &options.Sigma,
+	}
+
+	for name, value := range floats {
+		if cmd.Flags().Changed(name) {
+			*value, _ = cmd.Flags().GetFloat64(name)
+		}
+	}
+
+	return options
+}
+
+// getPredictor creates the predictor of the configured gateways, fitted on
+// the receptions matching the filter unless --no-fit is set. The receptions
+// are only queried for fitting, unless load is set.
+func getPredictor(cmd *cobra.Command, load bool) (prediction.Predictor, []model.Reception) {
+	options := getPredictionOptions(cmd)
+
+	db := connectDatabase()
+	defer db.Close()
+
+	gateways := getGateways(db)
+	if len(gateways) == 0 {
+		log.WithError(prediction.ErrNoGateways).Fatal("reading gateways")
+	}
+
+	var receptions []model.Reception
+
+	if !predictNoFit || load {
+		var err error
+
+		receptions, err = model.GetReceptions(db, getMetricName(), getFilter())
+		if err != nil {
+			log.WithError(err).Fatal("querying receptions")
+		}
+	}
+
+	fitted := receptions
+	if predictNoFit {
+		fitted = nil
+	}
+
+	return prediction.NewPredictor(prediction.NewGateways(gateways, fitted, options), options), receptions
+}
+
+func init() {
+	RootCmd.AddCommand(predictCmd)
+	predictCmd.AddCommand(predictPointCmd)
+	predictCmd.AddCommand(predictLayerCmd)
+	predictCmd.AddCommand(predictValidateCmd)
+	predictCmd.AddCommand(predictPlacementCmd)
+
+	defaults := prediction.DefaultOptions()
+
+	predictCmd.PersistentFlags().Float64Var(&predictTxPower, "tx-power", defaults.TxPower, "transmit power in dBm (default is prediction.txpower from the config or 14)")
+	predictCmd.PersistentFlags().Float64Var(&predictDeviceGain, "device-gain", defaults.DeviceGain, "antenna gain of the end device in dBi")
+	predictCmd.PersistentFlags().Float64Var(&predictFadeMargin, "fade-margin", defaults.FadeMargin, "margin in dB above the demodulation floor for the best spreading factor")
+	predictCmd.PersistentFlags().Float64Var(&predictExponent, "exponent", defaults.Exponent, "path loss exponent of the default model")
+	predictCmd.PersistentFlags().Float64Var(&predictSigma, "sigma", defaults.Sigma, "shadowing of the default model in dB")
+	predictCmd.PersistentFlags().BoolVar(&predictNoFit, "no-fit", false, "use the default model for every gateway")
+
+	predictLayerCmd.Flags().StringVarP(&predictFormat, "format", "f", "geojson", "output format: geojson or raster")
+	predictLayerCmd.Flags().StringVar(&predictValue, "value", prediction.ValueSF, "value of the raster: sf, rssi or snr")
+	predictLayerCmd.Flags().Float64Var(&predictCellSize, "cell-size", 100, "size of the cells in meters")
+	predictLayerCmd.Flags().StringVar(&predictArea, "area", "", "area of the layer [min_lon,min_lat,max_lon,max_lat] (default is the range of the gateways)")
+	predictLayerCmd.Flags().StringVarP(&predictOutput, "output", "o", "", "name of the output file (default is prediction.geojson or prediction.json)")
+	predictLayerCmd.Flags().StringVarP(&predictCallback, "callback", "c", "", "name of the callback function (jsonp)")
+
+	predictPlacementCmd.Flags().StringVar(&predictCandidates, "candidates", "", "GeoJSON file with the candidate sites")
+	predictPlacementCmd.Flags().StringVar(&predictTarget, "area", "", "GeoJSON file with the target area")
+	predictPlacementCmd.Flags().IntVar(&predictPicks, "picks", 1, "number of sites to pick")
+	predictPlacementCmd.Flags().StringVar(&predictObjective, "objective", prediction.ObjectiveCoverage, "objective: coverage or sf")
+	predictPlacementCmd.Flags().Float64Var(&predictCellSize, "cell-size", 100, "size of the cells in meters")
+	predictPlacementCmd.Flags().StringVarP(&placementFormat, "format", "f", "table", "output format: table or json")
+	predictPlacementCmd.MarkFlagRequired("candidates")
+	predictPlacementCmd.MarkFlagRequired("area")
+
+	addFilterFlags(predictPointCmd)
+	addFilterFlags(predictLayerCmd)
+	addFilterFlags(predictValidateCmd)
+	addFilterFlags(predictPlacementCmd)
+}
@@ -0,0 +1,142 @@
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/apex/log"
+	"github.com/bullettime/lora-mapper/analytics"
+	"github.com/bullettime/lora-mapper/model"
+	"github.com/spf13/cobra"
+)
+
+var (
+	redundancyGrid     string
+	redundancyOutput   string
+	redundancyCallback string
+)
+
+// redundancyCmd represents the redundancy command
+var redundancyCmd = &cobra.Command{
+	Use:   "redundancy",
+	Short: "Analyse how many gateways hear every location",
+	Long: `lora-mapper redundancy counts the distinct gateways that received in every cell
+of a grid, in total and per data rate, with the best and second best gateway
+by median rssi. It prints the number of cells per number of gateways and the
+cells that depend on a single gateway.
+
+With --output the cells are written as GeoJSON polygons as well.
+The data can be limited with the --campaign, --from, --to, --device, --gateway and --bbox flags.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		db := connectDatabase()
+		defer db.Close()
+
+		receptions, err := model.GetReceptions(db, getMetricName(), getFilter())
+		if err != nil {
+			log.WithError(err).Fatal("querying receptions")
+		}
+
+		grid, err := getGridOptions(redundancyGrid).NewGrid(model.ReceptionLocations(receptions))
+		if err != nil {
+			log.WithError(err).Fatal("invalid grid")
+		}
+
+		cells := analytics.Redundancy(grid, receptions)
+		summary := analytics.SummarizeRedundancy(cells)
+
+		fmt.Printf("%d cells, %.2f gateways on average, %d (%.0f%%) depend on a single gateway\n\n",
+			summary.Cells, summary.MeanGateways, summary.SingleGateway, summary.SingleGatewayShare*100)
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "GATEWAYS\tCELLS")
+		var counts []int
+		for n := range summary.Histogram {
+			counts = append(counts, n)
+		}
+		sort.Ints(counts)
+		for _, n := range counts {
+			fmt.Fprintf(w, "%d\t%d\n", n, summary.Histogram[n])
+		}
+		w.Flush()
+
+		fmt.Println()
+
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "DATA RATE\tCELLS\tSINGLE GATEWAY\tREDUNDANT")
+		for sf := 7; sf <= 12; sf++ {
+			if d, ok := summary.DataRates[model.DataRate(sf)]; ok {
+				fmt.Fprintf(w, "%s\t%d\t%d\t%d\n", model.DataRate(sf), d.Cells, d.SingleGateway, d.Redundant)
+			}
+		}
+		w.Flush()
+
+		if len(summary.Dependencies) > 0 {
+			fmt.Println()
+
+			w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "GATEWAY\tSINGLE GATEWAY CELLS")
+			var gateways []string
+			for g := range summary.Dependencies {
+				gateways = append(gateways, g)
+			}
+			sort.Strings(gateways)
+			for _, g := range gateways {
+				fmt.Fprintf(w, "%s\t%d\n", g, summary.Dependencies[g])
+			}
+			w.Flush()
+		}
+
+		if redundancyOutput == "" {
+			return
+		}
+
+		data, err := analytics.RedundancyGeoJSON(grid, cells, redundancyCallback)
+		if err != nil {
+			log.WithError(err).Fatal("creating geojson")
+		}
+
+		if err := ioutil.WriteFile(redundancyOutput, []byte(data), 0644); err != nil {
+			log.WithError(err).Fatal("writing output file")
+		}
+
+		log.WithFields(log.Fields{
+			"filename": redundancyOutput,
+			"cells":    len(cells),
+		}).Info("redundancy cells written")
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(redundancyCmd)
+
+	redundancyCmd.Flags().StringVar(&redundancyGrid, "grid", "", "shape of the grid cells: square or hexagon (default is grid.shape from the config or square)")
+	redundancyCmd.Flags().Float64Var(&gridSize, "cell-size", 0, "size of the grid cells in meters (default is grid.size from the config or 100)")
+	redundancyCmd.Flags().StringVar(&gridOrigin, "origin", "", "origin of the grid [lat,lon] (default is grid.origin from the config)")
+	redundancyCmd.Flags().StringVarP(&redundancyOutput, "output", "o", "", "name of the GeoJSON output file of the cells")
+	redundancyCmd.Flags().StringVarP(&redundancyCallback, "callback", "c", "", "name of the callback function (jsonp)")
+	addFilterFlags(redundancyCmd)
+}
@@ -0,0 +1,152 @@
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"text/tabwriter"
+
+	"github.com/apex/log"
+	"github.com/bullettime/lora-mapper/analytics"
+	"github.com/bullettime/lora-mapper/model"
+	"github.com/spf13/cobra"
+)
+
+var (
+	temporalGrid      string
+	temporalBucket    string
+	temporalAll       bool
+	temporalMinCount  int
+	temporalThreshold float64
+	temporalOutput    string
+	temporalCallback  string
+)
+
+// temporalCmd represents the temporal command
+var temporalCmd = &cobra.Command{
+	Use:   "temporal",
+	Short: "Analyse the coverage by hour of the day, weekday or month",
+	Long: `lora-mapper temporal buckets the receptions by hour of the day, weekday or
+month (--bucket) per cell of a grid and per gateway, in the --timezone. It
+prints the profile of all receptions and the gateways and cells with a
+significant variation: a median rssi of the buckets that differs by at least
+--threshold dB, or a change of the best spreading factor. Only buckets with
+--min-count receptions are compared.
+
+With --output the cells are written as GeoJSON polygons as well.
+The data can be limited with the --campaign, --from, --to, --device, --gateway,
+--bbox, --hours, --weekdays and --months flags.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		db := connectDatabase()
+		defer db.Close()
+
+		filter := getFilter()
+
+		receptions, err := model.GetReceptions(db, getMetricName(), filter)
+		if err != nil {
+			log.WithError(err).Fatal("querying receptions")
+		}
+
+		grid, err := getGridOptions(temporalGrid).NewGrid(model.ReceptionLocations(receptions))
+		if err != nil {
+			log.WithError(err).Fatal("invalid grid")
+		}
+
+		temporal, err := analytics.AnalyseTemporal(grid, receptions, analytics.TemporalOptions{
+			Bucket:    temporalBucket,
+			TimeZone:  filter.Window.TimeZone,
+			MinCount:  temporalMinCount,
+			Threshold: temporalThreshold,
+		})
+		if err != nil {
+			log.WithError(err).Fatal("analysing receptions")
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "BUCKET\tRECEPTIONS\tRSSI\tSNR\tBEST SF")
+		for _, b := range temporal.Summary.Profile {
+			fmt.Fprintf(w, "%s\t%d\t%.1f dBm\t%.1f dB\t%d\n", b.Label, b.Count, b.RSSI, b.SNR, b.BestSF)
+		}
+		w.Flush()
+
+		fmt.Println()
+
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "GATEWAY\tRECEPTIONS\tBUCKETS\tRANGE\tSF RANGE\tBEST\tWORST\tFLAGGED")
+		for _, g := range temporal.Gateways {
+			if g.Flagged || temporalAll {
+				fmt.Fprintf(w, "%s\t%d\t%d\t%.1f dB\t%d\t%s\t%s\t%t\n", g.GatewayID, g.Count, g.Compared, g.Range, g.SFRange, g.Best, g.Worst, g.Flagged)
+			}
+		}
+		w.Flush()
+
+		s := temporal.Summary
+		fmt.Printf("\n%d cells, %d with enough receptions to compare, %d flagged\n\n", s.Cells, s.Compared, s.Flagged)
+
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "CELL\tCENTER\tRECEPTIONS\tBUCKETS\tRANGE\tSF RANGE\tBEST\tWORST")
+		for _, c := range temporal.Cells {
+			if c.Flagged || (temporalAll && c.Compared >= 2) {
+				fmt.Fprintf(w, "%s\t%.6f,%.6f\t%d\t%d\t%.1f dB\t%d\t%s\t%s\n",
+					c.Cell, c.Center.Latitude, c.Center.Longitude, c.Count, c.Compared, c.Range, c.SFRange, c.Best, c.Worst)
+			}
+		}
+		w.Flush()
+
+		if temporalOutput == "" {
+			return
+		}
+
+		data, err := temporal.GeoJSON(temporalCallback)
+		if err != nil {
+			log.WithError(err).Fatal("creating geojson")
+		}
+
+		if err := ioutil.WriteFile(temporalOutput, []byte(data), 0644); err != nil {
+			log.WithError(err).Fatal("writing output file")
+		}
+
+		log.WithFields(log.Fields{
+			"filename": temporalOutput,
+			"cells":    len(temporal.Cells),
+		}).Info("temporal cells written")
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(temporalCmd)
+
+	defaults := analytics.DefaultTemporalOptions()
+
+	temporalCmd.Flags().StringVar(&temporalBucket, "bucket", defaults.Bucket, "bucket of the receptions: hour, weekday or month")
+	temporalCmd.Flags().BoolVar(&temporalAll, "all", false, "print every gateway and compared cell, not only the flagged ones")
+	temporalCmd.Flags().IntVar(&temporalMinCount, "min-count", defaults.MinCount, "minimum number of receptions of a bucket to be compared")
+	temporalCmd.Flags().Float64Var(&temporalThreshold, "threshold", defaults.Threshold, "range of the median rssi in dB of a significant variation")
+	temporalCmd.Flags().StringVar(&temporalGrid, "grid", "", "shape of the grid cells: square or hexagon (default is grid.shape from the config or square)")
+	temporalCmd.Flags().Float64Var(&gridSize, "cell-size", 0, "size of the grid cells in meters (default is grid.size from the config or 100)")
+	temporalCmd.Flags().StringVar(&gridOrigin, "origin", "", "origin of the grid [lat,lon] (default is grid.origin from the config)")
+	temporalCmd.Flags().StringVarP(&temporalOutput, "output", "o", "", "name of the GeoJSON output file of the cells")
+	temporalCmd.Flags().StringVarP(&temporalCallback, "callback", "c", "", "name of the callback function (jsonp)")
+	addFilterFlags(temporalCmd)
+}
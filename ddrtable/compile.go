@@ -0,0 +1,68 @@
+// The MIT License (MIT)
+//
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ddrtable
+
+import (
+	"math"
+
+	"github.com/bullettime/lora-mapper/model"
+)
+
+// Compile creates the table of the area with square cells of size meters,
+// from the recommendation of the ddr grid at the center of every cell. The
+// cells are aligned on the grid, with its longitude scale, so that cells of
+// the size of the grid cells map 1:1 on them (the table can be slightly
+// larger than the area). Cells where the grid falls back on SF12 are left
+// without data.
+func Compile(grid *model.DDRGrid, area model.BoundingBox, size float64) (*Table, error) {
+	minX, minY := grid.Grid.Project(model.LatLon{Latitude: area.MinLatitude, Longitude: area.MinLongitude})
+	maxX, maxY := grid.Grid.Project(model.LatLon{Latitude: area.MaxLatitude, Longitude: area.MaxLongitude})
+
+	column := math.Floor(minX / size)
+	row := math.Floor(minY / size)
+
+	columns := int(math.Ceil(maxX/size) - column)
+	rows := int(math.Ceil(maxY/size) - row)
+
+	min := grid.Grid.Unproject(column*size, row*size)
+	max := grid.Grid.Unproject((column+1)*size, (row+1)*size)
+
+	t, err := New(min.Latitude, min.Longitude, max.Latitude-min.Latitude, max.Longitude-min.Longitude, columns, rows)
+	if err != nil {
+		return nil, err
+	}
+
+	t.ComputedAt = grid.ComputedAt
+
+	for row := 0; row < t.Rows; row++ {
+		for column := 0; column < t.Columns; column++ {
+			lat, lon := t.Center(column, row)
+
+			if r := grid.Lookup(model.LatLon{Latitude: lat, Longitude: lon}); !r.Fallback {
+				t.Set(column, row, r.SF)
+			}
+		}
+	}
+
+	return t, nil
+}
@@ -0,0 +1,358 @@
+// The MIT License (MIT)
+//
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package ddrtable encodes the recommended spreading factors of an area into a
+// compact binary lookup table for device firmware, and is the reference
+// decoder of the format.
+//
+// A table is a grid of cells of a fixed size in degrees, row by row from the
+// south-west corner to the north. All values are little endian:
+//
+//	offset size
+//	0      4    magic "DDRT"
+//	4      1    version (1)
+//	5      1    encoding: 0 packed, 1 run-length
+//	6      2    reserved (0)
+//	8      4    latitude of the south-west corner (int32, 1e-7 degrees)
+//	12     4    longitude of the south-west corner (int32, 1e-7 degrees)
+//	16     4    height of a cell (int32, 1e-7 degrees)
+//	20     4    width of a cell (int32, 1e-7 degrees)
+//	24     2    columns (uint16)
+//	26     2    rows (uint16)
+//	28     4    time of the computation (uint32, unix seconds)
+//	32     4    length of the cells in bytes (uint32)
+//	36     n    cells
+//	36+n   4    CRC-32 (IEEE) of the header and the cells
+//
+// Every cell is a 3 bit code: 0 without data, 1 - 6 for SF7 - SF12. Packed
+// cells fill the bytes from the least significant bit on, a cell can span two
+// bytes. Run-length cells are bytes with the code in the 3 most significant
+// bits and the length of the run minus one (1 - 32 cells) in the other 5.
+package ddrtable
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"math"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	Version = 1
+
+	EncodingPacked    = 0
+	EncodingRunLength = 1
+
+	HeaderSize = 36
+	// MaxCells limits the size of a table.
+	MaxCells = 1 << 22
+
+	degreeScale = 1e7
+	maxRun      = 32
+)
+
+var (
+	magic = [4]byte{'D', 'D', 'R', 'T'}
+
+	ErrMagic    = errors.New("not a ddr table")
+	ErrVersion  = errors.New("unsupported ddr table version")
+	ErrChecksum = errors.New("ddr table checksum mismatch")
+	ErrSize     = errors.New("invalid ddr table size")
+)
+
+// Table holds the spreading factor (7 - 12, 0 without data) of every cell,
+// row by row from the south-west corner.
+type Table struct {
+	MinLatitude   float64
+	MinLongitude  float64
+	CellLatitude  float64
+	CellLongitude float64
+	Columns       int
+	Rows          int
+	ComputedAt    time.Time
+	Cells         []uint8
+}
+
+type header struct {
+	Magic         [4]byte
+	Version       uint8
+	Encoding      uint8
+	Reserved      uint16
+	MinLatitude   int32
+	MinLongitude  int32
+	CellLatitude  int32
+	CellLongitude int32
+	Columns       uint16
+	Rows          uint16
+	ComputedAt    uint32
+	Length        uint32
+}
+
+// New creates an empty table over the area from the south-west corner with
+// cells of the size in degrees.
+func New(minLatitude, minLongitude, cellLatitude, cellLongitude float64, columns, rows int) (*Table, error) {
+	if columns < 1 || rows < 1 || columns > math.MaxUint16 || rows > math.MaxUint16 || columns*rows > MaxCells {
+		return nil, errors.Wrapf(ErrSize, "%dx%d cells", columns, rows)
+	}
+
+	if cellLatitude <= 0 || cellLongitude <= 0 {
+		return nil, errors.Wrapf(ErrSize, "cells of %vx%v degrees", cellLatitude, cellLongitude)
+	}
+
+	return &Table{
+		MinLatitude:   quantize(minLatitude),
+		MinLongitude:  quantize(minLongitude),
+		CellLatitude:  quantize(cellLatitude),
+		CellLongitude: quantize(cellLongitude),
+		Columns:       columns,
+		Rows:          rows,
+		Cells:         make([]uint8, columns*rows),
+	}, nil
+}
+
+// quantize rounds the degrees to the resolution of the format.
+func quantize(degrees float64) float64 {
+	return float64(fixed(degrees)) / degreeScale
+}
+
+func fixed(degrees float64) int32 {
+	return int32(math.Floor(degrees*degreeScale + 0.5))
+}
+
+// Cell returns the column and row of the location, false outside of the
+// table.
+func (t *Table) Cell(latitude, longitude float64) (column, row int, ok bool) {
+	column = int(math.Floor((longitude - t.MinLongitude) / t.CellLongitude))
+	row = int(math.Floor((latitude - t.MinLatitude) / t.CellLatitude))
+
+	ok = column >= 0 && row >= 0 && column < t.Columns && row < t.Rows
+
+	return
+}
+
+// Center returns the latitude and longitude of the center of the cell.
+func (t *Table) Center(column, row int) (latitude, longitude float64) {
+	return t.MinLatitude + (float64(row)+0.5)*t.CellLatitude, t.MinLongitude + (float64(column)+0.5)*t.CellLongitude
+}
+
+// Set sets the spreading factor of the cell, any value outside of 7 - 12
+// clears it.
+func (t *Table) Set(column, row int, sf int) {
+	if sf < 7 || sf > 12 {
+		sf = 0
+	}
+
+	t.Cells[row*t.Columns+column] = uint8(sf)
+}
+
+// Lookup returns the spreading factor at the location, false outside of the
+// table or without data.
+func (t *Table) Lookup(latitude, longitude float64) (int, bool) {
+	column, row, ok := t.Cell(latitude, longitude)
+	if !ok {
+		return 0, false
+	}
+
+	sf := int(t.Cells[row*t.Columns+column])
+
+	return sf, sf != 0
+}
+
+func code(sf uint8) uint8 {
+	if sf < 7 || sf > 12 {
+		return 0
+	}
+
+	return sf - 6
+}
+
+func spreadingFactor(code uint8) uint8 {
+	if code == 0 || code > 6 {
+		return 0
+	}
+
+	return code + 6
+}
+
+func (t *Table) packed() []byte {
+	data := make([]byte, (len(t.Cells)*3+7)/8)
+
+	for i, sf := range t.Cells {
+		bit := i * 3
+		v := uint16(code(sf)) << uint(bit%8)
+
+		data[bit/8] |= byte(v)
+		if bit%8 > 5 {
+			data[bit/8+1] |= byte(v >> 8)
+		}
+	}
+
+	return data
+}
+
+func (t *Table) runLength() []byte {
+	var data []byte
+
+	for i := 0; i < len(t.Cells); {
+		c := code(t.Cells[i])
+
+		n := 1
+		for i+n < len(t.Cells) && n < maxRun && code(t.Cells[i+n]) == c {
+			n++
+		}
+
+		data = append(data, c<<5|byte(n-1))
+		i += n
+	}
+
+	return data
+}
+
+// MarshalBinary encodes the table with the smallest of the two encodings.
+func (t *Table) MarshalBinary() ([]byte, error) {
+	if t.Columns*t.Rows != len(t.Cells) {
+		return nil, errors.Wrapf(ErrSize, "%d cells for %dx%d", len(t.Cells), t.Columns, t.Rows)
+	}
+
+	encoding := uint8(EncodingPacked)
+	cells := t.packed()
+
+	if rl := t.runLength(); len(rl) < len(cells) {
+		encoding = EncodingRunLength
+		cells = rl
+	}
+
+	var computedAt uint32
+	if !t.ComputedAt.IsZero() {
+		computedAt = uint32(t.ComputedAt.Unix())
+	}
+
+	h := header{
+		Magic:         magic,
+		Version:       Version,
+		Encoding:      encoding,
+		MinLatitude:   fixed(t.MinLatitude),
+		MinLongitude:  fixed(t.MinLongitude),
+		CellLatitude:  fixed(t.CellLatitude),
+		CellLongitude: fixed(t.CellLongitude),
+		Columns:       uint16(t.Columns),
+		Rows:          uint16(t.Rows),
+		ComputedAt:    computedAt,
+		Length:        uint32(len(cells)),
+	}
+
+	var buf bytes.Buffer
+
+	binary.Write(&buf, binary.LittleEndian, h)
+	buf.Write(cells)
+	binary.Write(&buf, binary.LittleEndian, crc32.ChecksumIEEE(buf.Bytes()))
+
+	return buf.Bytes(), nil
+}
+
+// Decode reads a table and verifies its checksum.
+func Decode(data []byte) (*Table, error) {
+	var h header
+
+	if len(data) < HeaderSize+4 {
+		return nil, errors.Wrap(ErrSize, "too short")
+	}
+
+	if err := binary.Read(bytes.NewReader(data[:HeaderSize]), binary.LittleEndian, &h); err != nil {
+		return nil, errors.Wrap(err, "reading header")
+	}
+
+	if h.Magic != magic {
+		return nil, ErrMagic
+	}
+
+	if h.Version != Version {
+		return nil, errors.Wrapf(ErrVersion, "%d", h.Version)
+	}
+
+	end := HeaderSize + int(h.Length)
+	if len(data) != end+4 {
+		return nil, errors.Wrapf(ErrSize, "%d bytes for %d bytes of cells", len(data), h.Length)
+	}
+
+	if crc32.ChecksumIEEE(data[:end]) != binary.LittleEndian.Uint32(data[end:]) {
+		return nil, ErrChecksum
+	}
+
+	t := &Table{
+		MinLatitude:   float64(h.MinLatitude) / degreeScale,
+		MinLongitude:  float64(h.MinLongitude) / degreeScale,
+		CellLatitude:  float64(h.CellLatitude) / degreeScale,
+		CellLongitude: float64(h.CellLongitude) / degreeScale,
+		Columns:       int(h.Columns),
+		Rows:          int(h.Rows),
+		Cells:         make([]uint8, int(h.Columns)*int(h.Rows)),
+	}
+
+	if h.ComputedAt != 0 {
+		t.ComputedAt = time.Unix(int64(h.ComputedAt), 0).UTC()
+	}
+
+	cells := data[HeaderSize:end]
+
+	switch h.Encoding {
+	case EncodingPacked:
+		if len(cells) != (len(t.Cells)*3+7)/8 {
+			return nil, errors.Wrapf(ErrSize, "%d bytes of packed cells for %d cells", len(cells), len(t.Cells))
+		}
+
+		for i := range t.Cells {
+			bit := i * 3
+			v := uint16(cells[bit/8])
+			if bit/8+1 < len(cells) {
+				v |= uint16(cells[bit/8+1]) << 8
+			}
+
+			t.Cells[i] = spreadingFactor(uint8(v>>uint(bit%8)) & 7)
+		}
+	case EncodingRunLength:
+		i := 0
+
+		for _, b := range cells {
+			n := int(b&31) + 1
+			if i+n > len(t.Cells) {
+				return nil, errors.Wrap(ErrSize, "runs beyond the last cell")
+			}
+
+			for sf := spreadingFactor(b >> 5); n > 0; n-- {
+				t.Cells[i] = sf
+				i++
+			}
+		}
+
+		if i != len(t.Cells) {
+			return nil, errors.Wrapf(ErrSize, "runs of %d cells for %d cells", i, len(t.Cells))
+		}
+	default:
+		return nil, errors.Errorf("unknown encoding: %d", h.Encoding)
+	}
+
+	return t, nil
+}
@@ -0,0 +1,164 @@
+// The MIT License (MIT)
+//
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ddrtable
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/bullettime/lora-mapper/model"
+	"github.com/pkg/errors"
+)
+
+func testTable(t *testing.T, random bool) *Table {
+	table, err := New(50.99, 4.69, 0.0009, 0.0014, 37, 23)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	table.ComputedAt = time.Date(2018, 4, 1, 12, 0, 0, 0, time.UTC)
+
+	r := rand.New(rand.NewSource(1))
+	for row := 0; row < table.Rows; row++ {
+		for column := 0; column < table.Columns; column++ {
+			sf := 7 + row/4
+			if random {
+				sf = 6 + r.Intn(7)
+			}
+			table.Set(column, row, sf)
+		}
+	}
+
+	return table
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, random := range []bool{false, true} {
+		table := testTable(t, random)
+
+		data, err := table.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// rows of the same sf compress, random cells don't
+		encoding := data[5]
+		if random && encoding != EncodingPacked || !random && encoding != EncodingRunLength {
+			t.Errorf("random %t: unexpected encoding %d", random, encoding)
+		}
+
+		decoded, err := Decode(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if decoded.MinLatitude != table.MinLatitude || decoded.CellLongitude != table.CellLongitude ||
+			decoded.Columns != table.Columns || decoded.Rows != table.Rows || !decoded.ComputedAt.Equal(table.ComputedAt) {
+			t.Errorf("random %t: wrong header %+v", random, decoded)
+		}
+
+		for i := range table.Cells {
+			if decoded.Cells[i] != table.Cells[i] {
+				t.Fatalf("random %t: cell %d is %d instead of %d", random, i, decoded.Cells[i], table.Cells[i])
+			}
+		}
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	data, err := testTable(t, true).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	corrupt := append([]byte(nil), data...)
+	corrupt[HeaderSize+3] ^= 0x10
+	if _, err := Decode(corrupt); err != ErrChecksum {
+		t.Errorf("expected checksum error, got %v", err)
+	}
+
+	version := append([]byte(nil), data...)
+	version[4] = 2
+	if _, err := Decode(version); errors.Cause(err) != ErrVersion {
+		t.Errorf("expected version error, got %v", err)
+	}
+
+	if _, err := Decode(data[:len(data)-1]); errors.Cause(err) != ErrSize {
+		t.Errorf("expected size error, got %v", err)
+	}
+
+	if _, err := Decode([]byte("not a ddr table at all, just some text that is long enough")); err != ErrMagic {
+		t.Errorf("expected magic error, got %v", err)
+	}
+}
+
+func TestCompile(t *testing.T) {
+	ts := time.Date(2018, 4, 1, 12, 0, 0, 0, time.UTC)
+	ll := model.LatLon{Latitude: 51, Longitude: 4.7}
+
+	var receptions []model.Reception
+	for i := 0; i < 5; i++ {
+		receptions = append(receptions, model.Reception{Location: ll, GatewayID: "a", SF: 12, RSSI: -90, SNR: 8, Time: ts})
+	}
+
+	// an origin away from the area, where the longitude scale differs
+	origin := model.LatLon{Latitude: 50, Longitude: 4}
+
+	grid, err := model.ComputeDDRGrid(receptions, model.DefaultDDRGridSize, &origin, 100, model.DefaultDDROptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	area := model.BoundingBox{MinLatitude: 50.99, MinLongitude: 4.69, MaxLatitude: 51.01, MaxLongitude: 4.71}
+
+	table, err := Compile(grid, area, 25)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if sf, ok := table.Lookup(ll.Latitude, ll.Longitude); !ok || sf != 7 {
+		t.Errorf("expected SF7 at the receptions, got %d", sf)
+	}
+
+	if _, ok := table.Lookup(50.995, 4.695); ok {
+		t.Error("expected no data far from the receptions")
+	}
+
+	if _, ok := table.Lookup(52, 4.7); ok {
+		t.Error("expected no data outside of the table")
+	}
+
+	// the cells of the table are the cells of the grid, up to the far edge
+	for _, column := range []int{0, table.Columns - 1} {
+		for _, row := range []int{0, table.Rows - 1} {
+			lat, lon := table.Center(column, row)
+			center := grid.Grid.Center(grid.Grid.Cell(model.LatLon{Latitude: lat, Longitude: lon}))
+
+			if math.Abs(center.Latitude-lat) > 1e-5 || math.Abs(center.Longitude-lon) > 1e-5 {
+				t.Errorf("cell %d,%d: center %v,%v isn't the center %v of the grid cell", column, row, lat, lon, center)
+			}
+		}
+	}
+}
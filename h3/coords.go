@@ -0,0 +1,607 @@
+// The MIT License (MIT)
+//
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package h3
+
+import "math"
+
+const (
+	numFaces     = 20
+	numBaseCells = 122
+	maxFaceCoord = 2
+
+	sqrt7         = 2.6457513110645905905016157536392604257102
+	sqrt3Over2    = 0.8660254037844386467637231707529361834714
+	ap7RotRads    = 0.333473172251832115336090755351601070065900389
+	res0UGnomonic = 0.38196601125010500003
+	epsilon       = 0.0000000000000001
+)
+
+// The digits of an index are the directions of the child cells from the
+// center of their parent, as unit vectors in ijk coordinates.
+const (
+	centerDigit = iota
+	kAxesDigit
+	jAxesDigit
+	jkAxesDigit
+	iAxesDigit
+	ikAxesDigit
+	ijAxesDigit
+	invalidDigit
+)
+
+var unitVecs = [7]coordIJK{
+	{0, 0, 0},
+	{0, 0, 1},
+	{0, 1, 0},
+	{0, 1, 1},
+	{1, 0, 0},
+	{1, 0, 1},
+	{1, 1, 0},
+}
+
+type geoCoord struct {
+	lat, lon float64
+}
+
+type vec2d struct {
+	x, y float64
+}
+
+type vec3d struct {
+	x, y, z float64
+}
+
+// coordIJK are coordinates on a hexagonal grid with three axes 120 degrees
+// apart, of which at most two components are non-zero once normalized.
+type coordIJK struct {
+	i, j, k int
+}
+
+type faceIJK struct {
+	face  int
+	coord coordIJK
+}
+
+type faceOrientIJK struct {
+	face      int
+	translate coordIJK
+	ccwRot60  int
+}
+
+type baseCellInfo struct {
+	home         faceIJK
+	isPentagon   bool
+	cwOffsetPent [2]int
+}
+
+type baseCellRotation struct {
+	baseCell int
+	ccwRot60 int
+}
+
+type overage int
+
+const (
+	noOverage overage = iota
+	faceEdge
+	newFace
+)
+
+var faceCenterPoint [numFaces]vec3d
+
+func init() {
+	for f, g := range faceCenterGeo {
+		faceCenterPoint[f] = g.toVec3d()
+	}
+}
+
+func isClassIII(res int) bool {
+	return res%2 == 1
+}
+
+// maxDimClassII is the number of cells along the edge of a face at a Class II
+// resolution, unitScaleClassII the length of a res 0 unit vector.
+func maxDimClassII(res int) int {
+	return 2 * unitScaleClassII(res)
+}
+
+func unitScaleClassII(res int) int {
+	scale := 1
+	for r := 0; r < res/2; r++ {
+		scale *= 7
+	}
+	return scale
+}
+
+func posAngle(a float64) float64 {
+	if a < 0 {
+		a += 2 * math.Pi
+	}
+	if a >= 2*math.Pi {
+		a -= 2 * math.Pi
+	}
+	return a
+}
+
+func constrainLon(lon float64) float64 {
+	for lon > math.Pi {
+		lon -= 2 * math.Pi
+	}
+	for lon < -math.Pi {
+		lon += 2 * math.Pi
+	}
+	return lon
+}
+
+func lround(v float64) int {
+	if v < 0 {
+		return -int(math.Floor(-v + 0.5))
+	}
+	return int(math.Floor(v + 0.5))
+}
+
+func (g geoCoord) toVec3d() vec3d {
+	r := math.Cos(g.lat)
+
+	return vec3d{
+		x: math.Cos(g.lon) * r,
+		y: math.Sin(g.lon) * r,
+		z: math.Sin(g.lat),
+	}
+}
+
+func (v vec3d) squareDistance(w vec3d) float64 {
+	dx, dy, dz := v.x-w.x, v.y-w.y, v.z-w.z
+	return dx*dx + dy*dy + dz*dz
+}
+
+// azimuth returns the azimuth in radians from g to p.
+func (g geoCoord) azimuth(p geoCoord) float64 {
+	return math.Atan2(math.Cos(p.lat)*math.Sin(p.lon-g.lon),
+		math.Cos(g.lat)*math.Sin(p.lat)-math.Sin(g.lat)*math.Cos(p.lat)*math.Cos(p.lon-g.lon))
+}
+
+// destination returns the point at the distance (in radians on the unit
+// sphere) from g in the direction of the azimuth.
+func (g geoCoord) destination(az, distance float64) geoCoord {
+	if distance < epsilon {
+		return g
+	}
+
+	var p geoCoord
+
+	az = posAngle(az)
+
+	if az < epsilon || math.Abs(az-math.Pi) < epsilon {
+		// due north or south
+		if az < epsilon {
+			p.lat = g.lat + distance
+		} else {
+			p.lat = g.lat - distance
+		}
+
+		if math.Abs(p.lat-math.Pi/2) < epsilon {
+			return geoCoord{lat: math.Pi / 2}
+		} else if math.Abs(p.lat+math.Pi/2) < epsilon {
+			return geoCoord{lat: -math.Pi / 2}
+		}
+
+		p.lon = constrainLon(g.lon)
+		return p
+	}
+
+	sinLat := clamp(math.Sin(g.lat)*math.Cos(distance) + math.Cos(g.lat)*math.Sin(distance)*math.Cos(az))
+	p.lat = math.Asin(sinLat)
+
+	if math.Abs(p.lat-math.Pi/2) < epsilon {
+		return geoCoord{lat: math.Pi / 2}
+	} else if math.Abs(p.lat+math.Pi/2) < epsilon {
+		return geoCoord{lat: -math.Pi / 2}
+	}
+
+	sinLon := clamp(math.Sin(az) * math.Sin(distance) / math.Cos(p.lat))
+	cosLon := clamp((math.Cos(distance) - math.Sin(g.lat)*math.Sin(p.lat)) / math.Cos(g.lat) / math.Cos(p.lat))
+	p.lon = constrainLon(g.lon + math.Atan2(sinLon, cosLon))
+
+	return p
+}
+
+func clamp(v float64) float64 {
+	if v > 1 {
+		return 1
+	}
+	if v < -1 {
+		return -1
+	}
+	return v
+}
+
+// geoToHex2d projects g on the closest face with a gnomonic projection and
+// returns the face and the position in the hex grid of the resolution.
+func geoToHex2d(g geoCoord, res int) (int, vec2d) {
+	p := g.toVec3d()
+
+	face := 0
+	sqd := 5.0
+
+	for f := 0; f < numFaces; f++ {
+		if d := faceCenterPoint[f].squareDistance(p); d < sqd {
+			face = f
+			sqd = d
+		}
+	}
+
+	r := math.Acos(1 - sqd/2)
+	if r < epsilon {
+		return face, vec2d{}
+	}
+
+	theta := posAngle(faceAxisAzimuth[face] - posAngle(faceCenterGeo[face].azimuth(g)))
+
+	if isClassIII(res) {
+		theta = posAngle(theta - ap7RotRads)
+	}
+
+	r = math.Tan(r) / res0UGnomonic
+	for i := 0; i < res; i++ {
+		r *= sqrt7
+	}
+
+	return face, vec2d{x: r * math.Cos(theta), y: r * math.Sin(theta)}
+}
+
+// hex2dToGeo is the inverse of geoToHex2d. Substrate coordinates are a grid
+// with an extra aperture 3 (and 7 for Class III), used for the vertices.
+func hex2dToGeo(v vec2d, face, res int, substrate bool) geoCoord {
+	r := math.Hypot(v.x, v.y)
+	if r < epsilon {
+		return faceCenterGeo[face]
+	}
+
+	theta := math.Atan2(v.y, v.x)
+
+	for i := 0; i < res; i++ {
+		r /= sqrt7
+	}
+
+	if substrate {
+		r /= 3
+		if isClassIII(res) {
+			r /= sqrt7
+		}
+	}
+
+	r = math.Atan(r * res0UGnomonic)
+
+	if !substrate && isClassIII(res) {
+		theta = posAngle(theta + ap7RotRads)
+	}
+
+	theta = posAngle(faceAxisAzimuth[face] - theta)
+
+	return faceCenterGeo[face].destination(theta, r)
+}
+
+// hex2dToCoordIJK returns the ijk coordinates of the hexagon containing v.
+func hex2dToCoordIJK(v vec2d) coordIJK {
+	var c coordIJK
+
+	a1 := math.Abs(v.x)
+	a2 := math.Abs(v.y)
+
+	x2 := a2 / sqrt3Over2
+	x1 := a1 + x2/2
+
+	m1 := int(x1)
+	m2 := int(x2)
+
+	r1 := x1 - float64(m1)
+	r2 := x2 - float64(m2)
+
+	if r1 < 0.5 {
+		if r1 < 1.0/3.0 {
+			c.i = m1
+			if r2 < (1+r1)/2 {
+				c.j = m2
+			} else {
+				c.j = m2 + 1
+			}
+		} else {
+			if r2 < 1-r1 {
+				c.j = m2
+			} else {
+				c.j = m2 + 1
+			}
+
+			if 1-r1 <= r2 && r2 < 2*r1 {
+				c.i = m1 + 1
+			} else {
+				c.i = m1
+			}
+		}
+	} else {
+		if r1 < 2.0/3.0 {
+			if r2 < 1-r1 {
+				c.j = m2
+			} else {
+				c.j = m2 + 1
+			}
+
+			if 2*r1-1 < r2 && r2 < 1-r1 {
+				c.i = m1
+			} else {
+				c.i = m1 + 1
+			}
+		} else {
+			if r2 < r1/2 {
+				c.i = m1 + 1
+				c.j = m2
+			} else {
+				c.i = m1 + 1
+				c.j = m2 + 1
+			}
+		}
+	}
+
+	// fold across the axes if necessary
+	if v.x < 0 {
+		if c.j%2 == 0 {
+			axis := c.j / 2
+			c.i = c.i - 2*(c.i-axis)
+		} else {
+			axis := (c.j + 1) / 2
+			c.i = c.i - (2*(c.i-axis) + 1)
+		}
+	}
+
+	if v.y < 0 {
+		c.i = c.i - (2*c.j+1)/2
+		c.j = -c.j
+	}
+
+	return c.normalize()
+}
+
+func (c coordIJK) toHex2d() vec2d {
+	i := c.i - c.k
+	j := c.j - c.k
+
+	return vec2d{
+		x: float64(i) - 0.5*float64(j),
+		y: float64(j) * sqrt3Over2,
+	}
+}
+
+func (c coordIJK) add(o coordIJK) coordIJK {
+	return coordIJK{c.i + o.i, c.j + o.j, c.k + o.k}
+}
+
+func (c coordIJK) sub(o coordIJK) coordIJK {
+	return coordIJK{c.i - o.i, c.j - o.j, c.k - o.k}
+}
+
+func (c coordIJK) scale(factor int) coordIJK {
+	return coordIJK{c.i * factor, c.j * factor, c.k * factor}
+}
+
+// normalize makes all components non-negative with at least one of them zero.
+func (c coordIJK) normalize() coordIJK {
+	if c.i < 0 {
+		c.j -= c.i
+		c.k -= c.i
+		c.i = 0
+	}
+
+	if c.j < 0 {
+		c.i -= c.j
+		c.k -= c.j
+		c.j = 0
+	}
+
+	if c.k < 0 {
+		c.i -= c.k
+		c.j -= c.k
+		c.k = 0
+	}
+
+	min := c.i
+	if c.j < min {
+		min = c.j
+	}
+	if c.k < min {
+		min = c.k
+	}
+
+	if min > 0 {
+		c.i -= min
+		c.j -= min
+		c.k -= min
+	}
+
+	return c
+}
+
+// transform returns the coordinates expressed in the basis of the vectors.
+func (c coordIJK) transform(iVec, jVec, kVec coordIJK) coordIJK {
+	return iVec.scale(c.i).add(jVec.scale(c.j)).add(kVec.scale(c.k)).normalize()
+}
+
+// upAp7 returns the coordinates of the parent in the next coarser aperture 7
+// grid (counter clockwise rotated), upAp7r of the clockwise rotated one.
+func (c coordIJK) upAp7() coordIJK {
+	i := float64(c.i - c.k)
+	j := float64(c.j - c.k)
+
+	return coordIJK{lround((3*i - j) / 7), lround((i + 2*j) / 7), 0}.normalize()
+}
+
+func (c coordIJK) upAp7r() coordIJK {
+	i := float64(c.i - c.k)
+	j := float64(c.j - c.k)
+
+	return coordIJK{lround((2*i + j) / 7), lround((3*j - i) / 7), 0}.normalize()
+}
+
+// downAp7 returns the coordinates of the center child in the next finer
+// aperture 7 grid (counter clockwise rotated), downAp7r of the clockwise one.
+func (c coordIJK) downAp7() coordIJK {
+	return c.transform(coordIJK{3, 0, 1}, coordIJK{1, 3, 0}, coordIJK{0, 1, 3})
+}
+
+func (c coordIJK) downAp7r() coordIJK {
+	return c.transform(coordIJK{3, 1, 0}, coordIJK{0, 3, 1}, coordIJK{1, 0, 3})
+}
+
+// downAp3 and downAp3r go to the next finer aperture 3 grid.
+func (c coordIJK) downAp3() coordIJK {
+	return c.transform(coordIJK{2, 0, 1}, coordIJK{1, 2, 0}, coordIJK{0, 1, 2})
+}
+
+func (c coordIJK) downAp3r() coordIJK {
+	return c.transform(coordIJK{2, 1, 0}, coordIJK{0, 2, 1}, coordIJK{1, 0, 2})
+}
+
+func (c coordIJK) rotate60ccw() coordIJK {
+	return c.transform(coordIJK{1, 1, 0}, coordIJK{0, 1, 1}, coordIJK{1, 0, 1})
+}
+
+func (c coordIJK) rotate60cw() coordIJK {
+	return c.transform(coordIJK{1, 0, 1}, coordIJK{1, 1, 0}, coordIJK{0, 1, 1})
+}
+
+// neighbor returns the coordinates of the adjacent cell in the direction of
+// the digit.
+func (c coordIJK) neighbor(digit int) coordIJK {
+	if digit > centerDigit && digit < invalidDigit {
+		return c.add(unitVecs[digit]).normalize()
+	}
+	return c
+}
+
+// unitDigit returns the digit of a unit vector, or invalidDigit.
+func (c coordIJK) unitDigit() int {
+	c = c.normalize()
+
+	for digit, u := range unitVecs {
+		if c == u {
+			return digit
+		}
+	}
+
+	return invalidDigit
+}
+
+func rotateDigit60ccw(digit int) int {
+	switch digit {
+	case kAxesDigit:
+		return ikAxesDigit
+	case ikAxesDigit:
+		return iAxesDigit
+	case iAxesDigit:
+		return ijAxesDigit
+	case ijAxesDigit:
+		return jAxesDigit
+	case jAxesDigit:
+		return jkAxesDigit
+	case jkAxesDigit:
+		return kAxesDigit
+	default:
+		return digit
+	}
+}
+
+func rotateDigit60cw(digit int) int {
+	switch digit {
+	case kAxesDigit:
+		return jkAxesDigit
+	case jkAxesDigit:
+		return jAxesDigit
+	case jAxesDigit:
+		return ijAxesDigit
+	case ijAxesDigit:
+		return iAxesDigit
+	case iAxesDigit:
+		return ikAxesDigit
+	case ikAxesDigit:
+		return kAxesDigit
+	default:
+		return digit
+	}
+}
+
+// adjustOverage moves Class II coordinates that fall outside of their face to
+// the neighbouring face. With pentLeading4 the coordinates of a pentagon with
+// a leading 4 digit are rotated around the pentagon first.
+func (f *faceIJK) adjustOverage(res int, pentLeading4, substrate bool) overage {
+	maxDim := maxDimClassII(res)
+	if substrate {
+		maxDim *= 3
+	}
+
+	sum := f.coord.i + f.coord.j + f.coord.k
+
+	if substrate && sum == maxDim {
+		return faceEdge
+	}
+
+	if sum <= maxDim {
+		return noOverage
+	}
+
+	var orient faceOrientIJK
+
+	if f.coord.k > 0 {
+		if f.coord.j > 0 {
+			orient = faceNeighbors[f.face][3]
+		} else {
+			orient = faceNeighbors[f.face][2]
+
+			if pentLeading4 {
+				// rotate around the center of the pentagon
+				origin := coordIJK{maxDim, 0, 0}
+				f.coord = f.coord.sub(origin).normalize().rotate60cw().add(origin).normalize()
+			}
+		}
+	} else {
+		orient = faceNeighbors[f.face][1]
+	}
+
+	f.face = orient.face
+
+	for i := 0; i < orient.ccwRot60; i++ {
+		f.coord = f.coord.rotate60ccw()
+	}
+
+	unitScale := unitScaleClassII(res)
+	if substrate {
+		unitScale *= 3
+	}
+
+	f.coord = f.coord.add(orient.translate.scale(unitScale)).normalize()
+
+	if substrate && f.coord.i+f.coord.j+f.coord.k == maxDim {
+		return faceEdge
+	}
+
+	return newFace
+}
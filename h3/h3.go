@@ -0,0 +1,430 @@
+// The MIT License (MIT)
+//
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package h3 computes H3 indexes, the hierarchical hexagonal grid used by
+// the community coverage maps (Helium, TTN Mapper) to exchange data.
+package h3
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// MaxResolution is the finest resolution of the grid (about 1 m2 cells).
+const MaxResolution = 15
+
+const (
+	modeCell = 1
+
+	modeOffset      = 59
+	resOffset       = 52
+	baseCellOffset  = 45
+	digitBits       = 3
+	digitMask       = 7
+	resMask         = 15
+	baseCellMask    = 127
+	modeMask        = 15
+	reservedMask    = 7
+	reservedOffset  = 56
+	highBitOffset   = 63
+	initIndex       = Index(0x1fffffffffff)
+	numHexVertices  = 6
+	numPentVertices = 5
+)
+
+var (
+	ErrResolution = errors.New("resolution out of range")
+	ErrLatLon     = errors.New("invalid coordinate")
+	ErrIndex      = errors.New("invalid h3 index")
+)
+
+// Index identifies a cell of the H3 grid.
+type Index uint64
+
+// Coord is a coordinate in degrees.
+type Coord struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// FromLatLon returns the index of the cell at the resolution containing the
+// coordinate (in degrees).
+func FromLatLon(latitude, longitude float64, res int) (Index, error) {
+	if res < 0 || res > MaxResolution {
+		return 0, ErrResolution
+	}
+
+	if math.IsNaN(latitude) || math.IsNaN(longitude) || math.IsInf(latitude, 0) || math.IsInf(longitude, 0) {
+		return 0, ErrLatLon
+	}
+
+	g := geoCoord{
+		lat: latitude * math.Pi / 180,
+		lon: longitude * math.Pi / 180,
+	}
+
+	face, v := geoToHex2d(g, res)
+
+	return faceIJKToIndex(faceIJK{face: face, coord: hex2dToCoordIJK(v)}, res), nil
+}
+
+// ParseIndex parses the hexadecimal representation of an index.
+func ParseIndex(s string) (Index, error) {
+	v, err := strconv.ParseUint(s, 16, 64)
+	if err != nil {
+		return 0, errors.Wrapf(ErrIndex, "parsing %s", s)
+	}
+
+	h := Index(v)
+	if !h.IsValid() {
+		return 0, errors.Wrapf(ErrIndex, "parsing %s", s)
+	}
+
+	return h, nil
+}
+
+func (h Index) String() string {
+	return fmt.Sprintf("%x", uint64(h))
+}
+
+// MarshalText encodes the index as its hexadecimal representation.
+func (h Index) MarshalText() ([]byte, error) {
+	return []byte(h.String()), nil
+}
+
+// UnmarshalText decodes the hexadecimal representation of an index.
+func (h *Index) UnmarshalText(text []byte) error {
+	i, err := ParseIndex(string(text))
+	if err != nil {
+		return err
+	}
+
+	*h = i
+	return nil
+}
+
+// Resolution returns the resolution of the cell.
+func (h Index) Resolution() int {
+	return int(h>>resOffset) & resMask
+}
+
+// BaseCell returns the res 0 cell the cell descends from.
+func (h Index) BaseCell() int {
+	return int(h>>baseCellOffset) & baseCellMask
+}
+
+// IsPentagon reports whether the cell is one of the 12 pentagons of its
+// resolution.
+func (h Index) IsPentagon() bool {
+	return baseCellData[h.BaseCell()].isPentagon && h.leadingDigit() == centerDigit
+}
+
+// IsValid reports whether h is a valid cell index.
+func (h Index) IsValid() bool {
+	if h>>highBitOffset != 0 || int(h>>modeOffset)&modeMask != modeCell || int(h>>reservedOffset)&reservedMask != 0 {
+		return false
+	}
+
+	if h.BaseCell() >= numBaseCells {
+		return false
+	}
+
+	res := h.Resolution()
+	pentagon := baseCellData[h.BaseCell()].isPentagon
+	leading := true
+
+	for r := 1; r <= MaxResolution; r++ {
+		digit := h.digit(r)
+
+		if r > res {
+			if digit != invalidDigit {
+				return false
+			}
+			continue
+		}
+
+		if digit == invalidDigit {
+			return false
+		}
+
+		if leading && digit != centerDigit {
+			// the k sub-sequence of pentagons is deleted
+			if pentagon && digit == kAxesDigit {
+				return false
+			}
+			leading = false
+		}
+	}
+
+	return true
+}
+
+// Parent returns the index of the cell containing h at the coarser
+// resolution.
+func (h Index) Parent(res int) (Index, error) {
+	if res < 0 || res > h.Resolution() {
+		return 0, ErrResolution
+	}
+
+	p := h.withResolution(res)
+
+	for r := res + 1; r <= h.Resolution(); r++ {
+		p = p.withDigit(r, invalidDigit)
+	}
+
+	return p, nil
+}
+
+// Center returns the center of the cell.
+func (h Index) Center() Coord {
+	f := h.toFaceIJK()
+	g := hex2dToGeo(f.coord.toHex2d(), f.face, h.Resolution(), false)
+
+	return toCoord(g)
+}
+
+// Boundary returns the vertices of the cell in counter clockwise order. When
+// the cell crosses an edge of the icosahedron, the boundary is approximated
+// by the straight line between the vertices on both faces.
+func (h Index) Boundary() []Coord {
+	f := h.toFaceIJK()
+	res := h.Resolution()
+
+	// the vertices of an origin-centered cell on the aperture 3 substrate
+	// grid, which has a vertex at every position
+	verts := []coordIJK{{2, 1, 0}, {1, 2, 0}, {0, 2, 1}, {0, 1, 2}, {1, 0, 2}, {2, 0, 1}}
+	if isClassIII(res) {
+		verts = []coordIJK{{5, 4, 0}, {1, 5, 0}, {0, 5, 4}, {0, 1, 5}, {4, 0, 5}, {5, 0, 1}}
+	}
+
+	pentagon := h.IsPentagon()
+	if pentagon {
+		verts = verts[:numPentVertices]
+	}
+
+	center := f.coord.downAp3().downAp3r()
+
+	adjRes := res
+	if isClassIII(res) {
+		center = center.downAp7r()
+		adjRes++
+	}
+
+	boundary := make([]Coord, 0, len(verts))
+
+	for _, vert := range verts {
+		v := faceIJK{face: f.face, coord: center.add(vert).normalize()}
+
+		if pentagon {
+			for v.adjustOverage(adjRes, false, true) == newFace {
+			}
+		} else {
+			v.adjustOverage(adjRes, false, true)
+		}
+
+		boundary = append(boundary, toCoord(hex2dToGeo(v.coord.toHex2d(), v.face, adjRes, true)))
+	}
+
+	return boundary
+}
+
+func toCoord(g geoCoord) Coord {
+	return Coord{
+		Latitude:  g.lat * 180 / math.Pi,
+		Longitude: constrainLon(g.lon) * 180 / math.Pi,
+	}
+}
+
+func (h Index) digit(res int) int {
+	return int(h>>(uint(MaxResolution-res)*digitBits)) & digitMask
+}
+
+func (h Index) withDigit(res, digit int) Index {
+	shift := uint(MaxResolution-res) * digitBits
+	return h&^(Index(digitMask)<<shift) | Index(digit)<<shift
+}
+
+func (h Index) withResolution(res int) Index {
+	return h&^(Index(resMask)<<resOffset) | Index(res)<<resOffset
+}
+
+func (h Index) withBaseCell(baseCell int) Index {
+	return h&^(Index(baseCellMask)<<baseCellOffset) | Index(baseCell)<<baseCellOffset
+}
+
+// leadingDigit returns the first digit that is not the center digit.
+func (h Index) leadingDigit() int {
+	for r := 1; r <= h.Resolution(); r++ {
+		if d := h.digit(r); d != centerDigit {
+			return d
+		}
+	}
+	return centerDigit
+}
+
+func (h Index) rotate60ccw() Index {
+	for r := 1; r <= h.Resolution(); r++ {
+		h = h.withDigit(r, rotateDigit60ccw(h.digit(r)))
+	}
+	return h
+}
+
+func (h Index) rotate60cw() Index {
+	for r := 1; r <= h.Resolution(); r++ {
+		h = h.withDigit(r, rotateDigit60cw(h.digit(r)))
+	}
+	return h
+}
+
+// rotatePent60ccw rotates the digits of a pentagon, skipping the deleted k
+// sub-sequence.
+func (h Index) rotatePent60ccw() Index {
+	found := false
+
+	for r := 1; r <= h.Resolution(); r++ {
+		h = h.withDigit(r, rotateDigit60ccw(h.digit(r)))
+
+		if !found && h.digit(r) != centerDigit {
+			found = true
+
+			if h.leadingDigit() == kAxesDigit {
+				h = h.rotate60ccw()
+			}
+		}
+	}
+
+	return h
+}
+
+func isCwOffset(baseCell, face int) bool {
+	offsets := baseCellData[baseCell].cwOffsetPent
+	return offsets[0] == face || offsets[1] == face
+}
+
+// faceIJKToIndex walks up the aperture 7 hierarchy to find the digits and the
+// base cell of the coordinates, then rotates the digits into the orientation
+// of the base cell.
+func faceIJKToIndex(f faceIJK, res int) Index {
+	h := initIndex | Index(modeCell)<<modeOffset
+	h = h.withResolution(res)
+
+	c := f.coord
+
+	for r := res - 1; r >= 0; r-- {
+		last := c
+
+		var center coordIJK
+
+		if isClassIII(r + 1) {
+			c = c.upAp7()
+			center = c.downAp7()
+		} else {
+			c = c.upAp7r()
+			center = c.downAp7r()
+		}
+
+		h = h.withDigit(r+1, last.sub(center).unitDigit())
+	}
+
+	if c.i > maxFaceCoord || c.j > maxFaceCoord || c.k > maxFaceCoord {
+		return 0
+	}
+
+	rotation := faceIJKBaseCells[f.face][c.i][c.j][c.k]
+	h = h.withBaseCell(rotation.baseCell)
+
+	if baseCellData[rotation.baseCell].isPentagon {
+		if h.leadingDigit() == kAxesDigit {
+			if isCwOffset(rotation.baseCell, f.face) {
+				h = h.rotate60cw()
+			} else {
+				h = h.rotate60ccw()
+			}
+		}
+
+		for i := 0; i < rotation.ccwRot60; i++ {
+			h = h.rotatePent60ccw()
+		}
+	} else {
+		for i := 0; i < rotation.ccwRot60; i++ {
+			h = h.rotate60ccw()
+		}
+	}
+
+	return h
+}
+
+// toFaceIJK returns the face and coordinates of the cell center, starting on
+// the home face of the base cell and moving to a neighbouring face if needed.
+func (h Index) toFaceIJK() faceIJK {
+	baseCell := h.BaseCell()
+	pentagon := baseCellData[baseCell].isPentagon
+
+	if pentagon && h.leadingDigit() == ikAxesDigit {
+		h = h.rotate60cw()
+	}
+
+	f := baseCellData[baseCell].home
+	res := h.Resolution()
+
+	for r := 1; r <= res; r++ {
+		if isClassIII(r) {
+			f.coord = f.coord.downAp7()
+		} else {
+			f.coord = f.coord.downAp7r()
+		}
+
+		f.coord = f.coord.neighbor(h.digit(r))
+	}
+
+	if !pentagon && (res == 0 || baseCellData[baseCell].home.coord == coordIJK{}) {
+		// the cell can't leave the face of a base cell at a face center
+		return f
+	}
+
+	orig := f.coord
+
+	adjRes := res
+	if isClassIII(res) {
+		// work in the next finer Class II grid
+		f.coord = f.coord.downAp7r()
+		adjRes++
+	}
+
+	if f.adjustOverage(adjRes, pentagon && h.leadingDigit() == iAxesDigit, false) != noOverage {
+		if pentagon {
+			for f.adjustOverage(adjRes, false, false) != noOverage {
+			}
+		}
+
+		if adjRes != res {
+			f.coord = f.coord.upAp7r()
+		}
+	} else if adjRes != res {
+		f.coord = orig
+	}
+
+	return f
+}
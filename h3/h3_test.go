@@ -0,0 +1,165 @@
+// The MIT License (MIT)
+//
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package h3
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestFromLatLon(t *testing.T) {
+	tests := []struct {
+		lat, lon float64
+		res      int
+		want     string
+	}{
+		{37.3615593, -122.0553238, 7, "87283472bffffff"},
+		{37.775938728915946, -122.41795063018799, 9, "8928308280fffff"},
+		{37.769377, -122.388903, 9, "89283082e73ffff"},
+		{40.689167, -74.044444, 10, "8a2a1072b59ffff"},
+	}
+
+	for _, test := range tests {
+		h, err := FromLatLon(test.lat, test.lon, test.res)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if h.String() != test.want {
+			t.Errorf("FromLatLon(%v, %v, %d) = %s, want %s", test.lat, test.lon, test.res, h, test.want)
+		}
+	}
+
+	if _, err := FromLatLon(0, 0, 16); err != ErrResolution {
+		t.Errorf("resolution 16 should give %v, got %v", ErrResolution, err)
+	}
+}
+
+func TestIndex_Center(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+
+	for n := 0; n < 20000; n++ {
+		lat := math.Asin(2*r.Float64()-1) * 180 / math.Pi
+		lon := 360*r.Float64() - 180
+		res := r.Intn(MaxResolution + 1)
+
+		h, err := FromLatLon(lat, lon, res)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !h.IsValid() {
+			t.Fatalf("%s (%v, %v res %d) is not valid", h, lat, lon, res)
+		}
+
+		c := h.Center()
+
+		if back, _ := FromLatLon(c.Latitude, c.Longitude, res); back != h {
+			t.Fatalf("center of %s (%v, %v res %d) is in %s", h, lat, lon, res, back)
+		}
+
+		// the distance to the center is at most the edge length of the cell,
+		// 1108 km at res 0, with some slack for the distortion of the faces
+		if d := distance(Coord{lat, lon}, c); d > 1.3*1107.7/math.Pow(math.Sqrt(7), float64(res)) {
+			t.Fatalf("center of %s is %v km from (%v, %v)", h, d, lat, lon)
+		}
+	}
+}
+
+func TestIndex_Boundary(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+
+	for n := 0; n < 2000; n++ {
+		lat := math.Asin(2*r.Float64()-1) * 180 / math.Pi
+		lon := 360*r.Float64() - 180
+		res := 2 + r.Intn(MaxResolution-1)
+
+		h, _ := FromLatLon(lat, lon, res)
+		c := h.Center()
+		b := h.Boundary()
+
+		want := 6
+		if h.IsPentagon() {
+			want = 5
+		}
+
+		if len(b) != want {
+			t.Fatalf("%s has %d vertices", h, len(b))
+		}
+
+		// the vertices are about the edge length away from the center
+		for _, v := range b {
+			d := distance(c, v)
+			e := 1107.7 / math.Pow(math.Sqrt(7), float64(res))
+
+			if d < 0.5*e || d > 1.5*e {
+				t.Fatalf("vertex %v of %s is %v km from the center, edge is %v km", v, h, d, e)
+			}
+		}
+	}
+}
+
+func TestIndex_Parent(t *testing.T) {
+	h, _ := FromLatLon(37.775938728915946, -122.41795063018799, 9)
+
+	p, err := h.Parent(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want, _ := FromLatLon(37.775938728915946, -122.41795063018799, 7); p != want {
+		t.Errorf("parent of %s is %s, want %s", h, p, want)
+	}
+
+	if _, err := h.Parent(10); err != ErrResolution {
+		t.Errorf("parent at a finer resolution should give %v, got %v", ErrResolution, err)
+	}
+}
+
+func TestParseIndex(t *testing.T) {
+	h, err := ParseIndex("8928308280fffff")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if h.Resolution() != 9 || h.BaseCell() != 20 {
+		t.Errorf("8928308280fffff has resolution %d and base cell %d", h.Resolution(), h.BaseCell())
+	}
+
+	for _, s := range []string{"", "zz", "8928308280ffff7", "0"} {
+		if _, err := ParseIndex(s); err == nil {
+			t.Errorf("%q should not be a valid index", s)
+		}
+	}
+}
+
+func distance(a, b Coord) float64 {
+	lat1 := a.Latitude * math.Pi / 180
+	lat2 := b.Latitude * math.Pi / 180
+	dLon := (b.Longitude - a.Longitude) * math.Pi / 180
+
+	cos := math.Sin(lat1)*math.Sin(lat2) + math.Cos(lat1)*math.Cos(lat2)*math.Cos(dLon)
+
+	return 6371.0088 * math.Acos(math.Min(1, math.Max(-1, cos)))
+}
@@ -0,0 +1,674 @@
+// The MIT License (MIT)
+//
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package h3
+
+// The tables below describe the icosahedron the grid is projected on. They are
+// the tables of the H3 reference implementation (github.com/uber/h3), which
+// define the base cells and their orientation on the faces.
+
+// faceCenterGeo holds the center of every icosahedron face in radians.
+var faceCenterGeo = [numFaces]geoCoord{
+	{0.803582649718989942, 1.248397419617396099},
+	{1.307747883455638156, 2.536945009877921159},
+	{1.054751253523952054, -1.347517358900396623},
+	{0.600191595538186799, -0.450603909469755746},
+	{0.491715428198773866, 0.401988202911306943},
+	{0.172745327415618701, 1.678146885280433686},
+	{0.605929321571350690, 2.953923329812411617},
+	{0.427370518328979641, -1.888876200336285401},
+	{-0.079066118549212831, -0.733429513380867741},
+	{-0.230961644455383637, 0.506495587332349035},
+	{0.079066118549212831, 2.408163140208925497},
+	{0.230961644455383637, -2.635097066257444203},
+	{-0.172745327415618701, -1.463445768309359553},
+	{-0.605929321571350690, -0.187669323777381622},
+	{-0.427370518328979641, 1.252716453253507838},
+	{-0.600191595538186799, 2.690988744120037492},
+	{-0.491715428198773866, -2.739604450678486295},
+	{-0.803582649718989942, -1.893195233972397139},
+	{-1.307747883455638156, -0.604647643711872080},
+	{-1.054751253523952054, 1.794075294689396615},
+}
+
+// faceAxisAzimuth holds the azimuth in radians of the Class II i-axis of
+// every face, as seen from the face center.
+var faceAxisAzimuth = [numFaces]float64{
+	5.619958268523939882,
+	5.760339081714187279,
+	0.780213654393430055,
+	0.430469363979999913,
+	6.130269123335111400,
+	2.692877706530642877,
+	2.982963003477243874,
+	3.532912002790141181,
+	3.494305004259568154,
+	3.003214169499538391,
+	5.930472956509811562,
+	0.138378484090254847,
+	0.448714947059150361,
+	0.158629650112549365,
+	5.891865957979238535,
+	2.711123289609793325,
+	3.294508837434268316,
+	3.804819692245439833,
+	3.664438879055192436,
+	2.361378999196363184,
+}
+
+// faceNeighbors holds, for every face, the face across each edge together
+// with the translation and number of 60 degree ccw rotations that unfold the
+// neighbour into the plane of the face. The order is central, ij, ki, jk.
+var faceNeighbors = [numFaces][4]faceOrientIJK{
+	{
+		{0, coordIJK{0, 0, 0}, 0},
+		{4, coordIJK{2, 0, 2}, 1},
+		{1, coordIJK{2, 2, 0}, 5},
+		{5, coordIJK{0, 2, 2}, 3},
+	},
+	{
+		{1, coordIJK{0, 0, 0}, 0},
+		{0, coordIJK{2, 0, 2}, 1},
+		{2, coordIJK{2, 2, 0}, 5},
+		{6, coordIJK{0, 2, 2}, 3},
+	},
+	{
+		{2, coordIJK{0, 0, 0}, 0},
+		{1, coordIJK{2, 0, 2}, 1},
+		{3, coordIJK{2, 2, 0}, 5},
+		{7, coordIJK{0, 2, 2}, 3},
+	},
+	{
+		{3, coordIJK{0, 0, 0}, 0},
+		{2, coordIJK{2, 0, 2}, 1},
+		{4, coordIJK{2, 2, 0}, 5},
+		{8, coordIJK{0, 2, 2}, 3},
+	},
+	{
+		{4, coordIJK{0, 0, 0}, 0},
+		{3, coordIJK{2, 0, 2}, 1},
+		{0, coordIJK{2, 2, 0}, 5},
+		{9, coordIJK{0, 2, 2}, 3},
+	},
+	{
+		{5, coordIJK{0, 0, 0}, 0},
+		{10, coordIJK{2, 2, 0}, 3},
+		{14, coordIJK{2, 0, 2}, 3},
+		{0, coordIJK{0, 2, 2}, 3},
+	},
+	{
+		{6, coordIJK{0, 0, 0}, 0},
+		{11, coordIJK{2, 2, 0}, 3},
+		{10, coordIJK{2, 0, 2}, 3},
+		{1, coordIJK{0, 2, 2}, 3},
+	},
+	{
+		{7, coordIJK{0, 0, 0}, 0},
+		{12, coordIJK{2, 2, 0}, 3},
+		{11, coordIJK{2, 0, 2}, 3},
+		{2, coordIJK{0, 2, 2}, 3},
+	},
+	{
+		{8, coordIJK{0, 0, 0}, 0},
+		{13, coordIJK{2, 2, 0}, 3},
+		{12, coordIJK{2, 0, 2}, 3},
+		{3, coordIJK{0, 2, 2}, 3},
+	},
+	{
+		{9, coordIJK{0, 0, 0}, 0},
+		{14, coordIJK{2, 2, 0}, 3},
+		{13, coordIJK{2, 0, 2}, 3},
+		{4, coordIJK{0, 2, 2}, 3},
+	},
+	{
+		{10, coordIJK{0, 0, 0}, 0},
+		{5, coordIJK{2, 2, 0}, 3},
+		{6, coordIJK{2, 0, 2}, 3},
+		{15, coordIJK{0, 2, 2}, 3},
+	},
+	{
+		{11, coordIJK{0, 0, 0}, 0},
+		{6, coordIJK{2, 2, 0}, 3},
+		{7, coordIJK{2, 0, 2}, 3},
+		{16, coordIJK{0, 2, 2}, 3},
+	},
+	{
+		{12, coordIJK{0, 0, 0}, 0},
+		{7, coordIJK{2, 2, 0}, 3},
+		{8, coordIJK{2, 0, 2}, 3},
+		{17, coordIJK{0, 2, 2}, 3},
+	},
+	{
+		{13, coordIJK{0, 0, 0}, 0},
+		{8, coordIJK{2, 2, 0}, 3},
+		{9, coordIJK{2, 0, 2}, 3},
+		{18, coordIJK{0, 2, 2}, 3},
+	},
+	{
+		{14, coordIJK{0, 0, 0}, 0},
+		{9, coordIJK{2, 2, 0}, 3},
+		{5, coordIJK{2, 0, 2}, 3},
+		{19, coordIJK{0, 2, 2}, 3},
+	},
+	{
+		{15, coordIJK{0, 0, 0}, 0},
+		{16, coordIJK{2, 0, 2}, 1},
+		{19, coordIJK{2, 2, 0}, 5},
+		{10, coordIJK{0, 2, 2}, 3},
+	},
+	{
+		{16, coordIJK{0, 0, 0}, 0},
+		{17, coordIJK{2, 0, 2}, 1},
+		{15, coordIJK{2, 2, 0}, 5},
+		{11, coordIJK{0, 2, 2}, 3},
+	},
+	{
+		{17, coordIJK{0, 0, 0}, 0},
+		{18, coordIJK{2, 0, 2}, 1},
+		{16, coordIJK{2, 2, 0}, 5},
+		{12, coordIJK{0, 2, 2}, 3},
+	},
+	{
+		{18, coordIJK{0, 0, 0}, 0},
+		{19, coordIJK{2, 0, 2}, 1},
+		{17, coordIJK{2, 2, 0}, 5},
+		{13, coordIJK{0, 2, 2}, 3},
+	},
+	{
+		{19, coordIJK{0, 0, 0}, 0},
+		{15, coordIJK{2, 0, 2}, 1},
+		{18, coordIJK{2, 2, 0}, 5},
+		{14, coordIJK{0, 2, 2}, 3},
+	},
+}
+
+// baseCellData holds the home face and coordinates of every base cell, and
+// for the pentagons the faces that are offset clockwise.
+var baseCellData = [numBaseCells]baseCellInfo{
+	{faceIJK{1, coordIJK{1, 0, 0}}, false, [2]int{-1, -1}},  // base cell 0
+	{faceIJK{2, coordIJK{1, 1, 0}}, false, [2]int{-1, -1}},  // base cell 1
+	{faceIJK{1, coordIJK{0, 0, 0}}, false, [2]int{-1, -1}},  // base cell 2
+	{faceIJK{2, coordIJK{1, 0, 0}}, false, [2]int{-1, -1}},  // base cell 3
+	{faceIJK{0, coordIJK{2, 0, 0}}, true, [2]int{-1, -1}},   // base cell 4
+	{faceIJK{1, coordIJK{1, 1, 0}}, false, [2]int{-1, -1}},  // base cell 5
+	{faceIJK{1, coordIJK{0, 0, 1}}, false, [2]int{-1, -1}},  // base cell 6
+	{faceIJK{2, coordIJK{0, 0, 0}}, false, [2]int{-1, -1}},  // base cell 7
+	{faceIJK{0, coordIJK{1, 0, 0}}, false, [2]int{-1, -1}},  // base cell 8
+	{faceIJK{2, coordIJK{0, 1, 0}}, false, [2]int{-1, -1}},  // base cell 9
+	{faceIJK{1, coordIJK{0, 1, 0}}, false, [2]int{-1, -1}},  // base cell 10
+	{faceIJK{1, coordIJK{0, 1, 1}}, false, [2]int{-1, -1}},  // base cell 11
+	{faceIJK{3, coordIJK{1, 0, 0}}, false, [2]int{-1, -1}},  // base cell 12
+	{faceIJK{3, coordIJK{1, 1, 0}}, false, [2]int{-1, -1}},  // base cell 13
+	{faceIJK{11, coordIJK{2, 0, 0}}, true, [2]int{2, 6}},    // base cell 14
+	{faceIJK{4, coordIJK{1, 0, 0}}, false, [2]int{-1, -1}},  // base cell 15
+	{faceIJK{0, coordIJK{0, 0, 0}}, false, [2]int{-1, -1}},  // base cell 16
+	{faceIJK{6, coordIJK{0, 1, 0}}, false, [2]int{-1, -1}},  // base cell 17
+	{faceIJK{0, coordIJK{0, 0, 1}}, false, [2]int{-1, -1}},  // base cell 18
+	{faceIJK{2, coordIJK{0, 1, 1}}, false, [2]int{-1, -1}},  // base cell 19
+	{faceIJK{7, coordIJK{0, 0, 1}}, false, [2]int{-1, -1}},  // base cell 20
+	{faceIJK{2, coordIJK{0, 0, 1}}, false, [2]int{-1, -1}},  // base cell 21
+	{faceIJK{0, coordIJK{1, 1, 0}}, false, [2]int{-1, -1}},  // base cell 22
+	{faceIJK{6, coordIJK{0, 0, 1}}, false, [2]int{-1, -1}},  // base cell 23
+	{faceIJK{10, coordIJK{2, 0, 0}}, true, [2]int{1, 5}},    // base cell 24
+	{faceIJK{6, coordIJK{0, 0, 0}}, false, [2]int{-1, -1}},  // base cell 25
+	{faceIJK{3, coordIJK{0, 0, 0}}, false, [2]int{-1, -1}},  // base cell 26
+	{faceIJK{11, coordIJK{1, 0, 0}}, false, [2]int{-1, -1}}, // base cell 27
+	{faceIJK{4, coordIJK{1, 1, 0}}, false, [2]int{-1, -1}},  // base cell 28
+	{faceIJK{3, coordIJK{0, 1, 0}}, false, [2]int{-1, -1}},  // base cell 29
+	{faceIJK{0, coordIJK{0, 1, 1}}, false, [2]int{-1, -1}},  // base cell 30
+	{faceIJK{4, coordIJK{0, 0, 0}}, false, [2]int{-1, -1}},  // base cell 31
+	{faceIJK{5, coordIJK{0, 1, 0}}, false, [2]int{-1, -1}},  // base cell 32
+	{faceIJK{0, coordIJK{0, 1, 0}}, false, [2]int{-1, -1}},  // base cell 33
+	{faceIJK{7, coordIJK{0, 1, 0}}, false, [2]int{-1, -1}},  // base cell 34
+	{faceIJK{11, coordIJK{1, 1, 0}}, false, [2]int{-1, -1}}, // base cell 35
+	{faceIJK{7, coordIJK{0, 0, 0}}, false, [2]int{-1, -1}},  // base cell 36
+	{faceIJK{10, coordIJK{1, 0, 0}}, false, [2]int{-1, -1}}, // base cell 37
+	{faceIJK{12, coordIJK{2, 0, 0}}, true, [2]int{3, 7}},    // base cell 38
+	{faceIJK{6, coordIJK{1, 0, 1}}, false, [2]int{-1, -1}},  // base cell 39
+	{faceIJK{7, coordIJK{1, 0, 1}}, false, [2]int{-1, -1}},  // base cell 40
+	{faceIJK{4, coordIJK{0, 0, 1}}, false, [2]int{-1, -1}},  // base cell 41
+	{faceIJK{3, coordIJK{0, 0, 1}}, false, [2]int{-1, -1}},  // base cell 42
+	{faceIJK{3, coordIJK{0, 1, 1}}, false, [2]int{-1, -1}},  // base cell 43
+	{faceIJK{4, coordIJK{0, 1, 0}}, false, [2]int{-1, -1}},  // base cell 44
+	{faceIJK{6, coordIJK{1, 0, 0}}, false, [2]int{-1, -1}},  // base cell 45
+	{faceIJK{11, coordIJK{0, 0, 0}}, false, [2]int{-1, -1}}, // base cell 46
+	{faceIJK{8, coordIJK{0, 0, 1}}, false, [2]int{-1, -1}},  // base cell 47
+	{faceIJK{5, coordIJK{0, 0, 1}}, false, [2]int{-1, -1}},  // base cell 48
+	{faceIJK{14, coordIJK{2, 0, 0}}, true, [2]int{0, 9}},    // base cell 49
+	{faceIJK{5, coordIJK{0, 0, 0}}, false, [2]int{-1, -1}},  // base cell 50
+	{faceIJK{12, coordIJK{1, 0, 0}}, false, [2]int{-1, -1}}, // base cell 51
+	{faceIJK{10, coordIJK{1, 1, 0}}, false, [2]int{-1, -1}}, // base cell 52
+	{faceIJK{4, coordIJK{0, 1, 1}}, false, [2]int{-1, -1}},  // base cell 53
+	{faceIJK{12, coordIJK{1, 1, 0}}, false, [2]int{-1, -1}}, // base cell 54
+	{faceIJK{7, coordIJK{1, 0, 0}}, false, [2]int{-1, -1}},  // base cell 55
+	{faceIJK{11, coordIJK{0, 1, 0}}, false, [2]int{-1, -1}}, // base cell 56
+	{faceIJK{10, coordIJK{0, 0, 0}}, false, [2]int{-1, -1}}, // base cell 57
+	{faceIJK{13, coordIJK{2, 0, 0}}, true, [2]int{4, 8}},    // base cell 58
+	{faceIJK{10, coordIJK{0, 0, 1}}, false, [2]int{-1, -1}}, // base cell 59
+	{faceIJK{11, coordIJK{0, 0, 1}}, false, [2]int{-1, -1}}, // base cell 60
+	{faceIJK{9, coordIJK{0, 1, 0}}, false, [2]int{-1, -1}},  // base cell 61
+	{faceIJK{8, coordIJK{0, 1, 0}}, false, [2]int{-1, -1}},  // base cell 62
+	{faceIJK{6, coordIJK{2, 0, 0}}, true, [2]int{11, 15}},   // base cell 63
+	{faceIJK{8, coordIJK{0, 0, 0}}, false, [2]int{-1, -1}},  // base cell 64
+	{faceIJK{9, coordIJK{0, 0, 1}}, false, [2]int{-1, -1}},  // base cell 65
+	{faceIJK{14, coordIJK{1, 0, 0}}, false, [2]int{-1, -1}}, // base cell 66
+	{faceIJK{5, coordIJK{1, 0, 1}}, false, [2]int{-1, -1}},  // base cell 67
+	{faceIJK{11, coordIJK{0, 1, 1}}, false, [2]int{-1, -1}}, // base cell 68
+	{faceIJK{8, coordIJK{1, 0, 1}}, false, [2]int{-1, -1}},  // base cell 69
+	{faceIJK{5, coordIJK{1, 0, 0}}, false, [2]int{-1, -1}},  // base cell 70
+	{faceIJK{12, coordIJK{0, 0, 0}}, false, [2]int{-1, -1}}, // base cell 71
+	{faceIJK{7, coordIJK{2, 0, 0}}, true, [2]int{12, 16}},   // base cell 72
+	{faceIJK{12, coordIJK{0, 1, 0}}, false, [2]int{-1, -1}}, // base cell 73
+	{faceIJK{10, coordIJK{0, 1, 0}}, false, [2]int{-1, -1}}, // base cell 74
+	{faceIJK{9, coordIJK{0, 0, 0}}, false, [2]int{-1, -1}},  // base cell 75
+	{faceIJK{13, coordIJK{1, 0, 0}}, false, [2]int{-1, -1}}, // base cell 76
+	{faceIJK{16, coordIJK{0, 0, 1}}, false, [2]int{-1, -1}}, // base cell 77
+	{faceIJK{10, coordIJK{0, 1, 1}}, false, [2]int{-1, -1}}, // base cell 78
+	{faceIJK{15, coordIJK{0, 1, 0}}, false, [2]int{-1, -1}}, // base cell 79
+	{faceIJK{16, coordIJK{0, 1, 0}}, false, [2]int{-1, -1}}, // base cell 80
+	{faceIJK{14, coordIJK{1, 1, 0}}, false, [2]int{-1, -1}}, // base cell 81
+	{faceIJK{13, coordIJK{1, 1, 0}}, false, [2]int{-1, -1}}, // base cell 82
+	{faceIJK{5, coordIJK{2, 0, 0}}, true, [2]int{10, 19}},   // base cell 83
+	{faceIJK{8, coordIJK{1, 0, 0}}, false, [2]int{-1, -1}},  // base cell 84
+	{faceIJK{14, coordIJK{0, 0, 0}}, false, [2]int{-1, -1}}, // base cell 85
+	{faceIJK{9, coordIJK{1, 0, 1}}, false, [2]int{-1, -1}},  // base cell 86
+	{faceIJK{14, coordIJK{0, 0, 1}}, false, [2]int{-1, -1}}, // base cell 87
+	{faceIJK{17, coordIJK{0, 0, 1}}, false, [2]int{-1, -1}}, // base cell 88
+	{faceIJK{12, coordIJK{0, 0, 1}}, false, [2]int{-1, -1}}, // base cell 89
+	{faceIJK{16, coordIJK{0, 0, 0}}, false, [2]int{-1, -1}}, // base cell 90
+	{faceIJK{12, coordIJK{0, 1, 1}}, false, [2]int{-1, -1}}, // base cell 91
+	{faceIJK{15, coordIJK{0, 0, 1}}, false, [2]int{-1, -1}}, // base cell 92
+	{faceIJK{15, coordIJK{1, 1, 0}}, false, [2]int{-1, -1}}, // base cell 93
+	{faceIJK{9, coordIJK{1, 0, 0}}, false, [2]int{-1, -1}},  // base cell 94
+	{faceIJK{15, coordIJK{0, 0, 0}}, false, [2]int{-1, -1}}, // base cell 95
+	{faceIJK{13, coordIJK{0, 0, 0}}, false, [2]int{-1, -1}}, // base cell 96
+	{faceIJK{8, coordIJK{2, 0, 0}}, true, [2]int{13, 17}},   // base cell 97
+	{faceIJK{13, coordIJK{0, 1, 0}}, false, [2]int{-1, -1}}, // base cell 98
+	{faceIJK{16, coordIJK{1, 1, 0}}, false, [2]int{-1, -1}}, // base cell 99
+	{faceIJK{19, coordIJK{0, 1, 0}}, false, [2]int{-1, -1}}, // base cell 100
+	{faceIJK{14, coordIJK{0, 1, 0}}, false, [2]int{-1, -1}}, // base cell 101
+	{faceIJK{14, coordIJK{0, 1, 1}}, false, [2]int{-1, -1}}, // base cell 102
+	{faceIJK{17, coordIJK{0, 1, 0}}, false, [2]int{-1, -1}}, // base cell 103
+	{faceIJK{13, coordIJK{0, 0, 1}}, false, [2]int{-1, -1}}, // base cell 104
+	{faceIJK{17, coordIJK{0, 0, 0}}, false, [2]int{-1, -1}}, // base cell 105
+	{faceIJK{16, coordIJK{1, 0, 0}}, false, [2]int{-1, -1}}, // base cell 106
+	{faceIJK{9, coordIJK{2, 0, 0}}, true, [2]int{14, 18}},   // base cell 107
+	{faceIJK{19, coordIJK{1, 1, 0}}, false, [2]int{-1, -1}}, // base cell 108
+	{faceIJK{15, coordIJK{1, 0, 0}}, false, [2]int{-1, -1}}, // base cell 109
+	{faceIJK{13, coordIJK{0, 1, 1}}, false, [2]int{-1, -1}}, // base cell 110
+	{faceIJK{18, coordIJK{0, 0, 1}}, false, [2]int{-1, -1}}, // base cell 111
+	{faceIJK{19, coordIJK{0, 0, 1}}, false, [2]int{-1, -1}}, // base cell 112
+	{faceIJK{17, coordIJK{1, 0, 0}}, false, [2]int{-1, -1}}, // base cell 113
+	{faceIJK{19, coordIJK{0, 0, 0}}, false, [2]int{-1, -1}}, // base cell 114
+	{faceIJK{18, coordIJK{0, 1, 0}}, false, [2]int{-1, -1}}, // base cell 115
+	{faceIJK{17, coordIJK{1, 1, 0}}, false, [2]int{-1, -1}}, // base cell 116
+	{faceIJK{15, coordIJK{2, 0, 0}}, true, [2]int{-1, -1}},  // base cell 117
+	{faceIJK{19, coordIJK{1, 0, 0}}, false, [2]int{-1, -1}}, // base cell 118
+	{faceIJK{18, coordIJK{0, 0, 0}}, false, [2]int{-1, -1}}, // base cell 119
+	{faceIJK{18, coordIJK{1, 1, 0}}, false, [2]int{-1, -1}}, // base cell 120
+	{faceIJK{18, coordIJK{1, 0, 0}}, false, [2]int{-1, -1}}, // base cell 121
+}
+
+// faceIJKBaseCells holds the base cell and number of 60 degree ccw rotations
+// into the base cell orientation for every res 0 coordinate on every face.
+var faceIJKBaseCells = [numFaces][3][3][3]baseCellRotation{
+	{ // face 0
+		{
+			{{16, 0}, {18, 0}, {24, 0}},
+			{{33, 0}, {30, 0}, {32, 3}},
+			{{49, 1}, {48, 3}, {50, 3}},
+		},
+		{
+			{{8, 0}, {5, 5}, {10, 5}},
+			{{22, 0}, {16, 0}, {18, 0}},
+			{{41, 1}, {33, 0}, {30, 0}},
+		},
+		{
+			{{4, 0}, {0, 5}, {2, 5}},
+			{{15, 1}, {8, 0}, {5, 5}},
+			{{31, 1}, {22, 0}, {16, 0}},
+		},
+	},
+	{ // face 1
+		{
+			{{2, 0}, {6, 0}, {14, 0}},
+			{{10, 0}, {11, 0}, {17, 3}},
+			{{24, 1}, {23, 3}, {25, 3}},
+		},
+		{
+			{{0, 0}, {1, 5}, {9, 5}},
+			{{5, 0}, {2, 0}, {6, 0}},
+			{{18, 1}, {10, 0}, {11, 0}},
+		},
+		{
+			{{4, 1}, {3, 5}, {7, 5}},
+			{{8, 1}, {0, 0}, {1, 5}},
+			{{16, 1}, {5, 0}, {2, 0}},
+		},
+	},
+	{ // face 2
+		{
+			{{7, 0}, {21, 0}, {38, 0}},
+			{{9, 0}, {19, 0}, {34, 3}},
+			{{14, 1}, {20, 3}, {36, 3}},
+		},
+		{
+			{{3, 0}, {13, 5}, {29, 5}},
+			{{1, 0}, {7, 0}, {21, 0}},
+			{{6, 1}, {9, 0}, {19, 0}},
+		},
+		{
+			{{4, 2}, {12, 5}, {26, 5}},
+			{{0, 1}, {3, 0}, {13, 5}},
+			{{2, 1}, {1, 0}, {7, 0}},
+		},
+	},
+	{ // face 3
+		{
+			{{26, 0}, {42, 0}, {58, 0}},
+			{{29, 0}, {43, 0}, {62, 3}},
+			{{38, 1}, {47, 3}, {64, 3}},
+		},
+		{
+			{{12, 0}, {28, 5}, {44, 5}},
+			{{13, 0}, {26, 0}, {42, 0}},
+			{{21, 1}, {29, 0}, {43, 0}},
+		},
+		{
+			{{4, 3}, {15, 5}, {31, 5}},
+			{{3, 1}, {12, 0}, {28, 5}},
+			{{7, 1}, {13, 0}, {26, 0}},
+		},
+	},
+	{ // face 4
+		{
+			{{31, 0}, {41, 0}, {49, 0}},
+			{{44, 0}, {53, 0}, {61, 3}},
+			{{58, 1}, {65, 3}, {75, 3}},
+		},
+		{
+			{{15, 0}, {22, 5}, {33, 5}},
+			{{28, 0}, {31, 0}, {41, 0}},
+			{{42, 1}, {44, 0}, {53, 0}},
+		},
+		{
+			{{4, 4}, {8, 5}, {16, 5}},
+			{{12, 1}, {15, 0}, {22, 5}},
+			{{26, 1}, {28, 0}, {31, 0}},
+		},
+	},
+	{ // face 5
+		{
+			{{50, 0}, {48, 0}, {49, 3}},
+			{{32, 0}, {30, 3}, {33, 3}},
+			{{24, 3}, {18, 3}, {16, 3}},
+		},
+		{
+			{{70, 0}, {67, 0}, {66, 3}},
+			{{52, 3}, {50, 0}, {48, 0}},
+			{{37, 3}, {32, 0}, {30, 3}},
+		},
+		{
+			{{83, 0}, {87, 3}, {85, 3}},
+			{{74, 3}, {70, 0}, {67, 0}},
+			{{57, 3}, {52, 3}, {50, 0}},
+		},
+	},
+	{ // face 6
+		{
+			{{25, 0}, {23, 0}, {24, 3}},
+			{{17, 0}, {11, 3}, {10, 3}},
+			{{14, 3}, {6, 3}, {2, 3}},
+		},
+		{
+			{{45, 0}, {39, 0}, {37, 3}},
+			{{35, 3}, {25, 0}, {23, 0}},
+			{{27, 3}, {17, 0}, {11, 3}},
+		},
+		{
+			{{63, 0}, {59, 3}, {57, 3}},
+			{{56, 3}, {45, 0}, {39, 0}},
+			{{46, 3}, {35, 3}, {25, 0}},
+		},
+	},
+	{ // face 7
+		{
+			{{36, 0}, {20, 0}, {14, 3}},
+			{{34, 0}, {19, 3}, {9, 3}},
+			{{38, 3}, {21, 3}, {7, 3}},
+		},
+		{
+			{{55, 0}, {40, 0}, {27, 3}},
+			{{54, 3}, {36, 0}, {20, 0}},
+			{{51, 3}, {34, 0}, {19, 3}},
+		},
+		{
+			{{72, 0}, {60, 3}, {46, 3}},
+			{{73, 3}, {55, 0}, {40, 0}},
+			{{71, 3}, {54, 3}, {36, 0}},
+		},
+	},
+	{ // face 8
+		{
+			{{64, 0}, {47, 0}, {38, 3}},
+			{{62, 0}, {43, 3}, {29, 3}},
+			{{58, 3}, {42, 3}, {26, 3}},
+		},
+		{
+			{{84, 0}, {69, 0}, {51, 3}},
+			{{82, 3}, {64, 0}, {47, 0}},
+			{{76, 3}, {62, 0}, {43, 3}},
+		},
+		{
+			{{97, 0}, {89, 3}, {71, 3}},
+			{{98, 3}, {84, 0}, {69, 0}},
+			{{96, 3}, {82, 3}, {64, 0}},
+		},
+	},
+	{ // face 9
+		{
+			{{75, 0}, {65, 0}, {58, 3}},
+			{{61, 0}, {53, 3}, {44, 3}},
+			{{49, 3}, {41, 3}, {31, 3}},
+		},
+		{
+			{{94, 0}, {86, 0}, {76, 3}},
+			{{81, 3}, {75, 0}, {65, 0}},
+			{{66, 3}, {61, 0}, {53, 3}},
+		},
+		{
+			{{107, 0}, {104, 3}, {96, 3}},
+			{{101, 3}, {94, 0}, {86, 0}},
+			{{85, 3}, {81, 3}, {75, 0}},
+		},
+	},
+	{ // face 10
+		{
+			{{57, 0}, {59, 0}, {63, 3}},
+			{{74, 0}, {78, 0}, {79, 3}},
+			{{83, 3}, {92, 3}, {95, 3}},
+		},
+		{
+			{{37, 0}, {39, 3}, {45, 3}},
+			{{52, 0}, {57, 0}, {59, 0}},
+			{{70, 3}, {74, 0}, {78, 0}},
+		},
+		{
+			{{24, 0}, {23, 3}, {25, 3}},
+			{{32, 3}, {37, 0}, {39, 3}},
+			{{50, 3}, {52, 0}, {57, 0}},
+		},
+	},
+	{ // face 11
+		{
+			{{46, 0}, {60, 0}, {72, 3}},
+			{{56, 0}, {68, 0}, {80, 3}},
+			{{63, 3}, {77, 3}, {90, 3}},
+		},
+		{
+			{{27, 0}, {40, 3}, {55, 3}},
+			{{35, 0}, {46, 0}, {60, 0}},
+			{{45, 3}, {56, 0}, {68, 0}},
+		},
+		{
+			{{14, 0}, {20, 3}, {36, 3}},
+			{{17, 3}, {27, 0}, {40, 3}},
+			{{25, 3}, {35, 0}, {46, 0}},
+		},
+	},
+	{ // face 12
+		{
+			{{71, 0}, {89, 0}, {97, 3}},
+			{{73, 0}, {91, 0}, {103, 3}},
+			{{72, 3}, {88, 3}, {105, 3}},
+		},
+		{
+			{{51, 0}, {69, 3}, {84, 3}},
+			{{54, 0}, {71, 0}, {89, 0}},
+			{{55, 3}, {73, 0}, {91, 0}},
+		},
+		{
+			{{38, 0}, {47, 3}, {64, 3}},
+			{{34, 3}, {51, 0}, {69, 3}},
+			{{36, 3}, {54, 0}, {71, 0}},
+		},
+	},
+	{ // face 13
+		{
+			{{96, 0}, {104, 0}, {107, 3}},
+			{{98, 0}, {110, 0}, {115, 3}},
+			{{97, 3}, {111, 3}, {119, 3}},
+		},
+		{
+			{{76, 0}, {86, 3}, {94, 3}},
+			{{82, 0}, {96, 0}, {104, 0}},
+			{{84, 3}, {98, 0}, {110, 0}},
+		},
+		{
+			{{58, 0}, {65, 3}, {75, 3}},
+			{{62, 3}, {76, 0}, {86, 3}},
+			{{64, 3}, {82, 0}, {96, 0}},
+		},
+	},
+	{ // face 14
+		{
+			{{85, 0}, {87, 0}, {83, 3}},
+			{{101, 0}, {102, 0}, {100, 3}},
+			{{107, 3}, {112, 3}, {114, 3}},
+		},
+		{
+			{{66, 0}, {67, 3}, {70, 3}},
+			{{81, 0}, {85, 0}, {87, 0}},
+			{{94, 3}, {101, 0}, {102, 0}},
+		},
+		{
+			{{49, 0}, {48, 3}, {50, 3}},
+			{{61, 3}, {66, 0}, {67, 3}},
+			{{75, 3}, {81, 0}, {85, 0}},
+		},
+	},
+	{ // face 15
+		{
+			{{95, 0}, {92, 0}, {83, 0}},
+			{{79, 0}, {78, 3}, {74, 3}},
+			{{63, 1}, {59, 3}, {57, 3}},
+		},
+		{
+			{{109, 0}, {108, 5}, {100, 5}},
+			{{93, 0}, {95, 0}, {92, 0}},
+			{{77, 1}, {79, 0}, {78, 3}},
+		},
+		{
+			{{117, 0}, {118, 5}, {114, 5}},
+			{{106, 1}, {109, 0}, {108, 5}},
+			{{90, 1}, {93, 0}, {95, 0}},
+		},
+	},
+	{ // face 16
+		{
+			{{90, 0}, {77, 0}, {63, 0}},
+			{{80, 0}, {68, 3}, {56, 3}},
+			{{72, 1}, {60, 3}, {46, 3}},
+		},
+		{
+			{{106, 0}, {93, 5}, {79, 5}},
+			{{99, 0}, {90, 0}, {77, 0}},
+			{{88, 1}, {80, 0}, {68, 3}},
+		},
+		{
+			{{117, 4}, {109, 5}, {95, 5}},
+			{{113, 1}, {106, 0}, {93, 5}},
+			{{105, 1}, {99, 0}, {90, 0}},
+		},
+	},
+	{ // face 17
+		{
+			{{105, 0}, {88, 0}, {72, 0}},
+			{{103, 0}, {91, 3}, {73, 3}},
+			{{97, 1}, {89, 3}, {71, 3}},
+		},
+		{
+			{{113, 0}, {99, 5}, {80, 5}},
+			{{116, 0}, {105, 0}, {88, 0}},
+			{{111, 1}, {103, 0}, {91, 3}},
+		},
+		{
+			{{117, 3}, {106, 5}, {90, 5}},
+			{{121, 1}, {113, 0}, {99, 5}},
+			{{119, 1}, {116, 0}, {105, 0}},
+		},
+	},
+	{ // face 18
+		{
+			{{119, 0}, {111, 0}, {97, 0}},
+			{{115, 0}, {110, 3}, {98, 3}},
+			{{107, 1}, {104, 3}, {96, 3}},
+		},
+		{
+			{{121, 0}, {116, 5}, {103, 5}},
+			{{120, 0}, {119, 0}, {111, 0}},
+			{{112, 1}, {115, 0}, {110, 3}},
+		},
+		{
+			{{117, 2}, {113, 5}, {105, 5}},
+			{{118, 1}, {121, 0}, {116, 5}},
+			{{114, 1}, {120, 0}, {119, 0}},
+		},
+	},
+	{ // face 19
+		{
+			{{114, 0}, {112, 0}, {107, 0}},
+			{{100, 0}, {102, 3}, {101, 3}},
+			{{83, 1}, {87, 3}, {85, 3}},
+		},
+		{
+			{{118, 0}, {120, 5}, {115, 5}},
+			{{108, 0}, {114, 0}, {112, 0}},
+			{{92, 1}, {100, 0}, {102, 3}},
+		},
+		{
+			{{117, 1}, {121, 5}, {119, 5}},
+			{{109, 1}, {118, 0}, {120, 5}},
+			{{95, 1}, {108, 0}, {114, 0}},
+		},
+	},
+}
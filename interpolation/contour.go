@@ -0,0 +1,306 @@
+// The MIT License (MIT)
+//
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package interpolation
+
+import (
+	"math"
+	"sort"
+
+	"github.com/bullettime/lora-mapper/model"
+	"github.com/paulmach/go.geojson"
+)
+
+// Contour holds the polygons of the area where the surface is at least the
+// level. Every polygon is an outer ring (counter clockwise) followed by its
+// holes (clockwise), the rings are closed.
+type Contour struct {
+	Level    float64
+	Polygons [][][]model.LatLon
+}
+
+// edgeKey identifies the edge between two neighbouring cell centers, from
+// column, row to the next column (horizontal) or the next row (vertical).
+type edgeKey struct {
+	column, row int
+	vertical    bool
+}
+
+type xy struct {
+	x, y float64
+}
+
+// Levels returns the multiples of step between the lowest and highest value
+// of the surface.
+func (s *Surface) Levels(step float64) []float64 {
+	var levels []float64
+
+	min, max := math.Inf(1), math.Inf(-1)
+
+	for _, v := range s.Values {
+		if !math.IsNaN(v) {
+			min = math.Min(min, v)
+			max = math.Max(max, v)
+		}
+	}
+
+	if step <= 0 || math.IsInf(min, 0) {
+		return levels
+	}
+
+	for l := math.Ceil(min/step) * step; l <= max; l += step {
+		levels = append(levels, l)
+	}
+
+	return levels
+}
+
+// Contours traces the contour polygons of the levels with marching squares
+// between the cell centers. Masked cells and the area around the surface are
+// below every level, so all contours are closed.
+func (s *Surface) Contours(levels []float64) []Contour {
+	contours := make([]Contour, 0, len(levels))
+
+	for _, level := range levels {
+		contours = append(contours, Contour{
+			Level:    level,
+			Polygons: s.contour(level),
+		})
+	}
+
+	return contours
+}
+
+func (s *Surface) contour(level float64) [][][]model.LatLon {
+	inside := func(c, r int) bool {
+		v := s.Value(c, r)
+		return !math.IsNaN(v) && v >= level
+	}
+
+	next := make(map[edgeKey]edgeKey)
+
+	for r := -1; r < s.Rows; r++ {
+		for c := -1; c < s.Columns; c++ {
+			// the corners and edges of the square counter clockwise, from
+			// the south-west corner
+			corners := [4]bool{inside(c, r), inside(c+1, r), inside(c+1, r+1), inside(c, r+1)}
+			edges := [4]edgeKey{{c, r, false}, {c + 1, r, true}, {c, r + 1, false}, {c, r, true}}
+
+			var leave, enter []int
+
+			for i := 0; i < 4; i++ {
+				if corners[i] && !corners[(i+1)%4] {
+					leave = append(leave, i)
+				} else if !corners[i] && corners[(i+1)%4] {
+					enter = append(enter, i)
+				}
+			}
+
+			if len(leave) == 1 {
+				next[edges[leave[0]]] = edges[enter[0]]
+			} else if len(leave) == 2 {
+				// saddle: the center decides whether the inside corners
+				// are connected
+				connected := s.centerInside(c, r, level)
+
+				for _, l := range leave {
+					e := (l + 3) % 4
+					if connected {
+						e = (l + 1) % 4
+					}
+					next[edges[l]] = edges[e]
+				}
+			}
+		}
+	}
+
+	var outers [][]xy
+	var holes [][]xy
+
+	for len(next) > 0 {
+		var start edgeKey
+		for k := range next {
+			start = k
+			break
+		}
+
+		var ring []xy
+
+		for k := start; ; {
+			ring = append(ring, s.crossing(k, level))
+
+			n, ok := next[k]
+			delete(next, k)
+
+			if !ok || n == start {
+				break
+			}
+			k = n
+		}
+
+		if len(ring) < 3 {
+			continue
+		}
+
+		ring = append(ring, ring[0])
+
+		if area(ring) > 0 {
+			outers = append(outers, ring)
+		} else {
+			holes = append(holes, ring)
+		}
+	}
+
+	// largest first, so the rings keep a stable order
+	sort.Slice(outers, func(i, j int) bool {
+		return area(outers[i]) > area(outers[j])
+	})
+
+	polygons := make([][][]xy, len(outers))
+	for i, o := range outers {
+		polygons[i] = [][]xy{o}
+	}
+
+	for _, h := range holes {
+		best := -1
+
+		for i, o := range outers {
+			if contains(o, h[0]) && (best == -1 || area(o) < area(outers[best])) {
+				best = i
+			}
+		}
+
+		if best >= 0 {
+			polygons[best] = append(polygons[best], h)
+		}
+	}
+
+	result := make([][][]model.LatLon, len(polygons))
+
+	for i, p := range polygons {
+		for _, ring := range p {
+			lls := make([]model.LatLon, len(ring))
+			for j, v := range ring {
+				lls[j] = s.Grid.Unproject(v.x, v.y)
+			}
+			result[i] = append(result[i], lls)
+		}
+	}
+
+	return result
+}
+
+func (s *Surface) centerInside(c, r int, level float64) bool {
+	var sum float64
+
+	for _, v := range []float64{s.Value(c, r), s.Value(c+1, r), s.Value(c+1, r+1), s.Value(c, r+1)} {
+		if math.IsNaN(v) {
+			return false
+		}
+		sum += v
+	}
+
+	return sum/4 >= level
+}
+
+// crossing returns the position where the level crosses the edge, linearly
+// interpolated, or halfway when one side is masked.
+func (s *Surface) crossing(k edgeKey, level float64) xy {
+	c2, r2 := k.column+1, k.row
+	if k.vertical {
+		c2, r2 = k.column, k.row+1
+	}
+
+	a := s.Value(k.column, k.row)
+	b := s.Value(c2, r2)
+
+	t := 0.5
+	if !math.IsNaN(a) && !math.IsNaN(b) && a != b {
+		t = math.Min(math.Max((level-a)/(b-a), 0), 1)
+	}
+
+	size := s.Grid.Size()
+	x1 := (float64(s.Min.X+k.column) + 0.5) * size
+	y1 := (float64(s.Min.Y+k.row) + 0.5) * size
+	x2 := (float64(s.Min.X+c2) + 0.5) * size
+	y2 := (float64(s.Min.Y+r2) + 0.5) * size
+
+	return xy{x1 + t*(x2-x1), y1 + t*(y2-y1)}
+}
+
+// area returns the signed area of a closed ring, positive when counter
+// clockwise.
+func area(ring []xy) float64 {
+	var a float64
+
+	for i := 0; i+1 < len(ring); i++ {
+		a += ring[i].x*ring[i+1].y - ring[i+1].x*ring[i].y
+	}
+
+	return a / 2
+}
+
+func contains(ring []xy, p xy) bool {
+	in := false
+
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		a, b := ring[i], ring[j]
+
+		if (a.y > p.y) != (b.y > p.y) && p.x < (b.x-a.x)*(p.y-a.y)/(b.y-a.y)+a.x {
+			in = !in
+		}
+	}
+
+	return in
+}
+
+// ContoursGeoJSON returns the contours as GeoJSON multi polygons (in [lon,
+// lat] order) with the level as property, from the lowest to the highest.
+func ContoursGeoJSON(contours []Contour, callback string) (string, error) {
+	fc := geojson.NewFeatureCollection()
+
+	for _, c := range contours {
+		feature := geojson.NewFeature(c.Geometry())
+		feature.SetProperty("level", c.Level)
+
+		fc.AddFeature(feature)
+	}
+
+	return model.FeatureCollectionJSON(fc, callback)
+}
+
+// Geometry returns the polygons of the contour as a GeoJSON multi polygon.
+func (c Contour) Geometry() *geojson.Geometry {
+	polygons := make([][][][]float64, len(c.Polygons))
+
+	for i, p := range c.Polygons {
+		for _, ring := range p {
+			coordinates := make([][]float64, len(ring))
+			for j, ll := range ring {
+				coordinates[j] = []float64{ll.Longitude, ll.Latitude}
+			}
+			polygons[i] = append(polygons[i], coordinates)
+		}
+	}
+
+	return geojson.NewMultiPolygonGeometry(polygons...)
+}
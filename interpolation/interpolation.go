@@ -0,0 +1,420 @@
+// The MIT License (MIT)
+//
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package interpolation turns the drive-test samples into continuous coverage
+// surfaces on a regular grid, with inverse distance weighting or ordinary
+// kriging, and traces contour polygons on those surfaces.
+package interpolation
+
+import (
+	"encoding/json"
+	"math"
+	"sort"
+
+	"github.com/bullettime/lora-mapper/model"
+	"github.com/pkg/errors"
+)
+
+const (
+	MethodIDW     = "idw"
+	MethodKriging = "kriging"
+
+	// MaxCells limits the size of a surface.
+	MaxCells = 1000000
+)
+
+var ErrNoSamples = errors.New("no samples to interpolate")
+
+type Sample struct {
+	Location model.LatLon
+	Value    float64
+}
+
+type Options struct {
+	Method string
+	// CellSize is the size of the cells of the surface in meters.
+	CellSize float64
+	// MaxDistance masks the cells farther than this many meters from the
+	// closest sample.
+	MaxDistance float64
+	// Power of the inverse distance weights.
+	Power float64
+	// Neighbours is the number of closest samples used for every cell.
+	Neighbours int
+	// Origin of the grid, the default origin of the samples when nil.
+	Origin *model.LatLon
+}
+
+// DefaultOptions returns 50 m cells masked at 500 m from the samples,
+// interpolated by IDW with power 2 from the 16 closest samples.
+func DefaultOptions() Options {
+	return Options{
+		Method:      MethodIDW,
+		CellSize:    50,
+		MaxDistance: 500,
+		Power:       2,
+		Neighbours:  16,
+	}
+}
+
+func (o Options) validate() error {
+	if o.Method != MethodIDW && o.Method != MethodKriging {
+		return errors.Errorf("invalid interpolation method: %s", o.Method)
+	}
+
+	if o.MaxDistance <= 0 {
+		return errors.Errorf("invalid maximum distance: %v", o.MaxDistance)
+	}
+
+	if o.Power <= 0 {
+		return errors.Errorf("invalid power: %v", o.Power)
+	}
+
+	if o.Neighbours < 1 {
+		return errors.Errorf("invalid number of neighbours: %d", o.Neighbours)
+	}
+
+	return nil
+}
+
+// Surface holds the interpolated values at the centers of the cells of a
+// square grid, from the south-west cell Min, row by row to the north. Masked
+// cells hold NaN.
+type Surface struct {
+	Grid     model.Grid
+	Min      model.CellID
+	Columns  int
+	Rows     int
+	Values   []float64
+	Variance []float64
+	// Variogram is the variogram fitted for kriging.
+	Variogram *Variogram
+}
+
+// Value returns the value of the cell in the column and row, NaN when masked
+// or outside of the surface.
+func (s *Surface) Value(column, row int) float64 {
+	if column < 0 || row < 0 || column >= s.Columns || row >= s.Rows {
+		return math.NaN()
+	}
+
+	return s.Values[row*s.Columns+column]
+}
+
+// Center returns the center of the cell in the column and row.
+func (s *Surface) Center(column, row int) model.LatLon {
+	return s.Grid.Center(model.CellID{X: s.Min.X + column, Y: s.Min.Y + row})
+}
+
+// At returns the value of the cell containing the location.
+func (s *Surface) At(ll model.LatLon) (float64, bool) {
+	c := s.Grid.Cell(ll)
+	v := s.Value(c.X-s.Min.X, c.Y-s.Min.Y)
+
+	return v, !math.IsNaN(v)
+}
+
+type surfaceJSON struct {
+	Origin    model.LatLon `json:"origin"`
+	CellSize  float64      `json:"cell_size"`
+	Min       model.CellID `json:"min_cell"`
+	SouthWest model.LatLon `json:"south_west"`
+	Columns   int          `json:"columns"`
+	Rows      int          `json:"rows"`
+	Values    [][]*float64 `json:"values"`
+	Variance  [][]*float64 `json:"variance,omitempty"`
+	Variogram *Variogram   `json:"variogram,omitempty"`
+}
+
+// MarshalJSON encodes the values as rows from south to north, with null for
+// the masked cells.
+func (s *Surface) MarshalJSON() ([]byte, error) {
+	return json.Marshal(surfaceJSON{
+		Origin:    s.Grid.Origin(),
+		CellSize:  s.Grid.Size(),
+		Min:       s.Min,
+		SouthWest: s.Center(0, 0),
+		Columns:   s.Columns,
+		Rows:      s.Rows,
+		Values:    s.rows(s.Values),
+		Variance:  s.rows(s.Variance),
+		Variogram: s.Variogram,
+	})
+}
+
+func (s *Surface) rows(values []float64) [][]*float64 {
+	if values == nil {
+		return nil
+	}
+
+	rows := make([][]*float64, s.Rows)
+
+	for r := range rows {
+		rows[r] = make([]*float64, s.Columns)
+
+		for c := range rows[r] {
+			if v := values[r*s.Columns+c]; !math.IsNaN(v) {
+				rows[r][c] = &v
+			}
+		}
+	}
+
+	return rows
+}
+
+// MergeSamples averages the samples at the same location, which would make
+// the kriging system singular.
+func MergeSamples(samples []Sample) []Sample {
+	type sum struct {
+		total float64
+		count int
+	}
+
+	sums := make(map[model.LatLon]*sum)
+	var locations []model.LatLon
+
+	for _, s := range samples {
+		v, ok := sums[s.Location]
+		if !ok {
+			v = &sum{}
+			sums[s.Location] = v
+			locations = append(locations, s.Location)
+		}
+
+		v.total += s.Value
+		v.count++
+	}
+
+	merged := make([]Sample, len(locations))
+
+	for i, ll := range locations {
+		merged[i] = Sample{Location: ll, Value: sums[ll].total / float64(sums[ll].count)}
+	}
+
+	return merged
+}
+
+// RSSISamples returns the rssi of the receptions as samples.
+func RSSISamples(receptions []model.Reception) []Sample {
+	samples := make([]Sample, len(receptions))
+
+	for i, r := range receptions {
+		samples[i] = Sample{Location: r.Location, Value: r.RSSI}
+	}
+
+	return samples
+}
+
+// Interpolate computes the surface over the samples, extended by the maximum
+// distance on every side.
+func Interpolate(samples []Sample, options Options) (*Surface, error) {
+	if err := options.validate(); err != nil {
+		return nil, err
+	}
+
+	samples = MergeSamples(samples)
+
+	if len(samples) == 0 {
+		return nil, ErrNoSamples
+	}
+
+	locations := make([]model.LatLon, len(samples))
+	for i, s := range samples {
+		locations[i] = s.Location
+	}
+
+	grid, err := model.GridOptions{
+		Shape:  model.ShapeSquare,
+		Size:   options.CellSize,
+		Origin: options.Origin,
+	}.NewGrid(locations)
+	if err != nil {
+		return nil, err
+	}
+
+	points := make([]point, len(samples))
+
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+
+	for i, s := range samples {
+		x, y := grid.Project(s.Location)
+		points[i] = point{x: x, y: y, value: s.Value}
+
+		minX, minY = math.Min(minX, x), math.Min(minY, y)
+		maxX, maxY = math.Max(maxX, x), math.Max(maxY, y)
+	}
+
+	size := grid.Size()
+	min := model.CellID{
+		X: int(math.Floor((minX - options.MaxDistance) / size)),
+		Y: int(math.Floor((minY - options.MaxDistance) / size)),
+	}
+	max := model.CellID{
+		X: int(math.Floor((maxX + options.MaxDistance) / size)),
+		Y: int(math.Floor((maxY + options.MaxDistance) / size)),
+	}
+
+	s := &Surface{
+		Grid:    grid,
+		Min:     min,
+		Columns: max.X - min.X + 1,
+		Rows:    max.Y - min.Y + 1,
+	}
+
+	if s.Columns*s.Rows > MaxCells {
+		return nil, errors.Errorf("surface of %dx%d cells is too large, use larger cells", s.Columns, s.Rows)
+	}
+
+	var estimate estimator
+
+	if options.Method == MethodKriging {
+		variogram, err := fitVariogram(points)
+		if err != nil {
+			return nil, err
+		}
+
+		s.Variogram = &variogram
+		s.Variance = make([]float64, s.Columns*s.Rows)
+		estimate = krigingEstimator(variogram, options.Power)
+	} else {
+		estimate = idwEstimator(options.Power)
+	}
+
+	index := newIndex(points, options.MaxDistance)
+	s.Values = make([]float64, s.Columns*s.Rows)
+
+	for r := 0; r < s.Rows; r++ {
+		for c := 0; c < s.Columns; c++ {
+			i := r*s.Columns + c
+			x := (float64(min.X+c) + 0.5) * size
+			y := (float64(min.Y+r) + 0.5) * size
+
+			neighbours := index.nearest(x, y, options.MaxDistance, options.Neighbours)
+			if len(neighbours) == 0 {
+				s.Values[i] = math.NaN()
+				if s.Variance != nil {
+					s.Variance[i] = math.NaN()
+				}
+				continue
+			}
+
+			value, variance := estimate(x, y, neighbours)
+			s.Values[i] = value
+			if s.Variance != nil {
+				s.Variance[i] = variance
+			}
+		}
+	}
+
+	return s, nil
+}
+
+type point struct {
+	x, y  float64
+	value float64
+}
+
+func (p point) distance(x, y float64) float64 {
+	return math.Hypot(p.x-x, p.y-y)
+}
+
+type neighbour struct {
+	point
+	distance float64
+}
+
+// estimator returns the value and the variance (kriging only) at x, y from
+// the neighbours, sorted by distance.
+type estimator func(x, y float64, neighbours []neighbour) (float64, float64)
+
+// idwEstimator weights the neighbours with the inverse of their distance to
+// the power. A sample at the location gives its value.
+func idwEstimator(power float64) estimator {
+	return func(x, y float64, neighbours []neighbour) (float64, float64) {
+		var sum, weights float64
+
+		for _, n := range neighbours {
+			if n.distance < 1e-6 {
+				return n.value, 0
+			}
+
+			w := 1 / math.Pow(n.distance, power)
+			sum += w * n.value
+			weights += w
+		}
+
+		return sum / weights, 0
+	}
+}
+
+// index buckets the points in square buckets of the search radius.
+type index struct {
+	size    float64
+	buckets map[[2]int][]point
+}
+
+func newIndex(points []point, size float64) *index {
+	idx := &index{
+		size:    size,
+		buckets: make(map[[2]int][]point),
+	}
+
+	for _, p := range points {
+		key := idx.key(p.x, p.y)
+		idx.buckets[key] = append(idx.buckets[key], p)
+	}
+
+	return idx
+}
+
+func (idx *index) key(x, y float64) [2]int {
+	return [2]int{int(math.Floor(x / idx.size)), int(math.Floor(y / idx.size))}
+}
+
+// nearest returns at most n points within the radius of x, y, closest first.
+func (idx *index) nearest(x, y, radius float64, n int) []neighbour {
+	var neighbours []neighbour
+
+	center := idx.key(x, y)
+	reach := int(math.Ceil(radius / idx.size))
+
+	for bx := center[0] - reach; bx <= center[0]+reach; bx++ {
+		for by := center[1] - reach; by <= center[1]+reach; by++ {
+			for _, p := range idx.buckets[[2]int{bx, by}] {
+				if d := p.distance(x, y); d <= radius {
+					neighbours = append(neighbours, neighbour{point: p, distance: d})
+				}
+			}
+		}
+	}
+
+	sort.Slice(neighbours, func(i, j int) bool {
+		return neighbours[i].distance < neighbours[j].distance
+	})
+
+	if len(neighbours) > n {
+		neighbours = neighbours[:n]
+	}
+
+	return neighbours
+}
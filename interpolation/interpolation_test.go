@@ -0,0 +1,194 @@
+// The MIT License (MIT)
+//
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package interpolation
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/bullettime/lora-mapper/model"
+)
+
+var origin = model.LatLon{Latitude: 51, Longitude: 5}
+
+// samples of the field on a regular raster of spacing meters around origin
+func raster(t *testing.T, n int, spacing float64, field func(x, y float64) float64) []Sample {
+	g, err := model.NewGrid(model.ShapeSquare, spacing, origin)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var samples []Sample
+
+	for i := -n; i < n; i++ {
+		for j := -n; j < n; j++ {
+			ll := g.Center(model.CellID{X: i, Y: j})
+			x, y := g.Project(ll)
+			samples = append(samples, Sample{Location: ll, Value: field(x, y)})
+		}
+	}
+
+	return samples
+}
+
+func TestInterpolate_IDW(t *testing.T) {
+	samples := raster(t, 5, 100, func(x, y float64) float64 {
+		return -100 + x/100
+	})
+
+	options := DefaultOptions()
+	options.CellSize = 100
+	options.MaxDistance = 150
+	options.Origin = &origin
+
+	s, err := Interpolate(samples, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, sample := range samples {
+		v, ok := s.At(sample.Location)
+		if !ok || math.Abs(v-sample.Value) > 1e-6 {
+			t.Errorf("value at sample %v is %v, want %v", sample.Location, v, sample.Value)
+		}
+	}
+
+	// the surface extends 150 m on every side, the corners are masked
+	if s.Columns < 12 || s.Rows < 12 {
+		t.Errorf("surface of %dx%d cells is too small", s.Columns, s.Rows)
+	}
+
+	if v := s.Value(0, 0); !math.IsNaN(v) {
+		t.Errorf("corner should be masked, got %v", v)
+	}
+
+	if _, err := Interpolate(nil, options); err != ErrNoSamples {
+		t.Errorf("no samples should give %v, got %v", ErrNoSamples, err)
+	}
+}
+
+func TestInterpolate_Kriging(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	field := func(x, y float64) float64 {
+		return -100 + 10*math.Sin(x/400) + 5*math.Cos(y/300)
+	}
+
+	samples := raster(t, 10, 100, func(x, y float64) float64 {
+		return field(x, y) + r.NormFloat64()*0.1
+	})
+
+	options := DefaultOptions()
+	options.Method = MethodKriging
+	options.CellSize = 50
+	options.MaxDistance = 300
+	options.Origin = &origin
+
+	s, err := Interpolate(samples, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.Variogram == nil || s.Variogram.Range <= 0 || s.Variogram.PartialSill <= 0 {
+		t.Fatalf("unexpected variogram %+v", s.Variogram)
+	}
+
+	// between the samples the surface follows the field
+	var sum float64
+	var n int
+
+	for row := 0; row < s.Rows; row++ {
+		for column := 0; column < s.Columns; column++ {
+			v := s.Value(column, row)
+			if math.IsNaN(v) {
+				continue
+			}
+
+			x, y := s.Grid.Project(s.Center(column, row))
+			if math.Abs(x) > 900 || math.Abs(y) > 900 {
+				continue
+			}
+
+			sum += (v - field(x, y)) * (v - field(x, y))
+			n++
+		}
+	}
+
+	if rmse := math.Sqrt(sum / float64(n)); rmse > 0.5 {
+		t.Errorf("root mean square error is %v dB over %d cells", rmse, n)
+	}
+}
+
+func TestSurface_Contours(t *testing.T) {
+	// a ring of strong signal between 300 and 700 m from the origin
+	samples := raster(t, 15, 50, func(x, y float64) float64 {
+		d := math.Hypot(x, y)
+		return -100 + 20*math.Max(0, 1-math.Abs(d-500)/200)
+	})
+
+	options := DefaultOptions()
+	options.CellSize = 25
+	options.MaxDistance = 100
+	options.Origin = &origin
+
+	s, err := Interpolate(samples, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	contours := s.Contours([]float64{-90})
+	if len(contours) != 1 || len(contours[0].Polygons) != 1 {
+		t.Fatalf("expected one polygon, got %+v", contours)
+	}
+
+	polygon := contours[0].Polygons[0]
+	if len(polygon) != 2 {
+		t.Fatalf("expected an outer ring and a hole, got %d rings", len(polygon))
+	}
+
+	// the level is crossed at 400 and 600 m from the origin
+	for i, want := range []float64{600, 400} {
+		var rings []xy
+		for _, ll := range polygon[i] {
+			x, y := s.Grid.Project(ll)
+			rings = append(rings, xy{x, y})
+		}
+
+		a := area(rings)
+		if i == 1 {
+			a = -a
+		}
+
+		if r := math.Sqrt(a / math.Pi); math.Abs(r-want) > 15 {
+			t.Errorf("ring %d has a radius of %v m, want %v m", i, r, want)
+		}
+	}
+
+	levels := s.Levels(5)
+	for i, l := range levels {
+		if l != -100+5*float64(i) {
+			t.Errorf("unexpected levels %v", levels)
+			break
+		}
+	}
+}
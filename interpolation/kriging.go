@@ -0,0 +1,313 @@
+// The MIT License (MIT)
+//
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package interpolation
+
+import (
+	"math"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	ModelSpherical   = "spherical"
+	ModelExponential = "exponential"
+	ModelGaussian    = "gaussian"
+
+	variogramLags   = 15
+	variogramRanges = 100
+	// maxVariogramPoints limits the number of pairs of the empirical
+	// variogram, larger sets are thinned out evenly.
+	maxVariogramPoints = 1000
+)
+
+var variogramModels = []string{ModelSpherical, ModelExponential, ModelGaussian}
+
+// Variogram models the semivariance of the values as a function of the
+// distance (in meters) between the samples.
+type Variogram struct {
+	Model       string  `json:"model"`
+	Nugget      float64 `json:"nugget"`
+	PartialSill float64 `json:"partial_sill"`
+	Range       float64 `json:"range"`
+}
+
+// Gamma returns the semivariance at the distance.
+func (v Variogram) Gamma(distance float64) float64 {
+	if distance == 0 {
+		return 0
+	}
+
+	return v.Nugget + v.PartialSill*variogramShape(v.Model, distance/v.Range)
+}
+
+// variogramShape returns the normalized model at h (distance over range),
+// rising from 0 to (practically) 1 at the range.
+func variogramShape(model string, h float64) float64 {
+	switch model {
+	case ModelExponential:
+		return 1 - math.Exp(-3*h)
+	case ModelGaussian:
+		return 1 - math.Exp(-3*h*h)
+	default:
+		if h >= 1 {
+			return 1
+		}
+		return 1.5*h - 0.5*h*h*h
+	}
+}
+
+type lag struct {
+	distance     float64
+	semivariance float64
+	pairs        int
+}
+
+// empiricalVariogram bins the half squared differences of all pairs of
+// points by distance, up to half of the largest distance.
+func empiricalVariogram(points []point) ([]lag, float64) {
+	if len(points) > maxVariogramPoints {
+		stride := (len(points) + maxVariogramPoints - 1) / maxVariogramPoints
+		thinned := make([]point, 0, maxVariogramPoints)
+
+		for i := 0; i < len(points); i += stride {
+			thinned = append(thinned, points[i])
+		}
+
+		points = thinned
+	}
+
+	var maxDistance float64
+
+	for i := range points {
+		for j := i + 1; j < len(points); j++ {
+			maxDistance = math.Max(maxDistance, points[i].distance(points[j].x, points[j].y))
+		}
+	}
+
+	maxLag := maxDistance / 2
+	if maxLag == 0 {
+		return nil, 0
+	}
+
+	width := maxLag / variogramLags
+	bins := make([]lag, variogramLags)
+
+	for i := range points {
+		for j := i + 1; j < len(points); j++ {
+			d := points[i].distance(points[j].x, points[j].y)
+			b := int(d / width)
+
+			if b >= variogramLags {
+				continue
+			}
+
+			diff := points[i].value - points[j].value
+			bins[b].distance += d
+			bins[b].semivariance += diff * diff / 2
+			bins[b].pairs++
+		}
+	}
+
+	var lags []lag
+
+	for _, b := range bins {
+		if b.pairs == 0 {
+			continue
+		}
+
+		lags = append(lags, lag{
+			distance:     b.distance / float64(b.pairs),
+			semivariance: b.semivariance / float64(b.pairs),
+			pairs:        b.pairs,
+		})
+	}
+
+	return lags, maxLag
+}
+
+// fitVariogram fits the spherical, exponential and gaussian models on the
+// empirical variogram by least squares weighted by the number of pairs, and
+// returns the best fit.
+func fitVariogram(points []point) (Variogram, error) {
+	lags, maxLag := empiricalVariogram(points)
+	if len(lags) < 3 {
+		return Variogram{}, errors.New("not enough samples to fit a variogram")
+	}
+
+	var best Variogram
+	bestError := math.Inf(1)
+
+	for _, model := range variogramModels {
+		for step := 1; step <= variogramRanges; step++ {
+			v := Variogram{
+				Model: model,
+				Range: 2 * maxLag * float64(step) / variogramRanges,
+			}
+
+			var sw, sf, sff, sg, sfg float64
+
+			for _, l := range lags {
+				w := float64(l.pairs)
+				f := variogramShape(model, l.distance/v.Range)
+
+				sw += w
+				sf += w * f
+				sff += w * f * f
+				sg += w * l.semivariance
+				sfg += w * f * l.semivariance
+			}
+
+			if det := sw*sff - sf*sf; det > 0 {
+				v.PartialSill = (sw*sfg - sf*sg) / det
+				v.Nugget = (sg - v.PartialSill*sf) / sw
+			}
+
+			if v.Nugget < 0 && sff > 0 {
+				v.Nugget = 0
+				v.PartialSill = sfg / sff
+			}
+
+			if v.PartialSill < 0 || sff == 0 {
+				v.PartialSill = 0
+				v.Nugget = sg / sw
+			}
+
+			var e float64
+
+			for _, l := range lags {
+				diff := v.Gamma(l.distance) - l.semivariance
+				e += float64(l.pairs) * diff * diff
+			}
+
+			if e < bestError {
+				best = v
+				bestError = e
+			}
+		}
+	}
+
+	return best, nil
+}
+
+// krigingEstimator solves the ordinary kriging system for the neighbours. It
+// falls back on inverse distance weighting when the system is singular.
+func krigingEstimator(v Variogram, power float64) estimator {
+	fallback := idwEstimator(power)
+
+	return func(x, y float64, neighbours []neighbour) (float64, float64) {
+		n := len(neighbours)
+
+		a := make([][]float64, n+1)
+		b := make([]float64, n+1)
+
+		for i := 0; i < n; i++ {
+			a[i] = make([]float64, n+1)
+
+			for j := 0; j < n; j++ {
+				a[i][j] = v.Gamma(neighbours[i].point.distance(neighbours[j].x, neighbours[j].y))
+			}
+
+			a[i][n] = 1
+			b[i] = v.Gamma(neighbours[i].distance)
+		}
+
+		a[n] = make([]float64, n+1)
+		for j := 0; j < n; j++ {
+			a[n][j] = 1
+		}
+		b[n] = 1
+
+		weights, ok := solve(a, append([]float64(nil), b...))
+		if !ok {
+			return fallback(x, y, neighbours)
+		}
+
+		var value, variance float64
+
+		for i := 0; i < n; i++ {
+			value += weights[i] * neighbours[i].value
+			variance += weights[i] * b[i]
+		}
+
+		variance += weights[n]
+
+		return value, math.Max(variance, 0)
+	}
+}
+
+// solve solves a x = b by gaussian elimination with partial pivoting. Both a
+// and b are modified.
+func solve(a [][]float64, b []float64) ([]float64, bool) {
+	n := len(b)
+
+	var scale float64
+	for i := range a {
+		for j := range a[i] {
+			scale = math.Max(scale, math.Abs(a[i][j]))
+		}
+	}
+
+	if scale == 0 {
+		return nil, false
+	}
+
+	for col := 0; col < n; col++ {
+		pivot := col
+		for row := col + 1; row < n; row++ {
+			if math.Abs(a[row][col]) > math.Abs(a[pivot][col]) {
+				pivot = row
+			}
+		}
+
+		if math.Abs(a[pivot][col]) < 1e-12*scale {
+			return nil, false
+		}
+
+		a[col], a[pivot] = a[pivot], a[col]
+		b[col], b[pivot] = b[pivot], b[col]
+
+		for row := col + 1; row < n; row++ {
+			f := a[row][col] / a[col][col]
+
+			for j := col; j < n; j++ {
+				a[row][j] -= f * a[col][j]
+			}
+
+			b[row] -= f * b[col]
+		}
+	}
+
+	x := make([]float64, n)
+
+	for row := n - 1; row >= 0; row-- {
+		sum := b[row]
+
+		for j := row + 1; j < n; j++ {
+			sum -= a[row][j] * x[j]
+		}
+
+		x[row] = sum / a[row][row]
+	}
+
+	return x, true
+}
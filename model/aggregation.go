@@ -0,0 +1,282 @@
+// The MIT License (MIT)
+//
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package model
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/paulmach/go.geojson"
+	"github.com/pkg/errors"
+)
+
+type Stats struct {
+	Mean   float64 `json:"mean"`
+	Median float64 `json:"median"`
+	P10    float64 `json:"p10"`
+	P90    float64 `json:"p90"`
+}
+
+type CellStats struct {
+	Cell         CellID    `json:"cell"`
+	Center       LatLon    `json:"center"`
+	Count        int       `json:"count"`
+	RSSI         Stats     `json:"rssi"`
+	SNR          Stats     `json:"snr"`
+	ESP          Stats     `json:"esp"`
+	LinkMargin   Stats     `json:"link_margin"`
+	BestDataRate string    `json:"best_data_rate"`
+	BestSF       int       `json:"best_sf"`
+	Gateways     int       `json:"gateways"`
+	LastSeen     time.Time `json:"last_seen"`
+}
+
+type aggregation struct {
+	db              Database
+	measurementName string
+	options         GridOptions
+	filter          Filter
+}
+
+type Aggregation interface {
+	GetCells() (Grid, []CellStats, error)
+	GetGeoJSON(callback string) (string, error)
+	SetFilter(Filter)
+}
+
+// NewAggregation bins the receptions of the measurement in the cells of a
+// grid created with the options.
+func NewAggregation(db Database, measurementName string, options GridOptions) Aggregation {
+	return &aggregation{
+		db:              db,
+		measurementName: measurementName,
+		options:         options,
+	}
+}
+
+func (a *aggregation) SetFilter(filter Filter) {
+	a.filter = filter
+}
+
+func (a *aggregation) GetCells() (Grid, []CellStats, error) {
+	receptions, err := GetReceptions(a.db, a.measurementName, a.filter)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	grid, err := a.options.NewGrid(ReceptionLocations(receptions))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return grid, Aggregate(grid, receptions), nil
+}
+
+func (a *aggregation) GetGeoJSON(callback string) (string, error) {
+	grid, cells, err := a.GetCells()
+	if err != nil {
+		return "", err
+	}
+
+	return CellsGeoJSON(grid, cells, callback)
+}
+
+// Aggregate computes the statistics of every cell of the grid that holds at
+// least one reception. The cells are sorted by id.
+func Aggregate(grid Grid, receptions []Reception) []CellStats {
+	type bin struct {
+		rssi     []float64
+		snr      []float64
+		esp      []float64
+		margin   []float64
+		sf       int
+		gateways map[string]bool
+		lastSeen time.Time
+	}
+
+	bins := make(map[CellID]*bin)
+
+	for _, r := range receptions {
+		id := grid.Cell(r.Location)
+
+		b, ok := bins[id]
+		if !ok {
+			b = &bin{sf: 13, gateways: make(map[string]bool)}
+			bins[id] = b
+		}
+
+		b.rssi = append(b.rssi, r.RSSI)
+		b.snr = append(b.snr, r.SNR)
+		b.esp = append(b.esp, r.ESP())
+		b.margin = append(b.margin, r.LinkMargin())
+		b.gateways[r.GatewayID] = true
+
+		if r.SF < b.sf {
+			b.sf = r.SF
+		}
+
+		if r.Time.After(b.lastSeen) {
+			b.lastSeen = r.Time
+		}
+	}
+
+	cells := make([]CellStats, 0, len(bins))
+
+	for id, b := range bins {
+		cells = append(cells, CellStats{
+			Cell:         id,
+			Center:       grid.Center(id),
+			Count:        len(b.rssi),
+			RSSI:         NewStats(b.rssi),
+			SNR:          NewStats(b.snr),
+			ESP:          NewStats(b.esp),
+			LinkMargin:   NewStats(b.margin),
+			BestDataRate: DataRate(b.sf),
+			BestSF:       b.sf,
+			Gateways:     len(b.gateways),
+			LastSeen:     b.lastSeen,
+		})
+	}
+
+	SortCells(cells)
+
+	return cells
+}
+
+func SortCells(cells []CellStats) {
+	sort.Slice(cells, func(i, j int) bool {
+		return cellLess(cells[i].Cell, cells[j].Cell)
+	})
+}
+
+func cellLess(a, b CellID) bool {
+	if a.Y != b.Y {
+		return a.Y < b.Y
+	}
+	return a.X < b.X
+}
+
+// NewStats returns the mean, median and 10th and 90th percentile of the
+// values. Percentiles are interpolated between the closest ranks.
+func NewStats(values []float64) Stats {
+	if len(values) == 0 {
+		return Stats{}
+	}
+
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+
+	var sum float64
+	for _, v := range sorted {
+		sum += v
+	}
+
+	return Stats{
+		Mean:   sum / float64(len(sorted)),
+		Median: Percentile(sorted, 0.5),
+		P10:    Percentile(sorted, 0.1),
+		P90:    Percentile(sorted, 0.9),
+	}
+}
+
+// Percentile returns the p-th (0 - 1) percentile of sorted values.
+func Percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return math.NaN()
+	}
+
+	rank := p * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+
+	return sorted[lower] + (rank-float64(lower))*(sorted[upper]-sorted[lower])
+}
+
+// CellPolygon returns the outline of a cell as a GeoJSON polygon geometry.
+func CellPolygon(grid Grid, id CellID) *geojson.Geometry {
+	var ring [][]float64
+
+	for _, ll := range grid.Polygon(id) {
+		ring = append(ring, []float64{ll.Longitude, ll.Latitude})
+	}
+
+	return geojson.NewPolygonGeometry([][][]float64{ring})
+}
+
+// CellsGeoJSON returns the cells as GeoJSON polygons (in [lon, lat] order).
+func CellsGeoJSON(grid Grid, cells []CellStats, callback string) (string, error) {
+	fc := geojson.NewFeatureCollection()
+
+	for _, c := range cells {
+		feature := geojson.NewFeature(CellPolygon(grid, c.Cell))
+		feature.SetProperty("cell", c.Cell.String())
+		feature.SetProperty("count", c.Count)
+		feature.SetProperty("rssi_mean", round(c.RSSI.Mean, 2))
+		feature.SetProperty("rssi_median", round(c.RSSI.Median, 2))
+		feature.SetProperty("rssi_p10", round(c.RSSI.P10, 2))
+		feature.SetProperty("rssi_p90", round(c.RSSI.P90, 2))
+		feature.SetProperty("snr_mean", round(c.SNR.Mean, 2))
+		feature.SetProperty("snr_median", round(c.SNR.Median, 2))
+		feature.SetProperty("snr_p10", round(c.SNR.P10, 2))
+		feature.SetProperty("snr_p90", round(c.SNR.P90, 2))
+		feature.SetProperty("esp_mean", round(c.ESP.Mean, 2))
+		feature.SetProperty("esp_median", round(c.ESP.Median, 2))
+		feature.SetProperty("esp_p10", round(c.ESP.P10, 2))
+		feature.SetProperty("esp_p90", round(c.ESP.P90, 2))
+		feature.SetProperty("link_margin_mean", round(c.LinkMargin.Mean, 2))
+		feature.SetProperty("link_margin_median", round(c.LinkMargin.Median, 2))
+		feature.SetProperty("link_margin_p10", round(c.LinkMargin.P10, 2))
+		feature.SetProperty("link_margin_p90", round(c.LinkMargin.P90, 2))
+		feature.SetProperty("data_rate", c.BestDataRate)
+		feature.SetProperty("sf", fmt.Sprintf("sf%d", c.BestSF))
+		feature.SetProperty("gateways", c.Gateways)
+		feature.SetProperty("last_seen", c.LastSeen.UTC().Format(time.RFC3339))
+
+		fc.AddFeature(feature)
+	}
+
+	return FeatureCollectionJSON(fc, callback)
+}
+
+// FeatureCollectionJSON marshals the feature collection, wrapped in the
+// callback function when one is given (JSONP).
+func FeatureCollectionJSON(fc *geojson.FeatureCollection, callback string) (string, error) {
+	json, err := fc.MarshalJSON()
+	if err != nil {
+		return "", errors.Wrap(err, "marshalling json from featurecollection")
+	}
+
+	if callback != "" {
+		return fmt.Sprintf("%s(%s);", callback, json), nil
+	}
+
+	return string(json), nil
+}
+
+func round(v float64, decimals int) float64 {
+	p := math.Pow(10, float64(decimals))
+	return math.Floor(v*p+0.5) / p
+}
@@ -0,0 +1,109 @@
+// The MIT License (MIT)
+//
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package model
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+var origin = LatLon{Latitude: 51, Longitude: 5}
+
+func TestGrid_Cell(t *testing.T) {
+	for _, shape := range []string{ShapeSquare, ShapeHexagon} {
+		g, err := NewGrid(shape, 100, origin)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for x := -5; x <= 5; x++ {
+			for y := -5; y <= 5; y++ {
+				id := CellID{X: x, Y: y}
+
+				if c := g.Cell(g.Center(id)); c != id {
+					t.Errorf("%s: center of %v is in cell %v", shape, id, c)
+				}
+
+				for _, n := range g.Neighbours(id) {
+					d := g.Center(id).getDistance(g.Center(n)) * 1000
+					if math.Abs(d-100) > 0.5 {
+						t.Errorf("%s: distance between neighbours %v and %v is %v m", shape, id, n, d)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestNewGrid(t *testing.T) {
+	if _, err := NewGrid("triangle", 100, origin); err == nil {
+		t.Error("invalid shape should give an error")
+	}
+
+	if _, err := NewGrid(ShapeSquare, 0, origin); err == nil {
+		t.Error("invalid size should give an error")
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	g, _ := NewGrid(ShapeSquare, 100, origin)
+	ts := time.Date(2018, 4, 1, 12, 0, 0, 0, time.UTC)
+	location := g.Center(CellID{X: 3, Y: 4})
+
+	var receptions []Reception
+	for i := 0; i < 11; i++ {
+		receptions = append(receptions, Reception{
+			Location:  location,
+			GatewayID: []string{"a", "b"}[i%2],
+			SF:        12 - i%3,
+			RSSI:      -100 - float64(i),
+			SNR:       float64(i),
+			Time:      ts.Add(time.Duration(i) * time.Minute),
+		})
+	}
+
+	cells := Aggregate(g, receptions)
+	if len(cells) != 1 {
+		t.Fatalf("expected 1 cell, got %d", len(cells))
+	}
+
+	c := cells[0]
+
+	if c.Cell != (CellID{X: 3, Y: 4}) {
+		t.Errorf("wrong cell %v", c.Cell)
+	}
+	if c.Count != 11 || c.Gateways != 2 || c.BestSF != 10 || c.BestDataRate != "SF10BW125" {
+		t.Errorf("wrong cell stats %+v", c)
+	}
+	if c.RSSI.Mean != -105 || c.RSSI.Median != -105 || c.RSSI.P10 != -109 || c.RSSI.P90 != -101 {
+		t.Errorf("wrong rssi stats %+v", c.RSSI)
+	}
+	// the median esp is that of -105 dBm at 5 dB snr
+	if round(c.ESP.Median, 2) != -106.19 || c.LinkMargin.Median != 23 {
+		t.Errorf("wrong esp %+v or link margin %+v", c.ESP, c.LinkMargin)
+	}
+	if !c.LastSeen.Equal(ts.Add(10 * time.Minute)) {
+		t.Errorf("wrong last seen %v", c.LastSeen)
+	}
+}
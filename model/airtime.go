@@ -0,0 +1,332 @@
+// The MIT License (MIT)
+//
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package model
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/paulmach/go.geojson"
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultPayloadSize is the size (in bytes) of the application payload
+	// of the mapping messages.
+	DefaultPayloadSize = 7
+	// MaxPayloadSize is the largest application payload of a LoRa frame.
+	MaxPayloadSize = 242
+	// LoRaWANOverhead is the size (in bytes) of the MAC header, frame header
+	// without options, port and MIC around the application payload.
+	LoRaWANOverhead = 13
+
+	RegionEU868 = "EU868"
+	RegionUS915 = "US915"
+	RegionAU915 = "AU915"
+	RegionAS923 = "AS923"
+	RegionIN865 = "IN865"
+	RegionKR920 = "KR920"
+)
+
+// the uplink radio settings: 125 kHz bandwidth, coding rate 4/5, explicit
+// header, crc and an 8 symbol preamble
+const (
+	loraBandwidth  = 125000.0
+	loraCodingRate = 1
+	loraPreamble   = 8
+)
+
+// Region holds the regional limits of the uplinks: the duty cycle (0 - 1, 0
+// without limit) and the maximum dwell time (0 without limit).
+type Region struct {
+	Name      string
+	DutyCycle float64
+	DwellTime time.Duration
+}
+
+var regions = map[string]Region{
+	RegionEU868: {Name: RegionEU868, DutyCycle: 0.01},
+	RegionUS915: {Name: RegionUS915, DwellTime: 400 * time.Millisecond},
+	RegionAU915: {Name: RegionAU915},
+	RegionAS923: {Name: RegionAS923, DutyCycle: 0.01, DwellTime: 400 * time.Millisecond},
+	RegionIN865: {Name: RegionIN865},
+	RegionKR920: {Name: RegionKR920},
+}
+
+// GetRegion returns the region with the name (case insensitive), or EU868
+// when the name is empty.
+func GetRegion(name string) (Region, error) {
+	if name == "" {
+		name = RegionEU868
+	}
+
+	region, ok := regions[strings.ToUpper(name)]
+	if !ok {
+		return Region{}, errors.Errorf("unknown region: %s (allowed: %v)", name, Regions())
+	}
+
+	return region, nil
+}
+
+// Regions returns the names of the regions.
+func Regions() []string {
+	names := make([]string, 0, len(regions))
+	for name := range regions {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// TimeOnAir returns the airtime of an uplink with an application payload of
+// size bytes at the spreading factor, with the LoRaWAN overhead and the
+// uplink radio settings (125 kHz, coding rate 4/5, explicit header and crc).
+// The low data rate optimization is used at SF11 and SF12.
+func TimeOnAir(size int, sf int) time.Duration {
+	symbol := math.Pow(2, float64(sf)) / loraBandwidth
+
+	lowDataRate := 0
+	if sf >= 11 {
+		lowDataRate = 1
+	}
+
+	bits := float64(8*(size+LoRaWANOverhead) - 4*sf + 28 + 16)
+	symbols := math.Ceil(bits/float64(4*(sf-2*lowDataRate))) * (loraCodingRate + 4)
+
+	payload := 8 + math.Max(symbols, 0)
+	preamble := loraPreamble + 4.25
+
+	return time.Duration((preamble + payload) * symbol * float64(time.Second))
+}
+
+// EnergyProfile describes the uplinks and the power supply of a device: the
+// region, the application payload (in bytes) sent every interval, the battery
+// capacity (in mAh), the current (in mA) while transmitting, the current (in
+// µA) while sleeping and the supply voltage.
+type EnergyProfile struct {
+	Region       string
+	PayloadSize  int
+	Interval     time.Duration
+	Battery      float64
+	TxCurrent    float64
+	SleepCurrent float64
+	Voltage      float64
+}
+
+// DefaultEnergyProfile returns the profile of a mapping device on a 2400 mAh
+// lithium cell, sending the default payload every 15 minutes in EU868.
+func DefaultEnergyProfile() EnergyProfile {
+	return EnergyProfile{
+		Region:       RegionEU868,
+		PayloadSize:  DefaultPayloadSize,
+		Interval:     15 * time.Minute,
+		Battery:      2400,
+		TxCurrent:    44,
+		SleepCurrent: 5,
+		Voltage:      3.6,
+	}
+}
+
+// Validate checks the settings of the profile.
+func (p EnergyProfile) Validate() error {
+	if _, err := GetRegion(p.Region); err != nil {
+		return err
+	}
+
+	if p.PayloadSize < 0 || p.PayloadSize > MaxPayloadSize {
+		return errors.Errorf("invalid payload size: %d (allowed: 0 - %d bytes)", p.PayloadSize, MaxPayloadSize)
+	}
+
+	if p.Interval <= 0 {
+		return errors.Errorf("invalid interval: %v", p.Interval)
+	}
+
+	if p.Battery <= 0 || p.TxCurrent < 0 || p.SleepCurrent < 0 || p.Voltage <= 0 {
+		return errors.New("invalid battery capacity, currents or voltage")
+	}
+
+	return nil
+}
+
+// Energy is the estimate of the uplinks of a profile at a spreading factor.
+// The airtime is in milliseconds and the interval in seconds. The duty cycle
+// is the share of the time spent transmitting, the headroom the share of the
+// regional duty cycle limit left (negative when exceeded, 1 without limit),
+// and the minimum interval (in seconds) the shortest one within the limit.
+// An uplink is allowed when it's within the duty cycle limit and dwell time.
+// The energy (in mJ) and charge (in µAh) are those of a single uplink, the
+// average current (in µA) includes the sleep current and the lifetime of the
+// battery is in days. Receive windows and battery self-discharge are ignored.
+type Energy struct {
+	DataRate         string  `json:"datarate"`
+	SF               int     `json:"sf"`
+	Region           string  `json:"region"`
+	PayloadSize      int     `json:"payload_size"`
+	Airtime          float64 `json:"airtime"`
+	Interval         float64 `json:"interval"`
+	DutyCycle        float64 `json:"duty_cycle"`
+	DutyCycleLimit   float64 `json:"duty_cycle_limit"`
+	Headroom         float64 `json:"headroom"`
+	MinInterval      float64 `json:"min_interval"`
+	MessagesPerDay   int     `json:"messages_per_day"`
+	Allowed          bool    `json:"allowed"`
+	EnergyPerMessage float64 `json:"energy_per_message"`
+	ChargePerMessage float64 `json:"charge_per_message"`
+	AverageCurrent   float64 `json:"average_current"`
+	Lifetime         float64 `json:"lifetime"`
+}
+
+// EstimateEnergy returns the airtime, duty cycle and battery lifetime of the
+// uplinks of the profile at the spreading factor.
+func EstimateEnergy(sf int, profile EnergyProfile) (Energy, error) {
+	if sf < 7 || sf > 12 {
+		return Energy{}, errors.Errorf("invalid spreading factor: %d", sf)
+	}
+
+	if err := profile.Validate(); err != nil {
+		return Energy{}, err
+	}
+
+	region, _ := GetRegion(profile.Region)
+
+	airtime := TimeOnAir(profile.PayloadSize, sf)
+	seconds := airtime.Seconds()
+	interval := profile.Interval.Seconds()
+	dutyCycle := seconds / interval
+
+	e := Energy{
+		DataRate:       DataRate(sf),
+		SF:             sf,
+		Region:         region.Name,
+		PayloadSize:    profile.PayloadSize,
+		Airtime:        round(seconds*1000, 1),
+		Interval:       interval,
+		DutyCycle:      round(dutyCycle, 6),
+		DutyCycleLimit: region.DutyCycle,
+		Headroom:       1,
+		MinInterval:    round(seconds, 3),
+		Allowed:        region.DwellTime == 0 || airtime <= region.DwellTime,
+	}
+
+	if region.DutyCycle > 0 {
+		e.Headroom = round(1-dutyCycle/region.DutyCycle, 4)
+		e.MinInterval = round(seconds/region.DutyCycle, 3)
+		e.Allowed = e.Allowed && dutyCycle <= region.DutyCycle
+	}
+
+	e.MessagesPerDay = int(math.Floor(24 * 3600 / e.MinInterval))
+
+	// charge of an uplink in mAs, the average current in mA
+	charge := profile.TxCurrent * seconds
+	current := profile.SleepCurrent/1000 + charge/interval
+
+	e.EnergyPerMessage = round(charge*profile.Voltage, 3)
+	e.ChargePerMessage = round(charge/3.6, 4)
+	e.AverageCurrent = round(current*1000, 2)
+	e.Lifetime = round(profile.Battery/current/24, 1)
+
+	return e, nil
+}
+
+// Summary describes the estimate in a line of text, eg. "SF9BW125: 185.3 ms
+// airtime, 0.02% duty cycle (98% headroom), 29.36 mJ per message, 7112 days".
+func (e Energy) Summary() string {
+	s := fmt.Sprintf("%s: %.1f ms airtime, %.2f%% duty cycle", e.DataRate, e.Airtime, e.DutyCycle*100)
+
+	if e.DutyCycleLimit > 0 {
+		s += fmt.Sprintf(" (%.0f%% headroom)", e.Headroom*100)
+	}
+
+	if !e.Allowed {
+		s += ", not allowed in " + e.Region
+	}
+
+	return s + fmt.Sprintf(", %.2f mJ per message, %.0f days", e.EnergyPerMessage, e.Lifetime)
+}
+
+// EnergyRecommender adds the energy estimate of the profile to the
+// recommendations. A recommendation without estimate is still returned.
+func EnergyRecommender(recommend Recommender, profile EnergyProfile) Recommender {
+	return func(ll LatLon) (Recommendation, error) {
+		r, err := recommend(ll)
+		if err != nil {
+			return r, err
+		}
+
+		if e, err := EstimateEnergy(r.SF, profile); err == nil {
+			r.Energy = &e
+		}
+
+		return r, nil
+	}
+}
+
+// EnergyGeoJSON returns the cells of the grid within the bounding box (all
+// cells without one) as GeoJSON polygons (in [lon, lat] order) with the
+// recommended data rate and the energy estimate of the profile.
+func (g *DDRGrid) EnergyGeoJSON(profile EnergyProfile, bbox *BoundingBox, callback string) (string, error) {
+	ids := make([]CellID, 0, len(g.Cells))
+	for id := range g.Cells {
+		if bbox == nil || bbox.Contains(g.Grid.Center(id)) {
+			ids = append(ids, id)
+		}
+	}
+
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i].Less(ids[j])
+	})
+
+	fc := geojson.NewFeatureCollection()
+
+	for _, id := range ids {
+		r := g.Cells[id]
+
+		e, err := EstimateEnergy(r.SF, profile)
+		if err != nil {
+			return "", err
+		}
+
+		feature := geojson.NewFeature(CellPolygon(g.Grid, id))
+		feature.SetProperty("cell", id.String())
+		feature.SetProperty("datarate", r.DataRate)
+		feature.SetProperty("sf", r.SF)
+		feature.SetProperty("confidence", r.Confidence)
+		feature.SetProperty("airtime", e.Airtime)
+		feature.SetProperty("duty_cycle", e.DutyCycle)
+		feature.SetProperty("headroom", e.Headroom)
+		feature.SetProperty("min_interval", e.MinInterval)
+		feature.SetProperty("allowed", e.Allowed)
+		feature.SetProperty("energy_per_message", e.EnergyPerMessage)
+		feature.SetProperty("average_current", e.AverageCurrent)
+		feature.SetProperty("lifetime", e.Lifetime)
+
+		fc.AddFeature(feature)
+	}
+
+	return FeatureCollectionJSON(fc, callback)
+}
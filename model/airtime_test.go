@@ -0,0 +1,75 @@
+// The MIT License (MIT)
+//
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimeOnAir(t *testing.T) {
+	// 7 bytes of application payload is a 20 byte frame
+	for sf, expected := range map[int]float64{7: 56.6, 9: 185.3, 10: 370.7, 12: 1318.9} {
+		airtime := float64(TimeOnAir(DefaultPayloadSize, sf)) / float64(time.Millisecond)
+		if round(airtime, 1) != expected {
+			t.Errorf("SF%d: expected %v ms, got %v ms", sf, expected, airtime)
+		}
+	}
+}
+
+func TestEstimateEnergy(t *testing.T) {
+	profile := DefaultEnergyProfile()
+	profile.Interval = time.Minute
+
+	e, err := EstimateEnergy(12, profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 1.32 s every minute is over the 1% duty cycle of EU868
+	if e.Allowed || e.Headroom >= 0 || e.MinInterval != 131.891 || e.MessagesPerDay != 655 {
+		t.Errorf("unexpected duty cycle %+v", e)
+	}
+
+	e, err = EstimateEnergy(7, profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 44 mA for 56.6 ms every minute and 5 µA asleep
+	if !e.Allowed || e.AverageCurrent != 46.49 || e.Lifetime != 2151 {
+		t.Errorf("unexpected energy %+v", e)
+	}
+
+	profile.Region = RegionUS915
+
+	if e, err := EstimateEnergy(11, profile); err != nil || e.Allowed || e.Headroom != 1 {
+		t.Errorf("expected SF11 to exceed the dwell time of US915: %+v", e)
+	}
+
+	profile.Region = "mars"
+
+	if _, err := EstimateEnergy(7, profile); err == nil {
+		t.Error("expected an error for an unknown region")
+	}
+}
@@ -0,0 +1,177 @@
+// The MIT License (MIT)
+//
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package model
+
+import (
+	"math"
+	"strings"
+
+	"github.com/paulmach/go.geojson"
+	"github.com/pkg/errors"
+)
+
+// Polygon is an outer ring followed by its holes, the rings are closed or not.
+type Polygon [][]LatLon
+
+// Area is a set of polygons, eg. the target area of a study.
+type Area []Polygon
+
+// Contains reports whether the location is inside the outer ring and outside
+// of the holes (even-odd rule).
+func (p Polygon) Contains(ll LatLon) bool {
+	inside := false
+
+	for _, ring := range p {
+		if ringContains(ring, ll) {
+			inside = !inside
+		}
+	}
+
+	return inside
+}
+
+func ringContains(ring []LatLon, ll LatLon) bool {
+	inside := false
+
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		a, b := ring[i], ring[j]
+
+		if (a.Latitude > ll.Latitude) != (b.Latitude > ll.Latitude) &&
+			ll.Longitude < (b.Longitude-a.Longitude)*(ll.Latitude-a.Latitude)/(b.Latitude-a.Latitude)+a.Longitude {
+			inside = !inside
+		}
+	}
+
+	return inside
+}
+
+// Contains reports whether the location is inside one of the polygons.
+func (a Area) Contains(ll LatLon) bool {
+	for _, p := range a {
+		if p.Contains(ll) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Bounds returns the bounding box of the outer rings.
+func (a Area) Bounds() BoundingBox {
+	var locations []LatLon
+
+	for _, p := range a {
+		if len(p) > 0 {
+			locations = append(locations, p[0]...)
+		}
+	}
+
+	return Bounds(locations)
+}
+
+// ParseAreaGeoJSON reads the polygons of a GeoJSON geometry, feature or
+// feature collection (in [lon, lat] order), other geometries are ignored.
+func ParseAreaGeoJSON(data []byte) (Area, error) {
+	var geometries []*geojson.Geometry
+
+	if fc, err := geojson.UnmarshalFeatureCollection(data); err == nil && fc.Type == "FeatureCollection" {
+		for _, f := range fc.Features {
+			geometries = append(geometries, f.Geometry)
+		}
+	} else if f, err := geojson.UnmarshalFeature(data); err == nil && f.Type == "Feature" {
+		geometries = append(geometries, f.Geometry)
+	} else {
+		g, err := geojson.UnmarshalGeometry(data)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid geojson")
+		}
+		geometries = append(geometries, g)
+	}
+
+	var area Area
+
+	for _, g := range geometries {
+		switch {
+		case g == nil:
+		case g.IsPolygon():
+			area = append(area, newPolygon(g.Polygon))
+		case g.IsMultiPolygon():
+			for _, p := range g.MultiPolygon {
+				area = append(area, newPolygon(p))
+			}
+		}
+	}
+
+	if len(area) == 0 {
+		return nil, errors.New("no polygons in the geojson")
+	}
+
+	return area, nil
+}
+
+func newPolygon(rings [][][]float64) Polygon {
+	polygon := make(Polygon, 0, len(rings))
+
+	for _, ring := range rings {
+		var r []LatLon
+		for _, c := range ring {
+			if len(c) >= 2 {
+				r = append(r, LatLon{Latitude: c[1], Longitude: c[0]})
+			}
+		}
+		polygon = append(polygon, r)
+	}
+
+	return polygon
+}
+
+// AreaKm2 returns the area in km² of a number of square cells of size
+// meters.
+func AreaKm2(cells int, size float64) float64 {
+	return math.Floor(float64(cells)*size*size/1e4+0.5) / 100
+}
+
+// ParseArea parses a GeoJSON polygon (see ParseAreaGeoJSON) or a bounding box
+// in the "minlon,minlat,maxlon,maxlat" format.
+func ParseArea(s string) (Area, error) {
+	if strings.HasPrefix(strings.TrimSpace(s), "{") {
+		return ParseAreaGeoJSON([]byte(s))
+	}
+
+	b, err := ParseBoundingBox(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return Area{b.Polygon()}, nil
+}
+
+// Polygon returns the outline of the bounding box.
+func (b BoundingBox) Polygon() Polygon {
+	sw := LatLon{Latitude: b.MinLatitude, Longitude: b.MinLongitude}
+	se := LatLon{Latitude: b.MinLatitude, Longitude: b.MaxLongitude}
+	ne := LatLon{Latitude: b.MaxLatitude, Longitude: b.MaxLongitude}
+	nw := LatLon{Latitude: b.MaxLatitude, Longitude: b.MinLongitude}
+
+	return Polygon{{sw, se, ne, nw, sw}}
+}
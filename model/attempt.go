@@ -0,0 +1,192 @@
+// The MIT License (MIT)
+//
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package model
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/pkg/errors"
+)
+
+const (
+	// AttemptData is the default measurement of the transmission attempts.
+	AttemptData = "attempts"
+
+	InfluxAttempts = `select size from %s%s group by latitude, longitude, data_rate, power, device_id, campaign`
+
+	// ReceptionCondition selects the receptions by a gateway, the points
+	// without gateway are the rssi 0 placeholders of old datasets.
+	ReceptionCondition = "gateway_id != ''"
+
+	InfluxPlaceholders       = `select rssi, snr, size from %s where gateway_id = '' group by *`
+	InfluxDeletePlaceholders = `delete from %s where gateway_id = ''`
+)
+
+// Attempt is a transmission by a device at a data rate, whether or not it was
+// received by a gateway. The size of the packet is in bytes.
+type Attempt struct {
+	Location LatLon
+	DeviceID string
+	Campaign string
+	DataRate string
+	SF       int
+	TxPower  float64
+	Size     int
+	Time     time.Time
+}
+
+// GetAttempts returns every transmission attempt that matches the filter, the
+// gateway filter doesn't apply to attempts.
+func GetAttempts(db Database, measurementName string, filter Filter) ([]Attempt, error) {
+	var attempts []Attempt
+
+	filter.GatewayIDs = nil
+
+	where := filter.Condition()
+	if where != "" {
+		where = " where " + where
+	}
+
+	metrics, err := db.Query(fmt.Sprintf(InfluxAttempts, measurementName, where))
+	if err != nil {
+		return nil, errors.Wrap(err, "querying attempts")
+	}
+
+	for _, series := range metrics {
+		for _, metric := range series {
+			if !filter.Match(metric) {
+				continue
+			}
+
+			a, err := NewAttempt(metric)
+			if err != nil {
+				log.WithError(err).WithField("metric", metric).Warn("invalid attempt")
+				continue
+			}
+
+			attempts = append(attempts, a)
+		}
+	}
+
+	return attempts, nil
+}
+
+// NewAttempt reads an attempt from a metric. The transmit power (in dBm)
+// comes from the power tag and is NaN when unknown.
+func NewAttempt(metric Metric) (Attempt, error) {
+	var ok bool
+
+	tags := metric.Tags()
+
+	location, err := LatLonFromTags(tags)
+	if err != nil {
+		return Attempt{}, err
+	}
+
+	sf, err := SpreadingFactor(tags["data_rate"])
+	if err != nil {
+		return Attempt{}, err
+	}
+
+	a := Attempt{
+		Location: location,
+		DeviceID: tags["device_id"],
+		Campaign: tags[CampaignTag],
+		DataRate: tags["data_rate"],
+		SF:       sf,
+		Time:     metric.Time(),
+	}
+
+	if size, ok := ToFloat(metric.Fields()["size"]); ok {
+		a.Size = int(size)
+	}
+
+	a.TxPower, ok = ToFloat(tags["power"])
+	if !ok {
+		a.TxPower = math.NaN()
+	}
+
+	return a, nil
+}
+
+// MigratePlaceholders moves the rssi 0 placeholders of the receptions
+// measurement to the attempts measurement and deletes them. Points without
+// gateway that aren't valid placeholders are skipped (and deleted as well).
+func MigratePlaceholders(db Database, receptions, attempts string) (migrated, skipped int, err error) {
+	series, err := db.Query(fmt.Sprintf(InfluxPlaceholders, receptions))
+	if err != nil {
+		return 0, 0, errors.Wrap(err, "querying placeholders")
+	}
+
+	var metrics []Metric
+
+	for _, s := range series {
+		for _, m := range s {
+			if rssi, ok := ToFloat(m.Fields()["rssi"]); !ok || rssi != 0 {
+				log.WithField("metric", m).Warn("reception without gateway")
+				skipped++
+				continue
+			}
+
+			size, ok := ToFloat(m.Fields()["size"])
+			if !ok {
+				log.WithField("metric", m).Warn("placeholder without size")
+				skipped++
+				continue
+			}
+
+			fields := map[string]interface{}{"size": int(size)}
+
+			tags := make(map[string]string, len(m.Tags()))
+			for k, v := range m.Tags() {
+				if v != "" {
+					tags[k] = v
+				}
+			}
+
+			attempt, err := NewMetric(attempts, tags, fields, m.Time())
+			if err != nil {
+				return 0, 0, errors.Wrap(err, "creating attempt")
+			}
+
+			metrics = append(metrics, attempt)
+		}
+	}
+
+	if len(metrics) > 0 {
+		if err := db.Write(metrics); err != nil {
+			return 0, 0, errors.Wrap(err, "writing attempts")
+		}
+	}
+
+	if len(metrics)+skipped > 0 {
+		if _, err := db.Query(fmt.Sprintf(InfluxDeletePlaceholders, receptions)); err != nil {
+			return len(metrics), skipped, errors.Wrap(err, "deleting placeholders")
+		}
+	}
+
+	return len(metrics), skipped, nil
+}
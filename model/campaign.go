@@ -0,0 +1,205 @@
+// The MIT License (MIT)
+//
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package model
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	CampaignData = "campaigns"
+	CampaignTag  = "campaign"
+	// DefaultCampaign tags the data added without campaign.
+	DefaultCampaign = "default"
+
+	InfluxCampaigns = `select * from %s%s group by id`
+)
+
+var (
+	ErrCampaignNotFound = errors.New("campaign not found")
+	ErrCampaignExists   = errors.New("campaign already exists")
+	ErrCampaignArchived = errors.New("campaign is archived")
+	ErrCampaignID       = errors.New("invalid campaign id (allowed: letters, digits, '-' and '_')")
+
+	campaignIDRegex = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+)
+
+type Campaign struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	Archived    bool      `json:"archived"`
+	Created     time.Time `json:"created"`
+}
+
+type campaigns struct {
+	db              Database
+	measurementName string
+}
+
+type Campaigns interface {
+	Create(Campaign) error
+	Get(id string) (Campaign, error)
+	List(archived bool) ([]Campaign, error)
+	Archive(id string) error
+	// Active returns an error if the campaign doesn't exist or is archived.
+	Active(id string) error
+}
+
+func NewCampaigns(db Database, measurementName string) Campaigns {
+	return &campaigns{
+		db:              db,
+		measurementName: measurementName,
+	}
+}
+
+func (c *campaigns) Create(campaign Campaign) error {
+	if !campaignIDRegex.MatchString(campaign.ID) {
+		return ErrCampaignID
+	}
+
+	if _, err := c.Get(campaign.ID); err == nil {
+		return ErrCampaignExists
+	} else if err != ErrCampaignNotFound {
+		return err
+	}
+
+	if campaign.Name == "" {
+		campaign.Name = campaign.ID
+	}
+
+	if campaign.Created.IsZero() {
+		campaign.Created = time.Now().UTC()
+	}
+
+	return c.write(campaign)
+}
+
+func (c *campaigns) Get(id string) (Campaign, error) {
+	if !campaignIDRegex.MatchString(id) {
+		return Campaign{}, ErrCampaignID
+	}
+
+	list, err := c.query(fmt.Sprintf(" where id = '%s'", id))
+	if err != nil {
+		return Campaign{}, err
+	}
+
+	if len(list) == 0 {
+		return Campaign{}, ErrCampaignNotFound
+	}
+
+	return list[0], nil
+}
+
+func (c *campaigns) List(archived bool) ([]Campaign, error) {
+	var result []Campaign
+
+	list, err := c.query("")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, campaign := range list {
+		if !campaign.Archived || archived {
+			result = append(result, campaign)
+		}
+	}
+
+	return result, nil
+}
+
+func (c *campaigns) Archive(id string) error {
+	campaign, err := c.Get(id)
+	if err != nil {
+		return err
+	}
+
+	campaign.Archived = true
+
+	// the point is overwritten because the timestamp doesn't change
+	return c.write(campaign)
+}
+
+func (c *campaigns) Active(id string) error {
+	campaign, err := c.Get(id)
+	if err != nil {
+		return errors.Wrap(err, id)
+	}
+
+	if campaign.Archived {
+		return errors.Wrap(ErrCampaignArchived, id)
+	}
+
+	return nil
+}
+
+func (c *campaigns) write(campaign Campaign) error {
+	metric, err := NewMetric(c.measurementName, map[string]string{
+		"id": campaign.ID,
+	}, map[string]interface{}{
+		"name":        campaign.Name,
+		"description": campaign.Description,
+		"archived":    campaign.Archived,
+	}, campaign.Created)
+	if err != nil {
+		return err
+	}
+
+	return errors.Wrapf(c.db.Write([]Metric{metric}), "writing campaign %s", campaign.ID)
+}
+
+func (c *campaigns) query(where string) ([]Campaign, error) {
+	var result []Campaign
+
+	metrics, err := c.db.Query(fmt.Sprintf(InfluxCampaigns, c.measurementName, where))
+	if err != nil {
+		return nil, errors.Wrap(err, "querying campaigns")
+	}
+
+	for _, series := range metrics {
+		for _, metric := range series {
+			campaign := Campaign{
+				ID:      metric.Tags()["id"],
+				Created: metric.Time(),
+			}
+
+			campaign.Name, _ = metric.Fields()["name"].(string)
+			campaign.Description, _ = metric.Fields()["description"].(string)
+			campaign.Archived, _ = metric.Fields()["archived"].(bool)
+
+			result = append(result, campaign)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Created.Before(result[j].Created)
+	})
+
+	return result, nil
+}
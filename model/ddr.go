@@ -27,9 +27,12 @@ import (
 	"container/list"
 	"fmt"
 	"math"
+	"sort"
 	"strconv"
+	"time"
 
 	"github.com/apex/log"
+	"github.com/pkg/errors"
 )
 
 const (
@@ -41,13 +44,24 @@ const (
 )
 
 const (
-	InfluxDDR = `select distinct(data_rate) as "data_rate" from (select rssi, snr, data_rate from %s where rssi < 0 and latitude=~/%s/ and longitude=~/%s/ group by latitude, longitude) group by latitude, longitude`
+	// DefaultDDRHalfLife is the age difference after which a sample weighs
+	// half as much.
+	DefaultDDRHalfLife = 30 * 24 * time.Hour
+	// DefaultDDRMargin is the link margin in dB required for a data rate.
+	DefaultDDRMargin = 5.0
+	// DefaultDDRPercentile is the share of samples allowed below the margin.
+	DefaultDDRPercentile = 0.1
+	// minDDRSigma is the lower bound of the spread of the SNR in dB, so that a
+	// few similar samples don't give full confidence.
+	minDDRSigma = 2.0
 )
 
 type ddr struct {
 	db              Database
 	measurementName string
 	minRadius       float64
+	filter          Filter
+	options         DDROptions
 }
 
 type LatLon struct {
@@ -55,8 +69,68 @@ type LatLon struct {
 	Longitude float64
 }
 
+// DDROptions select the policy and tune the weights of the samples: a sample
+// at half the radius weighs half as much as one at the location, a sample
+// HalfLife older than the most recent sample nearby weighs half as much as
+// the most recent one. Margin is the link margin in dB and Percentile (0 - 1)
+// the share of samples allowed below the margin of the policies that use them.
+type DDROptions struct {
+	Policy     string
+	HalfLife   time.Duration
+	Margin     float64
+	Percentile float64
+}
+
+// DefaultDDROptions returns the weighted-margin policy with a margin of 5 dB,
+// a half life of 30 days and a percentile of 10%.
+func DefaultDDROptions() DDROptions {
+	return DDROptions{
+		Policy:     PolicyWeightedMargin,
+		HalfLife:   DefaultDDRHalfLife,
+		Margin:     DefaultDDRMargin,
+		Percentile: DefaultDDRPercentile,
+	}
+}
+
+// Recommendation is the data rate recommended at a location by a policy. The
+// confidence (0 - 1) grows with the weight of the samples and the margin of
+// the gateways at the recommended spreading factor. Without samples in the
+// radius SF12 is recommended as a fallback, with a confidence of 0. The
+// distances and margins are in meters and dB. Responses can add the energy
+// estimate of a device profile.
+type Recommendation struct {
+	DataRate        string    `json:"datarate"`
+	SF              int       `json:"sf"`
+	Confidence      float64   `json:"confidence"`
+	Samples         int       `json:"samples"`
+	NearestDistance float64   `json:"nearest_distance"`
+	Gateway         string    `json:"gateway,omitempty"`
+	Gateways        int       `json:"gateways"`
+	SNRMargin       float64   `json:"snr_margin"`
+	RSSIMargin      float64   `json:"rssi_margin"`
+	Policy          string    `json:"policy"`
+	Fallback        bool      `json:"fallback"`
+	ComputedAt      time.Time `json:"computed_at"`
+	Energy          *Energy   `json:"energy,omitempty"`
+}
+
+// Explanation describes how the recommendation was made, eg. "SF9BW125 by
+// weighted-margin from 12 samples of 2 gateways (nearest 23 m), gw-1 has a
+// 4.5 dB snr and 12.0 dB rssi margin".
+func (r Recommendation) Explanation() string {
+	if r.Fallback {
+		return fmt.Sprintf("%s by %s as a fallback, no samples in the radius", r.DataRate, r.Policy)
+	}
+
+	return fmt.Sprintf("%s by %s from %d samples of %d gateways (nearest %.0f m), %s has a %.1f dB snr and %.1f dB rssi margin",
+		r.DataRate, r.Policy, r.Samples, r.Gateways, r.NearestDistance, r.Gateway, r.SNRMargin, r.RSSIMargin)
+}
+
 type DDR interface {
 	GetSF(lon LatLon) (string, error)
+	Recommend(LatLon) (Recommendation, error)
+	SetFilter(Filter)
+	SetOptions(DDROptions)
 }
 
 func NewDDR(db Database, measurementName string, minRadius float64) DDR {
@@ -64,103 +138,249 @@ func NewDDR(db Database, measurementName string, minRadius float64) DDR {
 		db:              db,
 		measurementName: measurementName,
 		minRadius:       minRadius,
+		options:         DefaultDDROptions(),
 	}
 }
 
+func (d *ddr) SetFilter(filter Filter) {
+	d.filter = filter
+}
+
+func (d *ddr) SetOptions(options DDROptions) {
+	d.options = options
+}
+
 func (d *ddr) GetSF(ll LatLon) (string, error) {
-	var datarates = make(map[float64]int)
+	r, err := d.Recommend(ll)
+	if err != nil {
+		return "", err
+	}
 
-	minDistance := d.minRadius + 1
+	return r.DataRate, nil
+}
 
+func (d *ddr) Recommend(ll LatLon) (Recommendation, error) {
 	top, bottom := d.getBoundsTopBottom(ll)
 	latitude := getRegex(bottom, top)
 
 	left, right := d.getBoundsLeftRight(ll)
 	longitude := getRegex(left, right)
 
-	command := fmt.Sprintf(InfluxDDR, d.measurementName, latitude, longitude)
+	condition := fmt.Sprintf(" and latitude=~/%s/ and longitude=~/%s/%s", latitude, longitude, d.filter.And())
 
-	metrics, err := d.db.Query(command)
+	metrics, err := d.db.Query(fmt.Sprintf(InfluxReceptions, d.measurementName, condition))
 	if err != nil {
-		return "", err
+		return Recommendation{}, errors.Wrap(err, "querying receptions")
 	}
 
-	// TODO calculate distance to get weighted best datarate
+	var receptions []Reception
 
 	for _, series := range metrics {
-		var lat, lon float64
-
-		sf := 12
-
 		for _, metric := range series {
-			var err error
-
-			if !metric.HasTag("latitude") || !metric.HasTag("longitude") || !metric.HasField("data_rate") {
-				log.WithField("metric", metric).Warn("invalid metric")
+			if !d.filter.Match(metric) {
 				continue
 			}
 
-			if lat == 0 {
-				lat, err = strconv.ParseFloat(metric.Tags()["latitude"], 64)
-				if err != nil {
-					log.WithField("latitude", metric.Tags()["latitude"]).Warn("invalid latitude")
-					continue
-				}
-			}
-
-			if lon == 0 {
-				lon, err = strconv.ParseFloat(metric.Tags()["longitude"], 64)
-				if err != nil {
-					log.WithField("latitude", metric.Tags()["longitude"]).Warn("invalid longitude")
-					continue
-				}
-			}
-
-			dr := metric.Fields()["data_rate"].(string)
-			i, err := strconv.Atoi(dr[2 : len(dr)-5])
+			r, err := NewReception(metric)
 			if err != nil {
-				log.WithField("data rate", metric.Fields()["data_rate"]).Warn("invalid data rate")
+				log.WithError(err).WithField("metric", metric).Warn("invalid reception")
 				continue
 			}
 
-			if i < sf {
-				sf = i
-			}
+			receptions = append(receptions, r)
+		}
+	}
+
+	r, err := RecommendDataRate(ll, receptions, d.minRadius, d.options)
+	r.ComputedAt = time.Now().UTC()
+
+	return r, err
+}
+
+// DDRSample is a reception within the radius of a location, with its distance
+// in meters and its weight.
+type DDRSample struct {
+	Reception
+	Distance float64
+	Weight   float64
+}
+
+// DDRGateway holds the weighted mean RSSI, SNR and effective signal power of
+// the samples received by a gateway, with the spread of the SNR.
+type DDRGateway struct {
+	ID     string
+	Weight float64
+	RSSI   float64
+	SNR    float64
+	ESP    float64
+	Sigma  float64
+}
 
-			//if ll.getDistance(LatLon{Latitude: lat, Longitude: lon}) < distance {
-			//	sf = i
-			//}
+// Margin returns the SNR and RSSI margin of the spreading factor.
+func (g DDRGateway) Margin(sf int) (snr, rssi float64) {
+	return g.SNR - DemodulationFloor(sf), g.RSSI - Sensitivity(sf)
+}
+
+// LinkMargin returns the smallest margin of the spreading factor.
+func (g DDRGateway) LinkMargin(sf int) float64 {
+	return LinkMargin(sf, g.RSSI, g.SNR)
+}
+
+// Probability returns the probability that the spreading factor is received,
+// given the spread of the SNR.
+func (g DDRGateway) Probability(sf int) float64 {
+	return 0.5 * math.Erfc(-g.LinkMargin(sf)/(g.Sigma*math.Sqrt2))
+}
+
+// DDRSamples returns the receptions within the radius (in meters) with their
+// weight: a sample at half the radius weighs half as much as one at the
+// location and a sample the half life older than the newest sample weighs
+// half as much as the newest one.
+func DDRSamples(ll LatLon, receptions []Reception, radius float64, halfLife time.Duration) []DDRSample {
+	var newest time.Time
+	var samples []DDRSample
+
+	for _, r := range receptions {
+		distance := ll.getDistance(r.Location) * 1000
+		if distance > radius {
+			continue
 		}
 
-		if lat > 0 && lon > 0 {
-			location := LatLon{lat, lon}
-			distance := ll.getDistance(location)
-			datarates[distance] = sf
-			if distance < minDistance {
-				minDistance = distance
-			}
+		samples = append(samples, DDRSample{Reception: r, Distance: distance})
+
+		if r.Time.After(newest) {
+			newest = r.Time
 		}
 	}
 
-	bestDatarate := 12
+	for i, s := range samples {
+		w := 1 / (1 + math.Pow(2*s.Distance/radius, 2))
+
+		if halfLife > 0 {
+			w *= math.Pow(0.5, float64(newest.Sub(s.Time))/float64(halfLife))
+		}
 
-	if minDistance < d.minRadius + 1 {
-		bestDatarate = datarates[minDistance]
+		samples[i].Weight = w
 	}
 
-	//for _, dr := range datarates {
-	//	if dr < bestDatarate {
-	//		bestDatarate = dr
-	//	}
-	//}
+	return samples
+}
+
+// DDRGateways returns the gateways of the samples, sorted by id.
+func DDRGateways(samples []DDRSample) []DDRGateway {
+	var result []DDRGateway
 
-	return fmt.Sprintf("SF%dBW125", bestDatarate), nil
+	index := make(map[string]int)
+	snr2 := make(map[string]float64)
+
+	for _, s := range samples {
+		i, ok := index[s.GatewayID]
+		if !ok {
+			i = len(result)
+			index[s.GatewayID] = i
+			result = append(result, DDRGateway{ID: s.GatewayID})
+		}
+
+		result[i].Weight += s.Weight
+		result[i].RSSI += s.Weight * s.RSSI
+		result[i].SNR += s.Weight * s.SNR
+		result[i].ESP += s.Weight * s.ESP()
+		snr2[s.GatewayID] += s.Weight * s.SNR * s.SNR
+	}
+
+	for i, g := range result {
+		if g.Weight == 0 {
+			result[i].Sigma = minDDRSigma
+			continue
+		}
+
+		mean := g.SNR / g.Weight
+		result[i].RSSI = g.RSSI / g.Weight
+		result[i].SNR = mean
+		result[i].ESP = g.ESP / g.Weight
+		result[i].Sigma = math.Max(math.Sqrt(math.Max(snr2[g.ID]/g.Weight-mean*mean, 0)), minDDRSigma)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ID < result[j].ID
+	})
+
+	return result
+}
+
+// RecommendDataRate recommends the data rate of the policy of the options
+// (by default weighted-margin) from the receptions within the radius (in
+// meters). The gateway and margins are those of the gateway with the best
+// margin at the recommended spreading factor.
+func RecommendDataRate(ll LatLon, receptions []Reception, radius float64, options DDROptions) (Recommendation, error) {
+	policy, err := GetDDRPolicy(options.Policy)
+	if err != nil {
+		return Recommendation{}, err
+	}
+
+	result := Recommendation{
+		SF:       12,
+		DataRate: DataRate(12),
+		Policy:   policy.Name(),
+	}
+
+	samples := DDRSamples(ll, receptions, radius, options.HalfLife)
+
+	result.Samples = len(samples)
+
+	if len(samples) == 0 {
+		result.Fallback = true
+		return result, nil
+	}
+
+	result.NearestDistance = math.Inf(1)
+
+	var total float64
+
+	for _, s := range samples {
+		total += s.Weight
+		result.NearestDistance = math.Min(result.NearestDistance, s.Distance)
+	}
+
+	gateways := DDRGateways(samples)
+
+	result.Gateways = len(gateways)
+	result.SF = policy.SF(samples, gateways, options)
+	result.DataRate = DataRate(result.SF)
+
+	// probability that at least one gateway receives the data rate, scaled
+	// down when there are only a few (or distant or old) samples
+	missed := 1.0
+	best := gateways[0]
+
+	for _, g := range gateways {
+		missed *= 1 - g.Probability(result.SF)
+
+		if g.LinkMargin(result.SF) > best.LinkMargin(result.SF) {
+			best = g
+		}
+	}
+
+	result.Gateway = best.ID
+	result.SNRMargin, result.RSSIMargin = best.Margin(result.SF)
+
+	result.Confidence = round((1-math.Exp(-total))*(1-missed), 2)
+	result.NearestDistance = round(result.NearestDistance, 1)
+	result.SNRMargin = round(result.SNRMargin, 1)
+	result.RSSIMargin = round(result.RSSIMargin, 1)
+
+	return result, nil
 }
 
 func radians(degree float64) float64 {
 	return degree * math.Pi / 180
 }
 
+// Distance returns the great circle distance to y in km.
+func (x LatLon) Distance(y LatLon) float64 {
+	return x.getDistance(y)
+}
+
 func (x LatLon) getDistance(y LatLon) float64 {
 	lat1 := radians(x.Latitude)
 	lat2 := radians(y.Latitude)
@@ -195,12 +415,22 @@ func (d *ddr) getBoundsLeftRight(ll LatLon) (left float64, right float64) {
 }
 
 func getRegex(start, end float64) string {
+	return rangesRegex(int(start*floatToIntPrecision), int(end*floatToIntPrecision))
+}
+
+// rangesRegex returns the regular expression of the location tags from start
+// to end, in 1/floatToIntPrecision degrees.
+func rangesRegex(start, end int) string {
 	var result bytes.Buffer
 
-	left := leftBounds(int(start*floatToIntPrecision), int(end*floatToIntPrecision))
+	if start >= end {
+		return Range{Start: start, End: start}.Regex(floatToIntPrecision)
+	}
+
+	left := leftBounds(start, end)
 	lastLeft := left.Remove(left.Back()).(*Range)
 
-	right := rightBounds(lastLeft.Start, int(end*floatToIntPrecision))
+	right := rightBounds(lastLeft.Start, end)
 	firstRight := right.Remove(right.Front()).(*Range)
 
 	merged := list.New()
@@ -311,22 +541,45 @@ func (r Range) Overlaps(r2 *Range) bool {
 	return r.End > r2.Start && r2.End > r.Start
 }
 
+// Regex returns the regular expression of the location tags in the range.
+// The tags are truncated to 4 decimals without trailing zeros (eg. 51.23 for
+// 51.2300), so every decimal can also be the end of the tag.
 func (r Range) Regex(precision int) string {
 	startS := []byte(strconv.FormatFloat(float64(r.Start)/float64(precision), 'f', 4, 64))
 	endS := []byte(strconv.FormatFloat(float64(r.End)/float64(precision), 'f', 4, 64))
 
 	var result bytes.Buffer
 
+	result.WriteString("^-?")
+
+	groups := 0
+
 	for pos := 0; pos < len(startS); pos++ {
-		if startS[pos] == endS[pos] {
+		switch {
+		case startS[pos] == '.':
+			result.WriteString(`(\.`)
+			groups++
+		case startS[pos] == endS[pos]:
 			result.WriteByte(startS[pos])
-		} else {
+		default:
 			result.WriteByte('[')
 			result.WriteByte(startS[pos])
 			result.WriteByte('-')
 			result.WriteByte(endS[pos])
 			result.WriteByte(']')
 		}
+
+		// the tag can end after a decimal when the rest are zeros
+		if groups > 0 && startS[pos] != '.' && pos < len(startS)-1 {
+			result.WriteByte('(')
+			groups++
+		}
+	}
+
+	result.WriteString("$")
+
+	for ; groups > 0; groups-- {
+		result.WriteString("|$)")
 	}
 
 	return result.String()
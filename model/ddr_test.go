@@ -0,0 +1,166 @@
+// The MIT License (MIT)
+//
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package model
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestRecommendDataRate(t *testing.T) {
+	ts := time.Date(2018, 4, 1, 12, 0, 0, 0, time.UTC)
+	options := DDROptions{HalfLife: DefaultDDRHalfLife, Margin: DefaultDDRMargin}
+	ll := LatLon{Latitude: 51, Longitude: 4.7}
+	near := LatLon{Latitude: 51.0002, Longitude: 4.7}
+	far := LatLon{Latitude: 51.01, Longitude: 4.7}
+
+	r, _ := RecommendDataRate(ll, []Reception{{Location: far, GatewayID: "a", SF: 7, RSSI: -80, SNR: 10, Time: ts}}, 100, options)
+	if !r.Fallback || r.DataRate != "SF12BW125" || r.Confidence != 0 || r.Samples != 0 {
+		t.Errorf("expected SF12 fallback without samples in the radius, got %+v", r)
+	}
+
+	// strong signal received at SF12 allows SF7
+	var strong []Reception
+	for i := 0; i < 5; i++ {
+		strong = append(strong, Reception{Location: near, GatewayID: "a", SF: 12, RSSI: -90, SNR: 8, Time: ts})
+	}
+
+	r, _ = RecommendDataRate(ll, strong, 100, options)
+	if r.DataRate != "SF7BW125" || r.Gateway != "a" || r.Samples != 5 || r.Fallback {
+		t.Errorf("expected SF7 for a strong signal, got %+v", r)
+	}
+	if r.NearestDistance < 20 || r.NearestDistance > 25 {
+		t.Errorf("wrong nearest distance %v", r.NearestDistance)
+	}
+	if r.Confidence < 0.8 {
+		t.Errorf("expected a high confidence, got %v", r.Confidence)
+	}
+
+	single, _ := RecommendDataRate(ll, strong[:1], 100, options)
+	if single.Confidence >= r.Confidence {
+		t.Errorf("one sample should give less confidence than five: %v >= %v", single.Confidence, r.Confidence)
+	}
+
+	// a weak old sample is outweighed by strong recent samples
+	weak := Reception{Location: ll, GatewayID: "b", SF: 12, RSSI: -130, SNR: -15, Time: ts.Add(-365 * 24 * time.Hour)}
+	r, _ = RecommendDataRate(ll, append([]Reception{weak}, strong...), 100, options)
+	if r.DataRate != "SF7BW125" || r.Gateways != 2 {
+		t.Errorf("expected SF7 with 2 gateways, got %+v", r)
+	}
+
+	// SNR of -12 dB only leaves the 5 dB margin from SF11 on
+	r, _ = RecommendDataRate(ll, []Reception{{Location: ll, GatewayID: "a", SF: 12, RSSI: -120, SNR: -12, Time: ts}}, 100, options)
+	if r.DataRate != "SF11BW125" || r.SNRMargin != 5.5 {
+		t.Errorf("expected SF11 with 5.5 dB snr margin, got %+v", r)
+	}
+}
+
+func TestDDRPolicies(t *testing.T) {
+	ts := time.Date(2018, 4, 1, 12, 0, 0, 0, time.UTC)
+	ll := LatLon{Latitude: 51, Longitude: 4.7}
+
+	// mostly received at SF10 with a few SF8 receptions, the mean SNR leaves
+	// 5 dB margin from SF8 on, the worst 10% only from SF10 on
+	var receptions []Reception
+	for i, snr := range []float64{-2, -3, -1, -9, -2, -3, -2, -1, -2, -3} {
+		sf := 10
+		if i%4 == 0 {
+			sf = 8
+		}
+
+		receptions = append(receptions, Reception{Location: ll, GatewayID: "a", SF: sf, RSSI: -110, SNR: snr, Time: ts})
+	}
+
+	expected := map[string]string{
+		PolicyWeightedMargin:   "SF8BW125",
+		PolicyFastestSeen:      "SF8BW125",
+		PolicyPercentileMargin: "SF10BW125",
+		PolicyMajority:         "SF10BW125",
+		PolicyConservative:     "SF9BW125",
+		PolicyESPMargin:        "SF7BW125",
+	}
+
+	for policy, dr := range expected {
+		options := DefaultDDROptions()
+		options.Policy = policy
+
+		r, err := RecommendDataRate(ll, receptions, 100, options)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if r.DataRate != dr || r.Policy != policy {
+			t.Errorf("%s: expected %s, got %+v", policy, dr, r)
+		}
+	}
+
+	options := DefaultDDROptions()
+	options.Policy = "fastest"
+
+	if _, err := RecommendDataRate(ll, receptions, 100, options); err == nil {
+		t.Error("unknown policy should give an error")
+	}
+}
+
+func TestDDRGrid(t *testing.T) {
+	ts := time.Date(2018, 4, 1, 12, 0, 0, 0, time.UTC)
+	ll := LatLon{Latitude: 51, Longitude: 4.7}
+
+	var receptions []Reception
+	for i := 0; i < 5; i++ {
+		receptions = append(receptions, Reception{Location: ll, GatewayID: "a", SF: 12, RSSI: -90, SNR: 8, Time: ts.Add(time.Duration(i) * time.Minute)})
+	}
+
+	grid, err := ComputeDDRGrid(receptions, DefaultDDRGridSize, nil, 100, DefaultDDROptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !grid.LastReception.Equal(ts.Add(4 * time.Minute)) {
+		t.Errorf("wrong last reception %v", grid.LastReception)
+	}
+
+	r := grid.Lookup(ll)
+	if r.DataRate != "SF7BW125" || r.Fallback || !r.ComputedAt.Equal(grid.ComputedAt) {
+		t.Errorf("expected SF7 at the receptions, got %+v", r)
+	}
+
+	if r := grid.Lookup(LatLon{Latitude: 51.01, Longitude: 4.7}); !r.Fallback || r.DataRate != "SF12BW125" {
+		t.Errorf("expected SF12 fallback far from the receptions, got %+v", r)
+	}
+
+	var buf bytes.Buffer
+	if err := grid.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	read, err := ReadDDRGrid(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(read.Cells) != len(grid.Cells) || read.Lookup(ll) != grid.Lookup(ll) {
+		t.Errorf("grid changed after writing and reading: %+v != %+v", read.Lookup(ll), grid.Lookup(ll))
+	}
+}
@@ -0,0 +1,263 @@
+// The MIT License (MIT)
+//
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultDDRGridSize is the size of the cells of the ddr grid in meters.
+	DefaultDDRGridSize = 25.0
+
+	InfluxLastReception = `select last(rssi) from %s where ` + ReceptionCondition
+)
+
+// DDRGrid holds the recommendation at the center of every cell within the
+// radius of a reception, so that looking up a location doesn't query the
+// database.
+type DDRGrid struct {
+	Grid    Grid
+	Radius  float64
+	Options DDROptions
+	Cells   map[CellID]Recommendation
+	// ComputedAt is the time of the computation, LastReception the time of
+	// the newest reception it used.
+	ComputedAt    time.Time
+	LastReception time.Time
+}
+
+// ComputeDDRGrid recommends the data rate of every square cell within the
+// radius (in meters) of the receptions.
+func ComputeDDRGrid(receptions []Reception, size float64, origin *LatLon, radius float64, options DDROptions) (*DDRGrid, error) {
+	o := DefaultOrigin(ReceptionLocations(receptions))
+	if origin != nil {
+		o = *origin
+	}
+
+	grid, err := NewGrid(ShapeSquare, size, o)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := GetDDRPolicy(options.Policy); err != nil {
+		return nil, err
+	}
+
+	result := &DDRGrid{
+		Grid:       grid,
+		Radius:     radius,
+		Options:    options,
+		Cells:      make(map[CellID]Recommendation),
+		ComputedAt: time.Now().UTC(),
+	}
+
+	// receptions by cell
+	cells := make(map[CellID][]Reception)
+	for _, r := range receptions {
+		id := grid.Cell(r.Location)
+		cells[id] = append(cells[id], r)
+
+		if r.Time.After(result.LastReception) {
+			result.LastReception = r.Time
+		}
+	}
+
+	reach := int(math.Ceil(radius / size))
+
+	todo := make(map[CellID]bool)
+	for id := range cells {
+		for x := id.X - reach; x <= id.X+reach; x++ {
+			for y := id.Y - reach; y <= id.Y+reach; y++ {
+				todo[CellID{X: x, Y: y}] = true
+			}
+		}
+	}
+
+	for id := range todo {
+		var nearby []Reception
+
+		for x := id.X - reach; x <= id.X+reach; x++ {
+			for y := id.Y - reach; y <= id.Y+reach; y++ {
+				nearby = append(nearby, cells[CellID{X: x, Y: y}]...)
+			}
+		}
+
+		r, err := RecommendDataRate(grid.Center(id), nearby, radius, options)
+		if err != nil {
+			return nil, err
+		}
+
+		if r.Fallback {
+			continue
+		}
+
+		r.ComputedAt = result.ComputedAt
+		result.Cells[id] = r
+	}
+
+	return result, nil
+}
+
+// Lookup returns the recommendation of the cell of the location, SF12 as a
+// fallback outside of the cells. The distances are from the center of the
+// cell.
+func (g *DDRGrid) Lookup(ll LatLon) Recommendation {
+	if r, ok := g.Cells[g.Grid.Cell(ll)]; ok {
+		return r
+	}
+
+	policy, _ := GetDDRPolicy(g.Options.Policy)
+
+	return Recommendation{
+		SF:         12,
+		DataRate:   DataRate(12),
+		Policy:     policy.Name(),
+		Fallback:   true,
+		ComputedAt: g.ComputedAt,
+	}
+}
+
+type ddrGridCell struct {
+	X              int `json:"x"`
+	Y              int `json:"y"`
+	Recommendation `json:"recommendation"`
+}
+
+type ddrGridJSON struct {
+	Size          float64       `json:"size"`
+	Origin        LatLon        `json:"origin"`
+	Radius        float64       `json:"radius"`
+	Policy        string        `json:"policy"`
+	HalfLife      time.Duration `json:"half_life"`
+	Margin        float64       `json:"margin"`
+	Percentile    float64       `json:"percentile"`
+	ComputedAt    time.Time     `json:"computed_at"`
+	LastReception time.Time     `json:"last_reception"`
+	Cells         []ddrGridCell `json:"cells"`
+}
+
+// WriteJSON writes the grid, to be read back with ReadDDRGrid.
+func (g *DDRGrid) WriteJSON(w io.Writer) error {
+	data := ddrGridJSON{
+		Size:          g.Grid.Size(),
+		Origin:        g.Grid.Origin(),
+		Radius:        g.Radius,
+		Policy:        g.Options.Policy,
+		HalfLife:      g.Options.HalfLife,
+		Margin:        g.Options.Margin,
+		Percentile:    g.Options.Percentile,
+		ComputedAt:    g.ComputedAt,
+		LastReception: g.LastReception,
+		Cells:         make([]ddrGridCell, 0, len(g.Cells)),
+	}
+
+	for id, r := range g.Cells {
+		data.Cells = append(data.Cells, ddrGridCell{X: id.X, Y: id.Y, Recommendation: r})
+	}
+
+	return errors.Wrap(json.NewEncoder(w).Encode(data), "writing ddr grid")
+}
+
+func ReadDDRGrid(r io.Reader) (*DDRGrid, error) {
+	var data ddrGridJSON
+
+	if err := json.NewDecoder(r).Decode(&data); err != nil {
+		return nil, errors.Wrap(err, "reading ddr grid")
+	}
+
+	grid, err := NewGrid(ShapeSquare, data.Size, data.Origin)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &DDRGrid{
+		Grid:   grid,
+		Radius: data.Radius,
+		Options: DDROptions{
+			Policy:     data.Policy,
+			HalfLife:   data.HalfLife,
+			Margin:     data.Margin,
+			Percentile: data.Percentile,
+		},
+		Cells:         make(map[CellID]Recommendation, len(data.Cells)),
+		ComputedAt:    data.ComputedAt,
+		LastReception: data.LastReception,
+	}
+
+	for _, c := range data.Cells {
+		result.Cells[CellID{X: c.X, Y: c.Y}] = c.Recommendation
+	}
+
+	return result, nil
+}
+
+// LastReceptionTime returns the time of the newest reception, the zero time
+// without receptions.
+func LastReceptionTime(db Database, measurementName string) (time.Time, error) {
+	metrics, err := db.Query(fmt.Sprintf(InfluxLastReception, measurementName))
+	if err != nil {
+		return time.Time{}, errors.Wrap(err, "querying last reception")
+	}
+
+	for _, series := range metrics {
+		for _, metric := range series {
+			return metric.Time(), nil
+		}
+	}
+
+	return time.Time{}, nil
+}
+
+// DDRGrids holds the ddr grid of every profile, safe for concurrent use.
+type DDRGrids struct {
+	mutex sync.RWMutex
+	grids map[string]*DDRGrid
+}
+
+// Get returns the grid of the profile ("" for the default options), nil when
+// not computed yet.
+func (g *DDRGrids) Get(profile string) *DDRGrid {
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+
+	return g.grids[profile]
+}
+
+func (g *DDRGrids) Set(profile string, grid *DDRGrid) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if g.grids == nil {
+		g.grids = make(map[string]*DDRGrid)
+	}
+
+	g.grids[profile] = grid
+}
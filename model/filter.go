@@ -0,0 +1,306 @@
+// The MIT License (MIT)
+//
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package model
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type BoundingBox struct {
+	MinLatitude  float64
+	MinLongitude float64
+	MaxLatitude  float64
+	MaxLongitude float64
+}
+
+type Filter struct {
+	Start       time.Time
+	End         time.Time
+	DeviceIDs   []string
+	GatewayIDs  []string
+	Campaigns   []string
+	DataRates   []string
+	BoundingBox *BoundingBox
+	// Window selects recurring periods, it's only applied by Match.
+	Window TimeWindow
+}
+
+// ParseBoundingBox parses a bounding box in the GeoJSON order
+// "min_lon,min_lat,max_lon,max_lat".
+func ParseBoundingBox(s string) (BoundingBox, error) {
+	var values [4]float64
+
+	parts := strings.Split(s, ",")
+	if len(parts) != 4 {
+		return BoundingBox{}, errors.Errorf("invalid bounding box: %s", s)
+	}
+
+	for i, part := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return BoundingBox{}, errors.Wrapf(err, "invalid bounding box: %s", s)
+		}
+		values[i] = v
+	}
+
+	b := BoundingBox{
+		MinLongitude: values[0],
+		MinLatitude:  values[1],
+		MaxLongitude: values[2],
+		MaxLatitude:  values[3],
+	}
+
+	if b.MinLatitude > b.MaxLatitude || b.MinLongitude > b.MaxLongitude {
+		return BoundingBox{}, errors.Errorf("invalid bounding box: %s", s)
+	}
+
+	return b, nil
+}
+
+func (b BoundingBox) Contains(ll LatLon) bool {
+	return ll.Latitude >= b.MinLatitude && ll.Latitude <= b.MaxLatitude &&
+		ll.Longitude >= b.MinLongitude && ll.Longitude <= b.MaxLongitude
+}
+
+func (b BoundingBox) String() string {
+	return fmt.Sprintf("%v,%v,%v,%v", b.MinLongitude, b.MinLatitude, b.MaxLongitude, b.MaxLatitude)
+}
+
+// Condition returns the filter as an InfluxQL condition without a leading
+// "where" or "and", or an empty string when nothing is filtered. The bounding
+// box is matched with regular expressions on the truncated location tags,
+// which can select a little more, use Match for the exact edges and the
+// window.
+func (f Filter) Condition() string {
+	var conditions []string
+
+	if !f.Start.IsZero() {
+		conditions = append(conditions, fmt.Sprintf("time >= '%s'", f.Start.UTC().Format(time.RFC3339Nano)))
+	}
+
+	if !f.End.IsZero() {
+		conditions = append(conditions, fmt.Sprintf("time < '%s'", f.End.UTC().Format(time.RFC3339Nano)))
+	}
+
+	if len(f.DeviceIDs) > 0 {
+		conditions = append(conditions, tagCondition("device_id", f.DeviceIDs))
+	}
+
+	if len(f.GatewayIDs) > 0 {
+		conditions = append(conditions, tagCondition("gateway_id", f.GatewayIDs))
+	}
+
+	if len(f.Campaigns) > 0 {
+		conditions = append(conditions, tagCondition(CampaignTag, f.Campaigns))
+	}
+
+	if len(f.DataRates) > 0 {
+		conditions = append(conditions, tagCondition("data_rate", f.DataRates))
+	}
+
+	if f.BoundingBox != nil {
+		if latitude := locationRegex(f.BoundingBox.MinLatitude, f.BoundingBox.MaxLatitude); latitude != "" {
+			conditions = append(conditions, fmt.Sprintf("latitude =~ /%s/", latitude))
+		}
+
+		if longitude := locationRegex(f.BoundingBox.MinLongitude, f.BoundingBox.MaxLongitude); longitude != "" {
+			conditions = append(conditions, fmt.Sprintf("longitude =~ /%s/", longitude))
+		}
+	}
+
+	return strings.Join(conditions, " and ")
+}
+
+// And returns the condition prefixed with " and ", ready to be appended to an
+// existing where clause.
+func (f Filter) And() string {
+	condition := f.Condition()
+
+	if condition == "" {
+		return ""
+	}
+
+	return " and " + condition
+}
+
+func (f Filter) Match(m Metric) bool {
+	t := m.Time()
+
+	if !f.Start.IsZero() && t.Before(f.Start) {
+		return false
+	}
+
+	if !f.End.IsZero() && !t.Before(f.End) {
+		return false
+	}
+
+	if !f.Window.IsZero() && !f.Window.Contains(t) {
+		return false
+	}
+
+	if len(f.DeviceIDs) > 0 && !contains(f.DeviceIDs, m.Tags()["device_id"]) {
+		return false
+	}
+
+	if len(f.GatewayIDs) > 0 && !contains(f.GatewayIDs, m.Tags()["gateway_id"]) {
+		return false
+	}
+
+	if len(f.Campaigns) > 0 && !contains(f.Campaigns, m.Tags()[CampaignTag]) {
+		return false
+	}
+
+	if len(f.DataRates) > 0 && !contains(f.DataRates, m.Tags()["data_rate"]) {
+		return false
+	}
+
+	if f.BoundingBox != nil {
+		ll, err := LatLonFromTags(m.Tags())
+		if err != nil || !f.BoundingBox.Contains(ll) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func LatLonFromTags(tags map[string]string) (LatLon, error) {
+	lat, err := strconv.ParseFloat(tags["latitude"], 64)
+	if err != nil {
+		return LatLon{}, errors.Wrapf(err, "invalid latitude: %s", tags["latitude"])
+	}
+
+	lon, err := strconv.ParseFloat(tags["longitude"], 64)
+	if err != nil {
+		return LatLon{}, errors.Wrapf(err, "invalid longitude: %s", tags["longitude"])
+	}
+
+	return LatLon{Latitude: lat, Longitude: lon}, nil
+}
+
+// locationRegex returns the regular expression of the location tags from min
+// to max, or an empty string when the range crosses zero or a power of ten
+// (eg. 9.99 - 10.01), which the ranges of getRegex can't express.
+func locationRegex(min, max float64) string {
+	if min < 0 && max >= 0 {
+		return ""
+	}
+
+	// the sign is optional in the expression
+	if max < 0 {
+		min, max = -max, -min
+	}
+
+	// rounded outwards, so that the expression doesn't miss the edges
+	start := int(math.Floor(min * floatToIntPrecision))
+	end := int(math.Ceil(max * floatToIntPrecision))
+
+	if len(strconv.Itoa(start)) != len(strconv.Itoa(end)) {
+		return ""
+	}
+
+	return rangesRegex(start, end)
+}
+
+func tagCondition(key string, values []string) string {
+	var conditions []string
+
+	for _, v := range values {
+		conditions = append(conditions, fmt.Sprintf("%s = '%s'", key, quote(v)))
+	}
+
+	return "(" + strings.Join(conditions, " or ") + ")"
+}
+
+func quote(s string) string {
+	return strings.Replace(s, "'", `\'`, -1)
+}
+
+func contains(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Intersect returns the part of the bounding box inside the other one, false
+// when they don't overlap.
+func (b BoundingBox) Intersect(other BoundingBox) (BoundingBox, bool) {
+	result := BoundingBox{
+		MinLatitude:  math.Max(b.MinLatitude, other.MinLatitude),
+		MinLongitude: math.Max(b.MinLongitude, other.MinLongitude),
+		MaxLatitude:  math.Min(b.MaxLatitude, other.MaxLatitude),
+		MaxLongitude: math.Min(b.MaxLongitude, other.MaxLongitude),
+	}
+
+	if result.MinLatitude > result.MaxLatitude || result.MinLongitude > result.MaxLongitude {
+		return BoundingBox{}, false
+	}
+
+	return result, true
+}
+
+// Extend returns the bounding box grown by meters on every side.
+func (b BoundingBox) Extend(meters float64) BoundingBox {
+	dLat := meters / LatitudeDegreeInMeters
+	dLon := dLat / math.Cos(radians((b.MinLatitude+b.MaxLatitude)/2))
+
+	return BoundingBox{
+		MinLatitude:  b.MinLatitude - dLat,
+		MinLongitude: b.MinLongitude - dLon,
+		MaxLatitude:  b.MaxLatitude + dLat,
+		MaxLongitude: b.MaxLongitude + dLon,
+	}
+}
+
+// Bounds returns the bounding box of the locations.
+func Bounds(locations []LatLon) BoundingBox {
+	if len(locations) == 0 {
+		return BoundingBox{}
+	}
+
+	b := BoundingBox{
+		MinLatitude:  locations[0].Latitude,
+		MinLongitude: locations[0].Longitude,
+		MaxLatitude:  locations[0].Latitude,
+		MaxLongitude: locations[0].Longitude,
+	}
+
+	for _, ll := range locations[1:] {
+		b.MinLatitude = math.Min(b.MinLatitude, ll.Latitude)
+		b.MinLongitude = math.Min(b.MinLongitude, ll.Longitude)
+		b.MaxLatitude = math.Max(b.MaxLatitude, ll.Latitude)
+		b.MaxLongitude = math.Max(b.MaxLongitude, ll.Longitude)
+	}
+
+	return b
+}
@@ -0,0 +1,60 @@
+// The MIT License (MIT)
+//
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package model
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestFilterCondition(t *testing.T) {
+	bbox := BoundingBox{MinLatitude: 50.9, MinLongitude: -0.1, MaxLatitude: 51.3, MaxLongitude: 0.2}
+
+	condition := Filter{BoundingBox: &bbox}.Condition()
+
+	// the longitudes cross zero and can't be expressed
+	if !strings.HasPrefix(condition, "latitude =~ /") || strings.Contains(condition, "longitude") {
+		t.Errorf("unexpected condition %s", condition)
+	}
+
+	// every truncated tag in the range matches, with or without trailing
+	// zeros and sign
+	for _, r := range [][2]float64{{51.2345, 51.2399}, {4.7, 4.85}, {50.9, 51.3}, {-3.75, -3.1}, {51.23, 51.23}} {
+		re := regexp.MustCompile(locationRegex(r[0], r[1]))
+
+		for v := r[0] - 0.01; v <= r[1]+0.01; v += 0.00003 {
+			tag := strconv.FormatFloat(float64(int(v*10000))/10000, 'f', -1, 64)
+			value, _ := strconv.ParseFloat(tag, 64)
+
+			if value >= r[0] && value <= r[1] && !re.MatchString(tag) {
+				t.Errorf("%v: %s doesn't match %s", r, tag, re)
+			}
+		}
+	}
+
+	if re := regexp.MustCompile(locationRegex(51.2, 51.3)); re.MatchString("51.1") || re.MatchString("151.25") {
+		t.Errorf("%s matches tags outside of the range", re)
+	}
+}
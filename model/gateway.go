@@ -0,0 +1,456 @@
+// The MIT License (MIT)
+//
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/paulmach/go.geojson"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	GatewayData = "gateways"
+
+	GatewayActive   = "active"
+	GatewayPlanned  = "planned"
+	GatewayInactive = "inactive"
+
+	InfluxGateways = `select * from %s%s group by id`
+)
+
+var (
+	ErrGatewayNotFound = errors.New("gateway not found")
+	ErrGatewayExists   = errors.New("gateway already exists")
+	ErrGatewayID       = errors.New("invalid gateway id (allowed: letters, digits, '-' and '_')")
+	ErrGatewayInvalid  = errors.New("invalid gateway")
+
+	gatewayIDRegex = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+)
+
+// Gateway is a gateway of the registry, identified by its EUI. The altitude
+// and antenna height are in meters, the antenna gain in dBi and the cable
+// loss in dB.
+type Gateway struct {
+	ID            string
+	Name          string
+	Location      LatLon
+	Altitude      float64
+	AntennaGain   float64
+	AntennaHeight float64
+	CableLoss     float64
+	Owner         string
+	Status        string
+	Created       time.Time
+}
+
+// gatewayJSON is the gateway of the REST API, with the location as lat and
+// lon like the other endpoints.
+type gatewayJSON struct {
+	ID            string    `json:"id"`
+	Name          string    `json:"name"`
+	Latitude      float64   `json:"lat"`
+	Longitude     float64   `json:"lon"`
+	Altitude      float64   `json:"altitude"`
+	AntennaGain   float64   `json:"antenna_gain"`
+	AntennaHeight float64   `json:"antenna_height"`
+	CableLoss     float64   `json:"cable_loss"`
+	Owner         string    `json:"owner,omitempty"`
+	Status        string    `json:"status"`
+	Created       time.Time `json:"created"`
+}
+
+func newGatewayJSON(g Gateway) gatewayJSON {
+	return gatewayJSON{
+		ID:            g.ID,
+		Name:          g.Name,
+		Latitude:      g.Location.Latitude,
+		Longitude:     g.Location.Longitude,
+		Altitude:      g.Altitude,
+		AntennaGain:   g.AntennaGain,
+		AntennaHeight: g.AntennaHeight,
+		CableLoss:     g.CableLoss,
+		Owner:         g.Owner,
+		Status:        g.Status,
+		Created:       g.Created,
+	}
+}
+
+func (g Gateway) MarshalJSON() ([]byte, error) {
+	return json.Marshal(newGatewayJSON(g))
+}
+
+// UnmarshalJSON only overwrites the fields in the data, the other fields of
+// the gateway are kept.
+func (g *Gateway) UnmarshalJSON(data []byte) error {
+	v := newGatewayJSON(*g)
+
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	*g = Gateway{
+		ID:            v.ID,
+		Name:          v.Name,
+		Location:      LatLon{Latitude: v.Latitude, Longitude: v.Longitude},
+		Altitude:      v.Altitude,
+		AntennaGain:   v.AntennaGain,
+		AntennaHeight: v.AntennaHeight,
+		CableLoss:     v.CableLoss,
+		Owner:         v.Owner,
+		Status:        v.Status,
+		Created:       v.Created,
+	}
+
+	return nil
+}
+
+// Gain returns the antenna gain minus the cable loss.
+func (g Gateway) Gain() float64 {
+	return g.AntennaGain - g.CableLoss
+}
+
+// Validate checks the id, location and status of the gateway, the cause of
+// the error is ErrGatewayID or ErrGatewayInvalid.
+func (g Gateway) Validate() error {
+	if !gatewayIDRegex.MatchString(g.ID) {
+		return ErrGatewayID
+	}
+
+	if g.Location.Latitude < -90 || g.Location.Latitude > 90 || g.Location.Longitude < -180 || g.Location.Longitude > 180 {
+		return errors.Wrapf(ErrGatewayInvalid, "location %v of %s", g.Location, g.ID)
+	}
+
+	switch g.Status {
+	case GatewayActive, GatewayPlanned, GatewayInactive:
+	default:
+		return errors.Wrapf(ErrGatewayInvalid, "status %s of %s (allowed: active, planned or inactive)", g.Status, g.ID)
+	}
+
+	return nil
+}
+
+// GatewayLocations returns the locations of the gateways by id.
+func GatewayLocations(gateways []Gateway) map[string]LatLon {
+	locations := make(map[string]LatLon, len(gateways))
+
+	for _, g := range gateways {
+		locations[g.ID] = g.Location
+	}
+
+	return locations
+}
+
+// GatewaysGeoJSON returns the gateways as points (in [lon, lat] order) with
+// their id, name, altitude and status, for the markers on the maps.
+func GatewaysGeoJSON(gateways []Gateway, callback string) (string, error) {
+	fc := geojson.NewFeatureCollection()
+
+	for _, g := range gateways {
+		feature := geojson.NewPointFeature([]float64{g.Location.Longitude, g.Location.Latitude})
+		feature.SetProperty("id", g.ID)
+		feature.SetProperty("name", g.Name)
+		feature.SetProperty("altitude", g.Altitude)
+		feature.SetProperty("antenna_height", g.AntennaHeight)
+		feature.SetProperty("status", g.Status)
+
+		fc.AddFeature(feature)
+	}
+
+	return FeatureCollectionJSON(fc, callback)
+}
+
+type gateways struct {
+	db              Database
+	measurementName string
+}
+
+type Gateways interface {
+	Create(Gateway) error
+	Get(id string) (Gateway, error)
+	// List returns the gateways sorted by id, inactive gateways are only
+	// included with inactive set.
+	List(inactive bool) ([]Gateway, error)
+	Update(Gateway) error
+	Delete(id string) error
+	// Import creates the new gateways and updates the existing ones.
+	Import([]Gateway) (created int, updated int, err error)
+}
+
+func NewGateways(db Database, measurementName string) Gateways {
+	return &gateways{
+		db:              db,
+		measurementName: measurementName,
+	}
+}
+
+func (g *gateways) Create(gateway Gateway) error {
+	if gateway.Status == "" {
+		gateway.Status = GatewayActive
+	}
+
+	if err := gateway.Validate(); err != nil {
+		return err
+	}
+
+	if _, err := g.Get(gateway.ID); err == nil {
+		return ErrGatewayExists
+	} else if err != ErrGatewayNotFound {
+		return err
+	}
+
+	if gateway.Name == "" {
+		gateway.Name = gateway.ID
+	}
+
+	if gateway.Created.IsZero() {
+		gateway.Created = time.Now().UTC()
+	}
+
+	return g.write(gateway)
+}
+
+func (g *gateways) Get(id string) (Gateway, error) {
+	if !gatewayIDRegex.MatchString(id) {
+		return Gateway{}, ErrGatewayID
+	}
+
+	list, err := g.query(fmt.Sprintf(" where id = '%s'", id))
+	if err != nil {
+		return Gateway{}, err
+	}
+
+	if len(list) == 0 {
+		return Gateway{}, ErrGatewayNotFound
+	}
+
+	return list[0], nil
+}
+
+func (g *gateways) List(inactive bool) ([]Gateway, error) {
+	var result []Gateway
+
+	list, err := g.query("")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, gateway := range list {
+		if gateway.Status != GatewayInactive || inactive {
+			result = append(result, gateway)
+		}
+	}
+
+	return result, nil
+}
+
+func (g *gateways) Update(gateway Gateway) error {
+	existing, err := g.Get(gateway.ID)
+	if err != nil {
+		return err
+	}
+
+	if gateway.Status == "" {
+		gateway.Status = existing.Status
+	}
+
+	if gateway.Name == "" {
+		gateway.Name = existing.Name
+	}
+
+	if err := gateway.Validate(); err != nil {
+		return err
+	}
+
+	// the point is overwritten because the timestamp doesn't change
+	gateway.Created = existing.Created
+
+	return g.write(gateway)
+}
+
+func (g *gateways) Delete(id string) error {
+	if _, err := g.Get(id); err != nil {
+		return err
+	}
+
+	_, err := g.db.Query(fmt.Sprintf("delete from %s where id = '%s'", g.measurementName, id))
+
+	return errors.Wrapf(err, "deleting gateway %s", id)
+}
+
+func (g *gateways) Import(list []Gateway) (int, int, error) {
+	var created, updated int
+
+	for _, gateway := range list {
+		err := g.Update(gateway)
+		if err == ErrGatewayNotFound {
+			err = g.Create(gateway)
+			if err == nil {
+				created++
+			}
+		} else if err == nil {
+			updated++
+		}
+
+		if err != nil {
+			return created, updated, errors.Wrapf(err, "importing gateway %s", gateway.ID)
+		}
+	}
+
+	return created, updated, nil
+}
+
+func (g *gateways) write(gateway Gateway) error {
+	metric, err := NewMetric(g.measurementName, map[string]string{
+		"id": gateway.ID,
+	}, map[string]interface{}{
+		"name":           gateway.Name,
+		"latitude":       gateway.Location.Latitude,
+		"longitude":      gateway.Location.Longitude,
+		"altitude":       gateway.Altitude,
+		"antenna_gain":   gateway.AntennaGain,
+		"antenna_height": gateway.AntennaHeight,
+		"cable_loss":     gateway.CableLoss,
+		"owner":          gateway.Owner,
+		"status":         gateway.Status,
+	}, gateway.Created)
+	if err != nil {
+		return err
+	}
+
+	return errors.Wrapf(g.db.Write([]Metric{metric}), "writing gateway %s", gateway.ID)
+}
+
+func (g *gateways) query(where string) ([]Gateway, error) {
+	var result []Gateway
+
+	metrics, err := g.db.Query(fmt.Sprintf(InfluxGateways, g.measurementName, where))
+	if err != nil {
+		return nil, errors.Wrap(err, "querying gateways")
+	}
+
+	for _, series := range metrics {
+		for _, metric := range series {
+			fields := metric.Fields()
+
+			gateway := Gateway{
+				ID:      metric.Tags()["id"],
+				Created: metric.Time(),
+			}
+
+			gateway.Name, _ = fields["name"].(string)
+			gateway.Owner, _ = fields["owner"].(string)
+			gateway.Status, _ = fields["status"].(string)
+			gateway.Location.Latitude, _ = ToFloat(fields["latitude"])
+			gateway.Location.Longitude, _ = ToFloat(fields["longitude"])
+			gateway.Altitude, _ = ToFloat(fields["altitude"])
+			gateway.AntennaGain, _ = ToFloat(fields["antenna_gain"])
+			gateway.AntennaHeight, _ = ToFloat(fields["antenna_height"])
+			gateway.CableLoss, _ = ToFloat(fields["cable_loss"])
+
+			result = append(result, gateway)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ID < result[j].ID
+	})
+
+	return result, nil
+}
+
+type gatewayYAML struct {
+	ID            string  `yaml:"id"`
+	Name          string  `yaml:"name"`
+	Latitude      float64 `yaml:"latitude"`
+	Longitude     float64 `yaml:"longitude"`
+	Altitude      float64 `yaml:"altitude"`
+	AntennaGain   float64 `yaml:"antenna_gain"`
+	AntennaHeight float64 `yaml:"antenna_height"`
+	CableLoss     float64 `yaml:"cable_loss"`
+	Owner         string  `yaml:"owner"`
+	Status        string  `yaml:"status"`
+}
+
+// ReadGatewaysYAML reads a list of gateways, eg.
+//
+//	gateways:
+//	  - id: 008000000000b88d
+//	    name: home
+//	    latitude: 51.00178534
+//	    longitude: 4.71346780
+//	    altitude: 15
+//	    antenna_gain: 3
+//	    antenna_height: 10
+//	    cable_loss: 1
+//	    owner: sven
+//	    status: active
+func ReadGatewaysYAML(r io.Reader) ([]Gateway, error) {
+	var file struct {
+		Gateways []gatewayYAML `yaml:"gateways"`
+	}
+
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading gateways")
+	}
+
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, errors.Wrap(err, "parsing gateways")
+	}
+
+	result := make([]Gateway, 0, len(file.Gateways))
+
+	for _, g := range file.Gateways {
+		gateway := Gateway{
+			ID:            g.ID,
+			Name:          g.Name,
+			Location:      LatLon{Latitude: g.Latitude, Longitude: g.Longitude},
+			Altitude:      g.Altitude,
+			AntennaGain:   g.AntennaGain,
+			AntennaHeight: g.AntennaHeight,
+			CableLoss:     g.CableLoss,
+			Owner:         g.Owner,
+			Status:        g.Status,
+		}
+
+		if gateway.Status == "" {
+			gateway.Status = GatewayActive
+		}
+
+		if err := gateway.Validate(); err != nil {
+			return nil, err
+		}
+
+		result = append(result, gateway)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,97 @@
+// The MIT License (MIT)
+//
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package model
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+const gatewaysYAML = `
+gateways:
+  - id: 008000000000b88d
+    name: home
+    latitude: 51.00178534
+    longitude: 4.71346780
+    antenna_gain: 3
+    cable_loss: 1
+  - id: eui-b827ebfffe000001
+    latitude: 50.862279
+    longitude: 4.685495
+    status: planned
+`
+
+func TestReadGatewaysYAML(t *testing.T) {
+	gateways, err := ReadGatewaysYAML(strings.NewReader(gatewaysYAML))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(gateways) != 2 {
+		t.Fatalf("expected 2 gateways, got %d", len(gateways))
+	}
+
+	g := gateways[0]
+	if g.ID != "008000000000b88d" || g.Name != "home" || g.Status != GatewayActive || g.Location.Latitude != 51.00178534 {
+		t.Errorf("wrong gateway %+v", g)
+	}
+	if g.Gain() != 2 {
+		t.Errorf("wrong gain %v", g.Gain())
+	}
+	if gateways[1].Status != GatewayPlanned {
+		t.Errorf("wrong status %s", gateways[1].Status)
+	}
+
+	_, err = ReadGatewaysYAML(strings.NewReader("gateways:\n  - id: a\n    status: broken\n"))
+	if errors.Cause(err) != ErrGatewayInvalid {
+		t.Errorf("invalid status should give ErrGatewayInvalid, got %v", err)
+	}
+
+	_, err = ReadGatewaysYAML(strings.NewReader("gateways:\n  - id: a'b\n"))
+	if errors.Cause(err) != ErrGatewayID {
+		t.Errorf("invalid id should give ErrGatewayID, got %v", err)
+	}
+}
+
+func TestGatewayJSON(t *testing.T) {
+	g := Gateway{ID: "gw", Name: "home", Location: LatLon{Latitude: 51, Longitude: 4}, AntennaGain: 3, Status: GatewayActive}
+
+	data, err := json.Marshal(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"lat":51,"lon":4`) {
+		t.Errorf("expected lat and lon in %s", data)
+	}
+
+	// fields missing in the data are kept
+	if err := json.Unmarshal([]byte(`{"name":"roof","lat":50.5}`), &g); err != nil {
+		t.Fatal(err)
+	}
+	if g.Name != "roof" || g.Location.Latitude != 50.5 || g.Location.Longitude != 4 || g.AntennaGain != 3 || g.Status != GatewayActive {
+		t.Errorf("wrong gateway %+v", g)
+	}
+}
@@ -32,19 +32,21 @@ import (
 )
 
 const (
-	InfluxSF    = `select max("rssi") as "rssi" from (select mean("rssi") as "rssi", mean("snr") as "snr" from %s where data_rate='%s' group by latitude, longitude, gateway_id) group by latitude, longitude`
-	InfluxAllSF = `select distinct(data_rate) as "data_rate" from (select rssi, snr, data_rate from %s where rssi < 0 group by latitude, longitude) group by latitude, longitude`
+	InfluxSF    = `select max("rssi") as "rssi", "snr" from (select mean("rssi") as "rssi", mean("snr") as "snr" from %s where ` + ReceptionCondition + ` and data_rate='%s'%s group by latitude, longitude, gateway_id) group by latitude, longitude`
+	InfluxAllSF = `select distinct(data_rate) as "data_rate" from (select rssi, snr, data_rate from %s where ` + ReceptionCondition + `%s group by latitude, longitude) group by latitude, longitude`
 )
 
 type gjson struct {
 	db                Database
 	measurementName   string
+	filter            Filter
 	featureCollection *geojson.FeatureCollection
 }
 
 type GeoJSON interface {
 	GetGeoJSONFromSF(string, string) (string, error)
 	GetGeoJSONFromAllSF(string) (string, error)
+	SetFilter(Filter)
 }
 
 func NewGeoJSON(db Database, measurementName string) GeoJSON {
@@ -55,6 +57,10 @@ func NewGeoJSON(db Database, measurementName string) GeoJSON {
 	}
 }
 
+func (g *gjson) SetFilter(filter Filter) {
+	g.filter = filter
+}
+
 func (g *gjson) getJSON(callback string) (string, error) {
 	json, err := g.featureCollection.MarshalJSON()
 	if err != nil {
@@ -71,13 +77,19 @@ func (g *gjson) getJSON(callback string) (string, error) {
 func (g *gjson) GetGeoJSONFromSF(sf string, callback string) (string, error) {
 	g.featureCollection = geojson.NewFeatureCollection()
 
-	command := fmt.Sprintf(InfluxSF, g.measurementName, sf)
+	if !g.filter.Window.IsZero() {
+		return g.windowGeoJSONFromSF(sf, callback)
+	}
+
+	command := fmt.Sprintf(InfluxSF, g.measurementName, sf, g.filter.And())
 
 	metrics, err := g.db.Query(command)
 	if err != nil {
 		return "", err
 	}
 
+	bbox := g.bboxFilter()
+
 	for _, series := range metrics {
 		for _, metric := range series {
 			if !metric.HasTag("latitude") || !metric.HasTag("longitude") || !metric.HasField("rssi") {
@@ -85,6 +97,10 @@ func (g *gjson) GetGeoJSONFromSF(sf string, callback string) (string, error) {
 				continue
 			}
 
+			if !bbox.Match(metric) {
+				continue
+			}
+
 			lat, err := strconv.ParseFloat(metric.Tags()["latitude"], 64)
 			if err != nil {
 				log.WithField("latitude", metric.Tags()["latitude"]).Warn("invalid latitude")
@@ -100,6 +116,12 @@ func (g *gjson) GetGeoJSONFromSF(sf string, callback string) (string, error) {
 			feature := geojson.NewPointFeature([]float64{lat, lon})
 			feature.SetProperty("rssi", rssi)
 
+			if r, ok := ToFloat(rssi); ok {
+				if snr, ok := ToFloat(metric.Fields()["snr"]); ok {
+					feature.SetProperty("esp", round(ESP(r, snr), 2))
+				}
+			}
+
 			g.featureCollection.AddFeature(feature)
 		}
 	}
@@ -110,13 +132,19 @@ func (g *gjson) GetGeoJSONFromSF(sf string, callback string) (string, error) {
 func (g *gjson) GetGeoJSONFromAllSF(callback string) (string, error) {
 	g.featureCollection = geojson.NewFeatureCollection()
 
-	command := fmt.Sprintf(InfluxAllSF, g.measurementName)
+	if !g.filter.Window.IsZero() {
+		return g.windowGeoJSONFromAllSF(callback)
+	}
+
+	command := fmt.Sprintf(InfluxAllSF, g.measurementName, g.filter.And())
 
 	metrics, err := g.db.Query(command)
 	if err != nil {
 		return "", err
 	}
 
+	bbox := g.bboxFilter()
+
 	for _, series := range metrics {
 		var lat, lon float64
 
@@ -130,6 +158,10 @@ func (g *gjson) GetGeoJSONFromAllSF(callback string) (string, error) {
 				continue
 			}
 
+			if !bbox.Match(metric) {
+				continue
+			}
+
 			if lat == 0 {
 				lat, err = strconv.ParseFloat(metric.Tags()["latitude"], 64)
 				if err != nil {
@@ -168,3 +200,95 @@ func (g *gjson) GetGeoJSONFromAllSF(callback string) (string, error) {
 
 	return g.getJSON(callback)
 }
+
+// bboxFilter returns a filter with only the bounding box of the filter, to
+// match the locations of the aggregated series (which have no time).
+func (g *gjson) bboxFilter() Filter {
+	return Filter{BoundingBox: g.filter.BoundingBox}
+}
+
+// The database can't select recurring time windows, so with a window the
+// layers are aggregated from the matching receptions instead.
+
+// windowGeoJSONFromSF returns the highest mean rssi of the gateways at every
+// location, with the effective signal power of that gateway.
+func (g *gjson) windowGeoJSONFromSF(sf string, callback string) (string, error) {
+	filter := g.filter
+	filter.DataRates = []string{sf}
+
+	receptions, err := GetReceptions(g.db, g.measurementName, filter)
+	if err != nil {
+		return "", err
+	}
+
+	type key struct {
+		location LatLon
+		gateway  string
+	}
+
+	// sums of the rssi, snr and count
+	sums := make(map[key][3]float64)
+	seen := make(map[LatLon]bool)
+	var locations []LatLon
+
+	for _, r := range receptions {
+		k := key{r.Location, r.GatewayID}
+		if !seen[r.Location] {
+			seen[r.Location] = true
+			locations = append(locations, r.Location)
+		}
+
+		s := sums[k]
+		sums[k] = [3]float64{s[0] + r.RSSI, s[1] + r.SNR, s[2] + 1}
+	}
+
+	best := make(map[LatLon][2]float64)
+	for k, s := range sums {
+		rssi, snr := s[0]/s[2], s[1]/s[2]
+		if v, ok := best[k.location]; !ok || rssi > v[0] {
+			best[k.location] = [2]float64{rssi, snr}
+		}
+	}
+
+	for _, ll := range locations {
+		feature := geojson.NewPointFeature([]float64{ll.Latitude, ll.Longitude})
+		feature.SetProperty("rssi", best[ll][0])
+		feature.SetProperty("esp", round(ESP(best[ll][0], best[ll][1]), 2))
+
+		g.featureCollection.AddFeature(feature)
+	}
+
+	return g.getJSON(callback)
+}
+
+// windowGeoJSONFromAllSF returns the lowest spreading factor at every
+// location.
+func (g *gjson) windowGeoJSONFromAllSF(callback string) (string, error) {
+	receptions, err := GetReceptions(g.db, g.measurementName, g.filter)
+	if err != nil {
+		return "", err
+	}
+
+	best := make(map[LatLon]int)
+	var locations []LatLon
+
+	for _, r := range receptions {
+		sf, ok := best[r.Location]
+		if !ok {
+			locations = append(locations, r.Location)
+		}
+
+		if !ok || r.SF < sf {
+			best[r.Location] = r.SF
+		}
+	}
+
+	for _, ll := range locations {
+		feature := geojson.NewPointFeature([]float64{ll.Latitude, ll.Longitude})
+		feature.SetProperty("sf", fmt.Sprintf("sf%v", best[ll]))
+
+		g.featureCollection.AddFeature(feature)
+	}
+
+	return g.getJSON(callback)
+}
@@ -0,0 +1,87 @@
+// The MIT License (MIT)
+//
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package model
+
+import (
+	"encoding/xml"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+type gpxPoint struct {
+	Latitude  float64 `xml:"lat,attr"`
+	Longitude float64 `xml:"lon,attr"`
+}
+
+type gpx struct {
+	Tracks []struct {
+		Segments []struct {
+			Points []gpxPoint `xml:"trkpt"`
+		} `xml:"trkseg"`
+	} `xml:"trk"`
+	Routes []struct {
+		Points []gpxPoint `xml:"rtept"`
+	} `xml:"rte"`
+}
+
+// ReadGPX reads the track segments and routes of a GPX file, waypoints are
+// ignored.
+func ReadGPX(r io.Reader) ([][]LatLon, error) {
+	var g gpx
+
+	if err := xml.NewDecoder(r).Decode(&g); err != nil {
+		return nil, errors.Wrap(err, "reading gpx")
+	}
+
+	var lines [][]LatLon
+
+	add := func(points []gpxPoint) {
+		if len(points) == 0 {
+			return
+		}
+
+		line := make([]LatLon, len(points))
+		for i, p := range points {
+			line[i] = LatLon{Latitude: p.Latitude, Longitude: p.Longitude}
+		}
+
+		lines = append(lines, line)
+	}
+
+	for _, t := range g.Tracks {
+		for _, s := range t.Segments {
+			add(s.Points)
+		}
+	}
+
+	for _, r := range g.Routes {
+		add(r.Points)
+	}
+
+	if len(lines) == 0 {
+		return nil, errors.New("gpx has no tracks or routes")
+	}
+
+	return lines, nil
+}
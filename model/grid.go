@@ -0,0 +1,292 @@
+// The MIT License (MIT)
+//
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package model
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	ShapeSquare  = "square"
+	ShapeHexagon = "hexagon"
+
+	MinCellSize = 1.0
+	MaxCellSize = 100000.0
+)
+
+// CellID identifies a cell of a grid: column and row for squares, axial
+// coordinates (q, r) for hexagons.
+type CellID struct {
+	X int
+	Y int
+}
+
+func (c CellID) String() string {
+	return fmt.Sprintf("%d:%d", c.X, c.Y)
+}
+
+// Grid bins locations into square or hexagonal cells of a fixed size in
+// meters. Locations are projected on a plane tangent at the origin, which
+// keeps the cells stable as long as the same origin is used.
+// Less orders the cells by row and then by column.
+func (c CellID) Less(other CellID) bool {
+	return cellLess(c, other)
+}
+
+type Grid interface {
+	Shape() string
+	Size() float64
+	Origin() LatLon
+	Cell(LatLon) CellID
+	Center(CellID) LatLon
+	// Polygon returns the closed outline of the cell.
+	Polygon(CellID) []LatLon
+	// Neighbours returns the cells sharing an edge with the cell.
+	Neighbours(CellID) []CellID
+	// Project returns the position of the location in meters east (x) and
+	// north (y) of the origin on the tangent plane, Unproject the inverse.
+	Project(LatLon) (x, y float64)
+	Unproject(x, y float64) LatLon
+}
+
+type grid struct {
+	shape  string
+	size   float64
+	origin LatLon
+	// meters per degree of longitude at the origin
+	lonScale float64
+}
+
+// NewGrid creates a grid with cells of size meters. Squares have sides of
+// size meters, hexagons are size meters wide from flat side to flat side.
+func NewGrid(shape string, size float64, origin LatLon) (Grid, error) {
+	if shape != ShapeSquare && shape != ShapeHexagon {
+		return nil, errors.Errorf("invalid grid shape: %s", shape)
+	}
+
+	if size < MinCellSize || size > MaxCellSize {
+		return nil, errors.Errorf("invalid cell size: %v (allowed: %v - %v m)", size, MinCellSize, MaxCellSize)
+	}
+
+	return &grid{
+		shape:    shape,
+		size:     size,
+		origin:   origin,
+		lonScale: LatitudeDegreeInMeters * math.Cos(radians(origin.Latitude)),
+	}, nil
+}
+
+type GridOptions struct {
+	Shape  string
+	Size   float64
+	Origin *LatLon
+}
+
+// NewGrid creates the grid, with the default origin of the locations when no
+// origin is set.
+func (o GridOptions) NewGrid(locations []LatLon) (Grid, error) {
+	origin := DefaultOrigin(locations)
+
+	if o.Origin != nil {
+		origin = *o.Origin
+	}
+
+	return NewGrid(o.Shape, o.Size, origin)
+}
+
+// ParseLatLon parses a location in the "lat,lon" format.
+func ParseLatLon(s string) (LatLon, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 2 {
+		return LatLon{}, errors.Errorf("invalid location: %s", s)
+	}
+
+	lat, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	if err != nil || lat < -90 || lat > 90 {
+		return LatLon{}, errors.Errorf("invalid latitude: %s", parts[0])
+	}
+
+	lon, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if err != nil || lon < -180 || lon > 180 {
+		return LatLon{}, errors.Errorf("invalid longitude: %s", parts[1])
+	}
+
+	return LatLon{Latitude: lat, Longitude: lon}, nil
+}
+
+// DefaultOrigin returns an origin for a grid over the locations when none is
+// configured: the mean location rounded to whole degrees, so that the same
+// area keeps the same cells.
+func DefaultOrigin(locations []LatLon) LatLon {
+	var lat, lon float64
+
+	if len(locations) == 0 {
+		return LatLon{}
+	}
+
+	for _, l := range locations {
+		lat += l.Latitude
+		lon += l.Longitude
+	}
+
+	return LatLon{
+		Latitude:  math.Floor(lat/float64(len(locations)) + 0.5),
+		Longitude: math.Floor(lon/float64(len(locations)) + 0.5),
+	}
+}
+
+func (g *grid) Shape() string {
+	return g.shape
+}
+
+func (g *grid) Size() float64 {
+	return g.size
+}
+
+func (g *grid) Origin() LatLon {
+	return g.origin
+}
+
+func (g *grid) Project(ll LatLon) (x, y float64) {
+	x = (ll.Longitude - g.origin.Longitude) * g.lonScale
+	y = (ll.Latitude - g.origin.Latitude) * LatitudeDegreeInMeters
+	return
+}
+
+func (g *grid) Unproject(x, y float64) LatLon {
+	return LatLon{
+		Latitude:  g.origin.Latitude + y/LatitudeDegreeInMeters,
+		Longitude: g.origin.Longitude + x/g.lonScale,
+	}
+}
+
+// radius of the circle through the corners of a hexagon
+func (g *grid) radius() float64 {
+	return g.size / math.Sqrt(3)
+}
+
+func (g *grid) Cell(ll LatLon) CellID {
+	x, y := g.Project(ll)
+
+	if g.shape == ShapeSquare {
+		return CellID{
+			X: int(math.Floor(x / g.size)),
+			Y: int(math.Floor(y / g.size)),
+		}
+	}
+
+	// pointy topped hexagons in axial coordinates
+	r := g.radius()
+	q := (math.Sqrt(3)/3*x - y/3) / r
+	s := (2.0 / 3 * y) / r
+
+	return hexRound(q, s)
+}
+
+func (g *grid) center(c CellID) (x, y float64) {
+	if g.shape == ShapeSquare {
+		return (float64(c.X) + 0.5) * g.size, (float64(c.Y) + 0.5) * g.size
+	}
+
+	r := g.radius()
+	x = r * (math.Sqrt(3)*float64(c.X) + math.Sqrt(3)/2*float64(c.Y))
+	y = r * 1.5 * float64(c.Y)
+	return
+}
+
+func (g *grid) Center(c CellID) LatLon {
+	return g.Unproject(g.center(c))
+}
+
+func (g *grid) Polygon(c CellID) []LatLon {
+	var polygon []LatLon
+
+	cx, cy := g.center(c)
+
+	if g.shape == ShapeSquare {
+		h := g.size / 2
+		for _, corner := range [][2]float64{{-h, -h}, {h, -h}, {h, h}, {-h, h}, {-h, -h}} {
+			polygon = append(polygon, g.Unproject(cx+corner[0], cy+corner[1]))
+		}
+		return polygon
+	}
+
+	r := g.radius()
+	for i := 0; i <= 6; i++ {
+		angle := radians(float64(60*(i%6) - 30))
+		polygon = append(polygon, g.Unproject(cx+r*math.Cos(angle), cy+r*math.Sin(angle)))
+	}
+
+	return polygon
+}
+
+func (g *grid) Neighbours(c CellID) []CellID {
+	var directions [][2]int
+
+	if g.shape == ShapeSquare {
+		directions = [][2]int{{1, 0}, {0, 1}, {-1, 0}, {0, -1}}
+	} else {
+		directions = [][2]int{{1, 0}, {1, -1}, {0, -1}, {-1, 0}, {-1, 1}, {0, 1}}
+	}
+
+	neighbours := make([]CellID, 0, len(directions))
+	for _, d := range directions {
+		neighbours = append(neighbours, CellID{X: c.X + d[0], Y: c.Y + d[1]})
+	}
+
+	return neighbours
+}
+
+// CellArea returns the area of a cell in square meters.
+func CellArea(g Grid) float64 {
+	if g.Shape() == ShapeSquare {
+		return g.Size() * g.Size()
+	}
+
+	return math.Sqrt(3) / 2 * g.Size() * g.Size()
+}
+
+func hexRound(q, r float64) CellID {
+	s := -q - r
+
+	rq := math.Floor(q + 0.5)
+	rr := math.Floor(r + 0.5)
+	rs := math.Floor(s + 0.5)
+
+	dq := math.Abs(rq - q)
+	dr := math.Abs(rr - r)
+	ds := math.Abs(rs - s)
+
+	if dq > dr && dq > ds {
+		rq = -rr - rs
+	} else if dr > ds {
+		rr = -rq - rs
+	}
+
+	return CellID{X: int(rq), Y: int(rr)}
+}
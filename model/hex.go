@@ -0,0 +1,249 @@
+// The MIT License (MIT)
+//
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package model
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/bullettime/lora-mapper/h3"
+	"github.com/paulmach/go.geojson"
+	"github.com/pkg/errors"
+)
+
+// DefaultHexResolution is the H3 resolution of the hex layers when none is
+// configured. Cells at resolution 8 are about 0.7 km2.
+const DefaultHexResolution = 8
+
+var hexCSVHeader = []string{"h3", "resolution", "count", "best_rssi", "best_snr", "data_rate", "sf", "gateways", "last_seen", "latitude", "longitude"}
+
+type HexStats struct {
+	Index        h3.Index  `json:"h3"`
+	Count        int       `json:"count"`
+	BestRSSI     float64   `json:"best_rssi"`
+	BestSNR      float64   `json:"best_snr"`
+	BestDataRate string    `json:"best_data_rate"`
+	BestSF       int       `json:"best_sf"`
+	Gateways     int       `json:"gateways"`
+	LastSeen     time.Time `json:"last_seen"`
+}
+
+type hexLayer struct {
+	db              Database
+	measurementName string
+	resolution      int
+	filter          Filter
+}
+
+type HexLayer interface {
+	GetHexes() ([]HexStats, error)
+	GetGeoJSON(callback string) (string, error)
+	WriteCSV(w io.Writer) error
+	SetFilter(Filter)
+}
+
+// NewHexLayer bins the receptions of the measurement in the H3 cells of the
+// resolution, the format the community coverage maps exchange.
+func NewHexLayer(db Database, measurementName string, resolution int) HexLayer {
+	return &hexLayer{
+		db:              db,
+		measurementName: measurementName,
+		resolution:      resolution,
+	}
+}
+
+func (l *hexLayer) SetFilter(filter Filter) {
+	l.filter = filter
+}
+
+func (l *hexLayer) GetHexes() ([]HexStats, error) {
+	receptions, err := GetReceptions(l.db, l.measurementName, l.filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return AggregateHexes(l.resolution, receptions)
+}
+
+func (l *hexLayer) GetGeoJSON(callback string) (string, error) {
+	hexes, err := l.GetHexes()
+	if err != nil {
+		return "", err
+	}
+
+	return HexesGeoJSON(hexes, callback)
+}
+
+func (l *hexLayer) WriteCSV(w io.Writer) error {
+	hexes, err := l.GetHexes()
+	if err != nil {
+		return err
+	}
+
+	return WriteHexesCSV(w, hexes)
+}
+
+// H3 returns the index of the H3 cell at the resolution the reception was
+// made in.
+func (r Reception) H3(resolution int) (h3.Index, error) {
+	return h3.FromLatLon(r.Location.Latitude, r.Location.Longitude, resolution)
+}
+
+// AggregateHexes keeps the best signal of every H3 cell of the resolution
+// that holds at least one reception. The cells are sorted by index.
+func AggregateHexes(resolution int, receptions []Reception) ([]HexStats, error) {
+	type bin struct {
+		stats    HexStats
+		gateways map[string]bool
+	}
+
+	bins := make(map[h3.Index]*bin)
+
+	for _, r := range receptions {
+		index, err := r.H3(resolution)
+		if err != nil {
+			return nil, errors.Wrapf(err, "h3 index of %v", r.Location)
+		}
+
+		b, ok := bins[index]
+		if !ok {
+			b = &bin{
+				stats: HexStats{
+					Index:    index,
+					BestRSSI: r.RSSI,
+					BestSNR:  r.SNR,
+					BestSF:   r.SF,
+				},
+				gateways: make(map[string]bool),
+			}
+			bins[index] = b
+		}
+
+		b.stats.Count++
+		b.gateways[r.GatewayID] = true
+
+		if r.RSSI > b.stats.BestRSSI {
+			b.stats.BestRSSI = r.RSSI
+		}
+
+		if r.SNR > b.stats.BestSNR {
+			b.stats.BestSNR = r.SNR
+		}
+
+		if r.SF < b.stats.BestSF {
+			b.stats.BestSF = r.SF
+		}
+
+		if r.Time.After(b.stats.LastSeen) {
+			b.stats.LastSeen = r.Time
+		}
+	}
+
+	hexes := make([]HexStats, 0, len(bins))
+
+	for _, b := range bins {
+		b.stats.BestDataRate = DataRate(b.stats.BestSF)
+		b.stats.Gateways = len(b.gateways)
+		hexes = append(hexes, b.stats)
+	}
+
+	sort.Slice(hexes, func(i, j int) bool {
+		return hexes[i].Index < hexes[j].Index
+	})
+
+	return hexes, nil
+}
+
+// HexPolygon returns the outline of an H3 cell as a GeoJSON polygon geometry.
+func HexPolygon(index h3.Index) *geojson.Geometry {
+	var ring [][]float64
+
+	for _, c := range index.Boundary() {
+		ring = append(ring, []float64{c.Longitude, c.Latitude})
+	}
+
+	ring = append(ring, ring[0])
+
+	return geojson.NewPolygonGeometry([][][]float64{ring})
+}
+
+// HexesGeoJSON returns the cells as GeoJSON polygons (in [lon, lat] order).
+func HexesGeoJSON(hexes []HexStats, callback string) (string, error) {
+	fc := geojson.NewFeatureCollection()
+
+	for _, h := range hexes {
+		feature := geojson.NewFeature(HexPolygon(h.Index))
+		feature.SetProperty("h3", h.Index.String())
+		feature.SetProperty("resolution", h.Index.Resolution())
+		feature.SetProperty("count", h.Count)
+		feature.SetProperty("best_rssi", h.BestRSSI)
+		feature.SetProperty("best_snr", round(h.BestSNR, 2))
+		feature.SetProperty("data_rate", h.BestDataRate)
+		feature.SetProperty("sf", fmt.Sprintf("sf%d", h.BestSF))
+		feature.SetProperty("gateways", h.Gateways)
+		feature.SetProperty("last_seen", h.LastSeen.UTC().Format(time.RFC3339))
+
+		fc.AddFeature(feature)
+	}
+
+	return FeatureCollectionJSON(fc, callback)
+}
+
+// WriteHexesCSV writes the cells as CSV with a header line. The location is
+// the center of the cell.
+func WriteHexesCSV(w io.Writer, hexes []HexStats) error {
+	writer := csv.NewWriter(w)
+
+	if err := writer.Write(hexCSVHeader); err != nil {
+		return errors.Wrap(err, "writing csv header")
+	}
+
+	for _, h := range hexes {
+		center := h.Index.Center()
+
+		err := writer.Write([]string{
+			h.Index.String(),
+			strconv.Itoa(h.Index.Resolution()),
+			strconv.Itoa(h.Count),
+			strconv.FormatFloat(h.BestRSSI, 'f', -1, 64),
+			strconv.FormatFloat(round(h.BestSNR, 2), 'f', -1, 64),
+			h.BestDataRate,
+			strconv.Itoa(h.BestSF),
+			strconv.Itoa(h.Gateways),
+			h.LastSeen.UTC().Format(time.RFC3339),
+			strconv.FormatFloat(center.Latitude, 'f', 6, 64),
+			strconv.FormatFloat(center.Longitude, 'f', 6, 64),
+		})
+		if err != nil {
+			return errors.Wrap(err, "writing csv record")
+		}
+	}
+
+	writer.Flush()
+
+	return errors.Wrap(writer.Error(), "writing csv")
+}
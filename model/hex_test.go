@@ -0,0 +1,76 @@
+// The MIT License (MIT)
+//
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package model
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAggregateHexes(t *testing.T) {
+	ts := time.Date(2018, 4, 1, 12, 0, 0, 0, time.UTC)
+
+	receptions := []Reception{
+		{Location: LatLon{Latitude: 37.7759, Longitude: -122.4179}, GatewayID: "a", SF: 9, RSSI: -110, SNR: -3, Time: ts},
+		{Location: LatLon{Latitude: 37.7760, Longitude: -122.4180}, GatewayID: "b", SF: 7, RSSI: -118, SNR: 2.5, Time: ts.Add(time.Hour)},
+		{Location: LatLon{Latitude: 40.6892, Longitude: -74.0444}, GatewayID: "a", SF: 12, RSSI: -121, SNR: -15, Time: ts},
+	}
+
+	hexes, err := AggregateHexes(9, receptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(hexes) != 2 {
+		t.Fatalf("got %d hexes, want 2", len(hexes))
+	}
+
+	h := hexes[0]
+	if h.Index.String() != "8928308280fffff" {
+		t.Errorf("first hex is %s, want 8928308280fffff", h.Index)
+	}
+
+	if h.Count != 2 || h.BestRSSI != -110 || h.BestSNR != 2.5 || h.BestSF != 7 || h.BestDataRate != "SF7BW125" || h.Gateways != 2 {
+		t.Errorf("unexpected stats %+v", h)
+	}
+
+	if !h.LastSeen.Equal(ts.Add(time.Hour)) {
+		t.Errorf("last seen is %v, want %v", h.LastSeen, ts.Add(time.Hour))
+	}
+
+	var buf bytes.Buffer
+	if err := WriteHexesCSV(&buf, hexes); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[1], "8928308280fffff,9,2,-110,2.5,SF7BW125,7,2,") {
+		t.Errorf("unexpected csv:\n%s", buf.String())
+	}
+
+	if _, err := AggregateHexes(16, receptions); err == nil {
+		t.Error("resolution 16 should give an error")
+	}
+}
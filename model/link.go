@@ -0,0 +1,78 @@
+// The MIT License (MIT)
+//
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package model
+
+import "math"
+
+// demodulation floors (the minimum SNR in dB) and sensitivities (the minimum
+// RSSI in dBm) of the spreading factors at 125 kHz, from the SX1276 datasheet
+var (
+	snrFloors = map[int]float64{
+		7:  -7.5,
+		8:  -10,
+		9:  -12.5,
+		10: -15,
+		11: -17.5,
+		12: -20,
+	}
+	sensitivities = map[int]float64{
+		7:  -123,
+		8:  -126,
+		9:  -129,
+		10: -132,
+		11: -134.5,
+		12: -137,
+	}
+)
+
+// DemodulationFloor returns the minimum SNR in dB to receive the spreading
+// factor.
+func DemodulationFloor(sf int) float64 {
+	return snrFloors[sf]
+}
+
+// Sensitivity returns the minimum RSSI in dBm to receive the spreading factor.
+func Sensitivity(sf int) float64 {
+	return sensitivities[sf]
+}
+
+// LinkMargin returns the smallest of the SNR margin above the demodulation
+// floor and the RSSI margin above the sensitivity of the spreading factor.
+func LinkMargin(sf int, rssi, snr float64) float64 {
+	snrMargin := snr - DemodulationFloor(sf)
+	rssiMargin := rssi - Sensitivity(sf)
+
+	if rssiMargin < snrMargin {
+		return rssiMargin
+	}
+
+	return snrMargin
+}
+
+// ESP returns the effective signal power in dBm of a reception: the RSSI
+// (signal plus noise) without the noise, RSSI + SNR - 10·log10(1 + 10^(SNR/10)).
+// At a high SNR it approaches the RSSI, below the noise floor it drops with
+// the SNR.
+func ESP(rssi, snr float64) float64 {
+	return rssi + snr - 10*math.Log10(1+math.Pow(10, snr/10))
+}
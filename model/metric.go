@@ -59,7 +59,7 @@ func NewMetric(name string, tags map[string]string, fields map[string]interface{
 	}
 
 	if len(fields) == 0 {
-		return nil, errors.Errorf("[Metric] %s: missing field(s) (at least one required)")
+		return nil, errors.Errorf("[Metric] %s: missing field(s) (at least one required)", name)
 	}
 
 	m := &metric{
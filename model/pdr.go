@@ -0,0 +1,266 @@
+// The MIT License (MIT)
+//
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package model
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/paulmach/go.geojson"
+)
+
+// PDR is the packet delivery ratio, the share of the attempts that was
+// delivered.
+type PDR struct {
+	Attempts  int     `json:"attempts"`
+	Delivered int     `json:"delivered"`
+	Ratio     float64 `json:"pdr"`
+}
+
+// PDRReport is the delivery ratio of all attempts, by any gateway, per data
+// rate and per gateway.
+type PDRReport struct {
+	PDR
+	DataRates map[string]PDR `json:"data_rates"`
+	Gateways  map[string]PDR `json:"gateways"`
+}
+
+// PDRCell is the delivery ratio of the attempts in a cell, by any gateway and
+// per data rate.
+type PDRCell struct {
+	Cell   CellID `json:"cell"`
+	Center LatLon `json:"center"`
+	PDR
+	DataRates map[string]PDR `json:"data_rates"`
+}
+
+// GetTransmissions returns the attempts and the receptions that match the
+// filter, the gateway filter only applies to the receptions.
+func GetTransmissions(db Database, receptionsName, attemptsName string, filter Filter) ([]Attempt, []Reception, error) {
+	attempts, err := GetAttempts(db, attemptsName, filter)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	receptions, err := GetReceptions(db, receptionsName, filter)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return attempts, receptions, nil
+}
+
+// AttemptLocations returns the locations of the attempts and receptions.
+func AttemptLocations(attempts []Attempt, receptions []Reception) []LatLon {
+	locations := ReceptionLocations(receptions)
+
+	for _, a := range attempts {
+		locations = append(locations, a.Location)
+	}
+
+	return locations
+}
+
+// transmission holds the attempts and receptions of a device at a location,
+// power and data rate, the packets are the distinct reception times.
+type transmission struct {
+	location LatLon
+	dataRate string
+	attempts int
+	packets  map[time.Time]bool
+	gateways map[string]int
+}
+
+func transmissionKey(ll LatLon, deviceID, dataRate string, power float64) string {
+	return fmt.Sprintf("%v,%v|%s|%s|%v", ll.Latitude, ll.Longitude, deviceID, dataRate, power)
+}
+
+// transmissions matches the receptions with the attempts. A reception implies
+// an attempt, so older datasets that only stored the attempts that weren't
+// received still have the right ratio.
+func transmissions(attempts []Attempt, receptions []Reception) []*transmission {
+	index := make(map[string]*transmission)
+
+	get := func(key string, ll LatLon, dataRate string) *transmission {
+		t, ok := index[key]
+		if !ok {
+			t = &transmission{
+				location: ll,
+				dataRate: dataRate,
+				packets:  make(map[time.Time]bool),
+				gateways: make(map[string]int),
+			}
+			index[key] = t
+		}
+		return t
+	}
+
+	for _, a := range attempts {
+		get(transmissionKey(a.Location, a.DeviceID, a.DataRate, a.TxPower), a.Location, a.DataRate).attempts++
+	}
+
+	for _, r := range receptions {
+		t := get(transmissionKey(r.Location, r.DeviceID, r.DataRate, r.TxPower), r.Location, r.DataRate)
+		t.packets[r.Time.Truncate(time.Second)] = true
+		t.gateways[r.GatewayID]++
+	}
+
+	result := make([]*transmission, 0, len(index))
+	for _, t := range index {
+		if len(t.packets) > t.attempts {
+			t.attempts = len(t.packets)
+		}
+		result = append(result, t)
+	}
+
+	return result
+}
+
+// Add counts the attempts and the delivered ones, at most the attempts.
+func (p *PDR) Add(attempts, delivered int) {
+	if delivered > attempts {
+		delivered = attempts
+	}
+
+	p.Attempts += attempts
+	p.Delivered += delivered
+}
+
+// UpdateRatio computes the ratio of the counts.
+func (p *PDR) UpdateRatio() {
+	if p.Attempts > 0 {
+		p.Ratio = round(float64(p.Delivered)/float64(p.Attempts), 3)
+	}
+}
+
+// AddPDR counts the attempts and the delivered ones of the key of m.
+func AddPDR(m map[string]PDR, key string, attempts, delivered int) {
+	p := m[key]
+	p.Add(attempts, delivered)
+	m[key] = p
+}
+
+// UpdateRatios computes the ratio of every key of m.
+func UpdateRatios(m map[string]PDR) {
+	for k, p := range m {
+		p.UpdateRatio()
+		m[k] = p
+	}
+}
+
+// ComputePDR returns the delivery ratio of the attempts. The ratio of a
+// gateway is over all attempts, also those it didn't receive.
+func ComputePDR(attempts []Attempt, receptions []Reception) PDRReport {
+	report := PDRReport{
+		DataRates: make(map[string]PDR),
+		Gateways:  make(map[string]PDR),
+	}
+
+	ts := transmissions(attempts, receptions)
+
+	gateways := make(map[string]bool)
+	for _, t := range ts {
+		for g := range t.gateways {
+			gateways[g] = true
+		}
+	}
+
+	for _, t := range ts {
+		report.Add(t.attempts, len(t.packets))
+		AddPDR(report.DataRates, t.dataRate, t.attempts, len(t.packets))
+
+		for g := range gateways {
+			AddPDR(report.Gateways, g, t.attempts, t.gateways[g])
+		}
+	}
+
+	report.UpdateRatio()
+	UpdateRatios(report.DataRates)
+	UpdateRatios(report.Gateways)
+
+	return report
+}
+
+// CellsPDR returns the delivery ratio of every cell of the grid with at least
+// one attempt, sorted by id.
+func CellsPDR(grid Grid, attempts []Attempt, receptions []Reception) []PDRCell {
+	bins := make(map[CellID]*PDRCell)
+
+	for _, t := range transmissions(attempts, receptions) {
+		id := grid.Cell(t.location)
+
+		c, ok := bins[id]
+		if !ok {
+			c = &PDRCell{
+				Cell:      id,
+				Center:    grid.Center(id),
+				DataRates: make(map[string]PDR),
+			}
+			bins[id] = c
+		}
+
+		c.Add(t.attempts, len(t.packets))
+		AddPDR(c.DataRates, t.dataRate, t.attempts, len(t.packets))
+	}
+
+	cells := make([]PDRCell, 0, len(bins))
+	for _, c := range bins {
+		c.UpdateRatio()
+		UpdateRatios(c.DataRates)
+		cells = append(cells, *c)
+	}
+
+	sort.Slice(cells, func(i, j int) bool {
+		return cellLess(cells[i].Cell, cells[j].Cell)
+	})
+
+	return cells
+}
+
+// PDRCellsGeoJSON returns the cells as GeoJSON polygons (in [lon, lat] order)
+// with the ratio of all attempts and of every data rate, eg. pdr_sf7.
+func PDRCellsGeoJSON(grid Grid, cells []PDRCell, callback string) (string, error) {
+	fc := geojson.NewFeatureCollection()
+
+	for _, c := range cells {
+		feature := geojson.NewFeature(CellPolygon(grid, c.Cell))
+		feature.SetProperty("cell", c.Cell.String())
+		feature.SetProperty("attempts", c.Attempts)
+		feature.SetProperty("delivered", c.Delivered)
+		feature.SetProperty("pdr", c.Ratio)
+
+		for dataRate, p := range c.DataRates {
+			sf, err := SpreadingFactor(dataRate)
+			if err != nil {
+				continue
+			}
+			feature.SetProperty(fmt.Sprintf("pdr_sf%d", sf), p.Ratio)
+			feature.SetProperty(fmt.Sprintf("attempts_sf%d", sf), p.Attempts)
+		}
+
+		fc.AddFeature(feature)
+	}
+
+	return FeatureCollectionJSON(fc, callback)
+}
@@ -0,0 +1,144 @@
+// The MIT License (MIT)
+//
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package model
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+type placeholderDB struct {
+	metrics []Metric
+	written []Metric
+	queries []string
+}
+
+func (db *placeholderDB) Connect() error { return nil }
+
+func (db *placeholderDB) Write(metrics []Metric) error {
+	db.written = append(db.written, metrics...)
+	return nil
+}
+
+func (db *placeholderDB) Query(q string) ([][]Metric, error) {
+	db.queries = append(db.queries, q)
+	if strings.HasPrefix(q, "delete") {
+		return nil, nil
+	}
+	return [][]Metric{db.metrics}, nil
+}
+
+func (db *placeholderDB) HasMetric(Metric, time.Time) bool { return false }
+
+func (db *placeholderDB) Close() error { return nil }
+
+func TestComputePDR(t *testing.T) {
+	ts := time.Date(2018, 4, 1, 12, 0, 0, 0, time.UTC)
+	a := LatLon{Latitude: 51.0001, Longitude: 4.7001}
+	b := LatLon{Latitude: 51.0101, Longitude: 4.7001}
+
+	attempts := []Attempt{
+		{Location: a, DeviceID: "d", DataRate: "SF7BW125", TxPower: 14},
+		{Location: a, DeviceID: "d", DataRate: "SF7BW125", TxPower: 14},
+		{Location: a, DeviceID: "d", DataRate: "SF12BW125", TxPower: 14},
+		{Location: b, DeviceID: "d", DataRate: "SF7BW125", TxPower: 14},
+	}
+
+	receptions := []Reception{
+		// one packet at SF7 by both gateways
+		{Location: a, DeviceID: "d", GatewayID: "g1", DataRate: "SF7BW125", TxPower: 14, Time: ts},
+		{Location: a, DeviceID: "d", GatewayID: "g2", DataRate: "SF7BW125", TxPower: 14, Time: ts.Add(100 * time.Millisecond)},
+		{Location: a, DeviceID: "d", GatewayID: "g1", DataRate: "SF12BW125", TxPower: 14, Time: ts.Add(time.Minute)},
+		// received without a stored attempt
+		{Location: b, DeviceID: "d", GatewayID: "g1", DataRate: "SF9BW125", TxPower: 14, Time: ts},
+	}
+
+	report := ComputePDR(attempts, receptions)
+
+	if report.Attempts != 5 || report.Delivered != 3 || report.Ratio != 0.6 {
+		t.Errorf("unexpected total %+v", report.PDR)
+	}
+
+	if p := report.DataRates["SF7BW125"]; p.Attempts != 3 || p.Delivered != 1 {
+		t.Errorf("unexpected SF7 %+v", p)
+	}
+
+	if p := report.Gateways["g1"]; p.Delivered != 3 || p.Ratio != 0.6 {
+		t.Errorf("unexpected g1 %+v", p)
+	}
+
+	if p := report.Gateways["g2"]; p.Delivered != 1 || p.Ratio != 0.2 {
+		t.Errorf("unexpected g2 %+v", p)
+	}
+
+	grid, err := NewGrid(ShapeSquare, 100, LatLon{Latitude: 51, Longitude: 4.7})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cells := CellsPDR(grid, attempts, receptions)
+	if len(cells) != 2 {
+		t.Fatalf("expected 2 cells, got %d", len(cells))
+	}
+
+	for _, c := range cells {
+		if c.Cell == grid.Cell(a) && (c.Attempts != 3 || c.Delivered != 2 || c.DataRates["SF12BW125"].Ratio != 1) {
+			t.Errorf("unexpected cell %+v", c)
+		}
+	}
+}
+
+func TestMigratePlaceholders(t *testing.T) {
+	ts := time.Date(2018, 4, 1, 12, 0, 0, 0, time.UTC)
+	tags := map[string]string{"latitude": "51.0001", "longitude": "4.7001", "data_rate": "SF7BW125", "power": "14", "gateway_id": ""}
+
+	placeholder, _ := NewMetric("coverage", tags, map[string]interface{}{"rssi": json.Number("0"), "snr": json.Number("0"), "size": json.Number("7")}, ts)
+	reception, _ := NewMetric("coverage", tags, map[string]interface{}{"rssi": json.Number("-97"), "snr": json.Number("7"), "size": json.Number("7")}, ts)
+
+	db := &placeholderDB{metrics: []Metric{placeholder, reception}}
+
+	migrated, skipped, err := MigratePlaceholders(db, "coverage", AttemptData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if migrated != 1 || skipped != 1 || len(db.written) != 1 {
+		t.Fatalf("expected 1 migrated and 1 skipped, got %d and %d", migrated, skipped)
+	}
+
+	attempt, err := NewAttempt(db.written[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if db.written[0].Name() != AttemptData || db.written[0].HasTag("gateway_id") || db.written[0].HasField("rssi") ||
+		attempt.Size != 7 || attempt.SF != 7 || !attempt.Time.Equal(ts) {
+		t.Errorf("unexpected attempt %+v", db.written[0])
+	}
+
+	if len(db.queries) != 2 || !strings.HasPrefix(db.queries[1], "delete from coverage") {
+		t.Errorf("expected the placeholders to be deleted, got %v", db.queries)
+	}
+}
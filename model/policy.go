@@ -0,0 +1,262 @@
+// The MIT License (MIT)
+//
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package model
+
+import (
+	"math"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	PolicyWeightedMargin   = "weighted-margin"
+	PolicyFastestSeen      = "fastest-seen"
+	PolicyPercentileMargin = "percentile-margin"
+	PolicyMajority         = "majority"
+	PolicyConservative     = "conservative"
+	PolicyESPMargin        = "esp-margin"
+)
+
+var ddrPolicies = make(map[string]DDRPolicy)
+
+// DDRPolicy picks the spreading factor to recommend from the weighted samples
+// around a location and the gateways that received them. There is at least
+// one sample.
+type DDRPolicy interface {
+	Name() string
+	SF(samples []DDRSample, gateways []DDRGateway, options DDROptions) int
+}
+
+func init() {
+	RegisterDDRPolicy(weightedMargin{})
+	RegisterDDRPolicy(fastestSeen{})
+	RegisterDDRPolicy(percentileMargin{})
+	RegisterDDRPolicy(majority{})
+	RegisterDDRPolicy(conservative{})
+	RegisterDDRPolicy(espMargin{})
+}
+
+// RegisterDDRPolicy makes the policy selectable by its name, replacing a
+// policy with the same name.
+func RegisterDDRPolicy(policy DDRPolicy) {
+	ddrPolicies[policy.Name()] = policy
+}
+
+// GetDDRPolicy returns the policy with the name, or the weighted-margin
+// policy when the name is empty.
+func GetDDRPolicy(name string) (DDRPolicy, error) {
+	if name == "" {
+		name = PolicyWeightedMargin
+	}
+
+	policy, ok := ddrPolicies[name]
+	if !ok {
+		return nil, errors.Errorf("unknown ddr policy: %s (allowed: %v)", name, DDRPolicies())
+	}
+
+	return policy, nil
+}
+
+// DDRPolicies returns the names of the registered policies.
+func DDRPolicies() []string {
+	names := make([]string, 0, len(ddrPolicies))
+
+	for name := range ddrPolicies {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// weightedMargin recommends the lowest spreading factor at which the weighted
+// mean RSSI and SNR of one of the gateways leave the margin.
+type weightedMargin struct{}
+
+func (weightedMargin) Name() string {
+	return PolicyWeightedMargin
+}
+
+func (weightedMargin) SF(samples []DDRSample, gateways []DDRGateway, options DDROptions) int {
+	for sf := 7; sf < 12; sf++ {
+		for _, g := range gateways {
+			if g.LinkMargin(sf) >= options.Margin {
+				return sf
+			}
+		}
+	}
+
+	return 12
+}
+
+// fastestSeen recommends the lowest spreading factor received nearby, for
+// devices that prefer airtime over reliability.
+type fastestSeen struct{}
+
+func (fastestSeen) Name() string {
+	return PolicyFastestSeen
+}
+
+func (fastestSeen) SF(samples []DDRSample, gateways []DDRGateway, options DDROptions) int {
+	sf := 12
+
+	for _, s := range samples {
+		if s.SF >= 7 && s.SF < sf {
+			sf = s.SF
+		}
+	}
+
+	return sf
+}
+
+// percentileMargin recommends the lowest spreading factor at which all but
+// the percentile of the (weighted) samples of one of the gateways leave the
+// margin.
+type percentileMargin struct{}
+
+func (percentileMargin) Name() string {
+	return PolicyPercentileMargin
+}
+
+func (percentileMargin) SF(samples []DDRSample, gateways []DDRGateway, options DDROptions) int {
+	for sf := 7; sf < 12; sf++ {
+		for _, g := range gateways {
+			var margins, weights []float64
+
+			for _, s := range samples {
+				if s.GatewayID == g.ID {
+					margins = append(margins, LinkMargin(sf, s.RSSI, s.SNR))
+					weights = append(weights, s.Weight)
+				}
+			}
+
+			if weightedPercentile(margins, weights, options.Percentile) >= options.Margin {
+				return sf
+			}
+		}
+	}
+
+	return 12
+}
+
+// majority recommends the spreading factor with the most weight, the data
+// rate the devices nearby were set to most.
+type majority struct{}
+
+func (majority) Name() string {
+	return PolicyMajority
+}
+
+func (majority) SF(samples []DDRSample, gateways []DDRGateway, options DDROptions) int {
+	var weights [13]float64
+
+	for _, s := range samples {
+		if s.SF >= 7 && s.SF <= 12 {
+			weights[s.SF] += s.Weight
+		}
+	}
+
+	// ties go to the higher spreading factor
+	sf := 12
+	for i := 11; i >= 7; i-- {
+		if weights[i] > weights[sf] {
+			sf = i
+		}
+	}
+
+	return sf
+}
+
+// conservative recommends one spreading factor more than weighted-margin, for
+// devices that need reliability.
+type conservative struct{}
+
+func (conservative) Name() string {
+	return PolicyConservative
+}
+
+func (conservative) SF(samples []DDRSample, gateways []DDRGateway, options DDROptions) int {
+	sf := weightedMargin{}.SF(samples, gateways, options) + 1
+
+	if sf > 12 {
+		sf = 12
+	}
+
+	return sf
+}
+
+// espMargin recommends the lowest spreading factor at which the weighted mean
+// effective signal power of one of the gateways leaves the margin above the
+// sensitivity. Unlike the RSSI, the ESP doesn't count the noise and
+// interference as signal.
+type espMargin struct{}
+
+func (espMargin) Name() string {
+	return PolicyESPMargin
+}
+
+func (espMargin) SF(samples []DDRSample, gateways []DDRGateway, options DDROptions) int {
+	for sf := 7; sf < 12; sf++ {
+		for _, g := range gateways {
+			if g.ESP-Sensitivity(sf) >= options.Margin {
+				return sf
+			}
+		}
+	}
+
+	return 12
+}
+
+// weightedPercentile returns the p-th (0 - 1) percentile of the values, the
+// lowest value at which the cumulative weight reaches p of the total weight,
+// -Inf without values.
+func weightedPercentile(values, weights []float64, p float64) float64 {
+	var total float64
+
+	if len(values) == 0 {
+		return math.Inf(-1)
+	}
+
+	index := make([]int, len(values))
+	for i := range index {
+		index[i] = i
+		total += weights[i]
+	}
+
+	sort.Slice(index, func(i, j int) bool {
+		return values[index[i]] < values[index[j]]
+	})
+
+	var cumulative float64
+
+	for _, i := range index {
+		cumulative += weights[i]
+		if cumulative >= p*total {
+			return values[i]
+		}
+	}
+
+	return values[index[len(index)-1]]
+}
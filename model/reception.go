@@ -0,0 +1,195 @@
+// The MIT License (MIT)
+//
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/pkg/errors"
+)
+
+const (
+	InfluxReceptions = `select rssi, snr, fcnt from %s where ` + ReceptionCondition + `%s group by latitude, longitude, data_rate, power, gateway_id, device_id, campaign`
+)
+
+type Reception struct {
+	Location  LatLon
+	DeviceID  string
+	GatewayID string
+	Campaign  string
+	DataRate  string
+	SF        int
+	TxPower   float64
+	RSSI      float64
+	SNR       float64
+	FCnt      uint32
+	HasFCnt   bool
+	Time      time.Time
+}
+
+// GetReceptions returns every reception by a gateway that matches the filter.
+func GetReceptions(db Database, measurementName string, filter Filter) ([]Reception, error) {
+	var receptions []Reception
+
+	metrics, err := db.Query(fmt.Sprintf(InfluxReceptions, measurementName, filter.And()))
+	if err != nil {
+		return nil, errors.Wrap(err, "querying receptions")
+	}
+
+	for _, series := range metrics {
+		for _, metric := range series {
+			if !filter.Match(metric) {
+				continue
+			}
+
+			r, err := NewReception(metric)
+			if err != nil {
+				log.WithError(err).WithField("metric", metric).Warn("invalid reception")
+				continue
+			}
+
+			receptions = append(receptions, r)
+		}
+	}
+
+	return receptions, nil
+}
+
+// NewReception reads a reception from a metric. The transmit power (in dBm)
+// comes from the power tag and is NaN when unknown, the frame counter of the
+// uplink from the fcnt field when the network server provided it.
+func NewReception(metric Metric) (Reception, error) {
+	var ok bool
+
+	tags := metric.Tags()
+
+	location, err := LatLonFromTags(tags)
+	if err != nil {
+		return Reception{}, err
+	}
+
+	sf, err := SpreadingFactor(tags["data_rate"])
+	if err != nil {
+		return Reception{}, err
+	}
+
+	r := Reception{
+		Location:  location,
+		DeviceID:  tags["device_id"],
+		GatewayID: tags["gateway_id"],
+		Campaign:  tags[CampaignTag],
+		DataRate:  tags["data_rate"],
+		SF:        sf,
+		Time:      metric.Time(),
+	}
+
+	r.RSSI, ok = ToFloat(metric.Fields()["rssi"])
+	if !ok {
+		return Reception{}, errors.Errorf("invalid rssi: %v", metric.Fields()["rssi"])
+	}
+
+	r.SNR, _ = ToFloat(metric.Fields()["snr"])
+
+	if fcnt, ok := ToFloat(metric.Fields()["fcnt"]); ok && fcnt >= 0 {
+		r.FCnt = uint32(fcnt)
+		r.HasFCnt = true
+	}
+
+	r.TxPower, ok = ToFloat(tags["power"])
+	if !ok {
+		r.TxPower = math.NaN()
+	}
+
+	return r, nil
+}
+
+// ESP returns the effective signal power of the reception in dBm.
+func (r Reception) ESP() float64 {
+	return ESP(r.RSSI, r.SNR)
+}
+
+// LinkMargin returns the link margin in dB of the reception at its spreading
+// factor, above the demodulation floor and the sensitivity.
+func (r Reception) LinkMargin() float64 {
+	return LinkMargin(r.SF, r.RSSI, r.SNR)
+}
+
+func ReceptionLocations(receptions []Reception) []LatLon {
+	locations := make([]LatLon, len(receptions))
+
+	for i, r := range receptions {
+		locations[i] = r.Location
+	}
+
+	return locations
+}
+
+// SpreadingFactor returns the spreading factor of a data rate like SF7BW125.
+func SpreadingFactor(dataRate string) (int, error) {
+	i := strings.Index(dataRate, "BW")
+
+	if !strings.HasPrefix(dataRate, "SF") || i < 3 {
+		return 0, errors.Errorf("invalid data rate: %s", dataRate)
+	}
+
+	sf, err := strconv.Atoi(dataRate[2:i])
+	if err != nil || sf < 7 || sf > 12 {
+		return 0, errors.Errorf("invalid data rate: %s", dataRate)
+	}
+
+	return sf, nil
+}
+
+func DataRate(sf int) string {
+	return fmt.Sprintf("SF%dBW125", sf)
+}
+
+// ToFloat converts the numeric values returned by the database to a float64.
+func ToFloat(v interface{}) (float64, bool) {
+	switch value := v.(type) {
+	case float64:
+		return value, true
+	case float32:
+		return float64(value), true
+	case int:
+		return float64(value), true
+	case int64:
+		return float64(value), true
+	case int32:
+		return float64(value), true
+	case json.Number:
+		f, err := value.Float64()
+		return f, err == nil
+	case string:
+		f, err := strconv.ParseFloat(value, 64)
+		return f, err == nil
+	}
+
+	return 0, false
+}
@@ -0,0 +1,243 @@
+// The MIT License (MIT)
+//
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package model
+
+import (
+	"math"
+
+	"github.com/pkg/errors"
+)
+
+// MaxRouteSamples limits the number of recommendations of a route.
+const MaxRouteSamples = 10000
+
+// ErrRouteTooLong is returned when a route needs more than MaxRouteSamples
+// recommendations.
+var ErrRouteTooLong = errors.New("route too long")
+
+// Recommender returns the data rate recommendation at a location, from the
+// database, a precomputed grid or receptions in memory.
+type Recommender func(ll LatLon) (Recommendation, error)
+
+// ReceptionsRecommender recommends the data rate from the receptions in
+// memory.
+func ReceptionsRecommender(receptions []Reception, radius float64, options DDROptions) Recommender {
+	return func(ll LatLon) (Recommendation, error) {
+		return RecommendDataRate(ll, receptions, radius, options)
+	}
+}
+
+type RoutePoint struct {
+	Latitude  float64 `json:"lat"`
+	Longitude float64 `json:"lon"`
+	Recommendation
+}
+
+// RouteSegment is the part of a route between the points From and To, the
+// spreading factor is the worst one along the segment.
+type RouteSegment struct {
+	From      int     `json:"from"`
+	To        int     `json:"to"`
+	Distance  float64 `json:"distance"`
+	SF        int     `json:"sf"`
+	DataRate  string  `json:"datarate"`
+	Samples   int     `json:"samples"`
+	Uncovered float64 `json:"uncovered"`
+}
+
+// RouteSummary has the worst spreading factor with coverage and the share of
+// the route (of the points without segments) without coverage. The distance
+// is in meters.
+type RouteSummary struct {
+	Points        int     `json:"points"`
+	Distance      float64 `json:"distance"`
+	WorstSF       int     `json:"worst_sf"`
+	WorstDataRate string  `json:"worst_datarate"`
+	Uncovered     float64 `json:"uncovered"`
+}
+
+type Route struct {
+	Points   []RoutePoint   `json:"points"`
+	Segments []RouteSegment `json:"segments,omitempty"`
+	Summary  RouteSummary   `json:"summary"`
+}
+
+// RecommendPoints returns the recommendation of every point.
+func RecommendPoints(points []LatLon, recommend Recommender) (Route, error) {
+	if len(points) > MaxRouteSamples {
+		return Route{}, ErrRouteTooLong
+	}
+
+	route := Route{
+		Points: make([]RoutePoint, 0, len(points)),
+	}
+
+	uncovered := 0
+
+	for _, ll := range points {
+		r, err := recommend(ll)
+		if err != nil {
+			return Route{}, err
+		}
+
+		route.Points = append(route.Points, RoutePoint{
+			Latitude:       ll.Latitude,
+			Longitude:      ll.Longitude,
+			Recommendation: r,
+		})
+
+		if r.Fallback {
+			uncovered++
+		} else {
+			route.Summary.worst(r.SF)
+		}
+	}
+
+	route.Summary.Points = len(points)
+	if len(points) > 0 {
+		route.Summary.Uncovered = round(float64(uncovered)/float64(len(points)), 3)
+	}
+
+	return route, nil
+}
+
+// RecommendRoute returns the recommendation of every point of the line and
+// of its segments, sampled every step meters. The uncovered share of a
+// segment counts half of a step for every sample without coverage at its
+// ends.
+func RecommendRoute(line []LatLon, step float64, recommend Recommender) (Route, error) {
+	if step <= 0 {
+		return Route{}, errors.Errorf("invalid step: %v", step)
+	}
+
+	distances := make([]float64, len(line))
+	total := len(line)
+
+	for i := 1; i < len(line); i++ {
+		distances[i] = line[i-1].Distance(line[i]) * 1000
+		total += int(math.Ceil(distances[i]/step)) - 1
+	}
+
+	if total > MaxRouteSamples {
+		return Route{}, ErrRouteTooLong
+	}
+
+	route, err := RecommendPoints(line, recommend)
+	if err != nil {
+		return Route{}, err
+	}
+
+	// the summary of the line is that of the segments, or that of the points
+	// without distance (eg. a single point)
+	points := route.Summary
+	route.Summary = RouteSummary{Points: len(line)}
+
+	var uncovered float64
+
+	for i := 1; i < len(line); i++ {
+		from, to := route.Points[i-1].Recommendation, route.Points[i].Recommendation
+
+		samples := []Recommendation{from}
+
+		n := int(math.Ceil(distances[i] / step))
+		for j := 1; j < n; j++ {
+			t := float64(j) / float64(n)
+
+			r, err := recommend(LatLon{
+				Latitude:  line[i-1].Latitude + t*(line[i].Latitude-line[i-1].Latitude),
+				Longitude: line[i-1].Longitude + t*(line[i].Longitude-line[i-1].Longitude),
+			})
+			if err != nil {
+				return Route{}, err
+			}
+
+			samples = append(samples, r)
+		}
+
+		samples = append(samples, to)
+
+		segment := routeSegment(samples)
+		segment.From = i - 1
+		segment.To = i
+		segment.Distance = round(distances[i], 1)
+
+		route.Segments = append(route.Segments, segment)
+
+		if segment.Uncovered < 1 {
+			route.Summary.worst(segment.SF)
+		}
+
+		route.Summary.Distance += distances[i]
+		uncovered += distances[i] * segment.Uncovered
+	}
+
+	if route.Summary.Distance == 0 {
+		route.Summary = points
+		return route, nil
+	}
+
+	route.Summary.Uncovered = round(uncovered/route.Summary.Distance, 3)
+	route.Summary.Distance = round(route.Summary.Distance, 1)
+
+	return route, nil
+}
+
+func routeSegment(samples []Recommendation) RouteSegment {
+	segment := RouteSegment{
+		Samples: len(samples),
+	}
+
+	var uncovered float64
+
+	for i, r := range samples {
+		if r.Fallback {
+			if i > 0 {
+				uncovered += 0.5
+			}
+			if i < len(samples)-1 {
+				uncovered += 0.5
+			}
+			continue
+		}
+
+		if r.SF > segment.SF {
+			segment.SF = r.SF
+		}
+	}
+
+	if segment.SF == 0 {
+		segment.SF = 12
+	}
+
+	segment.DataRate = DataRate(segment.SF)
+	segment.Uncovered = round(uncovered/float64(len(samples)-1), 3)
+
+	return segment
+}
+
+func (s *RouteSummary) worst(sf int) {
+	if sf > s.WorstSF {
+		s.WorstSF = sf
+		s.WorstDataRate = DataRate(sf)
+	}
+}
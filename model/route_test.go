@@ -0,0 +1,118 @@
+// The MIT License (MIT)
+//
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package model
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRecommendRoute(t *testing.T) {
+	// covered at SF9 south of 51.005, at SF7 north of it up to 51.01
+	recommend := func(ll LatLon) (Recommendation, error) {
+		switch {
+		case ll.Latitude > 51.01:
+			return Recommendation{SF: 12, Fallback: true}, nil
+		case ll.Latitude > 51.005:
+			return Recommendation{SF: 7}, nil
+		default:
+			return Recommendation{SF: 9}, nil
+		}
+	}
+
+	line := []LatLon{
+		{Latitude: 51, Longitude: 4.7},
+		{Latitude: 51.008, Longitude: 4.7},
+		{Latitude: 51.02, Longitude: 4.7},
+	}
+
+	route, err := RecommendRoute(line, 100, recommend)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(route.Points) != 3 || len(route.Segments) != 2 {
+		t.Fatalf("expected 3 points and 2 segments, got %d and %d", len(route.Points), len(route.Segments))
+	}
+
+	if s := route.Segments[0]; s.SF != 9 || s.Uncovered != 0 || s.Samples != 10 {
+		t.Errorf("unexpected first segment %+v", s)
+	}
+
+	if s := route.Segments[1]; s.SF != 7 || s.Uncovered < 0.8 || s.Uncovered > 0.9 {
+		t.Errorf("unexpected second segment %+v", s)
+	}
+
+	if route.Summary.WorstSF != 9 || route.Summary.WorstDataRate != "SF9BW125" {
+		t.Errorf("expected SF9 as worst, got %d", route.Summary.WorstSF)
+	}
+
+	// about 10 of the 2.2 km are uncovered
+	if u := route.Summary.Uncovered; u < 0.4 || u > 0.5 {
+		t.Errorf("expected about 45%% uncovered, got %v", u)
+	}
+
+	points, err := RecommendPoints(line, recommend)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if points.Segments != nil || points.Summary.Uncovered != 0.333 || points.Summary.WorstSF != 9 {
+		t.Errorf("unexpected points summary %+v", points.Summary)
+	}
+
+	// a single point without coverage has no distance to share
+	single, err := RecommendRoute(line[2:], 100, recommend)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if single.Summary.Uncovered != 1 || single.Summary.Points != 1 {
+		t.Errorf("expected a single uncovered point, got %+v", single.Summary)
+	}
+}
+
+func TestReadGPX(t *testing.T) {
+	data := `<?xml version="1.0"?>
+<gpx version="1.1" xmlns="http://www.topografix.com/GPX/1/1">
+  <wpt lat="50.8" lon="4.3"/>
+  <trk><trkseg>
+    <trkpt lat="50.879" lon="4.701"><ele>20</ele></trkpt>
+    <trkpt lat="50.880" lon="4.702"/>
+  </trkseg></trk>
+  <rte><rtept lat="51.0" lon="4.1"/></rte>
+</gpx>`
+
+	lines, err := ReadGPX(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(lines) != 2 || len(lines[0]) != 2 || len(lines[1]) != 1 {
+		t.Fatalf("unexpected lines %v", lines)
+	}
+
+	if lines[0][1].Latitude != 50.880 || lines[0][1].Longitude != 4.702 {
+		t.Errorf("unexpected point %v", lines[0][1])
+	}
+}
@@ -0,0 +1,219 @@
+// The MIT License (MIT)
+//
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package model
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// TimeWindow selects recurring periods: hours of the day, weekdays and
+// months, in the time zone (UTC when not set). An empty set selects every
+// hour, weekday or month.
+type TimeWindow struct {
+	Hours    []int
+	Weekdays []time.Weekday
+	Months   []time.Month
+	TimeZone *time.Location
+}
+
+var weekdayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+var monthNames = []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}
+
+// IsZero reports whether the window selects every time.
+func (w TimeWindow) IsZero() bool {
+	return len(w.Hours) == 0 && len(w.Weekdays) == 0 && len(w.Months) == 0
+}
+
+// Local returns the time in the time zone of the window.
+func (w TimeWindow) Local(t time.Time) time.Time {
+	if w.TimeZone == nil {
+		return t.UTC()
+	}
+
+	return t.In(w.TimeZone)
+}
+
+func (w TimeWindow) Contains(t time.Time) bool {
+	t = w.Local(t)
+
+	if len(w.Hours) > 0 && !containsInt(w.Hours, t.Hour()) {
+		return false
+	}
+
+	if len(w.Weekdays) > 0 && !containsInt(weekdaysToInts(w.Weekdays), int(t.Weekday())) {
+		return false
+	}
+
+	if len(w.Months) > 0 && !containsInt(monthsToInts(w.Months), int(t.Month())) {
+		return false
+	}
+
+	return true
+}
+
+// ParseTimeWindow parses the comma separated hours, weekdays and months of a
+// window and the name of the time zone (eg. Europe/Brussels). Ranges wrap
+// around: hours "22-6" are from 22:00 to 6:00 (the end is exclusive),
+// weekdays "mon-fri" or "sat-sun" and months "jun-aug" or "11-2" include the
+// end.
+func ParseTimeWindow(hours, weekdays, months, zone string) (TimeWindow, error) {
+	var w TimeWindow
+	var err error
+
+	w.Hours, err = parseRanges(hours, 0, 24, nil, true)
+	if err != nil {
+		return w, errors.Wrap(err, "invalid hours")
+	}
+
+	days, err := parseRanges(weekdays, 0, 7, weekdayNames, false)
+	if err != nil {
+		return w, errors.Wrap(err, "invalid weekdays")
+	}
+	for _, d := range days {
+		w.Weekdays = append(w.Weekdays, time.Weekday(d))
+	}
+
+	values, err := parseRanges(months, 1, 13, monthNames, false)
+	if err != nil {
+		return w, errors.Wrap(err, "invalid months")
+	}
+	for _, m := range values {
+		w.Months = append(w.Months, time.Month(m))
+	}
+
+	if zone != "" {
+		w.TimeZone, err = time.LoadLocation(zone)
+		if err != nil {
+			return w, errors.Wrap(err, "invalid time zone")
+		}
+	}
+
+	return w, nil
+}
+
+// parseRanges parses values and ranges in [min, max[, numbers or names
+// (the first name is min). The end of a range is exclusive when set.
+func parseRanges(s string, min, max int, names []string, exclusive bool) ([]int, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+
+	value := func(v string) (int, error) {
+		v = strings.ToLower(strings.TrimSpace(v))
+
+		for i, name := range names {
+			if v == name || (len(v) > 3 && strings.HasPrefix(v, name)) {
+				return min + i, nil
+			}
+		}
+
+		n, err := strconv.Atoi(v)
+		if err != nil || n < min || n > max || (n == max && !exclusive) {
+			return 0, errors.Errorf("invalid value: %s", v)
+		}
+
+		return n, nil
+	}
+
+	seen := make(map[int]bool)
+	var result []int
+
+	add := func(n int) {
+		if !seen[n] {
+			seen[n] = true
+			result = append(result, n)
+		}
+	}
+
+	for _, part := range strings.Split(s, ",") {
+		bounds := strings.SplitN(part, "-", 2)
+
+		from, err := value(bounds[0])
+		if err != nil {
+			return nil, err
+		}
+
+		if from == max {
+			return nil, errors.Errorf("invalid value: %s", part)
+		}
+
+		if len(bounds) == 1 {
+			add(from)
+			continue
+		}
+
+		to, err := value(bounds[1])
+		if err != nil {
+			return nil, err
+		}
+
+		n := max - min
+		begin, end := from-min, to-min
+		if !exclusive {
+			end++
+		}
+
+		// a range that ends where it begins is a full cycle
+		count := ((end-begin)%n + n) % n
+		if count == 0 {
+			count = n
+		}
+
+		for i := 0; i < count; i++ {
+			add(min + (begin+i)%n)
+		}
+	}
+
+	return result, nil
+}
+
+func containsInt(values []int, v int) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+
+	return false
+}
+
+func weekdaysToInts(days []time.Weekday) []int {
+	result := make([]int, len(days))
+	for i, d := range days {
+		result[i] = int(d)
+	}
+	return result
+}
+
+func monthsToInts(months []time.Month) []int {
+	result := make([]int, len(months))
+	for i, m := range months {
+		result[i] = int(m)
+	}
+	return result
+}
@@ -0,0 +1,73 @@
+// The MIT License (MIT)
+//
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package model
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseTimeWindow(t *testing.T) {
+	w, err := ParseTimeWindow("22-2,12", "fri-mon", "nov-feb", "Europe/Brussels")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(w.Hours, []int{22, 23, 0, 1, 12}) {
+		t.Errorf("unexpected hours %v", w.Hours)
+	}
+
+	if !reflect.DeepEqual(w.Weekdays, []time.Weekday{time.Friday, time.Saturday, time.Sunday, time.Monday}) {
+		t.Errorf("unexpected weekdays %v", w.Weekdays)
+	}
+
+	if !reflect.DeepEqual(w.Months, []time.Month{time.November, time.December, time.January, time.February}) {
+		t.Errorf("unexpected months %v", w.Months)
+	}
+
+	// 22:30 in Brussels on Friday the 1st of December
+	if in := time.Date(2017, 12, 1, 21, 30, 0, 0, time.UTC); !w.Contains(in) {
+		t.Errorf("expected %v in the window", in)
+	}
+
+	// 22:30 UTC is 23:30 in Brussels, but on a Wednesday
+	if out := time.Date(2017, 12, 6, 22, 30, 0, 0, time.UTC); w.Contains(out) {
+		t.Errorf("expected %v outside the window", out)
+	}
+
+	w, err = ParseTimeWindow("8-18", "", "6-8", "")
+	if err != nil || len(w.Hours) != 10 || w.Hours[9] != 17 || len(w.Months) != 3 {
+		t.Errorf("unexpected window %+v %v", w, err)
+	}
+
+	if w, _ := ParseTimeWindow("", "", "", ""); !w.IsZero() {
+		t.Errorf("expected an empty window %+v", w)
+	}
+
+	for _, invalid := range [][3]string{{"25", "", ""}, {"24", "", ""}, {"", "moon", ""}, {"", "", "0"}} {
+		if _, err := ParseTimeWindow(invalid[0], invalid[1], invalid[2], ""); err == nil {
+			t.Errorf("expected an error for %v", invalid)
+		}
+	}
+}
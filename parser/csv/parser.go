@@ -62,11 +62,15 @@ type csvParser struct {
 	DefaultTags map[string]string
 }
 
+// New returns a parser of the Sodaq-One csv logs. Every line is one
+// transmission attempt per data rate that was sent, written to the attempts
+// measurement (attempt.measurement in the config file); the receptions by the
+// gateways are stored separately.
 func New() parser.Parser {
-	metricName := viper.GetString("metric.name")
+	metricName := viper.GetString("attempt.measurement")
 
 	if metricName == "" {
-		metricName = LocationData
+		metricName = model.AttemptData
 	}
 
 	p := csvParser{
@@ -108,8 +112,6 @@ func (p *csvParser) getMetricsFromRecord(record []string) ([]model.Metric, error
 
 	fields := map[string]interface{}{
 		"size": DefaultSize,
-		"rssi": 0,
-		"snr":  0.0,
 	}
 
 	SF, err := strconv.ParseInt(record[3], 10, 8)
@@ -80,6 +80,12 @@ func TestCsvParser_Parse(t *testing.T) {
 		t.Error("there should be 9 metrics")
 	}
 
+	for _, m := range metrics {
+		if m.HasField("rssi") || m.HasField("snr") || !m.HasField("size") {
+			t.Errorf("attempt should only have a size: %v", m.Fields())
+		}
+	}
+
 	//if metrics[i].HasTag("data_rate") {
 	//	t.Error("the first metric should not have set a data rate")
 	//}
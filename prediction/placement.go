@@ -0,0 +1,405 @@
+// The MIT License (MIT)
+//
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package prediction
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/bullettime/lora-mapper/model"
+	"github.com/paulmach/go.geojson"
+	"github.com/pkg/errors"
+)
+
+const (
+	// ObjectiveCoverage ranks the candidates by the newly covered cells, the
+	// reduction of the required spreading factor breaks ties.
+	ObjectiveCoverage = "coverage"
+	// ObjectiveSF ranks the candidates by the reduction of the required
+	// spreading factor, uncovered cells count as SF13.
+	ObjectiveSF = "sf"
+
+	// uncoveredSF is the required spreading factor of an uncovered cell.
+	uncoveredSF = 13
+)
+
+var ErrNoCandidates = errors.New("no candidate locations")
+
+// PlacementOptions of the gateway placement, Picks is the number of sites
+// to pick greedily and CellSize the size of the cells in meters.
+type PlacementOptions struct {
+	CellSize  float64
+	Picks     int
+	Objective string
+}
+
+// CandidateGain is the predicted gain of a candidate site. For the ranking
+// the gain is relative to the existing gateways, for the picks relative to
+// the existing gateways and the previous picks.
+type CandidateGain struct {
+	ID       string       `json:"id"`
+	Name     string       `json:"name,omitempty"`
+	Location model.LatLon `json:"location"`
+	// NewCells are the cells without coverage before, ImprovedCells the
+	// covered cells with a lower spreading factor.
+	NewCells      int     `json:"new_cells"`
+	NewArea       float64 `json:"new_area_km2"`
+	ImprovedCells int     `json:"improved_cells"`
+	// SFReduction is the total reduction of the required spreading factor.
+	SFReduction int `json:"sf_reduction"`
+	// Coverage and MeanSF (of the covered cells) of the area with the
+	// candidate.
+	Coverage float64 `json:"coverage"`
+	MeanSF   float64 `json:"mean_sf"`
+}
+
+// Placement is the coverage of the target area before and the gain of the
+// candidates.
+type Placement struct {
+	Objective string  `json:"objective"`
+	CellSize  float64 `json:"cell_size"`
+	Cells     int     `json:"cells"`
+	Area      float64 `json:"area_km2"`
+	// Coverage and MeanSF of the existing gateways.
+	Coverage float64         `json:"coverage"`
+	MeanSF   float64         `json:"mean_sf"`
+	Ranking  []CandidateGain `json:"ranking"`
+	Picks    []CandidateGain `json:"picks"`
+}
+
+// ParseCandidatesGeoJSON reads the candidate sites from the points of a
+// GeoJSON feature collection. The id, name, antenna_gain, cable_loss and
+// antenna_height properties are optional.
+func ParseCandidatesGeoJSON(data []byte) ([]model.Gateway, error) {
+	fc, err := geojson.UnmarshalFeatureCollection(data)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid geojson")
+	}
+
+	var candidates []model.Gateway
+
+	for _, f := range fc.Features {
+		if f.Geometry == nil || !f.Geometry.IsPoint() || len(f.Geometry.Point) < 2 {
+			continue
+		}
+
+		c := model.Gateway{
+			ID:       fmt.Sprintf("candidate-%d", len(candidates)+1),
+			Location: model.LatLon{Latitude: f.Geometry.Point[1], Longitude: f.Geometry.Point[0]},
+			Status:   model.GatewayPlanned,
+		}
+
+		if id, err := f.PropertyString("id"); err == nil && id != "" {
+			c.ID = id
+		}
+		c.Name, _ = f.PropertyString("name")
+		c.AntennaGain, _ = f.PropertyFloat64("antenna_gain")
+		c.CableLoss, _ = f.PropertyFloat64("cable_loss")
+		c.AntennaHeight, _ = f.PropertyFloat64("antenna_height")
+
+		candidates = append(candidates, c)
+	}
+
+	if len(candidates) == 0 {
+		return nil, ErrNoCandidates
+	}
+
+	return candidates, nil
+}
+
+// NewCandidates returns the candidates with the path loss model of the
+// network: the median of the fitted models, corrected for the difference in
+// gain, or the default model when no gateway is fitted.
+func NewCandidates(candidates []model.Gateway, gateways []Gateway, options Options) []Gateway {
+	var losses, exponents, sigmas, gains []float64
+
+	for _, g := range gateways {
+		if g.Fitted {
+			losses = append(losses, g.PathLoss.ReferenceLoss)
+			exponents = append(exponents, g.PathLoss.Exponent)
+			sigmas = append(sigmas, g.PathLoss.Sigma)
+			gains = append(gains, g.Gain())
+		}
+	}
+
+	result := make([]Gateway, len(candidates))
+
+	for i, c := range candidates {
+		fit := DefaultPathLoss(options)
+
+		if len(losses) > 0 {
+			gain := median(gains)
+
+			fit.ReferenceLoss = median(losses) - (c.Gain() - gain)
+			fit.Exponent = median(exponents)
+			fit.Sigma = median(sigmas)
+		}
+
+		fit.GatewayID = c.ID
+		fit.Location = c.Location
+
+		result[i] = Gateway{Gateway: c, PathLoss: fit, Fitted: len(losses) > 0}
+	}
+
+	return result
+}
+
+func median(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+
+	return model.Percentile(sorted, 0.5)
+}
+
+// placementState is the required spreading factor of every cell of the
+// target area.
+type placementState []int
+
+func (s placementState) summary() (coverage, meanSF float64) {
+	covered, total := 0, 0
+
+	for _, sf := range s {
+		if sf < uncoveredSF {
+			covered++
+			total += sf
+		}
+	}
+
+	if len(s) > 0 {
+		coverage = round(float64(covered)/float64(len(s)), 4)
+	}
+
+	if covered > 0 {
+		meanSF = round(float64(total)/float64(covered), 2)
+	}
+
+	return coverage, meanSF
+}
+
+func round(v float64, decimals int) float64 {
+	scale := math.Pow(10, float64(decimals))
+	return math.Floor(v*scale+0.5) / scale
+}
+
+// gain returns the gain of the candidate cells over the state.
+func (s placementState) gain(candidate Gateway, cells []int, size float64) CandidateGain {
+	g := CandidateGain{
+		ID:       candidate.ID,
+		Name:     candidate.Name,
+		Location: candidate.Location,
+	}
+
+	after := s.apply(cells)
+
+	for i, sf := range cells {
+		if sf >= s[i] {
+			continue
+		}
+
+		if s[i] == uncoveredSF {
+			g.NewCells++
+		} else {
+			g.ImprovedCells++
+		}
+
+		g.SFReduction += s[i] - sf
+	}
+
+	g.NewArea = model.AreaKm2(g.NewCells, size)
+	g.Coverage, g.MeanSF = after.summary()
+
+	return g
+}
+
+func (s placementState) apply(cells []int) placementState {
+	after := make(placementState, len(s))
+
+	for i := range s {
+		after[i] = s[i]
+		if cells[i] < after[i] {
+			after[i] = cells[i]
+		}
+	}
+
+	return after
+}
+
+// better reports whether gain a is better than b for the objective.
+func better(a, b CandidateGain, objective string) bool {
+	if objective == ObjectiveSF {
+		if a.SFReduction != b.SFReduction {
+			return a.SFReduction > b.SFReduction
+		}
+		return a.NewCells > b.NewCells
+	}
+
+	if a.NewCells != b.NewCells {
+		return a.NewCells > b.NewCells
+	}
+	return a.SFReduction > b.SFReduction
+}
+
+// requiredSF returns the best spreading factor predicted by the gateways at
+// every location, uncoveredSF without coverage.
+func requiredSF(gateways []Gateway, locations []model.LatLon, options Options) placementState {
+	p := NewPredictor(gateways, options)
+	state := make(placementState, len(locations))
+
+	for i, ll := range locations {
+		state[i] = uncoveredSF
+
+		if len(gateways) > 0 {
+			if sf := p.Predict(ll).BestSF; sf != 0 {
+				state[i] = sf
+			}
+		}
+	}
+
+	return state
+}
+
+// PlaceGateways predicts the gain of every candidate site in the target area
+// on top of the existing gateways, ranks them by the objective and greedily
+// picks the sites that add the most to the previous picks. The best
+// spreading factor of a set of gateways is the lowest of the gateways on
+// their own, so each candidate is predicted only once.
+func PlaceGateways(gateways, candidates []Gateway, area model.Area, options Options, placement PlacementOptions) (*Placement, error) {
+	if len(candidates) == 0 {
+		return nil, ErrNoCandidates
+	}
+
+	if placement.Objective == "" {
+		placement.Objective = ObjectiveCoverage
+	}
+
+	if placement.Objective != ObjectiveCoverage && placement.Objective != ObjectiveSF {
+		return nil, errors.Errorf("invalid objective: %s", placement.Objective)
+	}
+
+	bounds := area.Bounds()
+	sw := model.LatLon{Latitude: bounds.MinLatitude, Longitude: bounds.MinLongitude}
+	ne := model.LatLon{Latitude: bounds.MaxLatitude, Longitude: bounds.MaxLongitude}
+
+	grid, err := model.NewGrid(model.ShapeSquare, placement.CellSize, model.DefaultOrigin([]model.LatLon{sw, ne}))
+	if err != nil {
+		return nil, err
+	}
+
+	min := grid.Cell(sw)
+	max := grid.Cell(ne)
+
+	if (max.X-min.X+1)*(max.Y-min.Y+1) > MaxLayerCells {
+		return nil, errors.Errorf("area of %dx%d cells is too large, use larger cells", max.X-min.X+1, max.Y-min.Y+1)
+	}
+
+	var locations []model.LatLon
+
+	for x := min.X; x <= max.X; x++ {
+		for y := min.Y; y <= max.Y; y++ {
+			if ll := grid.Center(model.CellID{X: x, Y: y}); area.Contains(ll) {
+				locations = append(locations, ll)
+			}
+		}
+	}
+
+	if len(locations) == 0 {
+		return nil, errors.New("no cells in the target area, use smaller cells")
+	}
+
+	state := requiredSF(gateways, locations, options)
+
+	result := &Placement{
+		Objective: placement.Objective,
+		CellSize:  placement.CellSize,
+		Cells:     len(locations),
+		Area:      model.AreaKm2(len(locations), placement.CellSize),
+	}
+	result.Coverage, result.MeanSF = state.summary()
+
+	cells := make([][]int, len(candidates))
+
+	for i, c := range candidates {
+		cells[i] = requiredSF([]Gateway{c}, locations, options)
+		result.Ranking = append(result.Ranking, state.gain(c, cells[i], placement.CellSize))
+	}
+
+	sort.SliceStable(result.Ranking, func(i, j int) bool {
+		return better(result.Ranking[i], result.Ranking[j], placement.Objective)
+	})
+
+	picked := make([]bool, len(candidates))
+
+	for len(result.Picks) < placement.Picks {
+		best := -1
+		var bestGain CandidateGain
+
+		for i, c := range candidates {
+			if picked[i] {
+				continue
+			}
+
+			g := state.gain(c, cells[i], placement.CellSize)
+			if best < 0 || better(g, bestGain, placement.Objective) {
+				best, bestGain = i, g
+			}
+		}
+
+		if best < 0 || bestGain.SFReduction == 0 {
+			break
+		}
+
+		picked[best] = true
+		state = state.apply(cells[best])
+		result.Picks = append(result.Picks, bestGain)
+	}
+
+	return result, nil
+}
+
+// WriteReport writes the ranking and picks as tables.
+func (p *Placement) WriteReport(w io.Writer) {
+	fmt.Fprintf(w, "target area: %d cells of %.0f m (%.2f km²)\n", p.Cells, p.CellSize, p.Area)
+	fmt.Fprintf(w, "existing coverage: %.1f%%, mean SF %.2f\n", p.Coverage*100, p.MeanSF)
+
+	table := func(title string, gains []CandidateGain) {
+		fmt.Fprintf(w, "\n%s (objective: %s)\n", title, p.Objective)
+
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "#\tCANDIDATE\tLOCATION\tNEW CELLS\tNEW AREA\tIMPROVED\tSF REDUCTION\tCOVERAGE\tMEAN SF")
+		for i, g := range gains {
+			fmt.Fprintf(tw, "%d\t%s\t%.6f,%.6f\t%d\t%.2f km²\t%d\t%d\t%.1f%%\t%.2f\n",
+				i+1, g.ID, g.Location.Latitude, g.Location.Longitude, g.NewCells, g.NewArea,
+				g.ImprovedCells, g.SFReduction, g.Coverage*100, g.MeanSF)
+		}
+		tw.Flush()
+	}
+
+	table("ranking", p.Ranking)
+
+	if len(p.Picks) > 0 {
+		table("picks, cumulative", p.Picks)
+	}
+}
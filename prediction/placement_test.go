@@ -0,0 +1,112 @@
+// The MIT License (MIT)
+//
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package prediction
+
+import (
+	"testing"
+
+	"github.com/bullettime/lora-mapper/model"
+)
+
+const candidatesJSON = `{"type": "FeatureCollection", "features": [
+	{"type": "Feature", "geometry": {"type": "Point", "coordinates": [4.001, 51.001]}, "properties": {"id": "near"}},
+	{"type": "Feature", "geometry": {"type": "Point", "coordinates": [4.25, 51.1]}, "properties": {"id": "far", "antenna_gain": 3}},
+	{"type": "Feature", "geometry": {"type": "LineString", "coordinates": [[4, 51], [4.1, 51.1]]}}
+]}`
+
+const areaJSON = `{"type": "Polygon", "coordinates": [
+	[[3.95, 50.95], [4.35, 50.95], [4.35, 51.2], [3.95, 51.2], [3.95, 50.95]],
+	[[4.2, 51.15], [4.3, 51.15], [4.3, 51.18], [4.2, 51.18], [4.2, 51.15]]
+]}`
+
+func TestPlaceGateways(t *testing.T) {
+	candidates, err := ParseCandidatesGeoJSON([]byte(candidatesJSON))
+	if err != nil || len(candidates) != 2 || candidates[1].ID != "far" || candidates[1].AntennaGain != 3 {
+		t.Fatalf("wrong candidates %+v %v", candidates, err)
+	}
+
+	area, err := model.ParseAreaGeoJSON([]byte(areaJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !area.Contains(model.LatLon{Latitude: 51, Longitude: 4}) || area.Contains(model.LatLon{Latitude: 51.16, Longitude: 4.25}) {
+		t.Error("wrong contains of the area with a hole")
+	}
+
+	options := DefaultOptions()
+	gateways := NewGateways([]model.Gateway{gateway}, nil, options)
+
+	p, err := PlaceGateways(gateways, NewCandidates(candidates, gateways, options), area, options, PlacementOptions{
+		CellSize: 1000,
+		Picks:    2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if p.Coverage <= 0 || p.Coverage >= 1 {
+		t.Errorf("expected partial coverage of the area %v", p.Coverage)
+	}
+
+	if len(p.Ranking) != 2 || p.Ranking[0].ID != "far" || p.Ranking[0].NewCells <= p.Ranking[1].NewCells {
+		t.Errorf("wrong ranking %+v", p.Ranking)
+	}
+
+	if len(p.Picks) == 0 || p.Picks[0].ID != "far" || p.Picks[0].Coverage <= p.Coverage {
+		t.Errorf("wrong picks %+v", p.Picks)
+	}
+
+	for i := 1; i < len(p.Picks); i++ {
+		if p.Picks[i].Coverage < p.Picks[i-1].Coverage {
+			t.Errorf("coverage decreases with the picks %+v", p.Picks)
+		}
+	}
+
+	if _, err := PlaceGateways(gateways, nil, area, options, PlacementOptions{CellSize: 1000}); err != ErrNoCandidates {
+		t.Errorf("expected ErrNoCandidates, got %v", err)
+	}
+}
+
+func TestNewCandidates(t *testing.T) {
+	options := DefaultOptions()
+
+	var gateways []Gateway
+	for i, loss := range []float64{120, 130, 125} {
+		g := Gateway{Gateway: model.Gateway{ID: string('a' + rune(i)), AntennaGain: 2}, Fitted: true}
+		g.PathLoss.ReferenceLoss = loss
+		g.PathLoss.Exponent = 2.5 + float64(i)/10
+		g.PathLoss.Sigma = 6
+		gateways = append(gateways, g)
+	}
+
+	candidates := NewCandidates([]model.Gateway{{ID: "c", AntennaGain: 5}}, gateways, options)
+
+	if c := candidates[0]; !c.Fitted || c.PathLoss.ReferenceLoss != 122 || c.PathLoss.Exponent != 2.6 || c.PathLoss.Sigma != 6 {
+		t.Errorf("expected the median of the fitted models with 3 dB more gain, got %+v", c.PathLoss)
+	}
+
+	if c := NewCandidates([]model.Gateway{{ID: "c"}}, nil, options)[0]; c.Fitted || c.PathLoss.Exponent != options.Exponent {
+		t.Errorf("expected the default model, got %+v", c.PathLoss)
+	}
+}
@@ -0,0 +1,417 @@
+// The MIT License (MIT)
+//
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package prediction predicts the coverage of the gateways from a link budget
+// with a log-distance path loss model, fitted on the receptions of a gateway
+// when there are enough, a default model otherwise.
+package prediction
+
+import (
+	"math"
+	"sort"
+
+	"github.com/bullettime/lora-mapper/analytics"
+	"github.com/bullettime/lora-mapper/interpolation"
+	"github.com/bullettime/lora-mapper/model"
+	"github.com/paulmach/go.geojson"
+	"github.com/pkg/errors"
+)
+
+const (
+	ValueSF   = "sf"
+	ValueRSSI = "rssi"
+	ValueSNR  = "snr"
+
+	// MaxRange limits the range of a gateway in meters.
+	MaxRange = 50000.0
+	// MaxLayerCells limits the size of the layers.
+	MaxLayerCells = 250000
+)
+
+var ErrNoGateways = errors.New("no gateways to predict the coverage of")
+
+// Options of the link budget. Powers and gains are in dBm and dBi, the
+// frequency and bandwidth in Hz.
+type Options struct {
+	TxPower     float64
+	DeviceGain  float64
+	NoiseFigure float64
+	Bandwidth   float64
+	Frequency   float64
+	// Exponent and Sigma of the default path loss model.
+	Exponent float64
+	Sigma    float64
+	// FadeMargin is the margin in dB above the demodulation floor required
+	// for the best spreading factor.
+	FadeMargin float64
+}
+
+// DefaultOptions returns the link budget of a 14 dBm end device without
+// antenna gain in the 868 MHz band, received by a gateway with a noise figure
+// of 6 dB.
+func DefaultOptions() Options {
+	return Options{
+		TxPower:     analytics.DefaultTxPower,
+		NoiseFigure: 6,
+		Bandwidth:   125000,
+		Frequency:   868100000,
+		Exponent:    3,
+		Sigma:       8,
+	}
+}
+
+// NoiseFloor returns the thermal noise in dBm of the receiver.
+func NoiseFloor(bandwidth, noiseFigure float64) float64 {
+	return -174 + 10*math.Log10(bandwidth) + noiseFigure
+}
+
+// FreeSpaceLoss returns the free space path loss in dB at the distance in
+// meters and frequency in Hz.
+func FreeSpaceLoss(distance, frequency float64) float64 {
+	return 20*math.Log10(distance) + 20*math.Log10(frequency) - 147.55
+}
+
+// DefaultPathLoss returns the close-in model: free space loss up to 1 m and
+// the exponent beyond, expressed at the reference distance of 1 km.
+func DefaultPathLoss(options Options) analytics.PathLossFit {
+	return analytics.PathLossFit{
+		ReferenceDistance: analytics.ReferenceDistance,
+		ReferenceLoss:     FreeSpaceLoss(1, options.Frequency) + 10*options.Exponent*math.Log10(analytics.ReferenceDistance),
+		Exponent:          options.Exponent,
+		Sigma:             options.Sigma,
+	}
+}
+
+// Gateway is a gateway with its path loss model. Fitted models are fitted on
+// the received power, so they include the antenna gains.
+type Gateway struct {
+	model.Gateway
+	PathLoss analytics.PathLossFit `json:"path_loss"`
+	Fitted   bool                  `json:"fitted"`
+}
+
+// NewGateways fits the path loss model of the gateways on the receptions,
+// the gateways without enough receptions get the default model.
+func NewGateways(gateways []model.Gateway, receptions []model.Reception, options Options) []Gateway {
+	fits, _ := analytics.FitGateways(receptions, model.GatewayLocations(gateways), options.TxPower)
+
+	fitted := make(map[string]analytics.PathLossFit, len(fits))
+	for _, f := range fits {
+		f.Samples = nil
+		fitted[f.GatewayID] = f
+	}
+
+	result := make([]Gateway, len(gateways))
+
+	for i, g := range gateways {
+		fit, ok := fitted[g.ID]
+		if !ok {
+			fit = DefaultPathLoss(options)
+			fit.GatewayID = g.ID
+			fit.Location = g.Location
+		}
+
+		result[i] = Gateway{Gateway: g, PathLoss: fit, Fitted: ok}
+	}
+
+	return result
+}
+
+type Margin struct {
+	SF       int     `json:"sf"`
+	DataRate string  `json:"data_rate"`
+	Margin   float64 `json:"margin"`
+	// Probability that the SNR is above the demodulation floor, given the
+	// shadowing.
+	Probability float64 `json:"probability"`
+}
+
+type GatewayPrediction struct {
+	GatewayID string   `json:"gateway_id"`
+	Distance  float64  `json:"distance"`
+	PathLoss  float64  `json:"path_loss"`
+	RSSI      float64  `json:"rssi"`
+	SNR       float64  `json:"snr"`
+	Sigma     float64  `json:"sigma"`
+	Fitted    bool     `json:"fitted"`
+	Margins   []Margin `json:"margins"`
+}
+
+type Prediction struct {
+	Location     model.LatLon `json:"location"`
+	BestGateway  string       `json:"best_gateway"`
+	RSSI         float64      `json:"rssi"`
+	SNR          float64      `json:"snr"`
+	BestSF       int          `json:"best_sf"`
+	BestDataRate string       `json:"best_data_rate"`
+	// Probability that at least one gateway receives the best spreading
+	// factor.
+	Probability float64             `json:"probability"`
+	Gateways    []GatewayPrediction `json:"gateways"`
+}
+
+type predictor struct {
+	gateways []Gateway
+	options  Options
+	noise    float64
+}
+
+type Predictor interface {
+	Gateways() []Gateway
+	Predict(model.LatLon) Prediction
+	// Area returns the bounding box of the range of the gateways.
+	Area() model.BoundingBox
+	// Raster returns the best spreading factor (NaN without coverage), rssi or
+	// snr on a grid of cells of the size in meters.
+	Raster(area model.BoundingBox, cellSize float64, value string) (*interpolation.Surface, error)
+	// GeoJSON returns the area covered by every spreading factor.
+	GeoJSON(area model.BoundingBox, cellSize float64, callback string) (string, error)
+	Validate([]model.Reception) Validation
+}
+
+func NewPredictor(gateways []Gateway, options Options) Predictor {
+	return &predictor{
+		gateways: gateways,
+		options:  options,
+		noise:    NoiseFloor(options.Bandwidth, options.NoiseFigure),
+	}
+}
+
+func (p *predictor) Gateways() []Gateway {
+	return p.gateways
+}
+
+// rssi returns the predicted median rssi at the distance in meters.
+func (p *predictor) rssi(g Gateway, distance float64) (float64, float64) {
+	loss := g.PathLoss.PathLoss(math.Max(distance, 1))
+
+	if g.Fitted {
+		return p.options.TxPower - loss, loss
+	}
+
+	return p.options.TxPower + p.options.DeviceGain + g.Gain() - loss, loss
+}
+
+func (p *predictor) predictGateway(g Gateway, ll model.LatLon) GatewayPrediction {
+	distance := g.Location.Distance(ll) * 1000
+	rssi, loss := p.rssi(g, distance)
+
+	gp := GatewayPrediction{
+		GatewayID: g.ID,
+		Distance:  distance,
+		PathLoss:  loss,
+		RSSI:      rssi,
+		SNR:       rssi - p.noise,
+		Sigma:     g.PathLoss.Sigma,
+		Fitted:    g.Fitted,
+	}
+
+	for sf := 7; sf <= 12; sf++ {
+		margin := gp.SNR - model.DemodulationFloor(sf)
+
+		gp.Margins = append(gp.Margins, Margin{
+			SF:          sf,
+			DataRate:    model.DataRate(sf),
+			Margin:      margin,
+			Probability: probability(margin, gp.Sigma),
+		})
+	}
+
+	return gp
+}
+
+func (p *predictor) Predict(ll model.LatLon) Prediction {
+	prediction := Prediction{
+		Location: ll,
+		RSSI:     math.Inf(-1),
+		SNR:      math.Inf(-1),
+	}
+
+	for _, g := range p.gateways {
+		prediction.Gateways = append(prediction.Gateways, p.predictGateway(g, ll))
+	}
+
+	sort.Slice(prediction.Gateways, func(i, j int) bool {
+		return prediction.Gateways[i].RSSI > prediction.Gateways[j].RSSI
+	})
+
+	if len(prediction.Gateways) == 0 {
+		return prediction
+	}
+
+	best := prediction.Gateways[0]
+	prediction.BestGateway = best.GatewayID
+	prediction.RSSI = best.RSSI
+	prediction.SNR = best.SNR
+
+	for sf := 7; sf <= 12 && prediction.BestSF == 0; sf++ {
+		missed := 1.0
+
+		for _, g := range prediction.Gateways {
+			m := g.Margins[sf-7]
+			missed *= 1 - m.Probability
+
+			if m.Margin >= p.options.FadeMargin && prediction.BestSF == 0 {
+				prediction.BestSF = sf
+				prediction.BestDataRate = m.DataRate
+			}
+		}
+
+		if prediction.BestSF != 0 {
+			prediction.Probability = 1 - missed
+		}
+	}
+
+	return prediction
+}
+
+// probability returns the probability that a normally distributed variable
+// with the standard deviation is above -margin.
+func probability(margin, sigma float64) float64 {
+	if sigma <= 0 {
+		if margin >= 0 {
+			return 1
+		}
+		return 0
+	}
+
+	return 0.5 * math.Erfc(-margin/(sigma*math.Sqrt2))
+}
+
+func (p *predictor) Area() model.BoundingBox {
+	area := model.BoundingBox{
+		MinLatitude:  90,
+		MinLongitude: 180,
+		MaxLatitude:  -90,
+		MaxLongitude: -180,
+	}
+
+	for _, g := range p.gateways {
+		// the distance where the median snr reaches the floor of SF12
+		loss := g.PathLoss.PathLoss(g.PathLoss.ReferenceDistance)
+		rssi, _ := p.rssi(g, g.PathLoss.ReferenceDistance)
+		loss += rssi - p.noise - model.DemodulationFloor(12)
+
+		r := math.Min(g.PathLoss.Distance(loss), MaxRange)
+		if math.IsNaN(r) {
+			r = MaxRange
+		}
+
+		dLat := r / model.LatitudeDegreeInMeters
+		dLon := dLat / math.Max(math.Cos(g.Location.Latitude*math.Pi/180), 0.01)
+
+		area.MinLatitude = math.Min(area.MinLatitude, g.Location.Latitude-dLat)
+		area.MaxLatitude = math.Max(area.MaxLatitude, g.Location.Latitude+dLat)
+		area.MinLongitude = math.Min(area.MinLongitude, g.Location.Longitude-dLon)
+		area.MaxLongitude = math.Max(area.MaxLongitude, g.Location.Longitude+dLon)
+	}
+
+	return area
+}
+
+func (p *predictor) Raster(area model.BoundingBox, cellSize float64, value string) (*interpolation.Surface, error) {
+	if len(p.gateways) == 0 {
+		return nil, ErrNoGateways
+	}
+
+	if value != ValueSF && value != ValueRSSI && value != ValueSNR {
+		return nil, errors.Errorf("invalid value: %s", value)
+	}
+
+	sw := model.LatLon{Latitude: area.MinLatitude, Longitude: area.MinLongitude}
+	ne := model.LatLon{Latitude: area.MaxLatitude, Longitude: area.MaxLongitude}
+
+	grid, err := model.NewGrid(model.ShapeSquare, cellSize, model.DefaultOrigin([]model.LatLon{sw, ne}))
+	if err != nil {
+		return nil, err
+	}
+
+	min := grid.Cell(sw)
+	max := grid.Cell(ne)
+
+	s := &interpolation.Surface{
+		Grid:    grid,
+		Min:     min,
+		Columns: max.X - min.X + 1,
+		Rows:    max.Y - min.Y + 1,
+	}
+
+	if s.Columns*s.Rows > MaxLayerCells {
+		return nil, errors.Errorf("layer of %dx%d cells is too large, use larger cells", s.Columns, s.Rows)
+	}
+
+	s.Values = make([]float64, s.Columns*s.Rows)
+
+	for r := 0; r < s.Rows; r++ {
+		for c := 0; c < s.Columns; c++ {
+			prediction := p.Predict(s.Center(c, r))
+
+			v := math.NaN()
+
+			switch value {
+			case ValueSF:
+				if prediction.BestSF != 0 {
+					v = float64(prediction.BestSF)
+				}
+			case ValueRSSI:
+				v = prediction.RSSI
+			case ValueSNR:
+				v = prediction.SNR
+			}
+
+			s.Values[r*s.Columns+c] = v
+		}
+	}
+
+	return s, nil
+}
+
+func (p *predictor) GeoJSON(area model.BoundingBox, cellSize float64, callback string) (string, error) {
+	s, err := p.Raster(area, cellSize, ValueSF)
+	if err != nil {
+		return "", err
+	}
+
+	// contours are traced around the higher values, so the area of the
+	// spreading factors up to sf is the contour of -sf
+	for i, v := range s.Values {
+		s.Values[i] = -v
+	}
+
+	fc := geojson.NewFeatureCollection()
+
+	for sf := 12; sf >= 7; sf-- {
+		for _, c := range s.Contours([]float64{-float64(sf)}) {
+			if len(c.Polygons) == 0 {
+				continue
+			}
+
+			feature := geojson.NewFeature(c.Geometry())
+			feature.SetProperty("sf", sf)
+			feature.SetProperty("data_rate", model.DataRate(sf))
+
+			fc.AddFeature(feature)
+		}
+	}
+
+	return model.FeatureCollectionJSON(fc, callback)
+}
@@ -0,0 +1,133 @@
+// The MIT License (MIT)
+//
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package prediction
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/bullettime/lora-mapper/model"
+)
+
+var gateway = model.Gateway{
+	ID:       "a",
+	Location: model.LatLon{Latitude: 51, Longitude: 4},
+}
+
+// east returns the location at the distance in meters east of the gateway.
+func east(distance float64) model.LatLon {
+	scale := model.LatitudeDegreeInMeters * math.Cos(gateway.Location.Latitude*math.Pi/180)
+
+	return model.LatLon{
+		Latitude:  gateway.Location.Latitude,
+		Longitude: gateway.Location.Longitude + distance/scale,
+	}
+}
+
+func TestFreeSpaceLoss(t *testing.T) {
+	// 1 km at 868 MHz
+	if l := FreeSpaceLoss(1000, 868e6); math.Abs(l-91.2) > 0.1 {
+		t.Errorf("wrong free space loss %v", l)
+	}
+
+	if n := NoiseFloor(125000, 6); math.Abs(n+117.0) > 0.1 {
+		t.Errorf("wrong noise floor %v", n)
+	}
+}
+
+func TestPredict(t *testing.T) {
+	options := DefaultOptions()
+	p := NewPredictor(NewGateways([]model.Gateway{gateway}, nil, options), options)
+
+	near := p.Predict(east(100))
+	if near.BestSF != 7 || near.BestGateway != "a" || near.Probability < 0.5 {
+		t.Errorf("wrong prediction near the gateway %+v", near)
+	}
+
+	far := p.Predict(east(40000))
+	if far.BestSF != 0 {
+		t.Errorf("no coverage expected far from the gateway %+v", far)
+	}
+
+	last := 7
+	for d := 100.0; d < 40000; d *= 1.2 {
+		prediction := p.Predict(east(d))
+		if prediction.BestSF == 0 {
+			break
+		}
+		if prediction.BestSF < last {
+			t.Errorf("best sf decreases with the distance at %v m: %d < %d", d, prediction.BestSF, last)
+		}
+		last = prediction.BestSF
+	}
+	if last != 12 {
+		t.Errorf("expected SF12 at the edge of the coverage, got %d", last)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	options := DefaultOptions()
+	p := NewPredictor(NewGateways([]model.Gateway{gateway}, nil, options), options)
+
+	var receptions []model.Reception
+	for _, d := range []float64{200, 500, 1000, 2000} {
+		prediction := p.Predict(east(d))
+		receptions = append(receptions, model.Reception{
+			Location:  east(d),
+			GatewayID: "a",
+			SF:        12,
+			RSSI:      prediction.RSSI + 3,
+			TxPower:   math.NaN(),
+		})
+	}
+	receptions = append(receptions, model.Reception{GatewayID: "b"})
+
+	v := p.Validate(receptions)
+	if v.Count != 4 || v.Unknown != 1 || len(v.Gateways) != 1 {
+		t.Fatalf("wrong validation %+v", v)
+	}
+	if math.Abs(v.Bias-3) > 1e-6 || math.Abs(v.RMSE-3) > 1e-6 || v.Agreement != 1 {
+		t.Errorf("wrong validation stats %+v", v.GatewayValidation)
+	}
+}
+
+func TestGeoJSON(t *testing.T) {
+	options := DefaultOptions()
+	p := NewPredictor(NewGateways([]model.Gateway{gateway}, nil, options), options)
+
+	area := p.Area()
+	if !area.Contains(east(1000)) {
+		t.Errorf("area %v does not contain the coverage", area)
+	}
+
+	json, err := p.GeoJSON(area, 500, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, rate := range []string{"SF7BW125", "SF12BW125"} {
+		if !strings.Contains(json, rate) {
+			t.Errorf("no area of %s in %s", rate, json[:100])
+		}
+	}
+}
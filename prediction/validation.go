@@ -0,0 +1,130 @@
+// The MIT License (MIT)
+//
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package prediction
+
+import (
+	"math"
+	"sort"
+
+	"github.com/bullettime/lora-mapper/model"
+)
+
+// GatewayValidation compares the predicted rssi of a gateway with the
+// measured rssi. Residuals are measured minus predicted, a positive bias
+// means the prediction is pessimistic.
+type GatewayValidation struct {
+	GatewayID string      `json:"gateway_id"`
+	Fitted    bool        `json:"fitted"`
+	Count     int         `json:"count"`
+	Bias      float64     `json:"bias"`
+	RMSE      float64     `json:"rmse"`
+	Residuals model.Stats `json:"residuals"`
+	// Agreement is the fraction of the receptions received at a spreading
+	// factor the gateway was predicted to receive.
+	Agreement float64 `json:"agreement"`
+}
+
+type Validation struct {
+	GatewayValidation
+	Gateways []GatewayValidation `json:"gateways"`
+	// Unknown counts the receptions of gateways without a location.
+	Unknown int `json:"unknown"`
+}
+
+type validation struct {
+	residuals []float64
+	agreed    int
+}
+
+func (v *validation) result(id string, fitted bool) GatewayValidation {
+	gv := GatewayValidation{
+		GatewayID: id,
+		Fitted:    fitted,
+		Count:     len(v.residuals),
+		Residuals: model.NewStats(v.residuals),
+	}
+
+	if gv.Count == 0 {
+		return gv
+	}
+
+	var sum float64
+	for _, r := range v.residuals {
+		sum += r * r
+	}
+
+	gv.Bias = gv.Residuals.Mean
+	gv.RMSE = math.Sqrt(sum / float64(gv.Count))
+	gv.Agreement = float64(v.agreed) / float64(gv.Count)
+
+	return gv
+}
+
+// Validate compares the predictions with the measured receptions.
+func (p *predictor) Validate(receptions []model.Reception) Validation {
+	gateways := make(map[string]Gateway, len(p.gateways))
+	for _, g := range p.gateways {
+		gateways[g.ID] = g
+	}
+
+	var result Validation
+	var total validation
+	validations := make(map[string]*validation)
+
+	for _, r := range receptions {
+		g, ok := gateways[r.GatewayID]
+		if !ok {
+			result.Unknown++
+			continue
+		}
+
+		gp := p.predictGateway(g, r.Location)
+		residual := r.RSSI - gp.RSSI
+
+		v, ok := validations[g.ID]
+		if !ok {
+			v = &validation{}
+			validations[g.ID] = v
+		}
+
+		v.residuals = append(v.residuals, residual)
+		total.residuals = append(total.residuals, residual)
+
+		if r.SF >= 7 && r.SF <= 12 && gp.Margins[r.SF-7].Margin >= 0 {
+			v.agreed++
+			total.agreed++
+		}
+	}
+
+	result.GatewayValidation = total.result("", false)
+
+	for id, v := range validations {
+		result.Gateways = append(result.Gateways, v.result(id, gateways[id].Fitted))
+	}
+
+	sort.Slice(result.Gateways, func(i, j int) bool {
+		return result.Gateways[i].GatewayID < result.Gateways[j].GatewayID
+	})
+
+	return result
+}
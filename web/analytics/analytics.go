@@ -0,0 +1,606 @@
+// The MIT License (MIT)
+//
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package analytics
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/apex/log"
+	"github.com/bullettime/lora-mapper/analytics"
+	"github.com/bullettime/lora-mapper/model"
+	"github.com/bullettime/lora-mapper/parser/csv"
+	"github.com/bullettime/lora-mapper/web/utils"
+	"github.com/spf13/viper"
+)
+
+type Handler struct {
+	db          model.Database
+	metricName  string
+	attemptName string
+}
+
+type pathLossResponse struct {
+	Fits    []analytics.PathLossFit `json:"fits"`
+	Skipped map[string]string       `json:"skipped"`
+}
+
+type outageResponse struct {
+	*analytics.Outage
+	Report string `json:"report"`
+}
+
+func NewHandler(db model.Database) *Handler {
+	metricName := viper.GetString("metric.name")
+
+	if metricName == "" {
+		metricName = csv.LocationData
+	}
+
+	return &Handler{
+		db:          db,
+		metricName:  metricName,
+		attemptName: utils.AttemptMeasurement(),
+	}
+}
+
+func (h *Handler) Handle() http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		switch req.Method {
+		case "GET":
+			h.handleGet().ServeHTTP(res, req)
+		default:
+			http.Error(res, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+func (h *Handler) handleGet() http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		var head string
+
+		head, req.URL.Path = utils.ShiftPath(req.URL.Path)
+
+		switch head {
+		case "pathloss":
+			head, req.URL.Path = utils.ShiftPath(req.URL.Path)
+			h.handlePathLoss(head, req.Form).ServeHTTP(res, req)
+		case "pdr":
+			head, req.URL.Path = utils.ShiftPath(req.URL.Path)
+			h.handlePDR(head, req.Form).ServeHTTP(res, req)
+		case "frames":
+			head, req.URL.Path = utils.ShiftPath(req.URL.Path)
+			h.handleFrames(head, req.Form).ServeHTTP(res, req)
+		case "redundancy":
+			head, req.URL.Path = utils.ShiftPath(req.URL.Path)
+			h.handleRedundancy(head, req.Form).ServeHTTP(res, req)
+		case "outage":
+			head, req.URL.Path = utils.ShiftPath(req.URL.Path)
+			h.handleOutage(head, req.Form).ServeHTTP(res, req)
+		case "holes":
+			head, req.URL.Path = utils.ShiftPath(req.URL.Path)
+			h.handleHoles(head, req.Form).ServeHTTP(res, req)
+		case "anomalies":
+			h.handleAnomalies(req.Form).ServeHTTP(res, req)
+		case "temporal":
+			head, req.URL.Path = utils.ShiftPath(req.URL.Path)
+			h.handleTemporal(head, req.Form).ServeHTTP(res, req)
+		default:
+			http.NotFound(res, req)
+		}
+	})
+}
+
+// handlePathLoss fits the path loss model of every gateway, or of the
+// gateway in the path. Add samples=true for the residual of every reception.
+func (h *Handler) handlePathLoss(gatewayID string, params url.Values) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		filter, err := utils.ParseFilter(params)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if gatewayID != "" {
+			filter.GatewayIDs = []string{gatewayID}
+		}
+
+		txPower := analytics.DefaultTxPower
+		if viper.IsSet("analytics.txpower") {
+			txPower = viper.GetFloat64("analytics.txpower")
+		}
+
+		if p := params.Get("tx_power"); p != "" {
+			txPower, err = strconv.ParseFloat(p, 64)
+			if err != nil {
+				http.Error(res, "invalid tx_power", http.StatusBadRequest)
+				return
+			}
+		}
+
+		gateways, err := utils.Gateways(h.db)
+		if err != nil {
+			log.WithError(err).Error("handle path loss")
+			http.Error(res, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		receptions, err := model.GetReceptions(h.db, h.metricName, filter)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"parameters": params,
+			}).WithError(err).Error("handle path loss")
+			http.Error(res, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		fits, skipped := analytics.FitGateways(receptions, model.GatewayLocations(gateways), txPower)
+
+		if params.Get("samples") != "true" {
+			for i := range fits {
+				fits[i].Samples = nil
+			}
+		}
+
+		response := pathLossResponse{
+			Fits:    fits,
+			Skipped: make(map[string]string),
+		}
+
+		if response.Fits == nil {
+			response.Fits = []analytics.PathLossFit{}
+		}
+
+		for id, err := range skipped {
+			response.Skipped[id] = err.Error()
+		}
+
+		if gatewayID != "" {
+			if len(fits) == 0 {
+				reason := "no receptions"
+				if err, ok := skipped[gatewayID]; ok {
+					reason = err.Error()
+				}
+
+				http.Error(res, "no path loss model for gateway "+gatewayID+": "+reason, http.StatusNotFound)
+				return
+			}
+
+			h.writeJSON(fits[0]).ServeHTTP(res, req)
+			return
+		}
+
+		h.writeJSON(response).ServeHTTP(res, req)
+	})
+}
+
+// handlePDR returns the packet delivery ratio of all attempts, per data rate
+// and per gateway, or with cells in the path the ratio per cell of a grid as
+// GeoJSON polygons.
+func (h *Handler) handlePDR(head string, params url.Values) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if head != "" && head != "cells" {
+			http.NotFound(res, req)
+			return
+		}
+
+		filter, err := utils.ParseFilter(params)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		attempts, receptions, err := model.GetTransmissions(h.db, h.metricName, h.attemptName, filter)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"parameters": params,
+			}).WithError(err).Error("handle pdr")
+			http.Error(res, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		if head == "" {
+			h.writeJSON(model.ComputePDR(attempts, receptions)).ServeHTTP(res, req)
+			return
+		}
+
+		options, err := utils.ParseGridOptions(params)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		grid, err := options.NewGrid(model.AttemptLocations(attempts, receptions))
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		data, err := model.PDRCellsGeoJSON(grid, model.CellsPDR(grid, attempts, receptions), params.Get("callback"))
+		if err != nil {
+			log.WithError(err).Error("handle pdr")
+			http.Error(res, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		res.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(res, data)
+	})
+}
+
+// handleFrames returns the frame counter loss per device, or with cells in the
+// path the delivery ratio of the frames per cell of a grid as GeoJSON
+// polygons, per data rate and per gateway set.
+func (h *Handler) handleFrames(head string, params url.Values) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if head != "" && head != "cells" {
+			http.NotFound(res, req)
+			return
+		}
+
+		filter, err := utils.ParseFilter(params)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		options, err := utils.ParseFrameOptions(params)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		receptions, err := model.GetReceptions(h.db, h.metricName, filter)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"parameters": params,
+			}).WithError(err).Error("handle frames")
+			http.Error(res, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		loss := analytics.FrameCounterLoss(receptions, options)
+
+		if head == "" {
+			h.writeJSON(loss).ServeHTTP(res, req)
+			return
+		}
+
+		gridOptions, err := utils.ParseGridOptions(params)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		grid, err := gridOptions.NewGrid(analytics.FrameLocations(loss.Frames))
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		data, err := analytics.FrameCellsGeoJSON(grid, analytics.FrameCells(grid, loss.Frames), params.Get("callback"))
+		if err != nil {
+			log.WithError(err).Error("handle frames")
+			http.Error(res, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		res.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(res, data)
+	})
+}
+
+// handleRedundancy returns the summary of the gateway redundancy of the cells
+// of a grid, or with cells in the path the cells as GeoJSON polygons.
+func (h *Handler) handleRedundancy(head string, params url.Values) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if head != "" && head != "cells" {
+			http.NotFound(res, req)
+			return
+		}
+
+		filter, err := utils.ParseFilter(params)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		options, err := utils.ParseGridOptions(params)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		receptions, err := model.GetReceptions(h.db, h.metricName, filter)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"parameters": params,
+			}).WithError(err).Error("handle redundancy")
+			http.Error(res, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		grid, err := options.NewGrid(model.ReceptionLocations(receptions))
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		cells := analytics.Redundancy(grid, receptions)
+
+		if head == "" {
+			h.writeJSON(analytics.SummarizeRedundancy(cells)).ServeHTTP(res, req)
+			return
+		}
+
+		data, err := analytics.RedundancyGeoJSON(grid, cells, params.Get("callback"))
+		if err != nil {
+			log.WithError(err).Error("handle redundancy")
+			http.Error(res, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		res.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(res, data)
+	})
+}
+
+// handleOutage simulates the outage of the exclude gateways on the square
+// cells of the grid (size and origin), and returns the summary, changed cells
+// and report, or with cells in the path the changed cells as GeoJSON polygons.
+func (h *Handler) handleOutage(head string, params url.Values) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if head != "" && head != "cells" {
+			http.NotFound(res, req)
+			return
+		}
+
+		gateways := utils.ParseList(params, "exclude")
+		if len(gateways) == 0 {
+			http.Error(res, analytics.ErrNoOutageGateways.Error(), http.StatusBadRequest)
+			return
+		}
+
+		filter, err := utils.ParseFilter(params)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		gridOptions, err := utils.ParseGridOptions(params)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		ddrOptions, err := utils.ParseDDROptions(params)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		receptions, err := model.GetReceptions(h.db, h.metricName, filter)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"parameters": params,
+			}).WithError(err).Error("handle outage")
+			http.Error(res, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		outage, err := analytics.SimulateOutage(receptions, gateways, gridOptions.Size, gridOptions.Origin, utils.DDRRadius(), ddrOptions)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if head == "" {
+			h.writeJSON(outageResponse{Outage: outage, Report: outage.Report()}).ServeHTTP(res, req)
+			return
+		}
+
+		data, err := outage.GeoJSON(params.Get("callback"))
+		if err != nil {
+			log.WithError(err).Error("handle outage")
+			http.Error(res, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		res.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(res, data)
+	})
+}
+
+// handleHoles detects the coverage holes in the target area (a GeoJSON
+// polygon or minlon,minlat,maxlon,maxlat) on the cells of a grid, and returns
+// the summary and holes, or with polygons in the path the holes as GeoJSON
+// polygons. With sf12=false only the cells without receptions are holes,
+// min_cells drops the smaller holes.
+func (h *Handler) handleHoles(head string, params url.Values) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if head != "" && head != "polygons" {
+			http.NotFound(res, req)
+			return
+		}
+
+		filter, err := utils.ParseFilter(params)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		gridOptions, err := utils.ParseGridOptions(params)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if params.Get("area") == "" {
+			http.Error(res, "missing area", http.StatusBadRequest)
+			return
+		}
+
+		area, err := model.ParseArea(params.Get("area"))
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		options := analytics.DefaultHoleOptions()
+		options.SF12 = params.Get("sf12") != "false"
+
+		if m := params.Get("min_cells"); m != "" {
+			options.MinCells, err = strconv.Atoi(m)
+			if err != nil {
+				http.Error(res, "invalid min_cells: "+m, http.StatusBadRequest)
+				return
+			}
+		}
+
+		receptions, err := model.GetReceptions(h.db, h.metricName, filter)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"parameters": params,
+			}).WithError(err).Error("handle holes")
+			http.Error(res, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		gateways, err := utils.Gateways(h.db)
+		if err != nil {
+			log.WithError(err).Error("handle holes")
+			http.Error(res, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		grid, err := analytics.HoleGrid(gridOptions, receptions, area)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		holes, err := analytics.DetectHoles(grid, model.Aggregate(grid, receptions), area, gateways, options)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if head == "" {
+			h.writeJSON(holes).ServeHTTP(res, req)
+			return
+		}
+
+		data, err := analytics.HolesGeoJSON(holes, params.Get("callback"))
+		if err != nil {
+			log.WithError(err).Error("handle holes")
+			http.Error(res, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		res.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(res, data)
+	})
+}
+
+// handleTemporal buckets the receptions by hour, weekday or month (bucket)
+// per cell of a grid and per gateway, and returns the analysis, or with cells
+// in the path the cells as GeoJSON polygons.
+func (h *Handler) handleTemporal(head string, params url.Values) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if head != "" && head != "cells" {
+			http.NotFound(res, req)
+			return
+		}
+
+		filter, err := utils.ParseFilter(params)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		gridOptions, err := utils.ParseGridOptions(params)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		options, err := utils.ParseTemporalOptions(params, filter.Window.TimeZone)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		receptions, err := model.GetReceptions(h.db, h.metricName, filter)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"parameters": params,
+			}).WithError(err).Error("handle temporal")
+			http.Error(res, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		grid, err := gridOptions.NewGrid(model.ReceptionLocations(receptions))
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		temporal, err := analytics.AnalyseTemporal(grid, receptions, options)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if head == "" {
+			h.writeJSON(temporal).ServeHTTP(res, req)
+			return
+		}
+
+		data, err := temporal.GeoJSON(params.Get("callback"))
+		if err != nil {
+			log.WithError(err).Error("handle temporal")
+			http.Error(res, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		res.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(res, data)
+	})
+}
+
+func (h *Handler) writeJSON(v interface{}) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		js, err := json.Marshal(v)
+		if err != nil {
+			log.WithError(err).Error("writeJSON")
+			http.Error(res, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		res.Header().Set("Content-Type", "application/json")
+		res.Write(js)
+	})
+}
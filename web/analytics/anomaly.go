@@ -0,0 +1,167 @@
+// The MIT License (MIT)
+//
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package analytics
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/bullettime/lora-mapper/analytics"
+	"github.com/bullettime/lora-mapper/model"
+	"github.com/bullettime/lora-mapper/web/utils"
+	"github.com/spf13/viper"
+)
+
+// handleAnomalies detects the drops of the rssi and snr per gateway in the
+// windows from the from time until the to time (by default the last baseline
+// before now), with the receptions of the baseline before from.
+func (h *Handler) handleAnomalies(params url.Values) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		filter, err := utils.ParseFilter(params)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		gridOptions, err := utils.ParseGridOptions(params)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		options, err := utils.ParseAnomalyOptions(params)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		from, to := filter.Start, filter.End
+		if from.IsZero() {
+			from = time.Now().UTC().Add(-options.Baseline).Truncate(options.Window)
+		}
+
+		filter.Start = from.Add(-options.Baseline)
+
+		receptions, err := model.GetReceptions(h.db, h.metricName, filter)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"parameters": params,
+			}).WithError(err).Error("handle anomalies")
+			http.Error(res, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		grid, err := gridOptions.NewGrid(model.ReceptionLocations(receptions))
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		h.writeJSON(analytics.DetectAnomalies(grid, receptions, from, to, options)).ServeHTTP(res, req)
+	})
+}
+
+// RunAnomalyJob checks the last complete window every anomaly.interval and
+// emits the anomalies through the log and the webhook. It's disabled unless
+// the interval is set. A window is only checked again for the notifiers that
+// failed, eg. when the webhook is down.
+func (h *Handler) RunAnomalyJob() {
+	interval := viper.GetDuration("anomaly.interval")
+	if interval <= 0 {
+		return
+	}
+
+	options := utils.AnomalyOptions()
+	notifiers := utils.AnomalyNotifiers()
+
+	log.WithFields(log.Fields{
+		"interval": interval,
+		"window":   options.Window,
+	}).Info("[Anomaly] detection enabled")
+
+	// the last window delivered by every notifier
+	checked := make([]time.Time, len(notifiers))
+
+	for {
+		to := time.Now().UTC().Truncate(options.Window)
+
+		if pending(checked, to) {
+			if anomalies, err := h.detectAnomalies(to.Add(-options.Window), to, options); err != nil {
+				log.WithError(err).Error("[Anomaly] checking gateways")
+			} else {
+				notify(anomalies, notifiers, checked, to)
+			}
+		}
+
+		time.Sleep(interval)
+	}
+}
+
+// notify emits the anomalies through the notifiers that haven't delivered the
+// window until to yet.
+func notify(anomalies []analytics.Anomaly, notifiers []analytics.Notifier, checked []time.Time, to time.Time) {
+	for i, n := range notifiers {
+		if !to.After(checked[i]) {
+			continue
+		}
+
+		if err := n.Notify(anomalies); err != nil {
+			log.WithError(err).Error("[Anomaly] notifying anomalies")
+			continue
+		}
+
+		checked[i] = to
+	}
+}
+
+// pending returns true when a notifier hasn't delivered the window until to.
+func pending(checked []time.Time, to time.Time) bool {
+	for _, t := range checked {
+		if to.After(t) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (h *Handler) detectAnomalies(from, to time.Time, options analytics.AnomalyOptions) ([]analytics.Anomaly, error) {
+	receptions, err := model.GetReceptions(h.db, h.metricName, model.Filter{Start: from.Add(-options.Baseline), End: to})
+	if err != nil {
+		return nil, err
+	}
+
+	gridOptions, err := utils.ParseGridOptions(url.Values{})
+	if err != nil {
+		return nil, err
+	}
+
+	grid, err := gridOptions.NewGrid(model.ReceptionLocations(receptions))
+	if err != nil {
+		return nil, err
+	}
+
+	return analytics.DetectAnomalies(grid, receptions, from, to, options), nil
+}
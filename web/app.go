@@ -25,24 +25,39 @@ package web
 import (
 	"net"
 	"net/http"
+	"net/url"
 	"time"
 
 	"github.com/apex/log"
 	"github.com/bullettime/lora-mapper/model"
 	"github.com/bullettime/lora-mapper/web/adapter"
+	"github.com/bullettime/lora-mapper/web/analytics"
+	"github.com/bullettime/lora-mapper/web/campaigns"
 	"github.com/bullettime/lora-mapper/web/ddr"
+	"github.com/bullettime/lora-mapper/web/diff"
+	"github.com/bullettime/lora-mapper/web/gateways"
 	"github.com/bullettime/lora-mapper/web/geojson"
+	"github.com/bullettime/lora-mapper/web/hexes"
 	"github.com/bullettime/lora-mapper/web/index"
 	"github.com/bullettime/lora-mapper/web/maps"
+	"github.com/bullettime/lora-mapper/web/predict"
+	"github.com/bullettime/lora-mapper/web/surface"
 	"github.com/bullettime/lora-mapper/web/utils"
 	"github.com/spf13/viper"
 )
 
 type App struct {
-	IndexHandler   *index.Handler
-	GeoJSONHandler *geojson.Handler
-	MapsHandler    *maps.Handler
-	DDRHandler     *ddr.Handler
+	IndexHandler     *index.Handler
+	GeoJSONHandler   *geojson.Handler
+	MapsHandler      *maps.Handler
+	DDRHandler       *ddr.Handler
+	CampaignsHandler *campaigns.Handler
+	HexesHandler     *hexes.Handler
+	SurfaceHandler   *surface.Handler
+	AnalyticsHandler *analytics.Handler
+	PredictHandler   *predict.Handler
+	GatewaysHandler  *gateways.Handler
+	DiffHandler      *diff.Handler
 
 	baseURL string
 }
@@ -55,10 +70,13 @@ func (h *App) ServeHTTP(res http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	err := req.ParseForm()
+	// only the query string is parsed, the body of a POST is read by the
+	// handler whatever its content type (eg. curl --data-binary)
+	form, err := url.ParseQuery(req.URL.RawQuery)
 	if err != nil {
 		log.WithError(err).Warn("[Web] could not parse form")
 	}
+	req.Form = form
 
 	head, req.URL.Path = utils.ShiftPath(req.URL.Path)
 
@@ -71,6 +89,20 @@ func (h *App) ServeHTTP(res http.ResponseWriter, req *http.Request) {
 		adapter.Adapt(h.MapsHandler.Handle(), adapter.Log()).ServeHTTP(res, req)
 	case "ddr":
 		adapter.Adapt(h.DDRHandler.Handle(), adapter.Log()).ServeHTTP(res, req)
+	case "campaigns":
+		adapter.Adapt(h.CampaignsHandler.Handle(), adapter.Log()).ServeHTTP(res, req)
+	case "hexes":
+		adapter.Adapt(h.HexesHandler.Handle(), adapter.Log()).ServeHTTP(res, req)
+	case "surface":
+		adapter.Adapt(h.SurfaceHandler.Handle(), adapter.Log()).ServeHTTP(res, req)
+	case "analytics":
+		adapter.Adapt(h.AnalyticsHandler.Handle(), adapter.Log()).ServeHTTP(res, req)
+	case "predict":
+		adapter.Adapt(h.PredictHandler.Handle(), adapter.Log()).ServeHTTP(res, req)
+	case "gateways":
+		adapter.Adapt(h.GatewaysHandler.Handle(), adapter.Log()).ServeHTTP(res, req)
+	case "diff":
+		adapter.Adapt(h.DiffHandler.Handle(), adapter.Log()).ServeHTTP(res, req)
 	default:
 		http.NotFound(res, req)
 	}
@@ -86,14 +118,24 @@ func Start(listener net.Listener, db model.Database) {
 	}
 
 	app := &App{
-		IndexHandler:   index.NewHandler(),
-		GeoJSONHandler: geojson.NewHandler(db),
-		MapsHandler:    maps.NewHandler(base),
-		DDRHandler:     ddr.NewHandler(db),
-		baseURL:        base,
+		IndexHandler:     index.NewHandler(),
+		GeoJSONHandler:   geojson.NewHandler(db),
+		MapsHandler:      maps.NewHandler(base),
+		DDRHandler:       ddr.NewHandler(db),
+		CampaignsHandler: campaigns.NewHandler(db),
+		HexesHandler:     hexes.NewHandler(db),
+		SurfaceHandler:   surface.NewHandler(db),
+		AnalyticsHandler: analytics.NewHandler(db),
+		PredictHandler:   predict.NewHandler(db),
+		GatewaysHandler:  gateways.NewHandler(db),
+		DiffHandler:      diff.NewHandler(db),
+		baseURL:          base,
 	}
 
 	http.Handle("/", http.StripPrefix(base, app))
 
+	go app.DDRHandler.RunGridJob()
+	go app.AnalyticsHandler.RunAnomalyJob()
+
 	go server.Serve(listener)
 }
@@ -0,0 +1,123 @@
+// The MIT License (MIT)
+//
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package campaigns
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/apex/log"
+	"github.com/bullettime/lora-mapper/model"
+	"github.com/bullettime/lora-mapper/web/utils"
+	"github.com/spf13/viper"
+)
+
+type Handler struct {
+	campaigns model.Campaigns
+}
+
+func NewHandler(db model.Database) *Handler {
+	measurementName := viper.GetString("campaign.measurement")
+
+	if measurementName == "" {
+		measurementName = model.CampaignData
+	}
+
+	return &Handler{
+		campaigns: model.NewCampaigns(db, measurementName),
+	}
+}
+
+func (h *Handler) Handle() http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		switch req.Method {
+		case "GET":
+			h.handleGet().ServeHTTP(res, req)
+		default:
+			http.Error(res, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+func (h *Handler) handleGet() http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		var head string
+
+		head, req.URL.Path = utils.ShiftPath(req.URL.Path)
+
+		switch head {
+		case "":
+			h.handleList().ServeHTTP(res, req)
+		default:
+			h.handleCampaign(head).ServeHTTP(res, req)
+		}
+	})
+}
+
+func (h *Handler) handleList() http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		list, err := h.campaigns.List(req.FormValue("archived") == "true")
+		if err != nil {
+			log.WithError(err).Error("handleList")
+			http.Error(res, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		if list == nil {
+			list = []model.Campaign{}
+		}
+
+		h.writeJSON(list).ServeHTTP(res, req)
+	})
+}
+
+func (h *Handler) handleCampaign(id string) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		campaign, err := h.campaigns.Get(id)
+		if err == model.ErrCampaignNotFound || err == model.ErrCampaignID {
+			http.NotFound(res, req)
+			return
+		}
+		if err != nil {
+			log.WithError(err).WithField("id", id).Error("handleCampaign")
+			http.Error(res, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		h.writeJSON(campaign).ServeHTTP(res, req)
+	})
+}
+
+func (h *Handler) writeJSON(v interface{}) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		js, err := json.Marshal(v)
+		if err != nil {
+			log.WithError(err).Error("writeJSON")
+			http.Error(res, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		res.Header().Set("Content-Type", "application/json")
+		res.Write(js)
+	})
+}
@@ -0,0 +1,214 @@
+// The MIT License (MIT)
+//
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ddr
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/apex/log"
+	"github.com/bullettime/lora-mapper/model"
+	"github.com/bullettime/lora-mapper/web/utils"
+	"github.com/paulmach/go.geojson"
+	"github.com/pkg/errors"
+)
+
+// maxBatchSize limits the size of a batch request body.
+const maxBatchSize = 4 << 20
+
+type batchRequest struct {
+	Type   string `json:"type"`
+	Points []struct {
+		Latitude  float64 `json:"lat"`
+		Longitude float64 `json:"lon"`
+	} `json:"points"`
+}
+
+// parseBatch reads the locations of a batch request, either a list of points
+// {"points": [{"lat": 51, "lon": 4.7}, ...]} or a GeoJSON LineString or
+// MultiPoint geometry or feature. The line flag is set for a LineString.
+func parseBatch(body []byte) (points []model.LatLon, line bool, err error) {
+	var batch batchRequest
+
+	if err := json.Unmarshal(body, &batch); err != nil {
+		return nil, false, errors.Wrap(err, "invalid batch")
+	}
+
+	if batch.Type == "" {
+		for _, p := range batch.Points {
+			points = append(points, model.LatLon{Latitude: p.Latitude, Longitude: p.Longitude})
+		}
+	} else {
+		var geometry *geojson.Geometry
+
+		if batch.Type == "Feature" {
+			feature, err := geojson.UnmarshalFeature(body)
+			if err != nil {
+				return nil, false, errors.Wrap(err, "invalid feature")
+			}
+			geometry = feature.Geometry
+		} else {
+			geometry, err = geojson.UnmarshalGeometry(body)
+			if err != nil {
+				return nil, false, errors.Wrap(err, "invalid geometry")
+			}
+		}
+
+		var coordinates [][]float64
+
+		switch {
+		case geometry == nil:
+			return nil, false, errors.New("feature without geometry")
+		case geometry.IsLineString():
+			coordinates, line = geometry.LineString, true
+		case geometry.IsMultiPoint():
+			coordinates = geometry.MultiPoint
+		default:
+			return nil, false, errors.Errorf("unsupported geometry: %s", geometry.Type)
+		}
+
+		for _, c := range coordinates {
+			if len(c) < 2 {
+				return nil, false, errors.New("invalid coordinates")
+			}
+			points = append(points, model.LatLon{Latitude: c[1], Longitude: c[0]})
+		}
+	}
+
+	if len(points) == 0 {
+		return nil, false, errors.New("no points")
+	}
+
+	return points, line, nil
+}
+
+// handleBatch returns the recommendation of every point of the request, and
+// of every segment for a LineString (sampled every step meters, the radius by
+// default), with the worst spreading factor and the uncovered share. The
+// points have the energy estimate of the device profile when it's requested.
+// The body is read whatever its content type, so curl --data-binary works.
+func (h *Handler) handleBatch() http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		body, err := ioutil.ReadAll(http.MaxBytesReader(res, req.Body, maxBatchSize))
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		points, line, err := parseBatch(body)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		step := h.radius
+		if s := req.Form.Get("step"); s != "" {
+			step, err = strconv.ParseFloat(s, 64)
+			if err != nil || step <= 0 {
+				http.Error(res, "invalid step: "+s, http.StatusBadRequest)
+				return
+			}
+		}
+
+		recommend, ok := h.recommender(res, req, req.Form, points)
+		if !ok {
+			return
+		}
+
+		if utils.EnergyRequested(req.Form) {
+			profile, err := utils.ParseEnergyProfile(req.Form)
+			if err != nil {
+				log.WithError(err).WithField("parameters", req.Form).Warn("handleBatch: no energy estimate")
+			} else {
+				recommend = model.EnergyRecommender(recommend, profile)
+			}
+		}
+
+		var route model.Route
+
+		if line {
+			route, err = model.RecommendRoute(points, step, recommend)
+		} else {
+			route, err = model.RecommendPoints(points, recommend)
+		}
+		if errors.Cause(err) == model.ErrRouteTooLong {
+			http.Error(res, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+		if err != nil {
+			log.WithError(err).WithField("points", len(points)).Error("handleBatch")
+			http.Error(res, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		js, err := json.Marshal(route)
+		if err != nil {
+			log.WithError(err).Error("handleBatch")
+			http.Error(res, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		res.Header().Set("Content-Type", "application/json")
+		res.Write(js)
+	})
+}
+
+// recommender returns the lookup in the grid of the profile, or without a
+// grid (or with live parameters) recommends from the receptions around the
+// points, queried once.
+func (h *Handler) recommender(res http.ResponseWriter, req *http.Request, params url.Values, points []model.LatLon) (model.Recommender, bool) {
+	if grid := h.grid(req); grid != nil {
+		return func(ll model.LatLon) (model.Recommendation, error) {
+			return grid.Lookup(ll), nil
+		}, true
+	}
+
+	filter, err := utils.ParseFilter(params)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+
+	options, err := utils.ParseDDROptions(params)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+
+	if filter.BoundingBox == nil {
+		bbox := model.Bounds(points).Extend(h.radius)
+		filter.BoundingBox = &bbox
+	}
+
+	receptions, err := model.GetReceptions(h.db, h.metricName, filter)
+	if err != nil {
+		log.WithError(err).WithField("parameters", params).Error("handleBatch")
+		http.Error(res, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return nil, false
+	}
+
+	return model.ReceptionsRecommender(receptions, h.radius, options), true
+}
@@ -34,14 +34,19 @@ import (
 	"github.com/spf13/viper"
 )
 
-type response struct {
-	Datarate string `json:"datarate"`
-}
-
 type Handler struct {
-	ddr model.DDR
+	db         model.Database
+	metricName string
+	radius     float64
+	gridSize   float64
+	gridOrigin *model.LatLon
+	grids      *model.DDRGrids
 }
 
+// live parameters, requests with any of them query the database instead of
+// the grid
+var liveParameters = []string{"live", "from", "to", "hours", "weekdays", "months", "tz", "device", "gateway", "campaign", "data_rate", "bbox", "policy", "margin", "percentile", "half_life"}
+
 func NewHandler(db model.Database) *Handler {
 	metricName := viper.GetString("metric.name")
 
@@ -49,14 +54,29 @@ func NewHandler(db model.Database) *Handler {
 		metricName = csv.LocationData
 	}
 
-	radius := viper.GetFloat64("ddr.radius")
+	gridSize := viper.GetFloat64("ddr.grid.size")
 
-	if radius <= 0 {
-		radius = 100.0
+	if gridSize <= 0 {
+		gridSize = model.DefaultDDRGridSize
+	}
+
+	var gridOrigin *model.LatLon
+
+	if origin := viper.GetString("ddr.grid.origin"); origin != "" {
+		ll, err := model.ParseLatLon(origin)
+		if err != nil {
+			log.WithError(err).Fatal("parsing ddr grid origin")
+		}
+		gridOrigin = &ll
 	}
 
 	return &Handler{
-		ddr: model.NewDDR(db, metricName, radius),
+		db:         db,
+		metricName: metricName,
+		radius:     utils.DDRRadius(),
+		gridSize:   gridSize,
+		gridOrigin: gridOrigin,
+		grids:      &model.DDRGrids{},
 	}
 }
 
@@ -65,6 +85,8 @@ func (h *Handler) Handle() http.Handler {
 		switch req.Method {
 		case "GET":
 			h.handleGet().ServeHTTP(res, req)
+		case "POST":
+			h.handlePost().ServeHTTP(res, req)
 		default:
 			http.Error(res, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
 		}
@@ -80,6 +102,25 @@ func (h *Handler) handleGet() http.Handler {
 		switch head {
 		case "q":
 			h.handleDDR().ServeHTTP(res, req)
+		case "grid":
+			h.handleGrid().ServeHTTP(res, req)
+		case "energy":
+			h.handleEnergy().ServeHTTP(res, req)
+		default:
+			http.NotFound(res, req)
+		}
+	})
+}
+
+func (h *Handler) handlePost() http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		var head string
+
+		head, req.URL.Path = utils.ShiftPath(req.URL.Path)
+
+		switch head {
+		case "batch":
+			h.handleBatch().ServeHTTP(res, req)
 		default:
 			http.NotFound(res, req)
 		}
@@ -108,21 +149,97 @@ func (h *Handler) handleDDR() http.Handler {
 
 		location := model.LatLon{Latitude: lat, Longitude: lon}
 
-		sf, err := h.ddr.GetSF(location)
+		recommendation, ok := h.lookup(location, req)
+		if !ok {
+			filter, err := utils.ParseFilter(req.Form)
+			if err != nil {
+				http.Error(res, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			options, err := utils.ParseDDROptions(req.Form)
+			if err != nil {
+				http.Error(res, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			d := model.NewDDR(h.db, h.metricName, h.radius)
+			d.SetFilter(filter)
+			d.SetOptions(options)
+
+			recommendation, err = d.Recommend(location)
+			if err != nil {
+				log.WithError(err).WithFields(log.Fields{
+					"lat": lat,
+					"lon": lon,
+				}).Error("handleDDR")
+				http.Error(res, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+		}
+
+		if utils.EnergyRequested(req.Form) {
+			recommendation.Energy = estimateEnergy(recommendation.SF, req.Form)
+		}
+
+		js, err := json.Marshal(recommendation)
 		if err != nil {
 			log.WithError(err).WithFields(log.Fields{
-				"lat": lat,
-				"lon": lon,
+				"response": recommendation,
 			}).Error("handleDDR")
 			http.Error(res, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 			return
 		}
 
-		js, err := json.Marshal(response{sf})
+		res.Header().Set("Content-Type", "application/json")
+		res.Write(js)
+	})
+}
+
+// lookup returns the recommendation of the precomputed grid of the profile,
+// unless the request has live parameters or the grid isn't computed yet.
+func (h *Handler) lookup(ll model.LatLon, req *http.Request) (model.Recommendation, bool) {
+	grid := h.grid(req)
+	if grid == nil {
+		return model.Recommendation{}, false
+	}
+
+	return grid.Lookup(ll), true
+}
+
+// grid returns the precomputed grid of the profile of the request (profile or
+// the profile of device_profile), or nil when the request has live parameters
+// (except the ignored ones) or the grid isn't computed yet.
+func (h *Handler) grid(req *http.Request, ignored ...string) *model.DDRGrid {
+	for _, key := range liveParameters {
+		if _, ok := req.Form[key]; ok && !contains(ignored, key) {
+			return nil
+		}
+	}
+
+	return h.grids.Get(utils.ParseDDRProfile(req.Form))
+}
+
+// handleGrid returns the state of the grid of every profile.
+func (h *Handler) handleGrid() http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		status := []gridStatus{}
+
+		for _, profile := range append([]string{""}, utils.DDRProfiles()...) {
+			if grid := h.grids.Get(profile); grid != nil {
+				status = append(status, gridStatus{
+					Profile:       profile,
+					Policy:        grid.Options.Policy,
+					Cells:         len(grid.Cells),
+					ComputedAt:    grid.ComputedAt,
+					LastReception: grid.LastReception,
+				})
+			}
+		}
+
+		js, err := json.Marshal(status)
 		if err != nil {
-			log.WithError(err).WithFields(log.Fields{
-				"response": response{sf},
-			}).Error("handleDDR")
+			log.WithError(err).Error("handleGrid")
 			http.Error(res, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 			return
 		}
@@ -131,3 +248,13 @@ func (h *Handler) handleDDR() http.Handler {
 		res.Write(js)
 	})
 }
+
+func contains(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
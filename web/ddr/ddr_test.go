@@ -0,0 +1,58 @@
+// The MIT License (MIT)
+//
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ddr
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bullettime/lora-mapper/model"
+)
+
+func TestGridLiveParameters(t *testing.T) {
+	h := &Handler{grids: &model.DDRGrids{}}
+	h.grids.Set("", &model.DDRGrid{})
+
+	tests := []struct {
+		query string
+		grid  bool
+	}{
+		{"lat=51&lon=4", true},
+		{"lat=51&lon=4&hours=8-18", false},
+		{"lat=51&lon=4&weekdays=mon-fri", false},
+		{"lat=51&lon=4&months=6-8", false},
+		{"lat=51&lon=4&tz=Europe/Brussels", false},
+		{"lat=51&lon=4&device=tracker-001", false},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest("GET", "/q?"+test.query, nil)
+		if err := req.ParseForm(); err != nil {
+			t.Fatal(err)
+		}
+
+		if grid := h.grid(req); (grid != nil) != test.grid {
+			t.Errorf("%s: expected grid %v, got %v", test.query, test.grid, grid != nil)
+		}
+	}
+}
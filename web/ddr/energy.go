@@ -0,0 +1,116 @@
+// The MIT License (MIT)
+//
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ddr
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/apex/log"
+	"github.com/bullettime/lora-mapper/model"
+	"github.com/bullettime/lora-mapper/web/utils"
+)
+
+// estimateEnergy returns the energy estimate of the spreading factor with the
+// energy settings of the request, or nil when they are invalid: the estimate
+// never fails a recommendation.
+func estimateEnergy(sf int, params url.Values) *model.Energy {
+	profile, err := utils.ParseEnergyProfile(params)
+	if err != nil {
+		log.WithError(err).WithField("parameters", params).Warn("no energy estimate")
+		return nil
+	}
+
+	energy, err := model.EstimateEnergy(sf, profile)
+	if err != nil {
+		log.WithError(err).WithField("sf", sf).Warn("no energy estimate")
+		return nil
+	}
+
+	return &energy
+}
+
+// handleEnergy returns the cells of the grid of the profile within the bbox
+// as a GeoJSON layer with the airtime, duty cycle and battery lifetime of the
+// recommended data rates. The precomputed grid is clipped to the bbox, without
+// one (or with other live parameters) the grid is computed from the
+// receptions of the request.
+func (h *Handler) handleEnergy() http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		params := req.Form
+
+		filter, err := utils.ParseFilter(params)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		profile, err := utils.ParseEnergyProfile(params)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		area := filter.BoundingBox
+		grid := h.grid(req, "bbox")
+
+		if grid == nil {
+			options, err := utils.ParseDDROptions(params)
+			if err != nil {
+				http.Error(res, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			// include the receptions within the radius of the cells at the edges
+			if area != nil {
+				bbox := area.Extend(h.radius)
+				filter.BoundingBox = &bbox
+			}
+
+			receptions, err := model.GetReceptions(h.db, h.metricName, filter)
+			if err != nil {
+				log.WithFields(log.Fields{"parameters": params}).WithError(err).Error("handle energy")
+				http.Error(res, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+
+			grid, err = model.ComputeDDRGrid(receptions, h.gridSize, h.gridOrigin, h.radius, options)
+			if err != nil {
+				log.WithFields(log.Fields{"parameters": params}).WithError(err).Error("handle energy")
+				http.Error(res, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+		}
+
+		data, err := grid.EnergyGeoJSON(profile, area, params.Get("callback"))
+		if err != nil {
+			log.WithFields(log.Fields{"parameters": params}).WithError(err).Error("handle energy")
+			http.Error(res, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		res.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(res, data)
+	})
+}
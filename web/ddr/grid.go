@@ -0,0 +1,183 @@
+// The MIT License (MIT)
+//
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ddr
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/bullettime/lora-mapper/model"
+	"github.com/bullettime/lora-mapper/web/utils"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// DefaultGridInterval is the interval between the checks for new receptions.
+const DefaultGridInterval = 10 * time.Minute
+
+type gridStatus struct {
+	Profile       string    `json:"profile"`
+	Policy        string    `json:"policy"`
+	Cells         int       `json:"cells"`
+	ComputedAt    time.Time `json:"computed_at"`
+	LastReception time.Time `json:"last_reception"`
+}
+
+// gridFile returns the file of the grid of the profile in the ddr.grid.path
+// directory, or an empty string when the grids aren't stored.
+func gridFile(profile string) string {
+	path := viper.GetString("ddr.grid.path")
+	if path == "" {
+		return ""
+	}
+
+	if profile == "" {
+		return filepath.Join(path, "ddr_grid.json")
+	}
+
+	return filepath.Join(path, "ddr_grid_"+profile+".json")
+}
+
+// RunGridJob loads the stored ddr grids and recomputes them every
+// ddr.grid.interval when there are new receptions, until the interval is
+// negative.
+func (h *Handler) RunGridJob() {
+	interval := DefaultGridInterval
+	if viper.IsSet("ddr.grid.interval") {
+		interval = viper.GetDuration("ddr.grid.interval")
+	}
+
+	if interval < 0 {
+		log.Info("[DDR] grid disabled")
+		return
+	}
+
+	for _, profile := range append([]string{""}, utils.DDRProfiles()...) {
+		if err := h.loadGrid(profile); err != nil && !os.IsNotExist(errors.Cause(err)) {
+			log.WithError(err).WithField("profile", profile).Warn("[DDR] loading grid")
+		}
+	}
+
+	for {
+		if err := h.refreshGrids(); err != nil {
+			log.WithError(err).Error("[DDR] refreshing grids")
+		}
+
+		time.Sleep(interval)
+	}
+}
+
+func (h *Handler) loadGrid(profile string) error {
+	name := gridFile(profile)
+	if name == "" {
+		return nil
+	}
+
+	file, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	grid, err := model.ReadDDRGrid(file)
+	if err != nil {
+		return err
+	}
+
+	h.grids.Set(profile, grid)
+
+	return nil
+}
+
+func (h *Handler) storeGrid(profile string, grid *model.DDRGrid) error {
+	name := gridFile(profile)
+	if name == "" {
+		return nil
+	}
+
+	file, err := os.Create(name)
+	if err != nil {
+		return errors.Wrap(err, "storing ddr grid")
+	}
+	defer file.Close()
+
+	return grid.WriteJSON(file)
+}
+
+// refreshGrids recomputes the grids of the default options and of every
+// profile when there are receptions newer than the grid.
+func (h *Handler) refreshGrids() error {
+	last, err := model.LastReceptionTime(h.db, h.metricName)
+	if err != nil {
+		return err
+	}
+
+	if last.IsZero() {
+		return nil
+	}
+
+	var receptions []model.Reception
+
+	for _, profile := range append([]string{""}, utils.DDRProfiles()...) {
+		if grid := h.grids.Get(profile); grid != nil && !grid.LastReception.Before(last) {
+			continue
+		}
+
+		options, err := utils.DDROptions(profile)
+		if err != nil {
+			log.WithError(err).WithField("profile", profile).Error("[DDR] invalid profile")
+			continue
+		}
+
+		if receptions == nil {
+			receptions, err = model.GetReceptions(h.db, h.metricName, model.Filter{})
+			if err != nil {
+				return err
+			}
+		}
+
+		start := time.Now()
+
+		grid, err := model.ComputeDDRGrid(receptions, h.gridSize, h.gridOrigin, h.radius, options)
+		if err != nil {
+			return err
+		}
+
+		h.grids.Set(profile, grid)
+
+		if err := h.storeGrid(profile, grid); err != nil {
+			log.WithError(err).WithField("profile", profile).Warn("[DDR] storing grid")
+		}
+
+		log.WithFields(log.Fields{
+			"profile":  profile,
+			"policy":   options.Policy,
+			"cells":    len(grid.Cells),
+			"duration": time.Since(start),
+		}).Info("[DDR] grid computed")
+	}
+
+	return nil
+}
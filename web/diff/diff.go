@@ -0,0 +1,175 @@
+// The MIT License (MIT)
+//
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package diff
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/apex/log"
+	"github.com/bullettime/lora-mapper/analytics"
+	"github.com/bullettime/lora-mapper/model"
+	"github.com/bullettime/lora-mapper/parser/csv"
+	"github.com/bullettime/lora-mapper/web/utils"
+	"github.com/spf13/viper"
+)
+
+type Handler struct {
+	db         model.Database
+	metricName string
+}
+
+type diffResponse struct {
+	*analytics.Diff
+	Report string `json:"report"`
+}
+
+func NewHandler(db model.Database) *Handler {
+	metricName := viper.GetString("metric.name")
+
+	if metricName == "" {
+		metricName = csv.LocationData
+	}
+
+	return &Handler{
+		db:         db,
+		metricName: metricName,
+	}
+}
+
+func (h *Handler) Handle() http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		switch req.Method {
+		case "GET":
+			h.handleGet().ServeHTTP(res, req)
+		default:
+			http.Error(res, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+func (h *Handler) handleGet() http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		var head string
+
+		head, req.URL.Path = utils.ShiftPath(req.URL.Path)
+
+		switch head {
+		case "", "cells":
+			h.handleDiff(head, req.Form).ServeHTTP(res, req)
+		default:
+			http.NotFound(res, req)
+		}
+	})
+}
+
+// handleDiff compares the cells of a grid between the before and after
+// windows (before_from, before_to, before_campaign and the same for after),
+// the other filter parameters apply to both. It returns the summary, cells
+// and report, or with cells in the path the coloured GeoJSON diff layer.
+func (h *Handler) handleDiff(head string, params url.Values) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		filter, err := utils.ParseFilter(params)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		before, err := utils.ParseDiffWindow(params, "before")
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		after, err := utils.ParseDiffWindow(params, "after")
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		gridOptions, err := utils.ParseGridOptions(params)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		options, err := utils.ParseDiffOptions(params)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		receptions := make([][]model.Reception, 2)
+
+		for i, window := range []analytics.DiffWindow{before, after} {
+			receptions[i], err = model.GetReceptions(h.db, h.metricName, window.Filter(filter))
+			if err != nil {
+				log.WithFields(log.Fields{
+					"parameters": params,
+				}).WithError(err).Error("handle diff")
+				http.Error(res, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+		}
+
+		diff, err := analytics.CompareCells(gridOptions, receptions[0], receptions[1], options)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		diff.Summary.Before = before.String()
+		diff.Summary.After = after.String()
+
+		if head == "" {
+			h.writeJSON(diffResponse{Diff: diff, Report: diff.Report()}).ServeHTTP(res, req)
+			return
+		}
+
+		data, err := diff.GeoJSON(params.Get("callback"))
+		if err != nil {
+			log.WithError(err).Error("handle diff")
+			http.Error(res, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		res.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(res, data)
+	})
+}
+
+func (h *Handler) writeJSON(v interface{}) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		js, err := json.Marshal(v)
+		if err != nil {
+			log.WithError(err).Error("writeJSON")
+			http.Error(res, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		res.Header().Set("Content-Type", "application/json")
+		res.Write(js)
+	})
+}
@@ -0,0 +1,303 @@
+// The MIT License (MIT)
+//
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package gateways
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/apex/log"
+	"github.com/bullettime/lora-mapper/model"
+	"github.com/bullettime/lora-mapper/web/utils"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+type Handler struct {
+	gateways model.Gateways
+	writable bool
+	token    string
+}
+
+type importResponse struct {
+	Created int `json:"created"`
+	Updated int `json:"updated"`
+}
+
+// NewHandler creates the handler of the gateway registry. The registry is
+// read-only unless web.gateways.writable is set in the config file, writes
+// then need the web.gateways.token as bearer token when one is set, eg.
+//
+//	web:
+//	  gateways:
+//	    writable: true
+//	    token: secret
+func NewHandler(db model.Database) *Handler {
+	return &Handler{
+		gateways: utils.NewGateways(db),
+		writable: viper.GetBool("web.gateways.writable"),
+		token:    viper.GetString("web.gateways.token"),
+	}
+}
+
+func (h *Handler) Handle() http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		var head string
+
+		head, req.URL.Path = utils.ShiftPath(req.URL.Path)
+
+		switch req.Method {
+		case "GET":
+			h.handleGet(head).ServeHTTP(res, req)
+		case "POST":
+			h.authorize(h.handlePost(head)).ServeHTTP(res, req)
+		case "PUT":
+			h.authorize(h.handlePut(head)).ServeHTTP(res, req)
+		case "DELETE":
+			h.authorize(h.handleDelete(head)).ServeHTTP(res, req)
+		default:
+			http.Error(res, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+// authorize only passes write requests on when the registry is writable and
+// the request has the token.
+func (h *Handler) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if !h.writable {
+			http.Error(res, "the gateway registry is read-only", http.StatusForbidden)
+			return
+		}
+
+		if h.token != "" {
+			token := req.Header.Get("Authorization")
+			if subtle.ConstantTimeCompare([]byte(token), []byte("Bearer "+h.token)) != 1 {
+				res.Header().Set("WWW-Authenticate", "Bearer")
+				http.Error(res, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+		}
+
+		next.ServeHTTP(res, req)
+	})
+}
+
+func (h *Handler) handleGet(head string) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		switch head {
+		case "":
+			h.handleList().ServeHTTP(res, req)
+		case "geojson":
+			h.handleGeoJSON().ServeHTTP(res, req)
+		default:
+			h.handleGateway(head).ServeHTTP(res, req)
+		}
+	})
+}
+
+func (h *Handler) list(res http.ResponseWriter, req *http.Request) ([]model.Gateway, bool) {
+	list, err := h.gateways.List(req.FormValue("inactive") == "true")
+	if err != nil {
+		log.WithError(err).Error("list gateways")
+		http.Error(res, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return nil, false
+	}
+
+	if list == nil {
+		list = []model.Gateway{}
+	}
+
+	return list, true
+}
+
+func (h *Handler) handleList() http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		list, ok := h.list(res, req)
+		if !ok {
+			return
+		}
+
+		h.writeJSON(list).ServeHTTP(res, req)
+	})
+}
+
+// handleGeoJSON returns the gateways as points for the markers on the maps.
+func (h *Handler) handleGeoJSON() http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		list, ok := h.list(res, req)
+		if !ok {
+			return
+		}
+
+		json, err := model.GatewaysGeoJSON(list, req.FormValue("callback"))
+		if err != nil {
+			log.WithError(err).Error("handleGeoJSON")
+			http.Error(res, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		res.Header().Set("Content-Type", "application/json")
+		res.WriteHeader(http.StatusOK)
+		fmt.Fprint(res, json)
+	})
+}
+
+func (h *Handler) handleGateway(id string) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		gateway, err := h.gateways.Get(id)
+		if h.handleError(res, req, id, err) {
+			return
+		}
+
+		h.writeJSON(gateway).ServeHTTP(res, req)
+	})
+}
+
+// handlePost creates the gateway in the JSON body, or imports the gateways
+// of the YAML body on /gateways/import. The content type of the body isn't
+// checked.
+func (h *Handler) handlePost(head string) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		switch head {
+		case "":
+			var gateway model.Gateway
+
+			if err := json.NewDecoder(req.Body).Decode(&gateway); err != nil {
+				http.Error(res, "invalid gateway: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			if h.handleError(res, req, gateway.ID, h.gateways.Create(gateway)) {
+				return
+			}
+
+			gateway, err := h.gateways.Get(gateway.ID)
+			if h.handleError(res, req, gateway.ID, err) {
+				return
+			}
+
+			h.writeJSONStatus(gateway, http.StatusCreated).ServeHTTP(res, req)
+		case "import":
+			list, err := model.ReadGatewaysYAML(req.Body)
+			if err != nil {
+				http.Error(res, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			created, updated, err := h.gateways.Import(list)
+			if h.handleError(res, req, "", err) {
+				return
+			}
+
+			h.writeJSON(importResponse{Created: created, Updated: updated}).ServeHTTP(res, req)
+		default:
+			http.NotFound(res, req)
+		}
+	})
+}
+
+// handlePut updates the gateway with the fields of the JSON body, the fields
+// missing in the body are kept.
+func (h *Handler) handlePut(id string) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		gateway, err := h.gateways.Get(id)
+		if h.handleError(res, req, id, err) {
+			return
+		}
+
+		if err := json.NewDecoder(req.Body).Decode(&gateway); err != nil {
+			http.Error(res, "invalid gateway: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if gateway.ID != id {
+			http.Error(res, "the gateway id can't be changed", http.StatusBadRequest)
+			return
+		}
+
+		if h.handleError(res, req, id, h.gateways.Update(gateway)) {
+			return
+		}
+
+		gateway, err = h.gateways.Get(id)
+		if h.handleError(res, req, id, err) {
+			return
+		}
+
+		h.writeJSON(gateway).ServeHTTP(res, req)
+	})
+}
+
+func (h *Handler) handleDelete(id string) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if h.handleError(res, req, id, h.gateways.Delete(id)) {
+			return
+		}
+
+		res.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// handleError writes the response of the error and returns true, or returns
+// false without an error.
+func (h *Handler) handleError(res http.ResponseWriter, req *http.Request, id string, err error) bool {
+	if err == nil {
+		return false
+	}
+
+	switch errors.Cause(err) {
+	case model.ErrGatewayNotFound:
+		http.NotFound(res, req)
+	case model.ErrGatewayExists:
+		http.Error(res, err.Error(), http.StatusConflict)
+	case model.ErrGatewayID, model.ErrGatewayInvalid:
+		http.Error(res, err.Error(), http.StatusBadRequest)
+	default:
+		log.WithError(err).WithField("id", id).Error("gateways")
+		http.Error(res, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+
+	return true
+}
+
+func (h *Handler) writeJSON(v interface{}) http.Handler {
+	return h.writeJSONStatus(v, http.StatusOK)
+}
+
+func (h *Handler) writeJSONStatus(v interface{}, status int) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		js, err := json.Marshal(v)
+		if err != nil {
+			log.WithError(err).Error("writeJSON")
+			http.Error(res, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		res.Header().Set("Content-Type", "application/json")
+		res.WriteHeader(status)
+		res.Write(js)
+	})
+}
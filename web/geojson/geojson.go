@@ -35,7 +35,8 @@ import (
 )
 
 type Handler struct {
-	geoJSON model.GeoJSON
+	db         model.Database
+	metricName string
 }
 
 func NewHandler(db model.Database) *Handler {
@@ -46,7 +47,8 @@ func NewHandler(db model.Database) *Handler {
 	}
 
 	return &Handler{
-		geoJSON: model.NewGeoJSON(db, metricName),
+		db:         db,
+		metricName: metricName,
 	}
 }
 
@@ -70,6 +72,8 @@ func (h *Handler) handleGet() http.Handler {
 		switch head {
 		case "all":
 			h.handleAll(req.Form).ServeHTTP(res, req)
+		case "grid":
+			h.handleGrid(req.Form).ServeHTTP(res, req)
 		case "sf7":
 			h.handleSF("SF7BW125", req.Form).ServeHTTP(res, req)
 		case "sf8":
@@ -88,9 +92,27 @@ func (h *Handler) handleGet() http.Handler {
 	})
 }
 
+func (h *Handler) newGeoJSON(params url.Values) (model.GeoJSON, error) {
+	filter, err := utils.ParseFilter(params)
+	if err != nil {
+		return nil, err
+	}
+
+	g := model.NewGeoJSON(h.db, h.metricName)
+	g.SetFilter(filter)
+
+	return g, nil
+}
+
 func (h *Handler) handleSF(sf string, params url.Values) http.Handler {
 	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
-		json, err := h.geoJSON.GetGeoJSONFromSF(sf, params.Get("callback"))
+		g, err := h.newGeoJSON(params)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		json, err := g.GetGeoJSONFromSF(sf, params.Get("callback"))
 		if err != nil {
 			log.WithFields(log.Fields{
 				"sf":         sf,
@@ -106,7 +128,13 @@ func (h *Handler) handleSF(sf string, params url.Values) http.Handler {
 
 func (h *Handler) handleAll(params url.Values) http.Handler {
 	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
-		json, err := h.geoJSON.GetGeoJSONFromAllSF(params.Get("callback"))
+		g, err := h.newGeoJSON(params)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		json, err := g.GetGeoJSONFromAllSF(params.Get("callback"))
 		if err != nil {
 			log.WithFields(log.Fields{
 				"parameters": params,
@@ -119,6 +147,36 @@ func (h *Handler) handleAll(params url.Values) http.Handler {
 	})
 }
 
+func (h *Handler) handleGrid(params url.Values) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		filter, err := utils.ParseFilter(params)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		options, err := utils.ParseGridOptions(params)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		a := model.NewAggregation(h.db, h.metricName, options)
+		a.SetFilter(filter)
+
+		json, err := a.GetGeoJSON(params.Get("callback"))
+		if err != nil {
+			log.WithFields(log.Fields{
+				"parameters": params,
+			}).WithError(err).Error("handle grid")
+			http.NotFound(res, req)
+			return
+		}
+
+		h.writeJSON(json).ServeHTTP(res, req)
+	})
+}
+
 func (h *Handler) writeJSON(json string) http.Handler {
 	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
 		res.Header().Set("Content-Type", "application/json")
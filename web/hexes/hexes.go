@@ -0,0 +1,148 @@
+// The MIT License (MIT)
+//
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hexes
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/apex/log"
+	"github.com/bullettime/lora-mapper/model"
+	"github.com/bullettime/lora-mapper/parser/csv"
+	"github.com/bullettime/lora-mapper/web/utils"
+	"github.com/spf13/viper"
+)
+
+type Handler struct {
+	db         model.Database
+	metricName string
+}
+
+func NewHandler(db model.Database) *Handler {
+	metricName := viper.GetString("metric.name")
+
+	if metricName == "" {
+		metricName = csv.LocationData
+	}
+
+	return &Handler{
+		db:         db,
+		metricName: metricName,
+	}
+}
+
+func (h *Handler) Handle() http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		switch req.Method {
+		case "GET":
+			h.handleGet().ServeHTTP(res, req)
+		default:
+			http.Error(res, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+func (h *Handler) handleGet() http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		var head string
+
+		head, req.URL.Path = utils.ShiftPath(req.URL.Path)
+
+		switch head {
+		case "geojson":
+			h.handleGeoJSON(req.Form).ServeHTTP(res, req)
+		case "csv":
+			h.handleCSV(req.Form).ServeHTTP(res, req)
+		default:
+			http.NotFound(res, req)
+		}
+	})
+}
+
+func (h *Handler) newHexLayer(params url.Values) (model.HexLayer, error) {
+	filter, err := utils.ParseFilter(params)
+	if err != nil {
+		return nil, err
+	}
+
+	resolution, err := utils.ParseHexResolution(params)
+	if err != nil {
+		return nil, err
+	}
+
+	l := model.NewHexLayer(h.db, h.metricName, resolution)
+	l.SetFilter(filter)
+
+	return l, nil
+}
+
+func (h *Handler) handleGeoJSON(params url.Values) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		l, err := h.newHexLayer(params)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		json, err := l.GetGeoJSON(params.Get("callback"))
+		if err != nil {
+			log.WithFields(log.Fields{
+				"parameters": params,
+			}).WithError(err).Error("handle geojson")
+			http.NotFound(res, req)
+			return
+		}
+
+		res.Header().Set("Content-Type", "application/json")
+		res.WriteHeader(http.StatusOK)
+		fmt.Fprint(res, json)
+	})
+}
+
+func (h *Handler) handleCSV(params url.Values) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		l, err := h.newHexLayer(params)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var buf bytes.Buffer
+
+		err = l.WriteCSV(&buf)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"parameters": params,
+			}).WithError(err).Error("handle csv")
+			http.NotFound(res, req)
+			return
+		}
+
+		res.Header().Set("Content-Type", "text/csv")
+		res.Header().Set("Content-Disposition", `attachment; filename="hexes.csv"`)
+		res.WriteHeader(http.StatusOK)
+		buf.WriteTo(res)
+	})
+}
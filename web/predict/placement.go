@@ -0,0 +1,127 @@
+// The MIT License (MIT)
+//
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package predict
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/bullettime/lora-mapper/model"
+	"github.com/bullettime/lora-mapper/prediction"
+	"github.com/bullettime/lora-mapper/web/utils"
+	"github.com/pkg/errors"
+)
+
+// maxPlacementSize limits the size of a placement request body.
+const maxPlacementSize = 4 << 20
+
+// placementRequest holds the candidate sites (a GeoJSON feature collection of
+// points) and the target area (a GeoJSON polygon, feature or collection).
+type placementRequest struct {
+	Candidates json.RawMessage `json:"candidates"`
+	Area       json.RawMessage `json:"area"`
+}
+
+// handlePlacement ranks the candidate gateway sites of the request body by
+// their predicted gain in the target area and greedily picks the number of
+// picks (default 1) sites. The objective is coverage (default) or sf, size
+// the size of the cells in meters.
+func (h *Handler) handlePlacement() http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		body, err := ioutil.ReadAll(http.MaxBytesReader(res, req.Body, maxPlacementSize))
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var data placementRequest
+		if err := json.Unmarshal(body, &data); err != nil {
+			http.Error(res, "invalid request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		candidates, err := prediction.ParseCandidatesGeoJSON(data.Candidates)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		area, err := model.ParseAreaGeoJSON(data.Area)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		options, err := placementOptions(req.Form)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		p, _, ok := h.predictor(res, req.Form, false)
+		if !ok {
+			return
+		}
+
+		// already validated by the predictor
+		link, _ := utils.ParsePredictionOptions(req.Form)
+		gateways := p.Gateways()
+
+		placement, err := prediction.PlaceGateways(gateways, prediction.NewCandidates(candidates, gateways, link), area, link, options)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		h.writeJSON(placement).ServeHTTP(res, req)
+	})
+}
+
+func placementOptions(params url.Values) (prediction.PlacementOptions, error) {
+	options := prediction.PlacementOptions{
+		CellSize:  DefaultCellSize,
+		Picks:     1,
+		Objective: params.Get("objective"),
+	}
+
+	if s := params.Get("size"); s != "" {
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil || v <= 0 {
+			return options, errors.Errorf("invalid size: %s", s)
+		}
+		options.CellSize = v
+	}
+
+	if s := params.Get("picks"); s != "" {
+		v, err := strconv.Atoi(s)
+		if err != nil || v < 0 {
+			return options, errors.Errorf("invalid picks: %s", s)
+		}
+		options.Picks = v
+	}
+
+	return options, nil
+}
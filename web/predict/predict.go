@@ -0,0 +1,301 @@
+// The MIT License (MIT)
+//
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package predict
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/apex/log"
+	"github.com/bullettime/lora-mapper/model"
+	"github.com/bullettime/lora-mapper/parser/csv"
+	"github.com/bullettime/lora-mapper/prediction"
+	"github.com/bullettime/lora-mapper/web/utils"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// DefaultCellSize of the layers in meters.
+const DefaultCellSize = 100.0
+
+type Handler struct {
+	db         model.Database
+	metricName string
+}
+
+func NewHandler(db model.Database) *Handler {
+	metricName := viper.GetString("metric.name")
+
+	if metricName == "" {
+		metricName = csv.LocationData
+	}
+
+	return &Handler{
+		db:         db,
+		metricName: metricName,
+	}
+}
+
+func (h *Handler) Handle() http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		switch req.Method {
+		case "GET":
+			h.handleGet().ServeHTTP(res, req)
+		case "POST":
+			h.handlePost().ServeHTTP(res, req)
+		default:
+			http.Error(res, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+func (h *Handler) handleGet() http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		var head string
+
+		head, req.URL.Path = utils.ShiftPath(req.URL.Path)
+
+		switch head {
+		case "":
+			h.handlePoint(req.Form).ServeHTTP(res, req)
+		case "gateways":
+			h.handleGateways(req.Form).ServeHTTP(res, req)
+		case "raster":
+			h.handleRaster(req.Form).ServeHTTP(res, req)
+		case "geojson":
+			h.handleGeoJSON(req.Form).ServeHTTP(res, req)
+		case "validate":
+			h.handleValidate(req.Form).ServeHTTP(res, req)
+		default:
+			http.NotFound(res, req)
+		}
+	})
+}
+
+func (h *Handler) handlePost() http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		var head string
+
+		head, req.URL.Path = utils.ShiftPath(req.URL.Path)
+
+		switch head {
+		case "placement":
+			h.handlePlacement().ServeHTTP(res, req)
+		default:
+			http.NotFound(res, req)
+		}
+	})
+}
+
+// predictor fits the path loss model of the gateways on the receptions
+// matching the filter, unless fit=false, and returns the predictor with the
+// receptions. The receptions are only retrieved for fitting unless load is set.
+func (h *Handler) predictor(res http.ResponseWriter, params url.Values, load bool) (prediction.Predictor, []model.Reception, bool) {
+	filter, err := utils.ParseFilter(params)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return nil, nil, false
+	}
+
+	options, err := utils.ParsePredictionOptions(params)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return nil, nil, false
+	}
+
+	gateways, err := utils.Gateways(h.db)
+	if err != nil {
+		log.WithError(err).Error("predictor")
+		http.Error(res, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return nil, nil, false
+	}
+
+	if len(gateways) == 0 {
+		http.Error(res, prediction.ErrNoGateways.Error(), http.StatusNotFound)
+		return nil, nil, false
+	}
+
+	var receptions []model.Reception
+
+	fit := params.Get("fit") != "false"
+
+	if fit || load {
+		receptions, err = model.GetReceptions(h.db, h.metricName, filter)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"parameters": params,
+			}).WithError(err).Error("predictor")
+			http.Error(res, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return nil, nil, false
+		}
+	}
+
+	fitted := receptions
+	if !fit {
+		fitted = nil
+	}
+
+	return prediction.NewPredictor(prediction.NewGateways(gateways, fitted, options), options), receptions, true
+}
+
+// layer reads the area (minlon,minlat,maxlon,maxlat, by default the range of
+// the gateways) and size of the cells of a layer.
+func layer(params url.Values, p prediction.Predictor) (model.BoundingBox, float64, error) {
+	area := p.Area()
+
+	if a := params.Get("area"); a != "" {
+		var err error
+
+		area, err = model.ParseBoundingBox(a)
+		if err != nil {
+			return area, 0, err
+		}
+	}
+
+	size := DefaultCellSize
+
+	if s := params.Get("size"); s != "" {
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return area, 0, errors.Errorf("invalid size: %s", s)
+		}
+		size = v
+	}
+
+	return area, size, nil
+}
+
+// handlePoint predicts the coverage at the location parameter.
+func (h *Handler) handlePoint(params url.Values) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		ll, err := model.ParseLatLon(params.Get("location"))
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		p, _, ok := h.predictor(res, params, false)
+		if !ok {
+			return
+		}
+
+		h.writeJSON(p.Predict(ll)).ServeHTTP(res, req)
+	})
+}
+
+func (h *Handler) handleGateways(params url.Values) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		p, _, ok := h.predictor(res, params, false)
+		if !ok {
+			return
+		}
+
+		h.writeJSON(p.Gateways()).ServeHTTP(res, req)
+	})
+}
+
+// handleRaster returns the predicted value (sf, rssi or snr) of every cell.
+func (h *Handler) handleRaster(params url.Values) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		p, _, ok := h.predictor(res, params, false)
+		if !ok {
+			return
+		}
+
+		area, size, err := layer(params, p)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		value := params.Get("value")
+		if value == "" {
+			value = prediction.ValueSF
+		}
+
+		surface, err := p.Raster(area, size, value)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		h.writeJSON(surface).ServeHTTP(res, req)
+	})
+}
+
+// handleGeoJSON returns the areas of the predicted best spreading factors.
+func (h *Handler) handleGeoJSON(params url.Values) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		p, _, ok := h.predictor(res, params, false)
+		if !ok {
+			return
+		}
+
+		area, size, err := layer(params, p)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		json, err := p.GeoJSON(area, size, params.Get("callback"))
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		res.Header().Set("Content-Type", "application/json")
+		res.WriteHeader(http.StatusOK)
+		fmt.Fprint(res, json)
+	})
+}
+
+// handleValidate compares the predictions with the receptions matching the
+// filter. With fit=false the default model is validated, otherwise the
+// models are fitted on the same receptions.
+func (h *Handler) handleValidate(params url.Values) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		p, receptions, ok := h.predictor(res, params, true)
+		if !ok {
+			return
+		}
+
+		h.writeJSON(p.Validate(receptions)).ServeHTTP(res, req)
+	})
+}
+
+func (h *Handler) writeJSON(v interface{}) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		js, err := json.Marshal(v)
+		if err != nil {
+			log.WithError(err).Error("writeJSON")
+			http.Error(res, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		res.Header().Set("Content-Type", "application/json")
+		res.Write(js)
+	})
+}
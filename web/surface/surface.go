@@ -0,0 +1,168 @@
+// The MIT License (MIT)
+//
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package surface
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/apex/log"
+	"github.com/bullettime/lora-mapper/interpolation"
+	"github.com/bullettime/lora-mapper/model"
+	"github.com/bullettime/lora-mapper/parser/csv"
+	"github.com/bullettime/lora-mapper/web/utils"
+	"github.com/spf13/viper"
+)
+
+type Handler struct {
+	db         model.Database
+	metricName string
+}
+
+func NewHandler(db model.Database) *Handler {
+	metricName := viper.GetString("metric.name")
+
+	if metricName == "" {
+		metricName = csv.LocationData
+	}
+
+	return &Handler{
+		db:         db,
+		metricName: metricName,
+	}
+}
+
+func (h *Handler) Handle() http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		switch req.Method {
+		case "GET":
+			h.handleGet().ServeHTTP(res, req)
+		default:
+			http.Error(res, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+func (h *Handler) handleGet() http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		var head string
+
+		head, req.URL.Path = utils.ShiftPath(req.URL.Path)
+
+		switch head {
+		case "grid":
+			h.handleGrid(req.Form).ServeHTTP(res, req)
+		case "contours":
+			h.handleContours(req.Form).ServeHTTP(res, req)
+		default:
+			http.NotFound(res, req)
+		}
+	})
+}
+
+// interpolate computes the rssi surface of the receptions matching the
+// filter, use the gateway or data_rate parameters for a surface per gateway
+// or data rate.
+func (h *Handler) interpolate(res http.ResponseWriter, req *http.Request, params url.Values) (*interpolation.Surface, bool) {
+	filter, err := utils.ParseFilter(params)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+
+	options, err := utils.ParseInterpolationOptions(params)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+
+	receptions, err := model.GetReceptions(h.db, h.metricName, filter)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"parameters": params,
+		}).WithError(err).Error("interpolate")
+		http.Error(res, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return nil, false
+	}
+
+	surface, err := interpolation.Interpolate(interpolation.RSSISamples(receptions), options)
+	if err == interpolation.ErrNoSamples {
+		http.NotFound(res, req)
+		return nil, false
+	}
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+
+	return surface, true
+}
+
+func (h *Handler) handleGrid(params url.Values) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		surface, ok := h.interpolate(res, req, params)
+		if !ok {
+			return
+		}
+
+		js, err := json.Marshal(surface)
+		if err != nil {
+			log.WithError(err).Error("handle grid")
+			http.Error(res, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		res.Header().Set("Content-Type", "application/json")
+		res.Write(js)
+	})
+}
+
+func (h *Handler) handleContours(params url.Values) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		surface, ok := h.interpolate(res, req, params)
+		if !ok {
+			return
+		}
+
+		levels, err := utils.ParseLevels(params, surface)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		json, err := interpolation.ContoursGeoJSON(surface.Contours(levels), params.Get("callback"))
+		if err != nil {
+			log.WithFields(log.Fields{
+				"parameters": params,
+			}).WithError(err).Error("handle contours")
+			http.Error(res, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		res.Header().Set("Content-Type", "application/json")
+		res.WriteHeader(http.StatusOK)
+		fmt.Fprint(res, json)
+	})
+}
@@ -0,0 +1,140 @@
+// The MIT License (MIT)
+//
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package utils
+
+import (
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/bullettime/lora-mapper/analytics"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// AnomalyOptions returns the anomaly detection options of the config file:
+//
+//	anomaly:
+//	  window: 24h
+//	  baseline: 336h
+//	  minbaseline: 5
+//	  mincells: 2
+//	  mindrop: 3
+//	  z: 3
+//	  interval: 1h
+//	  webhook: https://example.com/hooks/lora
+func AnomalyOptions() analytics.AnomalyOptions {
+	options := analytics.DefaultAnomalyOptions()
+
+	if viper.IsSet("anomaly.window") {
+		options.Window = viper.GetDuration("anomaly.window")
+	}
+
+	if viper.IsSet("anomaly.baseline") {
+		options.Baseline = viper.GetDuration("anomaly.baseline")
+	}
+
+	if viper.IsSet("anomaly.minbaseline") {
+		options.MinBaseline = viper.GetInt("anomaly.minbaseline")
+	}
+
+	if viper.IsSet("anomaly.mincells") {
+		options.MinCells = viper.GetInt("anomaly.mincells")
+	}
+
+	if viper.IsSet("anomaly.mindrop") {
+		options.MinDrop = viper.GetFloat64("anomaly.mindrop")
+	}
+
+	if viper.IsSet("anomaly.z") {
+		options.Z = viper.GetFloat64("anomaly.z")
+	}
+
+	return options
+}
+
+// ParseAnomalyOptions reads the window and baseline (eg. 24h), min_baseline,
+// min_cells, min_drop (in dB) and z of the request parameters and falls back
+// on the config file.
+func ParseAnomalyOptions(params url.Values) (analytics.AnomalyOptions, error) {
+	options := AnomalyOptions()
+
+	durations := map[string]*time.Duration{
+		"window":   &options.Window,
+		"baseline": &options.Baseline,
+	}
+
+	for key, value := range durations {
+		if v := params.Get(key); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil || d <= 0 {
+				return options, errors.Errorf("invalid %s: %s", key, v)
+			}
+			*value = d
+		}
+	}
+
+	ints := map[string]*int{
+		"min_baseline": &options.MinBaseline,
+		"min_cells":    &options.MinCells,
+	}
+
+	for key, value := range ints {
+		if v := params.Get(key); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return options, errors.Errorf("invalid %s: %s", key, v)
+			}
+			*value = n
+		}
+	}
+
+	floats := map[string]*float64{
+		"min_drop": &options.MinDrop,
+		"z":        &options.Z,
+	}
+
+	for key, value := range floats {
+		if v := params.Get(key); v != "" {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return options, errors.Errorf("invalid %s: %s", key, v)
+			}
+			*value = f
+		}
+	}
+
+	return options, nil
+}
+
+// AnomalyNotifiers returns the log notifier and the webhook notifier of
+// anomaly.webhook when it's set.
+func AnomalyNotifiers() []analytics.Notifier {
+	notifiers := []analytics.Notifier{analytics.LogNotifier{}}
+
+	if url := viper.GetString("anomaly.webhook"); url != "" {
+		notifiers = append(notifiers, analytics.NewWebhookNotifier(url))
+	}
+
+	return notifiers
+}
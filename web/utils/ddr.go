@@ -0,0 +1,181 @@
+// The MIT License (MIT)
+//
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package utils
+
+import (
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/bullettime/lora-mapper/model"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// ErrUnknownProfile is returned for a device profile missing in the config.
+var ErrUnknownProfile = errors.New("unknown ddr profile")
+
+// DDROptions returns the ddr options of the config file, of the device
+// profile when one is given, eg.
+//
+//	ddr:
+//	  policy: weighted-margin
+//	  margin: 5
+//	  halflife: 720h
+//	  profiles:
+//	    tracker:
+//	      policy: fastest-seen
+//	    alarm:
+//	      policy: conservative
+//	      margin: 10
+//
+// Settings missing in the profile come from the ddr section.
+func DDROptions(profile string) (model.DDROptions, error) {
+	options := model.DefaultDDROptions()
+
+	prefixes := []string{"ddr."}
+
+	if profile != "" {
+		prefix := "ddr.profiles." + profile + "."
+
+		if !viper.IsSet("ddr.profiles." + profile) {
+			return options, errors.Wrap(ErrUnknownProfile, profile)
+		}
+
+		prefixes = append(prefixes, prefix)
+	}
+
+	for _, prefix := range prefixes {
+		if viper.IsSet(prefix + "policy") {
+			options.Policy = viper.GetString(prefix + "policy")
+		}
+
+		if viper.IsSet(prefix + "margin") {
+			options.Margin = viper.GetFloat64(prefix + "margin")
+		}
+
+		if viper.IsSet(prefix + "percentile") {
+			options.Percentile = viper.GetFloat64(prefix + "percentile")
+		}
+
+		if viper.IsSet(prefix + "halflife") {
+			options.HalfLife = viper.GetDuration(prefix + "halflife")
+		}
+	}
+
+	if _, err := model.GetDDRPolicy(options.Policy); err != nil {
+		return options, err
+	}
+
+	return options, nil
+}
+
+// DDRRadius returns the radius (in meters) of the ddr samples, ddr.radius of
+// the config file or 100.
+func DDRRadius() float64 {
+	radius := viper.GetFloat64("ddr.radius")
+
+	if radius <= 0 {
+		radius = 100.0
+	}
+
+	return radius
+}
+
+// DeviceProfile returns the ddr profile of the device in the devices map of
+// the ddr section, eg.
+//
+//	ddr:
+//	  devices:
+//	    tracker-001: tracker
+func DeviceProfile(deviceID string) string {
+	if deviceID == "" {
+		return ""
+	}
+
+	return viper.GetStringMapString("ddr.devices")[deviceID]
+}
+
+// ParseDDRProfile returns the profile parameter, or the profile of the
+// device_profile device. The device parameter only filters the receptions.
+func ParseDDRProfile(params url.Values) string {
+	if profile := params.Get("profile"); profile != "" {
+		return profile
+	}
+
+	return DeviceProfile(params.Get("device_profile"))
+}
+
+// DDRProfiles returns the names of the profiles in the config file.
+func DDRProfiles() []string {
+	var profiles []string
+
+	for name := range viper.GetStringMap("ddr.profiles") {
+		profiles = append(profiles, name)
+	}
+
+	sort.Strings(profiles)
+
+	return profiles
+}
+
+// ParseDDROptions reads the ddr options from the request parameters (profile,
+// policy, margin, percentile and half_life) and falls back on the config file.
+// Without profile the profile of the device_profile device is used.
+func ParseDDROptions(params url.Values) (model.DDROptions, error) {
+	options, err := DDROptions(ParseDDRProfile(params))
+	if err != nil {
+		return options, err
+	}
+
+	if policy := params.Get("policy"); policy != "" {
+		if _, err := model.GetDDRPolicy(policy); err != nil {
+			return options, err
+		}
+		options.Policy = policy
+	}
+
+	if m := params.Get("margin"); m != "" {
+		options.Margin, err = strconv.ParseFloat(m, 64)
+		if err != nil {
+			return options, errors.Wrap(err, "invalid margin")
+		}
+	}
+
+	if p := params.Get("percentile"); p != "" {
+		options.Percentile, err = strconv.ParseFloat(p, 64)
+		if err != nil || options.Percentile < 0 || options.Percentile > 1 {
+			return options, errors.Errorf("invalid percentile: %s", p)
+		}
+	}
+
+	if h := params.Get("half_life"); h != "" {
+		options.HalfLife, err = time.ParseDuration(h)
+		if err != nil {
+			return options, errors.Wrap(err, "invalid half_life")
+		}
+	}
+
+	return options, nil
+}
@@ -0,0 +1,82 @@
+// The MIT License (MIT)
+//
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package utils
+
+import (
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/bullettime/lora-mapper/analytics"
+	"github.com/pkg/errors"
+)
+
+// ParseDiffWindow reads a window of a diff from the parameters with the
+// prefix (before or after): prefix_from and prefix_to in RFC3339 format and
+// prefix_campaign.
+func ParseDiffWindow(params url.Values, prefix string) (analytics.DiffWindow, error) {
+	window := analytics.DiffWindow{
+		Campaigns: list(params, prefix+"_campaign"),
+	}
+
+	var err error
+
+	if from := params.Get(prefix + "_from"); from != "" {
+		window.Start, err = time.Parse(time.RFC3339, from)
+		if err != nil {
+			return window, errors.Wrapf(err, "invalid %s_from time", prefix)
+		}
+	}
+
+	if to := params.Get(prefix + "_to"); to != "" {
+		window.End, err = time.Parse(time.RFC3339, to)
+		if err != nil {
+			return window, errors.Wrapf(err, "invalid %s_to time", prefix)
+		}
+	}
+
+	return window, errors.Wrap(window.Validate(), prefix)
+}
+
+// ParseDiffOptions reads the min_count and threshold (in dB) of a diff.
+func ParseDiffOptions(params url.Values) (analytics.DiffOptions, error) {
+	options := analytics.DefaultDiffOptions()
+
+	if v := params.Get("min_count"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return options, errors.Wrap(err, "invalid min_count")
+		}
+		options.MinCount = n
+	}
+
+	if v := params.Get("threshold"); v != "" {
+		threshold, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return options, errors.Wrap(err, "invalid threshold")
+		}
+		options.Threshold = threshold
+	}
+
+	return options, nil
+}
@@ -0,0 +1,184 @@
+// The MIT License (MIT)
+//
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package utils
+
+import (
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/bullettime/lora-mapper/model"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+var (
+	// energy settings of the config file and the request parameters
+	energySettings   = []string{"region", "payloadsize", "interval", "battery", "txcurrent", "sleepcurrent", "voltage"}
+	energyParameters = []string{"region", "payload_size", "interval", "battery", "tx_current", "sleep_current", "voltage"}
+)
+
+// EnergyProfile returns the energy settings of the config file, of the device
+// profile when one is given, eg.
+//
+//	ddr:
+//	  region: EU868
+//	  payloadsize: 7
+//	  interval: 15m
+//	  profiles:
+//	    tracker:
+//	      interval: 5m
+//	      battery: 1000
+//	      txcurrent: 40
+//	      sleepcurrent: 2
+//	      voltage: 3
+//
+// The battery capacity is in mAh, the tx current in mA and the sleep current
+// in µA. Settings missing in the profile come from the ddr section.
+func EnergyProfile(profile string) (model.EnergyProfile, error) {
+	energy := model.DefaultEnergyProfile()
+
+	prefixes := []string{"ddr."}
+
+	if profile != "" {
+		if !viper.IsSet("ddr.profiles." + profile) {
+			return energy, errors.Wrap(ErrUnknownProfile, profile)
+		}
+
+		prefixes = append(prefixes, "ddr.profiles."+profile+".")
+	}
+
+	for _, prefix := range prefixes {
+		if viper.IsSet(prefix + "region") {
+			energy.Region = viper.GetString(prefix + "region")
+		}
+
+		if viper.IsSet(prefix + "payloadsize") {
+			energy.PayloadSize = viper.GetInt(prefix + "payloadsize")
+		}
+
+		if viper.IsSet(prefix + "interval") {
+			energy.Interval = viper.GetDuration(prefix + "interval")
+		}
+
+		if viper.IsSet(prefix + "battery") {
+			energy.Battery = viper.GetFloat64(prefix + "battery")
+		}
+
+		if viper.IsSet(prefix + "txcurrent") {
+			energy.TxCurrent = viper.GetFloat64(prefix + "txcurrent")
+		}
+
+		if viper.IsSet(prefix + "sleepcurrent") {
+			energy.SleepCurrent = viper.GetFloat64(prefix + "sleepcurrent")
+		}
+
+		if viper.IsSet(prefix + "voltage") {
+			energy.Voltage = viper.GetFloat64(prefix + "voltage")
+		}
+	}
+
+	return energy, energy.Validate()
+}
+
+// HasEnergySettings returns true when the ddr section or the device profile
+// of the config file has energy settings.
+func HasEnergySettings(profile string) bool {
+	prefixes := []string{"ddr."}
+
+	if profile != "" {
+		prefixes = append(prefixes, "ddr.profiles."+profile+".")
+	}
+
+	for _, prefix := range prefixes {
+		for _, key := range energySettings {
+			if viper.IsSet(prefix + key) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// EnergyRequested returns true when the request asks for the energy estimate
+// (with energy=true or any energy setting), or when the profile of the
+// request has energy settings in the config file.
+func EnergyRequested(params url.Values) bool {
+	if v := params.Get("energy"); v != "" {
+		requested, _ := strconv.ParseBool(v)
+		return requested
+	}
+
+	for _, key := range energyParameters {
+		if params.Get(key) != "" {
+			return true
+		}
+	}
+
+	return HasEnergySettings(ParseDDRProfile(params))
+}
+
+// ParseEnergyProfile reads the energy settings from the request parameters
+// (region, payload_size, interval, battery, tx_current, sleep_current and
+// voltage) and falls back on the config file of the profile of the request.
+func ParseEnergyProfile(params url.Values) (model.EnergyProfile, error) {
+	energy, err := EnergyProfile(ParseDDRProfile(params))
+	if err != nil {
+		return energy, err
+	}
+
+	if region := params.Get("region"); region != "" {
+		energy.Region = region
+	}
+
+	if s := params.Get("payload_size"); s != "" {
+		energy.PayloadSize, err = strconv.Atoi(s)
+		if err != nil {
+			return energy, errors.Wrap(err, "invalid payload_size")
+		}
+	}
+
+	if i := params.Get("interval"); i != "" {
+		energy.Interval, err = time.ParseDuration(i)
+		if err != nil {
+			return energy, errors.Wrap(err, "invalid interval")
+		}
+	}
+
+	for key, value := range map[string]*float64{
+		"battery":       &energy.Battery,
+		"tx_current":    &energy.TxCurrent,
+		"sleep_current": &energy.SleepCurrent,
+		"voltage":       &energy.Voltage,
+	} {
+		if v := params.Get(key); v != "" {
+			*value, err = strconv.ParseFloat(v, 64)
+			if err != nil {
+				return energy, errors.Wrapf(err, "invalid %s", key)
+			}
+		}
+	}
+
+	return energy, energy.Validate()
+}
@@ -0,0 +1,100 @@
+// The MIT License (MIT)
+//
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package utils
+
+import (
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/bullettime/lora-mapper/model"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// ParseFilter reads the filter from the request parameters. Parameters that
+// take a list accept both repeated keys and comma separated values, eg.
+// ?campaign=a&campaign=b or ?campaign=a,b.
+func ParseFilter(params url.Values) (model.Filter, error) {
+	var err error
+
+	filter := model.Filter{
+		DeviceIDs:  list(params, "device"),
+		GatewayIDs: list(params, "gateway"),
+		Campaigns:  list(params, "campaign"),
+		DataRates:  list(params, "data_rate"),
+	}
+
+	if from := params.Get("from"); from != "" {
+		filter.Start, err = time.Parse(time.RFC3339, from)
+		if err != nil {
+			return filter, errors.Wrap(err, "invalid from time")
+		}
+	}
+
+	if to := params.Get("to"); to != "" {
+		filter.End, err = time.Parse(time.RFC3339, to)
+		if err != nil {
+			return filter, errors.Wrap(err, "invalid to time")
+		}
+	}
+
+	if bbox := params.Get("bbox"); bbox != "" {
+		b, err := model.ParseBoundingBox(bbox)
+		if err != nil {
+			return filter, err
+		}
+		filter.BoundingBox = &b
+	}
+
+	zone := params.Get("tz")
+	if zone == "" {
+		zone = viper.GetString("analytics.timezone")
+	}
+
+	filter.Window, err = model.ParseTimeWindow(params.Get("hours"), params.Get("weekdays"), params.Get("months"), zone)
+	if err != nil {
+		return filter, err
+	}
+
+	return filter, nil
+}
+
+// ParseList returns the comma separated values of every key parameter.
+func ParseList(params url.Values, key string) []string {
+	return list(params, key)
+}
+
+func list(params url.Values, key string) []string {
+	var result []string
+
+	for _, value := range params[key] {
+		for _, v := range strings.Split(value, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				result = append(result, v)
+			}
+		}
+	}
+
+	return result
+}
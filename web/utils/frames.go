@@ -0,0 +1,71 @@
+// The MIT License (MIT)
+//
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package utils
+
+import (
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/bullettime/lora-mapper/analytics"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// ParseFrameOptions reads the frame loss options from the request parameters
+// (max_gap in frames and max_gap_duration, eg. 30m) and falls back on the
+// analytics.frames settings of the config file:
+//
+//	analytics:
+//	  frames:
+//	    maxgap: 64
+//	    maxgapduration: 1h
+func ParseFrameOptions(params url.Values) (analytics.FrameOptions, error) {
+	options := analytics.DefaultFrameOptions()
+
+	if viper.IsSet("analytics.frames.maxgap") {
+		options.MaxGap = uint32(viper.GetInt("analytics.frames.maxgap"))
+	}
+
+	if viper.IsSet("analytics.frames.maxgapduration") {
+		options.MaxGapDuration = viper.GetDuration("analytics.frames.maxgapduration")
+	}
+
+	if v := params.Get("max_gap"); v != "" {
+		gap, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			return options, errors.Wrap(err, "invalid max_gap")
+		}
+		options.MaxGap = uint32(gap)
+	}
+
+	if v := params.Get("max_gap_duration"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return options, errors.Wrap(err, "invalid max_gap_duration")
+		}
+		options.MaxGapDuration = d
+	}
+
+	return options, nil
+}
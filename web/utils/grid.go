@@ -0,0 +1,81 @@
+// The MIT License (MIT)
+//
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package utils
+
+import (
+	"net/url"
+	"strconv"
+
+	"github.com/bullettime/lora-mapper/model"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// ParseGridOptions reads the grid options from the request parameters (shape,
+// size and origin) and falls back on the grid settings of the config file.
+func ParseGridOptions(params url.Values) (model.GridOptions, error) {
+	options := model.GridOptions{
+		Shape: viper.GetString("grid.shape"),
+		Size:  viper.GetFloat64("grid.size"),
+	}
+
+	if options.Shape == "" {
+		options.Shape = model.ShapeSquare
+	}
+
+	if options.Size <= 0 {
+		options.Size = 100
+	}
+
+	origin := viper.GetString("grid.origin")
+
+	if shape := params.Get("shape"); shape != "" {
+		options.Shape = shape
+	}
+
+	if size := params.Get("size"); size != "" {
+		s, err := strconv.ParseFloat(size, 64)
+		if err != nil {
+			return options, errors.Wrap(err, "invalid size")
+		}
+		options.Size = s
+	}
+
+	if o := params.Get("origin"); o != "" {
+		origin = o
+	}
+
+	if origin != "" {
+		ll, err := model.ParseLatLon(origin)
+		if err != nil {
+			return options, err
+		}
+		options.Origin = &ll
+	}
+
+	if _, err := model.NewGrid(options.Shape, options.Size, model.LatLon{}); err != nil {
+		return options, err
+	}
+
+	return options, nil
+}
@@ -0,0 +1,57 @@
+// The MIT License (MIT)
+//
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package utils
+
+import (
+	"net/url"
+	"strconv"
+
+	"github.com/bullettime/lora-mapper/h3"
+	"github.com/bullettime/lora-mapper/model"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// ParseHexResolution reads the H3 resolution from the request parameters and
+// falls back on h3.resolution of the config file.
+func ParseHexResolution(params url.Values) (int, error) {
+	resolution := model.DefaultHexResolution
+
+	if viper.IsSet("h3.resolution") {
+		resolution = viper.GetInt("h3.resolution")
+	}
+
+	if r := params.Get("resolution"); r != "" {
+		i, err := strconv.Atoi(r)
+		if err != nil {
+			return 0, errors.Wrap(err, "invalid resolution")
+		}
+		resolution = i
+	}
+
+	if resolution < 0 || resolution > h3.MaxResolution {
+		return 0, h3.ErrResolution
+	}
+
+	return resolution, nil
+}
@@ -0,0 +1,120 @@
+// The MIT License (MIT)
+//
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package utils
+
+import (
+	"net/url"
+	"strconv"
+
+	"github.com/bullettime/lora-mapper/interpolation"
+	"github.com/bullettime/lora-mapper/model"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// ParseInterpolationOptions reads the interpolation options from the request
+// parameters (method, size, max_distance, power, neighbours and origin) and
+// falls back on the interpolation settings of the config file.
+func ParseInterpolationOptions(params url.Values) (interpolation.Options, error) {
+	var err error
+
+	options := interpolation.DefaultOptions()
+
+	if method := viper.GetString("interpolation.method"); method != "" {
+		options.Method = method
+	}
+
+	if size := viper.GetFloat64("interpolation.size"); size > 0 {
+		options.CellSize = size
+	}
+
+	if distance := viper.GetFloat64("interpolation.maxdistance"); distance > 0 {
+		options.MaxDistance = distance
+	}
+
+	if method := params.Get("method"); method != "" {
+		options.Method = method
+	}
+
+	floats := map[string]*float64{
+		"size":         &options.CellSize,
+		"max_distance": &options.MaxDistance,
+		"power":        &options.Power,
+	}
+
+	for key, value := range floats {
+		if v := params.Get(key); v != "" {
+			*value, err = strconv.ParseFloat(v, 64)
+			if err != nil {
+				return options, errors.Wrapf(err, "invalid %s", key)
+			}
+		}
+	}
+
+	if n := params.Get("neighbours"); n != "" {
+		options.Neighbours, err = strconv.Atoi(n)
+		if err != nil {
+			return options, errors.Wrap(err, "invalid neighbours")
+		}
+	}
+
+	if origin := params.Get("origin"); origin != "" {
+		ll, err := model.ParseLatLon(origin)
+		if err != nil {
+			return options, err
+		}
+		options.Origin = &ll
+	}
+
+	return options, nil
+}
+
+// ParseLevels reads the contour levels from the request parameters, either a
+// list of levels or a step (10 by default) between the levels.
+func ParseLevels(params url.Values, surface *interpolation.Surface) ([]float64, error) {
+	var levels []float64
+
+	for _, l := range list(params, "levels") {
+		v, err := strconv.ParseFloat(l, 64)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid level")
+		}
+		levels = append(levels, v)
+	}
+
+	if len(levels) > 0 {
+		return levels, nil
+	}
+
+	step := 10.0
+
+	if s := params.Get("step"); s != "" {
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil || v <= 0 {
+			return nil, errors.Errorf("invalid step: %s", s)
+		}
+		step = v
+	}
+
+	return surface.Levels(step), nil
+}
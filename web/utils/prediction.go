@@ -0,0 +1,93 @@
+// The MIT License (MIT)
+//
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package utils
+
+import (
+	"net/url"
+	"strconv"
+
+	"github.com/bullettime/lora-mapper/prediction"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// PredictionOptions returns the link budget of the prediction section of the
+// config file, the transmit power defaults to analytics.txpower.
+func PredictionOptions() prediction.Options {
+	options := prediction.DefaultOptions()
+
+	if viper.IsSet("analytics.txpower") {
+		options.TxPower = viper.GetFloat64("analytics.txpower")
+	}
+
+	floats := map[string]*float64{
+		"prediction.txpower":     &options.TxPower,
+		"prediction.devicegain":  &options.DeviceGain,
+		"prediction.noisefigure": &options.NoiseFigure,
+		"prediction.bandwidth":   &options.Bandwidth,
+		"prediction.frequency":   &options.Frequency,
+		"prediction.exponent":    &options.Exponent,
+		"prediction.sigma":       &options.Sigma,
+		"prediction.fademargin":  &options.FadeMargin,
+	}
+
+	for key, value := range floats {
+		if viper.IsSet(key) {
+			*value = viper.GetFloat64(key)
+		}
+	}
+
+	return options
+}
+
+// ParsePredictionOptions reads the link budget from the request parameters
+// (tx_power, device_gain, exponent, sigma and fade_margin) and falls back on
+// the config file.
+func ParsePredictionOptions(params url.Values) (prediction.Options, error) {
+	var err error
+
+	options := PredictionOptions()
+
+	floats := map[string]*float64{
+		"tx_power":    &options.TxPower,
+		"device_gain": &options.DeviceGain,
+		"exponent":    &options.Exponent,
+		"sigma":       &options.Sigma,
+		"fade_margin": &options.FadeMargin,
+	}
+
+	for key, value := range floats {
+		if v := params.Get(key); v != "" {
+			*value, err = strconv.ParseFloat(v, 64)
+			if err != nil {
+				return options, errors.Wrapf(err, "invalid %s", key)
+			}
+		}
+	}
+
+	if options.Exponent <= 0 {
+		return options, errors.Errorf("invalid exponent: %v", options.Exponent)
+	}
+
+	return options, nil
+}
@@ -0,0 +1,62 @@
+// The MIT License (MIT)
+//
+// Copyright © 2018 Sven Agneessens <sven.agneessens@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package utils
+
+import (
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/bullettime/lora-mapper/analytics"
+	"github.com/pkg/errors"
+)
+
+// ParseTemporalOptions reads the bucket (hour, weekday or month), min_count
+// and threshold (in dB) of the temporal analysis, in the time zone of the
+// filter.
+func ParseTemporalOptions(params url.Values, zone *time.Location) (analytics.TemporalOptions, error) {
+	options := analytics.DefaultTemporalOptions()
+	options.TimeZone = zone
+
+	if b := params.Get("bucket"); b != "" {
+		options.Bucket = b
+	}
+
+	if v := params.Get("min_count"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return options, errors.Wrap(err, "invalid min_count")
+		}
+		options.MinCount = n
+	}
+
+	if v := params.Get("threshold"); v != "" {
+		threshold, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return options, errors.Wrap(err, "invalid threshold")
+		}
+		options.Threshold = threshold
+	}
+
+	return options, options.Validate()
+}